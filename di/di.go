@@ -1,8 +1,11 @@
 package di
 
 import (
+	"os"
+
 	"github.com/denkhaus/agents/logger"
 	"github.com/denkhaus/agents/provider/agent"
+	"github.com/denkhaus/agents/provider/config"
 
 	"github.com/denkhaus/agents/provider/prompt"
 	"github.com/denkhaus/agents/provider/settings"
@@ -11,14 +14,32 @@ import (
 	"github.com/samber/do"
 )
 
+// SettingsSourceEnv selects the SettingsProvider registered by NewContainer
+const SettingsSourceEnv = "AGENTS_SETTINGS_SOURCE"
+
+// Settings sources selectable with AGENTS_SETTINGS_SOURCE
+const (
+	// SettingsSourceYAML serves the embedded YAML settings and Markdown prompt templates
+	SettingsSourceYAML = "yaml"
+	// SettingsSourceCUE serves the CUE compositions, see config.NewSettingsProvider
+	SettingsSourceCUE = "cue"
+)
+
 func NewContainer() *do.Injector {
 	injector := do.New()
 
 	do.Provide(injector, workspace.New)
 	do.Provide(injector, prompt.New)
+	do.Provide(injector, prompt.NewContextProvider)
 	do.Provide(injector, agent.New)
-	do.Provide(injector, settings.New)
 	do.Provide(injector, logger.New)
 
+	switch os.Getenv(SettingsSourceEnv) {
+	case SettingsSourceCUE:
+		do.Provide(injector, config.NewSettingsProvider)
+	default:
+		do.Provide(injector, settings.New)
+	}
+
 	return injector
 }
@@ -17,13 +17,17 @@ func NewMessageBroker() MessageBroker {
 	}
 }
 
-// RegisterAgent registers an agent with a predefined ID
+// RegisterAgent registers an agent with a predefined ID.
+// Registering an ID again replaces the agent but keeps its message channel,
+// so pending messages are delivered to the replacement.
 func (mb *messageBrokerImpl) RegisterAgent(agentID uuid.UUID, agent agent.Agent) {
 	mb.mu.Lock()
 	defer mb.mu.Unlock()
 
 	mb.agents.Set(agentID, agent)
-	mb.channels.Set(agentID, make(chan *Message, 100)) // Buffered channel
+	if !mb.channels.Exists(agentID) {
+		mb.channels.Set(agentID, make(chan *Message, 100)) // Buffered channel
+	}
 }
 
 // UnregisterAgent removes an agent from the broker
@@ -77,3 +77,35 @@ func TestResourceManagerIntegration(t *testing.T) {
 		t.Errorf("Expected remaining agent to be uuid2, got %s", agentIDs[0])
 	}
 }
+
+func TestReRegisterAgentKeepsMessageChannel(t *testing.T) {
+	broker := NewMessageBroker()
+
+	agentID := uuid.New()
+	broker.RegisterAgent(agentID, &mockAgent{name: "Agent", id: agentID})
+
+	before, err := broker.GetMessageChannel(agentID)
+	if err != nil {
+		t.Fatalf("Expected message channel, got error: %v", err)
+	}
+
+	if err := broker.SendMessage(uuid.New(), agentID, "pending"); err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+
+	// Replace the agent, e.g. after a configuration reload
+	broker.RegisterAgent(agentID, &mockAgent{name: "Agent", id: agentID})
+
+	after, err := broker.GetMessageChannel(agentID)
+	if err != nil {
+		t.Fatalf("Expected message channel, got error: %v", err)
+	}
+	if before != after {
+		t.Errorf("Expected re-registration to keep the message channel")
+	}
+
+	msg := <-after
+	if msg.Content != "pending" {
+		t.Errorf("Expected pending message, got %q", msg.Content)
+	}
+}
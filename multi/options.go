@@ -1,6 +1,7 @@
 package multi
 
 import (
+	"github.com/denkhaus/agents/provider"
 	"github.com/denkhaus/agents/shared"
 	"github.com/google/uuid"
 	"trpc.group/trpc-go/trpc-agent-go/model"
@@ -17,6 +18,8 @@ const (
 	SystemMessageSending
 	SystemMessageDelivered
 	SystemMessageProcessed
+	SystemMessageModelFallback
+	SystemMessageAgentRouted
 )
 
 // OnError is a callback function type for handling errors from agents.
@@ -40,6 +43,7 @@ type Options struct {
 	availableAgents    []shared.TheAgent
 	applicationName    string
 	sessionService     session.Service
+	workspaceProvider  provider.WorkspaceProvider
 	onToolCall         OnToolCall
 	onMessage          OnMessage
 	onReasoningMessage OnReasoningMessage
@@ -71,6 +75,14 @@ func WithSessionService(service session.Service) ChatProcessorOption {
 	}
 }
 
+// WithWorkspaceProvider sets the provider of the agent workspaces, EndSession
+// releases the scratch directories of the session from it.
+func WithWorkspaceProvider(workspaceProvider provider.WorkspaceProvider) ChatProcessorOption {
+	return func(opts *Options) {
+		opts.workspaceProvider = workspaceProvider
+	}
+}
+
 // WithAgents sets the AI agents for the ChatProcessor.
 func WithAgents(agents ...shared.TheAgent) ChatProcessorOption {
 	return func(opts *Options) {
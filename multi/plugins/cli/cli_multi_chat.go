@@ -11,11 +11,14 @@ import (
 
 	markdown "github.com/MichaelMure/go-term-markdown"
 	"github.com/acarl005/stripansi"
+	"github.com/denkhaus/agents/logger"
 	"github.com/denkhaus/agents/multi"
 	"github.com/denkhaus/agents/multi/plugins"
+	"github.com/denkhaus/agents/provider"
 	"github.com/denkhaus/agents/shared"
 	"github.com/google/uuid"
 	"github.com/mattn/go-runewidth"
+	"go.uber.org/zap"
 	"trpc.group/trpc-go/trpc-agent-go/model"
 )
 
@@ -125,6 +128,13 @@ func (p *cliMultiAgentChatImpl) handleOnReasoningMessage(info *shared.AgentInfo,
 // Start runs the interactive chat loop, handling user input and agent communication.
 // It supports commands like /exit, /list, /agent-name to select agents, and direct messaging.
 func (p *cliMultiAgentChatImpl) Start(ctx context.Context) error {
+	// The session ends with the chat
+	defer func() {
+		if err := p.Processor.EndSession(); err != nil {
+			logger.Log.Warn("failed to end chat session", zap.Error(err))
+		}
+	}()
+
 	// Show welcome message with all available commands
 	p.showWelcomeMessage()
 
@@ -171,6 +181,8 @@ func (p *cliMultiAgentChatImpl) Start(ctx context.Context) error {
 				p.printSystemMessage("Current agent cleared. Use /<agent-name> to select an agent.")
 			case "help":
 				p.printSystemText(p.getHelpMessage())
+			case "diff", "merge", "discard":
+				p.handleWorkspaceCommand(ctx, command)
 			default:
 				// Check if it's a width command
 				if strings.HasPrefix(command, "width ") {
@@ -214,6 +226,53 @@ func (p *cliMultiAgentChatImpl) Start(ctx context.Context) error {
 	return nil
 }
 
+// handleWorkspaceCommand shows, merges or discards the changes the current
+// agent made in its isolated workspace.
+func (p *cliMultiAgentChatImpl) handleWorkspaceCommand(ctx context.Context, command string) {
+	if p.currentAgent == nil {
+		p.printSystemMessage("No agent selected. Use /<agent-name> to select an agent.")
+		return
+	}
+	if p.Workspaces == nil {
+		p.printSystemMessage("The chat has no workspace provider.")
+		return
+	}
+
+	workspace, err := p.Workspaces.GetWorkspace(p.currentAgent.ID())
+	if err != nil {
+		p.printWithBorderColored("SYSTEM", fmt.Sprintf("%+v", err), plugins.MessageTypeError)
+		return
+	}
+	isolated, ok := workspace.(provider.IsolatedWorkspace)
+	if !ok {
+		p.printSystemMessage("%s works in its workspace directly, there are no changes to %s.", p.currentAgent.Name, command)
+		return
+	}
+
+	switch command {
+	case "diff":
+		var diff string
+		if diff, err = isolated.Diff(ctx); err == nil {
+			if diff == "" {
+				diff = "No changes."
+			}
+			p.printSystemText(diff)
+		}
+	case "merge":
+		if err = isolated.Merge(ctx); err == nil {
+			p.printSystemMessage("Merged the changes of %s into %s.", p.currentAgent.Name, isolated.GetBasePath())
+		}
+	case "discard":
+		if err = isolated.Discard(ctx); err == nil {
+			p.printSystemMessage("Discarded the changes of %s.", p.currentAgent.Name)
+		}
+	}
+
+	if err != nil {
+		p.printWithBorderColored("SYSTEM", fmt.Sprintf("%+v", err), plugins.MessageTypeError)
+	}
+}
+
 // printSystemMessage displays a system message with a standard border.
 func (p *cliMultiAgentChatImpl) printSystemMessage(format string, a ...any) {
 	message := fmt.Sprintf(format, a...)
@@ -242,6 +301,9 @@ func (p *cliMultiAgentChatImpl) getHelpMessage() string {
 	builder.WriteString("/clear                - Clear current agent selection\n")
 	builder.WriteString(fmt.Sprintf("/width <number>       - Set display width (min: 40, current: %d)\n", p.DisplayWidth))
 	builder.WriteString("/<agent-name>         - Select an agent to chat with\n")
+	builder.WriteString("/diff                 - Show the changes of the current agent in its isolated workspace\n")
+	builder.WriteString("/merge                - Merge the changes of the current agent into its base workspace\n")
+	builder.WriteString("/discard              - Discard the changes of the current agent\n")
 	builder.WriteString("/exit                 - Exit the chat\n")
 	builder.WriteString("\n")
 	builder.WriteString("=== Usage ===\n")
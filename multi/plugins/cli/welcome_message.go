@@ -28,6 +28,7 @@ func GetWelcomeMessage(agents []shared.AgentInfo, displayWidth int) string {
 		"- `/clear` - Clear current agent selection\n" +
 		"- `/width <number>` - Set display width (min: 40, current: %d)\n" +
 		"- `/<agent-name>` - Select an agent to chat with\n" +
+		"- `/diff`, `/merge`, `/discard` - Review the changes of an isolated agent\n" +
 		"- `/exit` - Exit the chat\n\n" +
 		"## Quick Start\n\n" +
 		"1. Select an agent: `/project-manager`\n" +
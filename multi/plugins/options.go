@@ -2,6 +2,7 @@ package plugins
 
 import (
 	"github.com/denkhaus/agents/multi"
+	"github.com/denkhaus/agents/provider"
 )
 
 // Options contains configuration for multi-agent chat plugins.
@@ -9,6 +10,8 @@ type Options struct {
 	Processor        multi.ChatProcessor
 	ProcessorOptions []multi.ChatProcessorOption
 	DisplayWidth     int // Width for chat display borders (default: 120)
+	// Workspaces resolves the isolated workspaces the diff, merge and discard commands work on
+	Workspaces provider.WorkspaceProvider
 }
 
 // MultiAgentChatOption is a function type for configuring multi-agent chat options.
@@ -32,3 +35,11 @@ func WithDisplayWidth(width int) MultiAgentChatOption {
 		opts.DisplayWidth = width
 	}
 }
+
+// WithWorkspaceProvider sets the provider of the agent workspaces, the chat
+// can then review, merge and discard the changes of isolated agents.
+func WithWorkspaceProvider(workspaces provider.WorkspaceProvider) MultiAgentChatOption {
+	return func(opts *Options) {
+		opts.Workspaces = workspaces
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
 
 	"github.com/denkhaus/agents/logger"
 	"github.com/denkhaus/agents/messaging"
@@ -42,12 +43,26 @@ type ChatProcessor interface {
 	// GetAgentNameByID returns the name of an agent given its UUID.
 	// Returns empty string if no agent is found with the given ID.
 	GetAgentNameByID(agentID uuid.UUID) string
+
+	// GetAgentDescriptor returns the descriptor of an agent given its UUID, with role,
+	// schemas and capabilities. Returns false if no agent is found with the given ID.
+	GetAgentDescriptor(agentID uuid.UUID) (shared.AgentDescriptor, bool)
+
+	// ReplaceAgents atomically swaps in the given agents, matched by ID. Agents with
+	// an unknown ID are added. Invocations already running on a replaced agent
+	// complete on it, afterwards the replaced agent is closed.
+	ReplaceAgents(agents ...shared.TheAgent) error
+
+	// EndSession ends the chat session and releases what the agents keep for
+	// it, like the scratch directories of their workspaces.
+	EndSession() error
 }
 
 // chatProcessorImpl implements the ChatProcessor interface and manages
 // the lifecycle and communication between multiple agents.
 type chatProcessorImpl struct {
 	Options
+	mu     sync.RWMutex
 	agents map[uuid.UUID]*AgentRunner
 	broker messaging.MessageBroker
 }
@@ -111,22 +126,131 @@ func (p *chatProcessorImpl) initAgents() {
 			continue
 		}
 
-		wrapper := messaging.NewMessagingWrapper(agent, p.broker)
+		ar := p.newAgentRunner(agent, p.sessionID)
+		p.agents[agent.ID()] = ar
+		p.startMessageProcessing(ar.ID())
+	}
+}
 
-		ar := &AgentRunner{
-			wrapper: wrapper,
-			runner: runner.NewRunner(
-				p.applicationName,
-				wrapper,
-				runner.WithSessionService(
-					p.sessionService,
-				),
+// newAgentRunner wraps an agent for messaging and creates its runner.
+func (p *chatProcessorImpl) newAgentRunner(agent shared.TheAgent, sessionID uuid.UUID) *AgentRunner {
+	wrapper := messaging.NewMessagingWrapper(agent, p.broker)
+
+	return &AgentRunner{
+		sessionID: sessionID,
+		agent:     agent,
+		wrapper:   wrapper,
+		runner: runner.NewRunner(
+			p.applicationName,
+			wrapper,
+			runner.WithSessionService(
+				p.sessionService,
 			),
+		),
+	}
+}
+
+// ReplaceAgents atomically swaps in the given agents, matched by ID.
+// Replaced agents are closed once their in-flight invocations have completed.
+func (p *chatProcessorImpl) ReplaceAgents(agents ...shared.TheAgent) error {
+	for _, agent := range agents {
+		if agent == nil {
+			return fmt.Errorf("cannot replace agent with nil")
 		}
+	}
 
-		p.agents[agent.ID()] = ar
-		p.startMessageProcessing(ar)
+	p.mu.Lock()
+	var added []uuid.UUID
+	var replaced []*AgentRunner
+	for _, agent := range agents {
+		sessionID := p.sessionID
+		if old, exists := p.agents[agent.ID()]; exists {
+			sessionID = old.sessionID
+			old.markRetired()
+			replaced = append(replaced, old)
+		} else {
+			added = append(added, agent.ID())
+		}
+
+		// The broker keeps the message channel of a re-registered agent,
+		// so the running message processing picks up the new runner.
+		p.agents[agent.ID()] = p.newAgentRunner(agent, sessionID)
+	}
+	p.mu.Unlock()
+
+	for _, agentID := range added {
+		p.startMessageProcessing(agentID)
+	}
+
+	for _, old := range replaced {
+		go p.retire(old)
+	}
+
+	return nil
+}
+
+// retire waits for the in-flight invocations of a replaced agent and closes it.
+func (p *chatProcessorImpl) retire(old *AgentRunner) {
+	old.Wait()
+
+	if err := shared.CloseAgent(old.agent); err != nil {
+		logger.Log.Warn("failed to close replaced agent",
+			zap.String("app_name", p.applicationName),
+			zap.String("agent", old.Name()),
+			zap.Error(err),
+		)
+	}
+}
+
+// EndSession releases the scratch directories of the session, if the processor has a workspace provider.
+func (p *chatProcessorImpl) EndSession() error {
+	if p.workspaceProvider == nil {
+		return nil
+	}
+
+	if err := p.workspaceProvider.ReleaseSession(p.sessionID.String()); err != nil {
+		return fmt.Errorf("failed to release session %s: %w", p.sessionID, err)
 	}
+	return nil
+}
+
+// getAgent returns the current runner of an agent.
+func (p *chatProcessorImpl) getAgent(agentID uuid.UUID) (*AgentRunner, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	agent, exists := p.agents[agentID]
+	return agent, exists
+}
+
+// runAgent runs the current runner of an agent. A runner retired between the
+// lookup and the start of the invocation is replaced, so the lookup is repeated.
+func (p *chatProcessorImpl) runAgent(ctx context.Context, fromAgentID, toAgentID uuid.UUID, message model.Message) (<-chan *event.Event, error) {
+	for {
+		agent, exists := p.getAgent(toAgentID)
+		if !exists {
+			return nil, fmt.Errorf("agent %q not found", toAgentID)
+		}
+
+		events, err := agent.Run(ctx, fromAgentID, message)
+		if errors.Is(err, ErrRunnerRetired) {
+			continue
+		}
+		return events, err
+	}
+}
+
+// listAgents returns a snapshot of all current runners.
+func (p *chatProcessorImpl) listAgents() []*AgentRunner {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	agents := make([]*AgentRunner, 0, len(p.agents))
+	for _, agent := range p.agents {
+		agents = append(agents, agent)
+	}
+
+	return agents
 }
 
 // SetMessageInterceptor sets a message interceptor on the underlying message broker.
@@ -138,7 +262,7 @@ func (p *chatProcessorImpl) SetMessageInterceptor(interceptor messaging.Intercep
 // GetAllAgentInfos returns a slice containing information about all registered agents.
 func (p *chatProcessorImpl) GetAllAgentInfos() []shared.AgentInfo {
 	var infos []shared.AgentInfo
-	for _, agent := range p.agents {
+	for _, agent := range p.listAgents() {
 		infos = append(infos, *agent.Info())
 	}
 
@@ -148,7 +272,7 @@ func (p *chatProcessorImpl) GetAllAgentInfos() []shared.AgentInfo {
 // GetAgentInfoByID retrieves agent information by UUID.
 // Returns nil if no agent is found with the given ID.
 func (p *chatProcessorImpl) GetAgentInfoByID(agentID uuid.UUID) *shared.AgentInfo {
-	if v, ok := p.agents[agentID]; ok {
+	if v, ok := p.getAgent(agentID); ok {
 		return v.Info()
 	}
 
@@ -167,7 +291,7 @@ func (p *chatProcessorImpl) GetAgentInfoByAuthor(author string) *shared.AgentInf
 	}
 
 	// If not UUID or not found, check if it's already a name
-	for _, agent := range p.agents {
+	for _, agent := range p.listAgents() {
 		if agent.Name() == author {
 			return agent.Info()
 		}
@@ -178,24 +302,31 @@ func (p *chatProcessorImpl) GetAgentInfoByAuthor(author string) *shared.AgentInf
 
 // GetAgentNameByID returns the agent name for a given AgentID
 func (p *chatProcessorImpl) GetAgentNameByID(agentID uuid.UUID) string {
-	// Check all agents
-	for _, agent := range p.agents {
-		if agent.ID() == agentID {
-			return agent.Name()
-		}
+	if agent, ok := p.getAgent(agentID); ok {
+		return agent.Name()
 	}
 
 	return ""
 }
 
+// GetAgentDescriptor returns the descriptor of the agent with the given AgentID
+func (p *chatProcessorImpl) GetAgentDescriptor(agentID uuid.UUID) (shared.AgentDescriptor, bool) {
+	if agent, ok := p.getAgent(agentID); ok {
+		return agent.Descriptor(), true
+	}
+
+	return shared.AgentDescriptor{}, false
+}
+
 // startMessageProcessing starts a goroutine to process incoming messages for the given agent.
-// It listens on the agent's message channel and forwards messages to the agent's runner.
-func (p *chatProcessorImpl) startMessageProcessing(agent *AgentRunner) {
+// It listens on the agent's message channel and forwards each message to the agent's
+// current runner, so messages arriving after ReplaceAgents reach the replacement.
+func (p *chatProcessorImpl) startMessageProcessing(agentID uuid.UUID) {
 	go func() {
 		// Get the message channel for this agent
-		msgChan, err := p.broker.GetMessageChannel(agent.ID())
+		msgChan, err := p.broker.GetMessageChannel(agentID)
 		if err != nil {
-			logger.Log.Error("failed to get message channel for agent", zap.String("agent", agent.Name()), zap.Error(err))
+			logger.Log.Error("failed to get message channel for agent", zap.Any("agent_id", agentID), zap.Error(err))
 			return
 		}
 
@@ -207,10 +338,10 @@ func (p *chatProcessorImpl) startMessageProcessing(agent *AgentRunner) {
 			// Format the message content
 			messageContent := fmt.Sprintf("Message from %s: %s", p.GetAgentNameByID(msg.From), msg.Content)
 
-			// Send to the agent's runner
-			events, err := agent.Run(ctx, msg.From, model.NewUserMessage(messageContent))
+			// Send to the agent's current runner
+			events, err := p.runAgent(ctx, msg.From, agentID, model.NewUserMessage(messageContent))
 			if err != nil {
-				logger.Log.Error("failed to process message for agent", zap.String("agent", agent.Name()), zap.Error(err))
+				logger.Log.Error("failed to process message for agent", zap.Any("agent_id", agentID), zap.Error(err))
 				continue
 			}
 
@@ -227,19 +358,13 @@ func (p *chatProcessorImpl) startMessageProcessing(agent *AgentRunner) {
 // SendMessage sends a message from one agent to another and returns a channel of events.
 // The caller is responsible for processing the events from the returned channel.
 func (p *chatProcessorImpl) SendMessage(ctx context.Context, fromAgentID, toAgentID uuid.UUID, message string) (<-chan *event.Event, error) {
-	agent, exists := p.agents[toAgentID]
-	if !exists {
-		return nil, fmt.Errorf("agent %q not found", toAgentID)
-	}
-
-	userMessage := model.NewUserMessage(message)
-	return agent.Run(ctx, fromAgentID, userMessage)
+	return p.runAgent(ctx, fromAgentID, toAgentID, model.NewUserMessage(message))
 }
 
 // SendMessageWithProcessing sends a message to an agent and automatically processes all resulting events.
 // This method handles event processing internally and provides progress updates through callbacks.
 func (p *chatProcessorImpl) SendMessageWithProcessing(ctx context.Context, fromAgentID, toAgentID uuid.UUID, message string) error {
-	agent, exists := p.agents[toAgentID]
+	agent, exists := p.getAgent(toAgentID)
 	if !exists {
 		return fmt.Errorf("agent %q not found", toAgentID)
 	}
@@ -247,7 +372,7 @@ func (p *chatProcessorImpl) SendMessageWithProcessing(ctx context.Context, fromA
 	userMessage := model.NewUserMessage(message)
 	p.onProgress(SystemMessageSending, "sending message to %s...", agent)
 
-	events, err := agent.Run(ctx, fromAgentID, userMessage)
+	events, err := p.runAgent(ctx, fromAgentID, toAgentID, userMessage)
 	if err != nil {
 		return fmt.Errorf("failed to send message from %s to %s: %w", fromAgentID, toAgentID, err)
 	}
@@ -271,6 +396,23 @@ func (p *chatProcessorImpl) processEvent(event *event.Event) {
 		p.onError(info, errors.New(event.Error.Message))
 	}
 
+	// Report a failover to a fallback model of the agent
+	if shared.IsFallbackResponse(event.Response) {
+		p.onProgress(SystemMessageModelFallback, "%s switched to model %s", event.Author, event.Response.Model)
+		return
+	}
+
+	// Report the sub-agent a router delegates to, its events follow with their own author
+	if shared.IsRouteResponse(event.Response) {
+		p.onProgress(SystemMessageAgentRouted, "%s is %s", event.Author, event.Response.Choices[0].Delta.Content)
+		return
+	}
+
+	// The prompt version an agent runs on is recorded in the state delta, not shown
+	if shared.IsPromptVersionResponse(event.Response) {
+		return
+	}
+
 	if event.Response != nil && len(event.Response.Choices) > 0 {
 		choice := event.Response.Choices[0]
 
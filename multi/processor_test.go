@@ -0,0 +1,32 @@
+package multi
+
+import (
+	"testing"
+
+	"github.com/denkhaus/agents/provider"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// releasingWorkspaces records the released sessions
+type releasingWorkspaces struct {
+	provider.WorkspaceProvider
+	released []string
+}
+
+func (w *releasingWorkspaces) ReleaseSession(sessionID string) error {
+	w.released = append(w.released, sessionID)
+	return nil
+}
+
+func TestChatProcessor_EndSession(t *testing.T) {
+	sessionID := uuid.New()
+	workspaces := &releasingWorkspaces{}
+
+	processor := NewChatProcessor(WithSessionID(sessionID), WithWorkspaceProvider(workspaces))
+	assert.NoError(t, processor.EndSession())
+	assert.Equal(t, []string{sessionID.String()}, workspaces.released)
+
+	// Without a workspace provider there is nothing to release
+	assert.NoError(t, NewChatProcessor(WithSessionID(sessionID)).EndSession())
+}
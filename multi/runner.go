@@ -2,7 +2,9 @@ package multi
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
 
 	"github.com/denkhaus/agents/shared"
 	"github.com/google/uuid"
@@ -12,11 +14,19 @@ import (
 	"trpc.group/trpc-go/trpc-agent-go/runner"
 )
 
+// ErrRunnerRetired is returned by Run once the agent of the runner was replaced
+var ErrRunnerRetired = errors.New("agent runner is retired")
+
 // AgentRunner represents an AI agent with messaging capabilities
 type AgentRunner struct {
 	sessionID uuid.UUID
 	runner    runner.Runner
+	agent     shared.TheAgent
 	wrapper   shared.TheAgent
+	// mu orders the start of invocations before the retirement of the runner
+	mu       sync.Mutex
+	retired  bool
+	inflight sync.WaitGroup
 }
 
 // ID returns the unique identifier of the agent.
@@ -36,7 +46,12 @@ func (p *AgentRunner) SessionID() string {
 
 // Info returns the agent's information structure.
 func (p *AgentRunner) Info() *shared.AgentInfo {
-	return p.wrapper.GetInfo()
+	return shared.TheAgentToInfo(p.wrapper)
+}
+
+// Descriptor returns the descriptor the agent was built with.
+func (p *AgentRunner) Descriptor() shared.AgentDescriptor {
+	return p.wrapper.Descriptor()
 }
 
 // String returns a string representation of the agent runner.
@@ -46,13 +61,57 @@ func (p *AgentRunner) String() string {
 
 // Run executes the agent with a message from another agent and returns a channel of events.
 // The fromAgentID identifies the sender, userMessage contains the message content,
-// and runOpts provides additional configuration options.
+// and runOpts provides additional configuration options. It returns ErrRunnerRetired
+// once the agent was replaced. The invocation is drained if ctx is cancelled
+// before the caller read all events.
 func (p *AgentRunner) Run(
 	ctx context.Context,
 	fromAgentID uuid.UUID,
 	userMessage model.Message,
 	runOpts ...agent.RunOption,
 ) (<-chan *event.Event, error) {
+	p.mu.Lock()
+	if p.retired {
+		p.mu.Unlock()
+		return nil, ErrRunnerRetired
+	}
+	p.inflight.Add(1)
+	p.mu.Unlock()
+
+	events, err := p.runner.Run(ctx, fromAgentID.String(), p.SessionID(), userMessage, runOpts...)
+	if err != nil {
+		p.inflight.Done()
+		return nil, err
+	}
+
+	// Track the invocation until its event stream is closed
+	tracked := make(chan *event.Event, cap(events))
+	go func() {
+		defer p.inflight.Done()
+		defer close(tracked)
+		for evt := range events {
+			select {
+			case tracked <- evt:
+			case <-ctx.Done():
+				for range events {
+				}
+				return
+			}
+		}
+	}()
+
+	return tracked, nil
+}
+
+// markRetired makes Run reject further invocations, so Wait observes all of them.
+func (p *AgentRunner) markRetired() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.retired = true
+}
 
-	return p.runner.Run(ctx, fromAgentID.String(), p.SessionID(), userMessage, runOpts...)
+// Wait blocks until all invocations started with Run have completed.
+func (p *AgentRunner) Wait() {
+	p.inflight.Wait()
 }
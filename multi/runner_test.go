@@ -0,0 +1,69 @@
+package multi
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"trpc.group/trpc-go/trpc-agent-go/agent"
+	"trpc.group/trpc-go/trpc-agent-go/event"
+	"trpc.group/trpc-go/trpc-agent-go/model"
+)
+
+// streamingRunner answers every run with the given number of events
+type streamingRunner struct {
+	events int
+}
+
+func (r *streamingRunner) Run(ctx context.Context, userID, sessionID string, message model.Message, runOpts ...agent.RunOption) (<-chan *event.Event, error) {
+	events := make(chan *event.Event)
+	go func() {
+		defer close(events)
+		for i := 0; i < r.events; i++ {
+			events <- &event.Event{ID: uuid.NewString()}
+		}
+	}()
+	return events, nil
+}
+
+func TestAgentRunner_RejectsRunsOnceRetired(t *testing.T) {
+	runner := &AgentRunner{runner: &streamingRunner{events: 1}}
+
+	events, err := runner.Run(context.Background(), uuid.New(), model.NewUserMessage("hi"))
+	require.NoError(t, err)
+	for range events {
+	}
+
+	runner.markRetired()
+	runner.Wait()
+
+	_, err = runner.Run(context.Background(), uuid.New(), model.NewUserMessage("hi"))
+	assert.ErrorIs(t, err, ErrRunnerRetired)
+}
+
+func TestAgentRunner_CancelDrainsInvocation(t *testing.T) {
+	runner := &AgentRunner{runner: &streamingRunner{events: 100}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := runner.Run(ctx, uuid.New(), model.NewUserMessage("hi"))
+	require.NoError(t, err)
+
+	// The caller stops reading after the first event
+	<-events
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		runner.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("invocation is still in flight after cancel")
+	}
+}
@@ -12,11 +12,13 @@ func _() {
 	_ = x[SystemMessageSending-1]
 	_ = x[SystemMessageDelivered-2]
 	_ = x[SystemMessageProcessed-3]
+	_ = x[SystemMessageModelFallback-4]
+	_ = x[SystemMessageAgentRouted-5]
 }
 
-const _SystemMessageType_name = "SystemMessageDefaultSystemMessageSendingSystemMessageDeliveredSystemMessageProcessed"
+const _SystemMessageType_name = "SystemMessageDefaultSystemMessageSendingSystemMessageDeliveredSystemMessageProcessedSystemMessageModelFallbackSystemMessageAgentRouted"
 
-var _SystemMessageType_index = [...]uint8{0, 20, 40, 62, 84}
+var _SystemMessageType_index = [...]uint8{0, 20, 40, 62, 84, 110, 134}
 
 func (i SystemMessageType) String() string {
 	if i < 0 || i >= SystemMessageType(len(_SystemMessageType_index)-1) {
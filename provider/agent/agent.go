@@ -4,12 +4,15 @@ package agent
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
+	"github.com/denkhaus/agents/logger"
 	"github.com/denkhaus/agents/provider"
 	"github.com/denkhaus/agents/shared"
 	"github.com/google/uuid"
 	"github.com/samber/do"
+	"go.uber.org/zap"
 	"trpc.group/trpc-go/trpc-agent-go/agent"
 	"trpc.group/trpc-go/trpc-agent-go/agent/chainagent"
 	"trpc.group/trpc-go/trpc-agent-go/agent/cycleagent"
@@ -20,39 +23,103 @@ import (
 // agentProviderImpl implements the AgentProvider interface
 type agentProviderImpl struct {
 	settingsProvider provider.SettingsProvider
+	registry         *agentRegistry
+	types            *TypeRegistry
 }
 
 // New creates a new AgentProvider instance using dependency injection
 func New(i *do.Injector) (provider.AgentProvider, error) {
 	settingsProvider := do.MustInvoke[provider.SettingsProvider](i)
+	return NewProvider(settingsProvider), nil
+}
+
+// NewProvider creates an AgentProvider building the agents configured by the settings provider
+func NewProvider(settingsProvider provider.SettingsProvider) provider.AgentProvider {
 	return &agentProviderImpl{
 		settingsProvider: settingsProvider,
-	}, nil
+		registry:         newAgentRegistry(),
+		types:            DefaultTypeRegistry,
+	}
 }
 
-// getDefaultAgent creates a default LLM agent with the provided configuration
-func (p *agentProviderImpl) getDefaultAgent(
+// newDefaultAgent creates a default LLM agent with the provided configuration
+func newDefaultAgent(
 	ctx context.Context,
+	p provider.AgentProvider,
+	agentID uuid.UUID,
 	agentConfig provider.AgentConfiguration,
-	opt ...llmagent.Option,
+	providerOptions provider.AgentProviderOptions,
 ) (agent.Agent, error) {
+	opt := providerOptions.LLMOpt
 	options, err := agentConfig.GetDefaultOptions(ctx, p, opt...)
 	if err != nil {
 		return nil, err
 	}
 
-	return llmagent.New(
-		agentConfig.GetName(), options...,
-	), nil
+	variants, selection, err := agentConfig.GetPromptVariants(ctx, p, opt...)
+	if err != nil {
+		return nil, err
+	}
+
+	renderer, err := agentConfig.GetInstructionRenderer(ctx, opt...)
+	if err != nil {
+		return nil, err
+	}
+
+	// The instructions are re-rendered per invocation and set by the model
+	// callbacks, the agents are built without one
+	var llmOptions llmagent.Options
+	for _, o := range options {
+		o(&llmOptions)
+	}
+	options = append(options, llmagent.WithInstruction(""))
+
+	if len(variants) == 0 {
+		llmAgent := newDynamicInstructionAgent(
+			llmagent.New(agentConfig.GetName(), append(options, llmagent.WithModelCallbacks(
+				newInstructionCallbacks(agentID, llmOptions.Instruction, llmOptions.ModelCallbacks),
+			))...),
+			agentID, 0, renderer,
+		)
+		if selection.Version == 0 {
+			return llmAgent, nil
+		}
+
+		// Record the resolved version of a pinned or latest prompt like a selected one
+		return newPromptVersionedAgent(agentID, selection,
+			[]provider.PromptVariant{{Version: selection.Version, Weight: 1}},
+			map[int]agent.Agent{selection.Version: llmAgent},
+		), nil
+	}
+
+	// Build an agent per prompt version, the later instruction options take precedence
+	agents := make(map[int]agent.Agent, len(variants))
+	for _, variant := range variants {
+		agents[variant.Version] = newDynamicInstructionAgent(
+			llmagent.New(
+				agentConfig.GetName(), append(options[:len(options):len(options)],
+					llmagent.WithGlobalInstruction(variant.GlobalInstruction),
+					llmagent.WithModelCallbacks(
+						newInstructionCallbacks(agentID, variant.Instruction, llmOptions.ModelCallbacks),
+					),
+				)...,
+			),
+			agentID, variant.Version, renderer,
+		)
+	}
+
+	return newPromptVersionedAgent(agentID, selection, variants, agents), nil
 }
 
-// getChainAgent creates a chain agent with the provided configuration
-func (p *agentProviderImpl) getChainAgent(
+// newChainAgent creates a chain agent with the provided configuration
+func newChainAgent(
 	ctx context.Context,
+	p provider.AgentProvider,
+	agentID uuid.UUID,
 	agentConfig provider.AgentConfiguration,
-	opt ...chainagent.Option,
+	providerOptions provider.AgentProviderOptions,
 ) (agent.Agent, error) {
-	options, err := agentConfig.GetChainOptions(ctx, p, opt...)
+	options, err := agentConfig.GetChainOptions(ctx, p, providerOptions.ChainOpt...)
 	if err != nil {
 		return nil, err
 	}
@@ -62,13 +129,15 @@ func (p *agentProviderImpl) getChainAgent(
 	), nil
 }
 
-// getCycleAgent creates a cycle agent with the provided configuration
-func (p *agentProviderImpl) getCycleAgent(
+// newCycleAgent creates a cycle agent with the provided configuration
+func newCycleAgent(
 	ctx context.Context,
+	p provider.AgentProvider,
+	agentID uuid.UUID,
 	agentConfig provider.AgentConfiguration,
-	opt ...cycleagent.Option,
+	providerOptions provider.AgentProviderOptions,
 ) (agent.Agent, error) {
-	options, err := agentConfig.GetCycleOptions(ctx, p, opt...)
+	options, err := agentConfig.GetCycleOptions(ctx, p, providerOptions.CycleOpt...)
 	if err != nil {
 		return nil, err
 	}
@@ -78,13 +147,15 @@ func (p *agentProviderImpl) getCycleAgent(
 	), nil
 }
 
-// getParallelAgent creates a parallel agent with the provided configuration
-func (p *agentProviderImpl) getParallelAgent(
+// newParallelAgent creates a parallel agent with the provided configuration
+func newParallelAgent(
 	ctx context.Context,
+	p provider.AgentProvider,
+	agentID uuid.UUID,
 	agentConfig provider.AgentConfiguration,
-	opt ...parallelagent.Option,
+	providerOptions provider.AgentProviderOptions,
 ) (agent.Agent, error) {
-	options, err := agentConfig.GetParallelOptions(ctx, p, opt...)
+	options, err := agentConfig.GetParallelOptions(ctx, p, providerOptions.ParallelOpt...)
 	if err != nil {
 		return nil, err
 	}
@@ -94,8 +165,9 @@ func (p *agentProviderImpl) getParallelAgent(
 	), nil
 }
 
-// GetAgent creates and returns an agent based on the provided agent ID and configuration.
-// It also returns whether streaming is enabled for the agent and any error that occurred.
+// GetAgent returns the cached agent for the agent ID and the instance key of the
+// options, building it on first use. Sub-agents resolved while building it are
+// shared with the other agents using them.
 func (p *agentProviderImpl) GetAgent(
 	ctx context.Context,
 	agentID uuid.UUID,
@@ -107,28 +179,145 @@ func (p *agentProviderImpl) GetAgent(
 		o(&options)
 	}
 
+	key := instanceKey{agentID: agentID, key: options.InstanceKey}
+	theAgent, err := p.registry.getOrBuild(ctx, key, opt, hasBuildOptions(options), func(ctx context.Context) (shared.TheAgent, error) {
+		return p.buildAgent(ctx, agentID, options)
+	})
+	if err != nil {
+		// Only a built agent takes ownership of the toolsets of the options
+		closeOptionToolSets(agentID, options)
+		return nil, err
+	}
+	return theAgent, nil
+}
+
+// hasBuildOptions reports whether the options change how the agent is built
+func hasBuildOptions(options provider.AgentProviderOptions) bool {
+	return len(options.LLMOpt) > 0 || len(options.CycleOpt) > 0 ||
+		len(options.ParallelOpt) > 0 || len(options.ChainOpt) > 0
+}
+
+// closeOptionToolSets closes the toolsets passed in the options of an agent that was not built
+func closeOptionToolSets(agentID uuid.UUID, options provider.AgentProviderOptions) {
+	var llmOptions llmagent.Options
+	for _, o := range options.LLMOpt {
+		o(&llmOptions)
+	}
+
+	for _, toolSet := range llmOptions.ToolSets {
+		if err := toolSet.Close(); err != nil {
+			logger.Log.Warn("failed to close toolset of rejected agent options",
+				zap.String("agent_id", agentID.String()),
+				zap.Error(err),
+			)
+		}
+	}
+}
+
+// buildAgent creates an agent based on the provided agent ID and configuration.
+// The agent takes ownership of the toolsets passed in the options.
+func (p *agentProviderImpl) buildAgent(
+	ctx context.Context,
+	agentID uuid.UUID,
+	options provider.AgentProviderOptions,
+) (shared.TheAgent, error) {
+
 	agentConfig, err := p.settingsProvider.GetAgentConfiguration(agentID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get agent settings for agent %s", agentID)
 	}
 
-	var ag agent.Agent
-	switch agentConfig.GetType() {
-	case shared.AgentTypeDefault:
-		ag, err = p.getDefaultAgent(ctx, agentConfig, options.LLMOpt...)
-	case shared.AgentTypeChain:
-		ag, err = p.getChainAgent(ctx, agentConfig, options.ChainOpt...)
-	case shared.AgentTypeCycle:
-		ag, err = p.getCycleAgent(ctx, agentConfig, options.CycleOpt...)
-	case shared.AgentTypeParallel:
-		ag, err = p.getParallelAgent(ctx, agentConfig, options.ParallelOpt...)
-	default:
-		ag, err = p.getDefaultAgent(ctx, agentConfig, options.LLMOpt...)
+	// Agents without a type are default agents
+	agentType := agentConfig.GetType()
+	if agentType == "" {
+		agentType = shared.AgentTypeDefault
+	}
+
+	constructor, err := p.types.constructor(agentType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build agent %s: %w", agentID, err)
+	}
+
+	ag, err := constructor(ctx, p, agentID, agentConfig, options)
+	if err != nil {
+		return nil, err
+	}
+
+	var llmOptions llmagent.Options
+	for _, o := range options.LLMOpt {
+		o(&llmOptions)
 	}
 
+	// The registry owns the sub-agents, closing the agent leaves them open
 	return shared.NewAgent(
 		ag,
 		agentID,
 		agentConfig.IsStreamingEnabled(),
-	), err
+		shared.WithOwnedToolSets(llmOptions.ToolSets...),
+		shared.WithSharedSubAgents(),
+		shared.WithDescriptor(shared.AgentDescriptor{
+			Name:         agentConfig.GetName(),
+			Description:  agentConfig.GetDescription(),
+			Role:         agentConfig.GetRole(),
+			Type:         agentConfig.GetType(),
+			InputSchema:  agentConfig.GetInputSchema(),
+			OutputSchema: agentConfig.GetOutputSchema(),
+			Capabilities: agentConfig.GetCapabilities(),
+		}),
+	), nil
+}
+
+// Close evicts and closes all instances of the agent and of the agents using it as sub-agent
+func (p *agentProviderImpl) Close(agentID uuid.UUID) error {
+	evicted, err := p.registry.evict(agentID)
+	if len(evicted) > 0 {
+		logger.Log.Info("agent instances closed",
+			zap.String("agent_id", agentID.String()),
+			zap.Int("instances", len(evicted)),
+		)
+	}
+	return err
+}
+
+// Reload closes all instances of the agent and of the agents using it as
+// sub-agent and rebuilds them with the options they were built with
+func (p *agentProviderImpl) Reload(ctx context.Context, agentID uuid.UUID) error {
+	evicted, err := p.registry.evict(agentID)
+	if err != nil {
+		logger.Log.Warn("failed to close agent instances on reload",
+			zap.String("agent_id", agentID.String()),
+			zap.Error(err),
+		)
+	}
+
+	// Sub-agents are rebuilt before the agents using them, so an instance built
+	// with options is not resolved without them first
+	var errs []error
+	for len(evicted) > 0 {
+		for key, entry := range evicted {
+			if hasPendingSubAgent(entry, evicted) {
+				continue
+			}
+			delete(evicted, key)
+			if _, err := p.GetAgent(ctx, key.agentID, entry.options...); err != nil {
+				errs = append(errs, fmt.Errorf("failed to reload agent %s: %w", key.agentID, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// hasPendingSubAgent reports whether a sub-agent of the entry still waits to be rebuilt
+func hasPendingSubAgent(entry *registryEntry, pending map[instanceKey]*registryEntry) bool {
+	for _, subAgent := range entry.subAgents {
+		if _, ok := pending[subAgent]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Shutdown closes all cached agents, it is called by the DI container
+func (p *agentProviderImpl) Shutdown() error {
+	return p.registry.closeAll()
 }
@@ -0,0 +1,431 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/denkhaus/agents/provider"
+	"github.com/google/uuid"
+	"trpc.group/trpc-go/trpc-agent-go/agent"
+	"trpc.group/trpc-go/trpc-agent-go/agent/graphagent"
+	"trpc.group/trpc-go/trpc-agent-go/agent/llmagent"
+	"trpc.group/trpc-go/trpc-agent-go/event"
+	"trpc.group/trpc-go/trpc-agent-go/graph"
+	"trpc.group/trpc-go/trpc-agent-go/model"
+	"trpc.group/trpc-go/trpc-agent-go/session"
+	"trpc.group/trpc-go/trpc-agent-go/tool"
+)
+
+// graphInvocationKey carries the invocation of a graph agent to its nodes
+type graphInvocationKey struct{}
+
+// graphEventsKey carries the sink of the node agent events to the nodes of a graph agent
+type graphEventsKey struct{}
+
+// graphNodeFunc is the function of a graph node
+type graphNodeFunc = func(ctx context.Context, state graph.State) (any, error)
+
+// graphEventSink passes the events of the node agents on to the events of the graph agent
+type graphEventSink struct {
+	events chan *event.Event
+	done   chan struct{}
+}
+
+// forward passes an event on, it is dropped once the graph run is over
+func (s *graphEventSink) forward(ctx context.Context, ev *event.Event) {
+	select {
+	case s.events <- ev:
+	case <-s.done:
+	case <-ctx.Done():
+	}
+}
+
+// graphAgentImpl runs a compiled workflow graph and passes the invocation to its
+// nodes. The events of the node agents are merged into the events of the graph.
+type graphAgentImpl struct {
+	agent.Agent
+	nodeAgents []agent.Agent
+}
+
+func (a *graphAgentImpl) Run(ctx context.Context, invocation *agent.Invocation) (<-chan *event.Event, error) {
+	sink := &graphEventSink{events: make(chan *event.Event), done: make(chan struct{})}
+
+	ctx = context.WithValue(ctx, graphInvocationKey{}, invocation)
+	events, err := a.Agent.Run(context.WithValue(ctx, graphEventsKey{}, sink), invocation)
+	if err != nil {
+		return nil, err
+	}
+
+	eventChan := make(chan *event.Event, cap(events))
+	go func() {
+		defer close(eventChan)
+		defer close(sink.done)
+
+		// The events of the graph are drained once the caller goes away
+		forward := func(ev *event.Event) {
+			select {
+			case eventChan <- ev:
+			case <-ctx.Done():
+			}
+		}
+
+		for {
+			select {
+			case ev, ok := <-events:
+				if !ok {
+					return
+				}
+				forward(ev)
+			case ev := <-sink.events:
+				forward(ev)
+			}
+		}
+	}()
+
+	return eventChan, nil
+}
+
+// SubAgents returns the agents run by the nodes of the graph
+func (a *graphAgentImpl) SubAgents() []agent.Agent {
+	return a.nodeAgents
+}
+
+func (a *graphAgentImpl) FindSubAgent(name string) agent.Agent {
+	for _, nodeAgent := range a.nodeAgents {
+		if nodeAgent.Info().Name == name {
+			return nodeAgent
+		}
+	}
+	return nil
+}
+
+// newGraphAgent creates a graph agent from the workflow of the configuration.
+// The node agents are resolved through the provider, the node tools are taken
+// from the tools and toolsets of the LLM options.
+func newGraphAgent(
+	ctx context.Context,
+	p provider.AgentProvider,
+	agentID uuid.UUID,
+	agentConfig provider.AgentConfiguration,
+	providerOptions provider.AgentProviderOptions,
+) (agent.Agent, error) {
+	settings, err := agentConfig.GetGraph()
+	if err != nil {
+		return nil, err
+	}
+	if err := settings.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid graph of agent %s: %w", agentID, err)
+	}
+
+	tools := graphTools(ctx, providerOptions.LLMOpt...)
+
+	stateGraph := graph.NewStateGraph(graph.MessagesStateSchema())
+	var nodeAgents []agent.Agent
+	for _, node := range settings.Nodes {
+		if node.Agent != "" {
+			nodeAgentID, err := uuid.Parse(node.Agent)
+			if err != nil {
+				return nil, fmt.Errorf("graph node %s references invalid agent %q: %w", node.ID, node.Agent, err)
+			}
+			nodeAgent, err := p.GetAgent(ctx, nodeAgentID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get agent of graph node %s: %w", node.ID, err)
+			}
+			nodeAgents = append(nodeAgents, nodeAgent)
+			stateGraph.AddNode(node.ID, newAgentNode(node, nodeAgent))
+			continue
+		}
+
+		nodeTool, exists := tools[node.Tool]
+		if !exists {
+			return nil, fmt.Errorf("graph node %s runs unknown tool %s", node.ID, node.Tool)
+		}
+		stateGraph.AddNode(node.ID, newToolNode(node, nodeTool))
+	}
+
+	// All branches end in the finish node
+	stateGraph.AddNode(provider.GraphEnd, passNode)
+	stateGraph.SetEntryPoint(settings.GetEntry())
+	stateGraph.SetFinishPoint(provider.GraphEnd)
+	addGraphEdges(stateGraph, settings)
+
+	compiled, err := stateGraph.Compile()
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile graph of agent %s: %w", agentID, err)
+	}
+
+	graphAgent, err := graphagent.New(agentConfig.GetName(), compiled,
+		graphagent.WithDescription(agentConfig.GetDescription()),
+		graphagent.WithInitialState(graph.State{}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create graph agent %s: %w", agentID, err)
+	}
+
+	return &graphAgentImpl{Agent: graphAgent, nodeAgents: nodeAgents}, nil
+}
+
+// addGraphEdges connects the nodes, nodes without edges lead to the end.
+// Conditional edges route to a single node, so an edge with several targets
+// fans out through a pass node.
+func addGraphEdges(stateGraph *graph.StateGraph, settings provider.GraphSettings) {
+	edges := make(map[string][]provider.GraphEdge)
+	for _, edge := range settings.Edges {
+		edges[edge.From] = append(edges[edge.From], edge)
+	}
+
+	for _, node := range settings.Nodes {
+		nodeEdges := edges[node.ID]
+		if len(nodeEdges) == 0 {
+			stateGraph.AddEdge(node.ID, provider.GraphEnd)
+			continue
+		}
+
+		if !hasConditions(nodeEdges) {
+			for _, edge := range nodeEdges {
+				for _, to := range edge.To {
+					stateGraph.AddEdge(node.ID, to)
+				}
+			}
+			continue
+		}
+
+		pathMap := map[string]string{provider.GraphEnd: provider.GraphEnd}
+		for i, edge := range nodeEdges {
+			target := edge.To[0]
+			if len(edge.To) > 1 {
+				target = fmt.Sprintf("%s#%d", node.ID, i)
+				stateGraph.AddNode(target, passNode)
+				for _, to := range edge.To {
+					stateGraph.AddEdge(target, to)
+				}
+			}
+			pathMap[strconv.Itoa(i)] = target
+		}
+		stateGraph.AddConditionalEdges(node.ID, newEdgeCondition(nodeEdges), pathMap)
+	}
+}
+
+func hasConditions(edges []provider.GraphEdge) bool {
+	for _, edge := range edges {
+		if edge.When != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// newEdgeCondition returns the index of the first edge whose condition holds
+func newEdgeCondition(edges []provider.GraphEdge) func(ctx context.Context, state graph.State) (string, error) {
+	conditions := make([]*graphCondition, len(edges))
+	for i, edge := range edges {
+		if edge.When != nil {
+			conditions[i] = newGraphCondition(*edge.When)
+		}
+	}
+
+	return func(ctx context.Context, state graph.State) (string, error) {
+		for i, condition := range conditions {
+			if condition == nil || condition.holds(ctx, state) {
+				return strconv.Itoa(i), nil
+			}
+		}
+		return provider.GraphEnd, nil
+	}
+}
+
+// graphCondition is a validated condition with its compiled pattern
+type graphCondition struct {
+	provider.GraphCondition
+	pattern *regexp.Regexp
+}
+
+func newGraphCondition(condition provider.GraphCondition) *graphCondition {
+	c := &graphCondition{GraphCondition: condition}
+	if condition.Matches != "" {
+		c.pattern = regexp.MustCompile(condition.Matches)
+	}
+	return c
+}
+
+func (c *graphCondition) holds(ctx context.Context, state graph.State) bool {
+	var value string
+	if c.Key != "" {
+		value = stateString(state[c.Key])
+	} else if invocation, ok := ctx.Value(graphInvocationKey{}).(*agent.Invocation); ok && invocation.Session != nil {
+		value = string(invocation.Session.State[c.SessionKey])
+	}
+
+	holds := value != ""
+	switch {
+	case c.Equals != "":
+		holds = value == c.Equals
+	case c.Contains != "":
+		holds = strings.Contains(value, c.Contains)
+	case c.pattern != nil:
+		holds = c.pattern.MatchString(value)
+	}
+
+	return holds != c.Not
+}
+
+func passNode(ctx context.Context, state graph.State) (any, error) {
+	return graph.State{}, nil
+}
+
+// newAgentNode runs the agent with the inputs of the node and stores its final answer
+func newAgentNode(node provider.GraphNode, nodeAgent agent.Agent) graphNodeFunc {
+	return func(ctx context.Context, state graph.State) (any, error) {
+		output, err := runGraphNodeAgent(ctx, node.ID, nodeAgent, graphNodeInput(ctx, node, state))
+		if err != nil {
+			return nil, fmt.Errorf("graph node %s failed: %w", node.ID, err)
+		}
+
+		return graph.State{
+			node.GetOutputKey():        output,
+			graph.StateKeyLastResponse: output,
+		}, nil
+	}
+}
+
+// newToolNode calls the tool with the arguments of the node and stores its result
+func newToolNode(node provider.GraphNode, nodeTool tool.CallableTool) graphNodeFunc {
+	return func(ctx context.Context, state graph.State) (any, error) {
+		args := make(map[string]interface{}, len(node.Args))
+		for key, value := range node.Args {
+			if ref, ok := value.(string); ok && strings.HasPrefix(ref, "$") {
+				value = state[strings.TrimPrefix(ref, "$")]
+			}
+			args[key] = value
+		}
+
+		jsonArgs, err := json.Marshal(args)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode arguments of graph node %s: %w", node.ID, err)
+		}
+
+		result, err := nodeTool.Call(ctx, jsonArgs)
+		if err != nil {
+			return nil, fmt.Errorf("graph node %s failed: %w", node.ID, err)
+		}
+
+		output := stateString(result)
+		return graph.State{
+			node.GetOutputKey():        output,
+			graph.StateKeyLastResponse: output,
+		}, nil
+	}
+}
+
+// graphNodeInput returns the message passed to the agent of a node
+func graphNodeInput(ctx context.Context, node provider.GraphNode, state graph.State) string {
+	switch len(node.Inputs) {
+	case 0:
+	case 1:
+		return stateString(state[node.Inputs[0]])
+	default:
+		sections := make([]string, 0, len(node.Inputs))
+		for _, key := range node.Inputs {
+			sections = append(sections, fmt.Sprintf("## %s\n\n%s", key, stateString(state[key])))
+		}
+		return strings.Join(sections, "\n\n")
+	}
+
+	if output := stateString(state[graph.StateKeyLastResponse]); output != "" {
+		return output
+	}
+	if input := stateString(state[graph.StateKeyUserInput]); input != "" {
+		return input
+	}
+	if invocation, ok := ctx.Value(graphInvocationKey{}).(*agent.Invocation); ok {
+		return invocation.Message.Content
+	}
+	return ""
+}
+
+// runGraphNodeAgent runs the agent of a node in the session of the graph and returns
+// its final answer, the events of the agent are forwarded to the graph agent
+func runGraphNodeAgent(ctx context.Context, nodeID string, nodeAgent agent.Agent, input string) (string, error) {
+	invocation := &agent.Invocation{
+		AgentName:    nodeAgent.Info().Name,
+		InvocationID: uuid.NewString(),
+		Message:      model.NewUserMessage(input),
+		Session:      &session.Session{ID: uuid.NewString()},
+	}
+	if parent, ok := ctx.Value(graphInvocationKey{}).(*agent.Invocation); ok && parent.Session != nil {
+		invocation.InvocationID = parent.InvocationID + "/" + nodeID
+		invocation.Session = parent.Session
+	}
+
+	events, err := nodeAgent.Run(ctx, invocation)
+	if err != nil {
+		return "", err
+	}
+
+	sink, _ := ctx.Value(graphEventsKey{}).(*graphEventSink)
+
+	var output string
+	var errs []error
+	for ev := range events {
+		switch {
+		case ev.Response == nil:
+		case ev.Error != nil:
+			errs = append(errs, errors.New(ev.Error.Message))
+		case ev.IsPartial || len(ev.Choices) == 0:
+		default:
+			if message := ev.Choices[0].Message; message.Role == model.RoleAssistant && message.Content != "" {
+				output = message.Content
+			}
+		}
+
+		if sink != nil {
+			sink.forward(ctx, ev)
+		}
+	}
+
+	return output, errors.Join(errs...)
+}
+
+// graphTools returns the callable tools of the LLM options by name
+func graphTools(ctx context.Context, opt ...llmagent.Option) map[string]tool.CallableTool {
+	var options llmagent.Options
+	for _, o := range opt {
+		o(&options)
+	}
+
+	tools := make(map[string]tool.CallableTool)
+	for _, t := range options.Tools {
+		if callable, ok := t.(tool.CallableTool); ok {
+			tools[t.Declaration().Name] = callable
+		}
+	}
+	for _, toolSet := range options.ToolSets {
+		for _, t := range toolSet.Tools(ctx) {
+			tools[t.Declaration().Name] = t
+		}
+	}
+	return tools
+}
+
+// stateString converts a state value to the text passed between nodes
+func stateString(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(data)
+	}
+}
@@ -0,0 +1,238 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/denkhaus/agents/provider"
+	"github.com/denkhaus/agents/shared"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"trpc.group/trpc-go/trpc-agent-go/agent"
+	"trpc.group/trpc-go/trpc-agent-go/agent/llmagent"
+	"trpc.group/trpc-go/trpc-agent-go/event"
+	"trpc.group/trpc-go/trpc-agent-go/graph"
+	"trpc.group/trpc-go/trpc-agent-go/model"
+	"trpc.group/trpc-go/trpc-agent-go/session"
+	"trpc.group/trpc-go/trpc-agent-go/tool"
+)
+
+// staticProvider returns the agents it was created with
+type staticProvider map[uuid.UUID]shared.TheAgent
+
+func (p staticProvider) GetAgent(ctx context.Context, agentID uuid.UUID, opt ...provider.AgentProviderOption) (shared.TheAgent, error) {
+	if theAgent, ok := p[agentID]; ok {
+		return theAgent, nil
+	}
+	return nil, fmt.Errorf("agent %s not found", agentID)
+}
+
+func (p staticProvider) Close(agentID uuid.UUID) error                       { return nil }
+func (p staticProvider) Reload(ctx context.Context, agentID uuid.UUID) error { return nil }
+
+// graphConfiguration is the configuration of a graph agent, other settings are not used
+type graphConfiguration struct {
+	provider.AgentConfiguration
+	graph provider.GraphSettings
+}
+
+func (c *graphConfiguration) GetName() string                           { return "pipeline" }
+func (c *graphConfiguration) GetDescription() string                    { return "Codes and reviews a change" }
+func (c *graphConfiguration) GetGraph() (provider.GraphSettings, error) { return c.graph, nil }
+
+// replyAgent answers every run with the reply to its input and records the inputs
+type replyAgent struct {
+	name   string
+	reply  func(input string) string
+	inputs []string
+}
+
+func (a *replyAgent) Run(ctx context.Context, invocation *agent.Invocation) (<-chan *event.Event, error) {
+	a.inputs = append(a.inputs, invocation.Message.Content)
+
+	events := make(chan *event.Event, 1)
+	events <- &event.Event{
+		Response: &model.Response{
+			Done:    true,
+			Choices: []model.Choice{{Message: model.NewAssistantMessage(a.reply(invocation.Message.Content))}},
+		},
+		InvocationID: invocation.InvocationID,
+		Author:       a.name,
+		ID:           uuid.NewString(),
+	}
+	close(events)
+	return events, nil
+}
+
+func (a *replyAgent) Tools() []tool.Tool                   { return nil }
+func (a *replyAgent) Info() agent.Info                     { return agent.Info{Name: a.name} }
+func (a *replyAgent) SubAgents() []agent.Agent             { return nil }
+func (a *replyAgent) FindSubAgent(name string) agent.Agent { return nil }
+
+// recordingTool records the arguments of its calls, branches call it in parallel
+type recordingTool struct {
+	name string
+	mu   sync.Mutex
+	args []string
+}
+
+func (t *recordingTool) Declaration() *tool.Declaration {
+	return &tool.Declaration{Name: t.name}
+}
+
+func (t *recordingTool) Call(ctx context.Context, jsonArgs []byte) (any, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.args = append(t.args, string(jsonArgs))
+	return map[string]interface{}{"passed": true}, nil
+}
+
+func TestGraphSettings_Validate(t *testing.T) {
+	valid := provider.GraphSettings{
+		Nodes: []provider.GraphNode{
+			{ID: "code", Agent: "550e8400-e29b-41d4-a716-446655440001"},
+			{ID: "review", Agent: "550e8400-e29b-41d4-a716-446655440002"},
+		},
+		Edges: []provider.GraphEdge{
+			{From: "code", To: []string{"review"}},
+			{From: "review", To: []string{"code"}, When: &provider.GraphCondition{Key: "review", Contains: "CHANGES"}},
+			{From: "review", To: []string{provider.GraphEnd}},
+		},
+	}
+	assert.NoError(t, valid.Validate())
+	assert.Equal(t, "code", valid.GetEntry())
+
+	for name, tc := range map[string]struct {
+		settings provider.GraphSettings
+		err      string
+	}{
+		"no nodes":       {provider.GraphSettings{}, "graph has no nodes"},
+		"reserved id":    {provider.GraphSettings{Nodes: []provider.GraphNode{{ID: "end", Tool: "t"}}}, "is reserved"},
+		"agent and tool": {provider.GraphSettings{Nodes: []provider.GraphNode{{ID: "a", Agent: "x", Tool: "t"}}}, "needs either an agent or a tool"},
+		"unknown entry":  {provider.GraphSettings{Entry: "b", Nodes: []provider.GraphNode{{ID: "a", Tool: "t"}}}, "graph entry b is no node"},
+		"unknown target": {provider.GraphSettings{
+			Nodes: []provider.GraphNode{{ID: "a", Tool: "t"}},
+			Edges: []provider.GraphEdge{{From: "a", To: []string{"b"}}},
+		}, "to unknown node b"},
+		"invalid condition": {provider.GraphSettings{
+			Nodes: []provider.GraphNode{{ID: "a", Tool: "t"}},
+			Edges: []provider.GraphEdge{{From: "a", To: []string{"end"}, When: &provider.GraphCondition{Key: "a", SessionKey: "b"}}},
+		}, "either a key or a session_key"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.ErrorContains(t, tc.settings.Validate(), tc.err)
+		})
+	}
+}
+
+func TestGraphEdgeCondition(t *testing.T) {
+	invocation := &agent.Invocation{
+		Message: model.NewUserMessage("fix the build"),
+		Session: &session.Session{State: session.StateMap{"approved": []byte("yes")}},
+	}
+	ctx := context.WithValue(context.Background(), graphInvocationKey{}, invocation)
+
+	condition := newEdgeCondition([]provider.GraphEdge{
+		{To: []string{"code"}, When: &provider.GraphCondition{Key: "review", Matches: `(?i)changes requested`}},
+		{To: []string{"deploy"}, When: &provider.GraphCondition{SessionKey: "approved", Equals: "yes"}},
+		{To: []string{"end"}, When: &provider.GraphCondition{Key: "review", Not: true}},
+	})
+
+	next, err := condition(ctx, graph.State{"review": "Changes requested: add tests"})
+	assert.NoError(t, err)
+	assert.Equal(t, "0", next)
+
+	next, _ = condition(ctx, graph.State{"review": "LGTM"})
+	assert.Equal(t, "1", next)
+
+	next, _ = condition(context.Background(), graph.State{})
+	assert.Equal(t, "2", next)
+
+	next, _ = condition(context.Background(), graph.State{"review": "LGTM"})
+	assert.Equal(t, provider.GraphEnd, next)
+}
+
+func TestGraphNodeInput(t *testing.T) {
+	invocation := &agent.Invocation{Message: model.NewUserMessage("fix the build")}
+	ctx := context.WithValue(context.Background(), graphInvocationKey{}, invocation)
+
+	assert.Equal(t, "fix the build", graphNodeInput(ctx, provider.GraphNode{ID: "code"}, graph.State{}))
+	assert.Equal(t, "patch", graphNodeInput(ctx, provider.GraphNode{ID: "review"},
+		graph.State{graph.StateKeyLastResponse: "patch"}))
+
+	// Several inputs join the outputs of parallel branches
+	assert.Equal(t, "## code\n\npatch\n\n## test\n\n{\"passed\":true}", graphNodeInput(ctx,
+		provider.GraphNode{ID: "review", Inputs: []string{"code", "test"}},
+		graph.State{"code": "patch", "test": map[string]interface{}{"passed": true}},
+	))
+}
+
+func TestGraphAgent_Run(t *testing.T) {
+	coderID, reviewerID := uuid.New(), uuid.New()
+
+	var patches int
+	coder := &replyAgent{name: "coder", reply: func(input string) string {
+		patches++
+		return fmt.Sprintf("patch %d", patches)
+	}}
+	reviewer := &replyAgent{name: "reviewer", reply: func(input string) string {
+		if input == "patch 1" {
+			return "CHANGES requested: add tests"
+		}
+		return "LGTM"
+	}}
+	lint, test := &recordingTool{name: "lint"}, &recordingTool{name: "test"}
+
+	p := staticProvider{
+		coderID:    shared.NewAgent(coder, coderID, false),
+		reviewerID: shared.NewAgent(reviewer, reviewerID, false),
+	}
+
+	// The approved patch fans out to lint and test through a pass node, both end the graph
+	graphAgent, err := newGraphAgent(context.Background(), p, uuid.New(), &graphConfiguration{
+		graph: provider.GraphSettings{
+			Nodes: []provider.GraphNode{
+				{ID: "code", Agent: coderID.String()},
+				{ID: "review", Agent: reviewerID.String()},
+				{ID: "lint", Tool: "lint", Args: map[string]interface{}{"patch": "$code"}},
+				{ID: "test", Tool: "test", Args: map[string]interface{}{"patch": "$code"}},
+			},
+			Edges: []provider.GraphEdge{
+				{From: "code", To: []string{"review"}},
+				{From: "review", To: []string{"code"}, When: &provider.GraphCondition{Key: "review", Contains: "CHANGES"}},
+				{From: "review", To: []string{"lint", "test"}},
+			},
+		},
+	}, provider.AgentProviderOptions{
+		LLMOpt: []llmagent.Option{llmagent.WithTools([]tool.Tool{lint, test})},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "pipeline", graphAgent.Info().Name)
+	assert.Len(t, graphAgent.SubAgents(), 2)
+
+	events := collectEvents(t, graphAgent, &agent.Invocation{
+		InvocationID: "invocation",
+		Message:      model.NewUserMessage("fix the build"),
+		Session:      &session.Session{ID: "session", State: session.StateMap{}},
+	})
+
+	assert.Equal(t, []string{"fix the build", "CHANGES requested: add tests"}, coder.inputs)
+	assert.Equal(t, []string{"patch 1", "patch 2"}, reviewer.inputs)
+	assert.Equal(t, []string{`{"patch":"patch 2"}`}, lint.args)
+	assert.Equal(t, []string{`{"patch":"patch 2"}`}, test.args)
+
+	// The answers of the node agents are forwarded with their author
+	authors := make(map[string]int)
+	for _, ev := range events {
+		if ev.Response != nil {
+			assert.Nil(t, ev.Error)
+		}
+		authors[ev.Author]++
+	}
+	assert.Equal(t, 2, authors["coder"])
+	assert.Equal(t, 2, authors["reviewer"])
+}
@@ -0,0 +1,107 @@
+package agent
+
+import (
+	"context"
+
+	"github.com/denkhaus/agents/logger"
+	"github.com/denkhaus/agents/provider"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"trpc.group/trpc-go/trpc-agent-go/agent"
+	"trpc.group/trpc-go/trpc-agent-go/event"
+	"trpc.group/trpc-go/trpc-agent-go/model"
+)
+
+// instructionContextKey carries the instruction rendered for an invocation
+type instructionContextKey struct{}
+
+// renderedInstruction is the instruction rendered for an invocation of an agent
+type renderedInstruction struct {
+	agentID     uuid.UUID
+	instruction string
+}
+
+// newInstructionCallbacks returns model callbacks that set the instruction of
+// an LLM agent built without one. The instruction rendered for the invocation
+// takes precedence over the static one. It is appended to the first system
+// message, which holds the global instruction, or becomes the first message.
+// The callbacks of the given options run afterwards.
+func newInstructionCallbacks(agentID uuid.UUID, static string, existing *model.Callbacks) *model.Callbacks {
+	callbacks := model.NewCallbacks()
+	callbacks.RegisterBeforeModel(func(ctx context.Context, req *model.Request) (*model.Response, error) {
+		instruction := static
+		if rendered, ok := ctx.Value(instructionContextKey{}).(renderedInstruction); ok && rendered.agentID == agentID {
+			instruction = rendered.instruction
+		}
+		if instruction == "" {
+			return nil, nil
+		}
+
+		for i := range req.Messages {
+			message := &req.Messages[i]
+			if message.Role != model.RoleSystem {
+				continue
+			}
+			if message.Content == "" {
+				message.Content = instruction
+			} else {
+				message.Content += "\n\n" + instruction
+			}
+			return nil, nil
+		}
+
+		req.Messages = append([]model.Message{model.NewSystemMessage(instruction)}, req.Messages...)
+		return nil, nil
+	})
+
+	if existing != nil {
+		callbacks.BeforeModel = append(callbacks.BeforeModel, existing.BeforeModel...)
+		callbacks.AfterModel = append(callbacks.AfterModel, existing.AfterModel...)
+	}
+
+	return callbacks
+}
+
+// dynamicInstructionAgent re-renders the instruction of an LLM agent at the
+// start of each invocation, the agent must use newInstructionCallbacks
+type dynamicInstructionAgent struct {
+	agent.Agent
+	agentID  uuid.UUID
+	version  int
+	renderer provider.InstructionRenderer
+}
+
+func newDynamicInstructionAgent(
+	llmAgent agent.Agent,
+	agentID uuid.UUID,
+	version int,
+	renderer provider.InstructionRenderer,
+) agent.Agent {
+	return &dynamicInstructionAgent{
+		Agent:    llmAgent,
+		agentID:  agentID,
+		version:  version,
+		renderer: renderer,
+	}
+}
+
+// Run renders the instruction from live data, a failed render keeps the
+// instruction the agent was built with
+func (a *dynamicInstructionAgent) Run(ctx context.Context, invocation *agent.Invocation) (<-chan *event.Event, error) {
+	instruction, err := a.renderer.Render(ctx, a.version, invocation.Session)
+	if err != nil {
+		logger.Log.Warn("failed to render instruction, keeping the previous one",
+			zap.String("agent_id", a.agentID.String()),
+			zap.String("invocation_id", invocation.InvocationID),
+			zap.Error(err),
+		)
+		return a.Agent.Run(ctx, invocation)
+	}
+
+	ctx = context.WithValue(ctx, instructionContextKey{}, renderedInstruction{
+		agentID:     a.agentID,
+		instruction: instruction,
+	})
+
+	return a.Agent.Run(ctx, invocation)
+}
@@ -0,0 +1,89 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"trpc.group/trpc-go/trpc-agent-go/agent"
+	"trpc.group/trpc-go/trpc-agent-go/model"
+	"trpc.group/trpc-go/trpc-agent-go/session"
+)
+
+// staticRenderer renders its instruction or fails with its error
+type staticRenderer struct {
+	instruction string
+	err         error
+	version     int
+}
+
+func (r *staticRenderer) Render(ctx context.Context, version int, session *session.Session) (string, error) {
+	r.version = version
+	return r.instruction, r.err
+}
+
+func TestInstructionCallbacks(t *testing.T) {
+	agentID := uuid.New()
+
+	var calls []string
+	existing := model.NewCallbacks()
+	existing.RegisterBeforeModel(func(ctx context.Context, req *model.Request) (*model.Response, error) {
+		calls = append(calls, "existing")
+		return nil, nil
+	})
+
+	callbacks := newInstructionCallbacks(agentID, "static", existing)
+	require.Len(t, callbacks.BeforeModel, 2)
+
+	run := func(ctx context.Context, messages ...model.Message) []model.Message {
+		req := &model.Request{Messages: messages}
+		for _, callback := range callbacks.BeforeModel {
+			_, err := callback(ctx, req)
+			require.NoError(t, err)
+		}
+		return req.Messages
+	}
+
+	// The static instruction follows the global instruction
+	messages := run(context.Background(), model.NewSystemMessage("global"), model.NewUserMessage("hi"))
+	assert.Equal(t, "global\n\nstatic", messages[0].Content)
+	assert.Equal(t, []string{"existing"}, calls)
+
+	// The rendered instruction becomes the system message if there is none
+	rendered := context.WithValue(context.Background(), instructionContextKey{}, renderedInstruction{
+		agentID:     agentID,
+		instruction: "rendered",
+	})
+	messages = run(rendered, model.NewUserMessage("hi"))
+	require.Len(t, messages, 2)
+	assert.Equal(t, model.NewSystemMessage("rendered"), messages[0])
+
+	// The instruction rendered for another agent is ignored
+	other := context.WithValue(context.Background(), instructionContextKey{}, renderedInstruction{
+		agentID:     uuid.New(),
+		instruction: "other",
+	})
+	messages = run(other, model.NewUserMessage("hi"))
+	assert.Equal(t, "static", messages[0].Content)
+}
+
+func TestDynamicInstructionAgent(t *testing.T) {
+	agentID := uuid.New()
+	llmAgent := &eventAgent{name: "coder", events: 1}
+	renderer := &staticRenderer{instruction: "rendered"}
+
+	dynamic := newDynamicInstructionAgent(llmAgent, agentID, 2, renderer)
+	assert.Len(t, collectEvents(t, dynamic, &agent.Invocation{}), 1)
+	assert.Equal(t, 2, renderer.version)
+	assert.Equal(t, renderedInstruction{agentID: agentID, instruction: "rendered"},
+		llmAgent.ctx.Value(instructionContextKey{}))
+
+	// A failed render keeps the static instruction
+	renderer.err = errors.New("render failed")
+	assert.Len(t, collectEvents(t, dynamic, &agent.Invocation{}), 1)
+	assert.Nil(t, llmAgent.ctx.Value(instructionContextKey{}))
+	assert.Equal(t, "coder", dynamic.Info().Name)
+}
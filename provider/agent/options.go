@@ -40,3 +40,12 @@ func WithChainAgentOptions(opt ...chainagent.Option) provider.AgentProviderOptio
 		opts.ChainOpt = opt
 	}
 }
+
+// WithInstanceKey caches the agent apart from the instances built with other keys.
+// Build options are only accepted for an instance that is not cached yet, calls
+// passing options for an agent that is also resolved elsewhere need their own key.
+func WithInstanceKey(key string) provider.AgentProviderOption {
+	return func(opts *provider.AgentProviderOptions) {
+		opts.InstanceKey = key
+	}
+}
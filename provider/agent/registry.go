@@ -0,0 +1,188 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/denkhaus/agents/logger"
+	"github.com/denkhaus/agents/provider"
+	"github.com/denkhaus/agents/shared"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// ErrInstanceBuilt is returned if build options are passed for an agent instance that is
+// already cached. The options cannot be compared, an instance built with options needs its own key.
+var ErrInstanceBuilt = errors.New("agent instance is already built")
+
+// instanceKey identifies a cached agent instance
+type instanceKey struct {
+	agentID uuid.UUID
+	key     string
+}
+
+// registryEntry is a cached agent with what is needed to rebuild it
+type registryEntry struct {
+	agent   shared.TheAgent
+	options []provider.AgentProviderOption
+	// subAgents are the instances resolved through the provider while the agent was built
+	subAgents []instanceKey
+}
+
+// buildFrameKey carries the agents being built in the context of nested GetAgent calls
+type buildFrameKey struct{}
+
+// buildFrame is the agent being built and the agents it was requested by
+type buildFrame struct {
+	parent    *buildFrame
+	key       instanceKey
+	subAgents []instanceKey
+}
+
+// path returns the agent IDs from the outermost agent to the frame
+func (f *buildFrame) path() []string {
+	var path []string
+	for frame := f; frame != nil; frame = frame.parent {
+		path = append([]string{frame.key.agentID.String()}, path...)
+	}
+	return path
+}
+
+// agentRegistry caches the agents built by the provider and tracks which
+// agents use which as sub-agent, so dependents are evicted together
+type agentRegistry struct {
+	mu      sync.RWMutex
+	entries map[instanceKey]*registryEntry
+	// buildMu serializes the outermost builds, nested builds run under the lock of their parent
+	buildMu sync.Mutex
+}
+
+func newAgentRegistry() *agentRegistry {
+	return &agentRegistry{
+		entries: make(map[instanceKey]*registryEntry),
+	}
+}
+
+// getOrBuild returns the cached instance or builds it. Nested calls made while
+// building an agent are recorded as its sub-agents and checked for cycles. A
+// cached instance is only returned to calls without build options.
+func (r *agentRegistry) getOrBuild(
+	ctx context.Context,
+	key instanceKey,
+	options []provider.AgentProviderOption,
+	configured bool,
+	build func(ctx context.Context) (shared.TheAgent, error),
+) (shared.TheAgent, error) {
+
+	parent, _ := ctx.Value(buildFrameKey{}).(*buildFrame)
+	for frame := parent; frame != nil; frame = frame.parent {
+		if frame.key.agentID == key.agentID {
+			return nil, fmt.Errorf("sub-agent cycle detected: %s -> %s",
+				strings.Join(parent.path(), " -> "), key.agentID)
+		}
+	}
+	if parent != nil {
+		parent.subAgents = append(parent.subAgents, key)
+	} else {
+		r.buildMu.Lock()
+		defer r.buildMu.Unlock()
+	}
+
+	r.mu.RLock()
+	entry, ok := r.entries[key]
+	r.mu.RUnlock()
+	if ok {
+		if configured {
+			return nil, fmt.Errorf("%w: agent %s with instance key %q, pass its options with another key",
+				ErrInstanceBuilt, key.agentID, key.key)
+		}
+		return entry.agent, nil
+	}
+
+	frame := &buildFrame{parent: parent, key: key}
+	theAgent, err := build(context.WithValue(ctx, buildFrameKey{}, frame))
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	r.entries[key] = &registryEntry{
+		agent:     theAgent,
+		options:   options,
+		subAgents: frame.subAgents,
+	}
+	r.mu.Unlock()
+
+	logger.Log.Debug("agent instance cached",
+		zap.String("agent_id", key.agentID.String()),
+		zap.String("instance_key", key.key),
+		zap.Int("sub_agents", len(frame.subAgents)),
+	)
+
+	return theAgent, nil
+}
+
+// evict removes all instances of the agent and, transitively, of the agents
+// using them as sub-agent. The evicted entries are closed and returned.
+func (r *agentRegistry) evict(agentID uuid.UUID) (map[instanceKey]*registryEntry, error) {
+	r.buildMu.Lock()
+	defer r.buildMu.Unlock()
+
+	r.mu.Lock()
+	evicted := make(map[instanceKey]*registryEntry)
+	for key, entry := range r.entries {
+		if key.agentID == agentID {
+			evicted[key] = entry
+		}
+	}
+
+	// Evict the dependents until no cached agent uses an evicted one
+	for changed := true; changed; {
+		changed = false
+		for key, entry := range r.entries {
+			if _, ok := evicted[key]; ok {
+				continue
+			}
+			for _, subAgent := range entry.subAgents {
+				if _, ok := evicted[subAgent]; ok {
+					evicted[key] = entry
+					changed = true
+					break
+				}
+			}
+		}
+	}
+
+	for key := range evicted {
+		delete(r.entries, key)
+	}
+	r.mu.Unlock()
+
+	return evicted, closeEntries(evicted)
+}
+
+// closeAll evicts and closes all cached agents
+func (r *agentRegistry) closeAll() error {
+	r.buildMu.Lock()
+	defer r.buildMu.Unlock()
+
+	r.mu.Lock()
+	evicted := r.entries
+	r.entries = make(map[instanceKey]*registryEntry)
+	r.mu.Unlock()
+
+	return closeEntries(evicted)
+}
+
+func closeEntries(entries map[instanceKey]*registryEntry) error {
+	var errs []error
+	for key, entry := range entries {
+		if err := shared.CloseAgent(entry.agent); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close agent %s: %w", key.agentID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,133 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/denkhaus/agents/provider"
+
+	"github.com/denkhaus/agents/shared"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"trpc.group/trpc-go/trpc-agent-go/agent/llmagent"
+	"trpc.group/trpc-go/trpc-agent-go/tool"
+)
+
+// closingToolSet records whether it was closed
+type closingToolSet struct {
+	closed bool
+}
+
+func (s *closingToolSet) Tools(ctx context.Context) []tool.CallableTool {
+	return nil
+}
+
+func (s *closingToolSet) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestAgentRegistry(t *testing.T) {
+	registry := newAgentRegistry()
+	parentID, childID, otherID := uuid.New(), uuid.New(), uuid.New()
+
+	builds := make(map[uuid.UUID]int)
+	var getAgent func(ctx context.Context, agentID uuid.UUID, subAgents ...uuid.UUID) (shared.TheAgent, error)
+	getAgent = func(ctx context.Context, agentID uuid.UUID, subAgents ...uuid.UUID) (shared.TheAgent, error) {
+		return registry.getOrBuild(ctx, instanceKey{agentID: agentID}, nil, false, func(ctx context.Context) (shared.TheAgent, error) {
+			builds[agentID]++
+			for _, subAgentID := range subAgents {
+				if _, err := getAgent(ctx, subAgentID); err != nil {
+					return nil, err
+				}
+			}
+			return shared.NewAgent(llmagent.New(agentID.String()), agentID, false), nil
+		})
+	}
+
+	parent, err := getAgent(context.Background(), parentID, childID)
+	require.NoError(t, err)
+	child, err := getAgent(context.Background(), childID)
+	require.NoError(t, err)
+	_, err = getAgent(context.Background(), otherID)
+	require.NoError(t, err)
+
+	// Instances are built once, shared sub-agents included
+	again, err := getAgent(context.Background(), parentID, childID)
+	require.NoError(t, err)
+	assert.Same(t, parent, again)
+	assert.Equal(t, map[uuid.UUID]int{parentID: 1, childID: 1, otherID: 1}, builds)
+	assert.Equal(t, []instanceKey{{agentID: childID}}, registry.entries[instanceKey{agentID: parentID}].subAgents)
+
+	// Evicting the child evicts the parent using it
+	evicted, err := registry.evict(childID)
+	require.NoError(t, err)
+	assert.Len(t, evicted, 2)
+	assert.Contains(t, registry.entries, instanceKey{agentID: otherID})
+
+	rebuilt, err := getAgent(context.Background(), childID)
+	require.NoError(t, err)
+	assert.NotSame(t, child, rebuilt)
+	assert.Equal(t, 2, builds[childID])
+
+	require.NoError(t, registry.closeAll())
+	assert.Empty(t, registry.entries)
+}
+
+func TestAgentRegistry_Cycle(t *testing.T) {
+	registry := newAgentRegistry()
+	firstID, secondID := uuid.New(), uuid.New()
+
+	var getAgent func(ctx context.Context, agentID, subAgentID uuid.UUID) (shared.TheAgent, error)
+	getAgent = func(ctx context.Context, agentID, subAgentID uuid.UUID) (shared.TheAgent, error) {
+		return registry.getOrBuild(ctx, instanceKey{agentID: agentID}, nil, false, func(ctx context.Context) (shared.TheAgent, error) {
+			if _, err := getAgent(ctx, subAgentID, agentID); err != nil {
+				return nil, err
+			}
+			return shared.NewAgent(llmagent.New(agentID.String()), agentID, false), nil
+		})
+	}
+
+	_, err := getAgent(context.Background(), firstID, secondID)
+	assert.ErrorContains(t, err, "sub-agent cycle detected: "+firstID.String()+" -> "+secondID.String()+" -> "+firstID.String())
+	assert.Empty(t, registry.entries)
+}
+
+func TestAgentRegistry_OptionsOfCachedInstance(t *testing.T) {
+	registry := newAgentRegistry()
+	agentID := uuid.New()
+	key := instanceKey{agentID: agentID}
+
+	build := func(ctx context.Context) (shared.TheAgent, error) {
+		return shared.NewAgent(llmagent.New(agentID.String()), agentID, false), nil
+	}
+
+	// A sub-agent resolved without options first
+	cached, err := registry.getOrBuild(context.Background(), key, nil, false, build)
+	require.NoError(t, err)
+
+	again, err := registry.getOrBuild(context.Background(), key, nil, false, build)
+	require.NoError(t, err)
+	assert.Same(t, cached, again)
+
+	// is not handed out to a call with build options
+	_, err = registry.getOrBuild(context.Background(), key, nil, true, build)
+	assert.ErrorIs(t, err, ErrInstanceBuilt)
+
+	_, err = registry.getOrBuild(context.Background(), instanceKey{agentID: agentID, key: "tools"}, nil, true, build)
+	assert.NoError(t, err)
+}
+
+func TestCloseOptionToolSets(t *testing.T) {
+	toolSet := &closingToolSet{}
+	var options provider.AgentProviderOptions
+	WithLLMAgentOptions(llmagent.WithToolSets([]tool.ToolSet{toolSet}))(&options)
+	WithInstanceKey("tools")(&options)
+
+	assert.True(t, hasBuildOptions(options))
+	assert.False(t, hasBuildOptions(provider.AgentProviderOptions{InstanceKey: "tools"}))
+
+	closeOptionToolSets(uuid.New(), options)
+	assert.True(t, toolSet.closed)
+}
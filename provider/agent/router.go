@@ -0,0 +1,419 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/denkhaus/agents/logger"
+	"github.com/denkhaus/agents/provider"
+	"github.com/denkhaus/agents/shared"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"trpc.group/trpc-go/trpc-agent-go/agent"
+	"trpc.group/trpc-go/trpc-agent-go/agent/llmagent"
+	"trpc.group/trpc-go/trpc-agent-go/event"
+	"trpc.group/trpc-go/trpc-agent-go/model"
+	"trpc.group/trpc-go/trpc-agent-go/session"
+	"trpc.group/trpc-go/trpc-agent-go/tool"
+)
+
+// RouteDecision is the sub-agent a message is delegated to
+type RouteDecision struct {
+	Agent      string  `json:"agent"`
+	Confidence float64 `json:"confidence"`
+	Reason     string  `json:"reason,omitempty"`
+	// Fallback is set if the classification was not confident enough
+	Fallback bool `json:"-"`
+}
+
+func (d RouteDecision) String() string {
+	decision := fmt.Sprintf("routing to %s (confidence %.2f)", d.Agent, d.Confidence)
+	if d.Fallback {
+		decision = fmt.Sprintf("falling back to %s (confidence %.2f)", d.Agent, d.Confidence)
+	}
+	if d.Reason != "" {
+		decision += ": " + d.Reason
+	}
+	return decision
+}
+
+// routeClassifier chooses the sub-agent handling the message of an invocation
+type routeClassifier interface {
+	classify(ctx context.Context, invocation *agent.Invocation) (RouteDecision, error)
+}
+
+// routerAgentImpl classifies each message and delegates it to a sub-agent,
+// the events of the sub-agent are passed on with their author
+type routerAgentImpl struct {
+	name          string
+	description   string
+	classifier    routeClassifier
+	subAgents     []agent.Agent
+	fallback      agent.Agent
+	minConfidence float64
+}
+
+func (a *routerAgentImpl) Run(ctx context.Context, invocation *agent.Invocation) (<-chan *event.Event, error) {
+	decision, err := a.classifier.classify(ctx, invocation)
+	if err != nil {
+		logger.Log.Warn("router failed to classify message, using fallback",
+			zap.String("router", a.name),
+			zap.Error(err),
+		)
+		decision = RouteDecision{Reason: err.Error()}
+	}
+
+	target, decision := a.route(decision)
+
+	delegated := *invocation
+	delegated.AgentName = target.Info().Name
+
+	events, err := target.Run(ctx, &delegated)
+	if err != nil {
+		return nil, fmt.Errorf("router %s failed to delegate to %s: %w", a.name, decision.Agent, err)
+	}
+
+	eventChan := make(chan *event.Event, cap(events)+1)
+	go func() {
+		defer close(eventChan)
+		// Drain the delegated invocation if the caller goes away
+		defer func() {
+			for range events {
+			}
+		}()
+
+		select {
+		case eventChan <- a.routeEvent(invocation, decision):
+		case <-ctx.Done():
+			return
+		}
+
+		for ev := range events {
+			if ev.Author == "" {
+				ev.Author = decision.Agent
+			}
+			select {
+			case eventChan <- ev:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return eventChan, nil
+}
+
+// route returns the sub-agent of the decision, or the fallback agent if the
+// decision names no sub-agent or is not confident enough
+func (a *routerAgentImpl) route(decision RouteDecision) (agent.Agent, RouteDecision) {
+	if decision.Confidence >= a.minConfidence {
+		for _, subAgent := range a.subAgents {
+			if subAgent.Info().Name == decision.Agent {
+				return subAgent, decision
+			}
+		}
+	}
+
+	decision.Agent = a.fallback.Info().Name
+	decision.Fallback = true
+	return a.fallback, decision
+}
+
+func (a *routerAgentImpl) routeEvent(invocation *agent.Invocation, decision RouteDecision) *event.Event {
+	return &event.Event{
+		Response: &model.Response{
+			Object:    shared.ObjectTypeAgentRoute,
+			Created:   time.Now().Unix(),
+			Timestamp: time.Now(),
+			IsPartial: true,
+			Choices: []model.Choice{{
+				Delta: model.Message{Role: model.RoleSystem, Content: decision.String()},
+			}},
+		},
+		InvocationID: invocation.InvocationID,
+		Author:       a.name,
+		ID:           uuid.NewString(),
+		Timestamp:    time.Now(),
+	}
+}
+
+func (a *routerAgentImpl) Info() agent.Info {
+	return agent.Info{Name: a.name, Description: a.description}
+}
+
+func (a *routerAgentImpl) Tools() []tool.Tool {
+	return []tool.Tool{}
+}
+
+func (a *routerAgentImpl) SubAgents() []agent.Agent {
+	return a.subAgents
+}
+
+func (a *routerAgentImpl) FindSubAgent(name string) agent.Agent {
+	for _, subAgent := range a.subAgents {
+		if subAgent.Info().Name == name {
+			return subAgent
+		}
+	}
+	if a.fallback.Info().Name == name {
+		return a.fallback
+	}
+	return nil
+}
+
+// newRouterAgent creates a router agent delegating to the sub-agents of the
+// configuration. The LLM classifier uses the model and the instruction of the
+// default options of the router.
+func newRouterAgent(
+	ctx context.Context,
+	p provider.AgentProvider,
+	agentID uuid.UUID,
+	agentConfig provider.AgentConfiguration,
+	providerOptions provider.AgentProviderOptions,
+) (agent.Agent, error) {
+	settings, err := agentConfig.GetRouter()
+	if err != nil {
+		return nil, err
+	}
+	if err := settings.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid router of agent %s: %w", agentID, err)
+	}
+
+	options, err := agentConfig.GetDefaultOptions(ctx, p, providerOptions.LLMOpt...)
+	if err != nil {
+		return nil, err
+	}
+
+	var llmOptions llmagent.Options
+	for _, o := range options {
+		o(&llmOptions)
+	}
+
+	if len(llmOptions.SubAgents) == 0 {
+		return nil, fmt.Errorf("router agent %s has no sub-agents", agentID)
+	}
+
+	router := &routerAgentImpl{
+		name:          agentConfig.GetName(),
+		description:   agentConfig.GetDescription(),
+		subAgents:     llmOptions.SubAgents,
+		fallback:      llmOptions.SubAgents[0],
+		minConfidence: settings.GetMinConfidence(),
+	}
+
+	routes := make([]resolvedRoute, 0, len(settings.Routes))
+	for _, route := range settings.Routes {
+		subAgent, err := findRouteAgent(llmOptions.SubAgents, route.Agent)
+		if err != nil {
+			return nil, fmt.Errorf("invalid route of router agent %s: %w", agentID, err)
+		}
+		routes = append(routes, resolvedRoute{RouterRoute: route, agent: subAgent})
+	}
+
+	if settings.Fallback != "" {
+		fallbackID, err := uuid.Parse(settings.Fallback)
+		if err != nil {
+			return nil, fmt.Errorf("router agent %s references invalid fallback %q: %w", agentID, settings.Fallback, err)
+		}
+		fallback, err := p.GetAgent(ctx, fallbackID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get fallback of router agent %s: %w", agentID, err)
+		}
+		router.fallback = fallback
+	}
+
+	switch settings.GetClassifier() {
+	case provider.RouterClassifierRules:
+		router.classifier = newRulesClassifier(routes)
+	default:
+		if llmOptions.Model == nil {
+			return nil, fmt.Errorf("router agent %s has no model to classify messages", agentID)
+		}
+		router.classifier = newLLMClassifier(llmOptions, routes)
+	}
+
+	return router, nil
+}
+
+// resolvedRoute is a route with its sub-agent
+type resolvedRoute struct {
+	provider.RouterRoute
+	agent agent.Agent
+}
+
+func findRouteAgent(subAgents []agent.Agent, ref string) (agent.Agent, error) {
+	agentID, err := uuid.Parse(ref)
+	if err != nil {
+		return nil, fmt.Errorf("route references invalid agent %q: %w", ref, err)
+	}
+	for _, subAgent := range subAgents {
+		if theAgent, ok := subAgent.(shared.TheAgent); ok && theAgent.ID() == agentID {
+			return subAgent, nil
+		}
+	}
+	return nil, fmt.Errorf("route to agent %s, which is no sub-agent", agentID)
+}
+
+// rulesClassifier takes the first route whose metadata and keywords match
+type rulesClassifier struct {
+	routes []resolvedRoute
+}
+
+func newRulesClassifier(routes []resolvedRoute) *rulesClassifier {
+	c := &rulesClassifier{}
+	for _, route := range routes {
+		if route.HasRules() {
+			c.routes = append(c.routes, route)
+		}
+	}
+	return c
+}
+
+func (c *rulesClassifier) classify(ctx context.Context, invocation *agent.Invocation) (RouteDecision, error) {
+	content := strings.ToLower(invocation.Message.Content)
+	for _, route := range c.routes {
+		if reason, ok := route.matches(content, invocation.Session); ok {
+			return RouteDecision{Agent: route.agent.Info().Name, Confidence: 1, Reason: reason}, nil
+		}
+	}
+	return RouteDecision{Reason: "no route matches"}, nil
+}
+
+// matches checks that all metadata equal the session state and, if the route
+// has keywords, that the lower case content contains one of them
+func (r resolvedRoute) matches(content string, sess *session.Session) (string, bool) {
+	for key, value := range r.Metadata {
+		if sess == nil || string(sess.State[key]) != value {
+			return "", false
+		}
+	}
+	if len(r.Keywords) == 0 {
+		return "metadata match", true
+	}
+
+	for _, keyword := range r.Keywords {
+		if strings.Contains(content, strings.ToLower(keyword)) {
+			return fmt.Sprintf("keyword %q", keyword), true
+		}
+	}
+	return "", false
+}
+
+// llmClassifier asks the model of the router for a decision, the answer is
+// constrained to routeSchema through structured output
+type llmClassifier struct {
+	model            model.Model
+	generationConfig model.GenerationConfig
+	structuredOutput *model.StructuredOutput
+	prompt           string
+}
+
+func newLLMClassifier(options llmagent.Options, routes []resolvedRoute) *llmClassifier {
+	descriptions := make(map[string]string, len(routes))
+	for _, route := range routes {
+		descriptions[route.agent.Info().Name] = route.Description
+	}
+
+	var names []string
+	var candidates strings.Builder
+	for _, subAgent := range options.SubAgents {
+		info := subAgent.Info()
+		description := descriptions[info.Name]
+		if description == "" {
+			description = info.Description
+		}
+		names = append(names, info.Name)
+		fmt.Fprintf(&candidates, "- %s: %s\n", info.Name, description)
+	}
+
+	var prompt strings.Builder
+	if options.Instruction != "" {
+		prompt.WriteString(options.Instruction)
+		prompt.WriteString("\n\n")
+	}
+	fmt.Fprintf(&prompt, "Choose the agent that should handle the user message. The agents are:\n%s\n", candidates.String())
+	prompt.WriteString("Answer with a JSON object naming the agent, your confidence between 0 and 1 and the reason of your choice.")
+
+	generationConfig := options.GenerationConfig
+	generationConfig.Stream = false
+
+	return &llmClassifier{
+		model:            options.Model,
+		generationConfig: generationConfig,
+		structuredOutput: &model.StructuredOutput{
+			Type: model.StructuredOutputJSONSchema,
+			JSONSchema: &model.JSONSchemaConfig{
+				Name:        "route_decision",
+				Description: "The agent that handles the user message",
+				Schema:      routeSchema(names),
+				Strict:      true,
+			},
+		},
+		prompt: prompt.String(),
+	}
+}
+
+// routeSchema is the output schema of the LLM classification. Strict schemas
+// require every property and no additional ones.
+func routeSchema(agentNames []string) map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"agent":      map[string]interface{}{"type": "string", "enum": agentNames},
+			"confidence": map[string]interface{}{"type": "number", "minimum": 0, "maximum": 1},
+			"reason":     map[string]interface{}{"type": "string"},
+		},
+		"required":             []string{"agent", "confidence", "reason"},
+		"additionalProperties": false,
+	}
+}
+
+func (c *llmClassifier) classify(ctx context.Context, invocation *agent.Invocation) (RouteDecision, error) {
+	responses, err := c.model.GenerateContent(ctx, &model.Request{
+		Messages: []model.Message{
+			model.NewSystemMessage(c.prompt),
+			model.NewUserMessage(invocation.Message.Content),
+		},
+		GenerationConfig: c.generationConfig,
+		StructuredOutput: c.structuredOutput,
+	})
+	if err != nil {
+		return RouteDecision{}, fmt.Errorf("failed to classify message: %w", err)
+	}
+
+	var content string
+	var errs []error
+	for response := range responses {
+		if response.Error != nil {
+			errs = append(errs, errors.New(response.Error.Message))
+			continue
+		}
+		if response.IsPartial || len(response.Choices) == 0 {
+			continue
+		}
+		content = response.Choices[0].Message.Content
+	}
+	if err := errors.Join(errs...); err != nil {
+		return RouteDecision{}, fmt.Errorf("failed to classify message: %w", err)
+	}
+
+	return parseRouteDecision(content)
+}
+
+// parseRouteDecision decodes the JSON object of an answer, which may be wrapped in a code block
+func parseRouteDecision(content string) (RouteDecision, error) {
+	start, end := strings.Index(content, "{"), strings.LastIndex(content, "}")
+	if start < 0 || end < start {
+		return RouteDecision{}, fmt.Errorf("classification %q contains no JSON object", content)
+	}
+
+	var decision RouteDecision
+	if err := json.Unmarshal([]byte(content[start:end+1]), &decision); err != nil {
+		return RouteDecision{}, fmt.Errorf("failed to decode classification: %w", err)
+	}
+	return decision, nil
+}
@@ -0,0 +1,194 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/denkhaus/agents/provider"
+	"github.com/denkhaus/agents/shared"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"trpc.group/trpc-go/trpc-agent-go/agent"
+	"trpc.group/trpc-go/trpc-agent-go/agent/llmagent"
+	"trpc.group/trpc-go/trpc-agent-go/model"
+	"trpc.group/trpc-go/trpc-agent-go/session"
+)
+
+// answeringModel answers every request with its answer and records the last request
+type answeringModel struct {
+	answer  string
+	request *model.Request
+}
+
+func (m *answeringModel) Info() model.Info {
+	return model.Info{Name: "classifier"}
+}
+
+func (m *answeringModel) GenerateContent(ctx context.Context, request *model.Request) (<-chan *model.Response, error) {
+	m.request = request
+
+	responseChan := make(chan *model.Response, 1)
+	responseChan <- &model.Response{
+		Done:    true,
+		Choices: []model.Choice{{Message: model.NewAssistantMessage(m.answer)}},
+	}
+	close(responseChan)
+
+	return responseChan, nil
+}
+
+func TestRouterSettings_Validate(t *testing.T) {
+	valid := provider.RouterSettings{
+		Classifier: provider.RouterClassifierRules,
+		Routes: []provider.RouterRoute{
+			{Agent: "550e8400-e29b-41d4-a716-446655440001", Keywords: []string{"bug"}},
+			{Agent: "550e8400-e29b-41d4-a716-446655440002"},
+		},
+	}
+	assert.NoError(t, valid.Validate())
+	assert.NoError(t, provider.RouterSettings{}.Validate())
+	assert.Equal(t, provider.RouterClassifierLLM, provider.RouterSettings{}.GetClassifier())
+	assert.Equal(t, provider.DefaultRouterMinConfidence, provider.RouterSettings{}.GetMinConfidence())
+
+	for name, tc := range map[string]struct {
+		settings provider.RouterSettings
+		err      string
+	}{
+		"classifier": {provider.RouterSettings{Classifier: "vote"}, "invalid router classifier vote"},
+		"confidence": {provider.RouterSettings{MinConfidence: 1.5}, "not between 0 and 1"},
+		"no agent":   {provider.RouterSettings{Routes: []provider.RouterRoute{{}}}, "route without agent"},
+		"twice": {provider.RouterSettings{
+			Routes: []provider.RouterRoute{{Agent: "coder"}, {Agent: "coder"}},
+		}, "declared twice"},
+		"no rules": {provider.RouterSettings{
+			Classifier: provider.RouterClassifierRules,
+			Routes:     []provider.RouterRoute{{Agent: "coder"}},
+		}, "no route with keywords or metadata"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.ErrorContains(t, tc.settings.Validate(), tc.err)
+		})
+	}
+}
+
+func TestRulesClassifier(t *testing.T) {
+	coder, ops := llmagent.New("coder"), llmagent.New("ops")
+	classifier := newRulesClassifier([]resolvedRoute{
+		{RouterRoute: provider.RouterRoute{Metadata: map[string]string{"channel": "pager"}}, agent: ops},
+		{RouterRoute: provider.RouterRoute{Keywords: []string{"Bug", "refactor"}}, agent: coder},
+		{RouterRoute: provider.RouterRoute{}, agent: ops},
+	})
+
+	decision, err := classifier.classify(context.Background(), &agent.Invocation{
+		Message: model.NewUserMessage("Fix the bug in the parser"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, RouteDecision{Agent: "coder", Confidence: 1, Reason: `keyword "Bug"`}, decision)
+
+	decision, _ = classifier.classify(context.Background(), &agent.Invocation{
+		Message: model.NewUserMessage("Fix the bug in the parser"),
+		Session: &session.Session{State: session.StateMap{"channel": []byte("pager")}},
+	})
+	assert.Equal(t, "ops", decision.Agent)
+
+	decision, _ = classifier.classify(context.Background(), &agent.Invocation{
+		Message: model.NewUserMessage("Write the release notes"),
+	})
+	assert.Equal(t, RouteDecision{Reason: "no route matches"}, decision)
+}
+
+func TestLLMClassifier(t *testing.T) {
+	classifierModel := &answeringModel{
+		answer: "```json\n{\"agent\": \"coder\", \"confidence\": 0.8, \"reason\": \"code change\"}\n```",
+	}
+	coder := llmagent.New("coder", llmagent.WithDescription("Writes code"))
+	ops := llmagent.New("ops", llmagent.WithDescription("Runs deployments"))
+
+	classifier := newLLMClassifier(llmagent.Options{
+		Model:       classifierModel,
+		Instruction: "You are the supervisor.",
+		SubAgents:   []agent.Agent{coder, ops},
+	}, []resolvedRoute{
+		{RouterRoute: provider.RouterRoute{Description: "Handles incidents and deployments"}, agent: ops},
+	})
+
+	decision, err := classifier.classify(context.Background(), &agent.Invocation{
+		Message: model.NewUserMessage("Add a flag to the CLI"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, RouteDecision{Agent: "coder", Confidence: 0.8, Reason: "code change"}, decision)
+
+	// The route description takes precedence over the description of the agent
+	prompt := classifierModel.request.Messages[0].Content
+	assert.Contains(t, prompt, "You are the supervisor.")
+	assert.Contains(t, prompt, "- coder: Writes code\n")
+	assert.Contains(t, prompt, "- ops: Handles incidents and deployments\n")
+
+	// The answer is constrained to the sub-agents through structured output
+	structuredOutput := classifierModel.request.StructuredOutput
+	require.NotNil(t, structuredOutput)
+	assert.Equal(t, model.StructuredOutputJSONSchema, structuredOutput.Type)
+	require.NotNil(t, structuredOutput.JSONSchema)
+	assert.True(t, structuredOutput.JSONSchema.Strict)
+	assert.Equal(t, []string{"coder", "ops"},
+		structuredOutput.JSONSchema.Schema["properties"].(map[string]interface{})["agent"].(map[string]interface{})["enum"])
+
+	classifierModel.answer = "I think the coder should do it"
+	_, err = classifier.classify(context.Background(), &agent.Invocation{
+		Message: model.NewUserMessage("Add a flag to the CLI"),
+	})
+	assert.ErrorContains(t, err, "contains no JSON object")
+}
+
+func TestRouterAgent_Route(t *testing.T) {
+	coder, general := llmagent.New("coder"), llmagent.New("general")
+	router := &routerAgentImpl{
+		name:          "supervisor",
+		subAgents:     []agent.Agent{coder, general},
+		fallback:      general,
+		minConfidence: 0.5,
+	}
+
+	target, decision := router.route(RouteDecision{Agent: "coder", Confidence: 0.9})
+	assert.Equal(t, coder, target)
+	assert.False(t, decision.Fallback)
+
+	target, decision = router.route(RouteDecision{Agent: "coder", Confidence: 0.3, Reason: "unsure"})
+	assert.Equal(t, general, target)
+	assert.Equal(t, RouteDecision{Agent: "general", Confidence: 0.3, Reason: "unsure", Fallback: true}, decision)
+	assert.Equal(t, "falling back to general (confidence 0.30): unsure", decision.String())
+
+	target, _ = router.route(RouteDecision{Agent: "unknown", Confidence: 1})
+	assert.Equal(t, general, target)
+
+	assert.True(t, shared.IsRouteResponse(router.routeEvent(&agent.Invocation{}, decision).Response))
+	assert.Equal(t, general, router.FindSubAgent("general"))
+}
+
+// fixedClassifier always takes the same decision
+type fixedClassifier RouteDecision
+
+func (c fixedClassifier) classify(ctx context.Context, invocation *agent.Invocation) (RouteDecision, error) {
+	return RouteDecision(c), nil
+}
+
+func TestRouterAgent_Cancel(t *testing.T) {
+	coder := &eventAgent{name: "coder", events: 100, done: make(chan struct{})}
+	router := &routerAgentImpl{
+		name:       "supervisor",
+		classifier: fixedClassifier{Agent: "coder", Confidence: 1},
+		subAgents:  []agent.Agent{coder},
+		fallback:   coder,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := router.Run(ctx, &agent.Invocation{Message: model.NewUserMessage("fix the build")})
+	require.NoError(t, err)
+
+	// The events of the sub-agent are drained once the caller goes away
+	assert.True(t, shared.IsRouteResponse((<-events).Response))
+	cancel()
+	for range events {
+	}
+	<-coder.done
+}
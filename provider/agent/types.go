@@ -0,0 +1,117 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/denkhaus/agents/provider"
+	"github.com/denkhaus/agents/shared"
+	"github.com/google/uuid"
+	"trpc.group/trpc-go/trpc-agent-go/agent"
+)
+
+// AgentConstructor builds an agent of a registered type. Sub-agents are
+// resolved through the provider p, so they are cached and checked for cycles.
+// The provider wraps the agent, it closes the toolsets passed in the options.
+type AgentConstructor func(
+	ctx context.Context,
+	p provider.AgentProvider,
+	agentID uuid.UUID,
+	agentConfig provider.AgentConfiguration,
+	options provider.AgentProviderOptions,
+) (agent.Agent, error)
+
+// TypeRegistry maps agent types to the constructors building them
+type TypeRegistry struct {
+	mu           sync.RWMutex
+	constructors map[shared.AgentType]AgentConstructor
+}
+
+// DefaultTypeRegistry is used by the agent provider. The built-in types are registered in it.
+var DefaultTypeRegistry = NewTypeRegistry()
+
+// NewTypeRegistry creates a registry holding the built-in agent types
+func NewTypeRegistry() *TypeRegistry {
+	return &TypeRegistry{
+		constructors: map[shared.AgentType]AgentConstructor{
+			shared.AgentTypeDefault:  newDefaultAgent,
+			shared.AgentTypeChain:    newChainAgent,
+			shared.AgentTypeCycle:    newCycleAgent,
+			shared.AgentTypeParallel: newParallelAgent,
+			shared.AgentTypeGraph:    newGraphAgent,
+			shared.AgentTypeRouter:   newRouterAgent,
+		},
+	}
+}
+
+// Register registers the constructor of an agent type and makes the type valid
+// for shared.AgentType.Validate
+func (r *TypeRegistry) Register(agentType shared.AgentType, description string, constructor AgentConstructor) error {
+	if constructor == nil {
+		return fmt.Errorf("constructor of agent type %s cannot be nil", agentType)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.constructors[agentType]; exists {
+		return fmt.Errorf("agent type %s is already registered", agentType)
+	}
+
+	// Types registered in another registry are already valid
+	if agentType.Validate() != nil {
+		if err := shared.RegisterAgentType(agentType, description); err != nil {
+			return err
+		}
+	}
+
+	r.constructors[agentType] = constructor
+	return nil
+}
+
+// MustRegister registers the constructor of an agent type and panics on error
+func (r *TypeRegistry) MustRegister(agentType shared.AgentType, description string, constructor AgentConstructor) {
+	if err := r.Register(agentType, description, constructor); err != nil {
+		panic(err.Error())
+	}
+}
+
+// Types returns all agent types with a constructor, sorted by name
+func (r *TypeRegistry) Types() []shared.AgentType {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	types := make([]shared.AgentType, 0, len(r.constructors))
+	for agentType := range r.constructors {
+		types = append(types, agentType)
+	}
+
+	sort.Slice(types, func(i, j int) bool {
+		return types[i] < types[j]
+	})
+	return types
+}
+
+func (r *TypeRegistry) constructor(agentType shared.AgentType) (AgentConstructor, error) {
+	r.mu.RLock()
+	constructor, exists := r.constructors[agentType]
+	r.mu.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("agent type %s has no registered constructor", agentType)
+	}
+
+	return constructor, nil
+}
+
+// RegisterType registers the constructor of an agent type in the default registry
+func RegisterType(agentType shared.AgentType, description string, constructor AgentConstructor) error {
+	return DefaultTypeRegistry.Register(agentType, description, constructor)
+}
+
+// MustRegisterType registers the constructor of an agent type in the default registry and panics on error
+func MustRegisterType(agentType shared.AgentType, description string, constructor AgentConstructor) {
+	DefaultTypeRegistry.MustRegister(agentType, description, constructor)
+}
@@ -0,0 +1,56 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/denkhaus/agents/provider"
+	"github.com/denkhaus/agents/shared"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"trpc.group/trpc-go/trpc-agent-go/agent"
+	"trpc.group/trpc-go/trpc-agent-go/agent/llmagent"
+)
+
+func TestTypeRegistry_Register(t *testing.T) {
+	registry := NewTypeRegistry()
+	reviewType := shared.AgentType("review-" + uuid.NewString())
+	constructor := func(ctx context.Context, p provider.AgentProvider, agentID uuid.UUID,
+		agentConfig provider.AgentConfiguration, options provider.AgentProviderOptions) (agent.Agent, error) {
+		return llmagent.New(agentConfig.GetName()), nil
+	}
+
+	assert.ErrorContains(t, reviewType.Validate(), "invalid agent type")
+	require.NoError(t, registry.Register(reviewType, "Reviews changes", constructor))
+	assert.NoError(t, reviewType.Validate())
+	assert.Equal(t, "Reviews changes", reviewType.Description())
+	assert.Contains(t, registry.Types(), reviewType)
+
+	_, err := registry.constructor(reviewType)
+	assert.NoError(t, err)
+
+	// A type registered by another registry only needs a constructor
+	other := NewTypeRegistry()
+	require.NoError(t, other.Register(reviewType, "", constructor))
+
+	assert.ErrorContains(t, registry.Register(reviewType, "", constructor), "already registered")
+	assert.ErrorContains(t, registry.Register(shared.AgentTypeChain, "", constructor), "already registered")
+	assert.ErrorContains(t, registry.Register("other", "", nil), "cannot be nil")
+
+	_, err = registry.constructor("unknown")
+	assert.ErrorContains(t, err, "has no registered constructor")
+}
+
+func TestRegisterAgentRole(t *testing.T) {
+	role := shared.AgentRole("reviewer-" + uuid.NewString())
+
+	assert.ErrorContains(t, role.Validate(), "invalid agent role")
+	require.NoError(t, shared.RegisterAgentRole(role, "Reviews changes"))
+	assert.NoError(t, role.Validate())
+	assert.Equal(t, "Reviews changes", role.Description())
+	assert.Contains(t, shared.AgentRoleNames(), role.String())
+
+	assert.ErrorContains(t, shared.RegisterAgentRole(shared.AgentRoleCoder, ""), "already registered")
+	assert.ErrorContains(t, shared.RegisterAgentRole("", ""), "cannot be empty")
+}
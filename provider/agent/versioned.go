@@ -0,0 +1,172 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/denkhaus/agents/logger"
+	"github.com/denkhaus/agents/provider"
+	"github.com/denkhaus/agents/provider/prompt"
+	"github.com/denkhaus/agents/shared"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"trpc.group/trpc-go/trpc-agent-go/agent"
+	"trpc.group/trpc-go/trpc-agent-go/event"
+	"trpc.group/trpc-go/trpc-agent-go/model"
+	"trpc.group/trpc-go/trpc-agent-go/tool"
+)
+
+// PromptVersionStateKey is the session state key the prompt version of an agent is recorded under
+func PromptVersionStateKey(agentID uuid.UUID) string {
+	return "prompt_version:" + agentID.String()
+}
+
+// PromptVersion returns the prompt version recorded for an agent in the state
+// delta of an event or in the state of a session
+func PromptVersion(state map[string][]byte, agentID uuid.UUID) (int, bool) {
+	value, ok := state[PromptVersionStateKey(agentID)]
+	if !ok {
+		return 0, false
+	}
+	version, err := strconv.Atoi(string(value))
+	return version, err == nil
+}
+
+// promptVersionedAgent holds an LLM agent per prompt version and runs each
+// invocation on the version the prompt selection picks for it
+type promptVersionedAgent struct {
+	agentID   uuid.UUID
+	selection provider.PromptSelection
+	variants  []provider.PromptVariant
+	agents    map[int]agent.Agent
+	// latest answers everything but Run, the versions only differ in their instruction
+	latest agent.Agent
+}
+
+func newPromptVersionedAgent(
+	agentID uuid.UUID,
+	selection provider.PromptSelection,
+	variants []provider.PromptVariant,
+	agents map[int]agent.Agent,
+) agent.Agent {
+	return &promptVersionedAgent{
+		agentID:   agentID,
+		selection: selection,
+		variants:  variants,
+		agents:    agents,
+		latest:    agents[variants[len(variants)-1].Version],
+	}
+}
+
+// Run selects the prompt version for the invocation and announces it with a
+// partial event. The version is recorded in the state delta of the first
+// complete event, the runner stores only those in the session.
+func (a *promptVersionedAgent) Run(ctx context.Context, invocation *agent.Invocation) (<-chan *event.Event, error) {
+	selection := a.selection
+	if invocation.Session != nil {
+		selection.SessionID = invocation.Session.ID
+	}
+
+	variant, err := prompt.SelectVariant(a.agentID, a.variants, selection)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Log.Info("prompt version selected",
+		zap.String("agent_id", a.agentID.String()),
+		zap.String("invocation_id", invocation.InvocationID),
+		zap.String("session_id", selection.SessionID),
+		zap.String("policy", string(selection.Policy)),
+		zap.Int("version", variant.Version),
+	)
+
+	events, err := a.agents[variant.Version].Run(ctx, invocation)
+	if err != nil {
+		return nil, err
+	}
+
+	eventChan := make(chan *event.Event, cap(events)+1)
+	go func() {
+		defer close(eventChan)
+		// Drain the invocation if the caller goes away
+		defer func() {
+			for range events {
+			}
+		}()
+
+		select {
+		case eventChan <- a.versionEvent(invocation, selection.Policy, variant.Version):
+		case <-ctx.Done():
+			return
+		}
+
+		recorded := false
+		for ev := range events {
+			if !recorded && ev != nil && ev.Response != nil && !ev.IsPartial {
+				a.recordVersion(ev, variant.Version)
+				recorded = true
+			}
+
+			select {
+			case eventChan <- ev:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return eventChan, nil
+}
+
+func (a *promptVersionedAgent) versionEvent(invocation *agent.Invocation, policy provider.PromptPolicy, version int) *event.Event {
+	if policy == "" {
+		policy = provider.PromptPolicyLatest
+	}
+
+	return &event.Event{
+		Response: &model.Response{
+			Object:    shared.ObjectTypePromptVersion,
+			Created:   time.Now().Unix(),
+			Timestamp: time.Now(),
+			IsPartial: true,
+			Choices: []model.Choice{{
+				Delta: model.Message{
+					Role:    model.RoleSystem,
+					Content: fmt.Sprintf("prompt version %d (%s)", version, policy),
+				},
+			}},
+		},
+		InvocationID: invocation.InvocationID,
+		Author:       a.Info().Name,
+		ID:           uuid.NewString(),
+		Timestamp:    time.Now(),
+	}
+}
+
+// recordVersion adds the prompt version to the state delta of an event
+func (a *promptVersionedAgent) recordVersion(ev *event.Event, version int) {
+	delta := make(map[string][]byte, len(ev.StateDelta)+1)
+	for key, value := range ev.StateDelta {
+		delta[key] = value
+	}
+	delta[PromptVersionStateKey(a.agentID)] = []byte(strconv.Itoa(version))
+	ev.StateDelta = delta
+}
+
+func (a *promptVersionedAgent) Tools() []tool.Tool {
+	return a.latest.Tools()
+}
+
+func (a *promptVersionedAgent) Info() agent.Info {
+	return a.latest.Info()
+}
+
+func (a *promptVersionedAgent) SubAgents() []agent.Agent {
+	return a.latest.SubAgents()
+}
+
+func (a *promptVersionedAgent) FindSubAgent(name string) agent.Agent {
+	return a.latest.FindSubAgent(name)
+}
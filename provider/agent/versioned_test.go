@@ -0,0 +1,180 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/denkhaus/agents/provider"
+	"github.com/denkhaus/agents/shared"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"trpc.group/trpc-go/trpc-agent-go/agent"
+	"trpc.group/trpc-go/trpc-agent-go/event"
+	"trpc.group/trpc-go/trpc-agent-go/model"
+	"trpc.group/trpc-go/trpc-agent-go/runner"
+	"trpc.group/trpc-go/trpc-agent-go/session"
+	"trpc.group/trpc-go/trpc-agent-go/session/inmemory"
+	"trpc.group/trpc-go/trpc-agent-go/tool"
+)
+
+// eventAgent answers every run with its events and records the context of the
+// last run, done is closed once all events were sent
+type eventAgent struct {
+	name   string
+	events int
+	ctx    context.Context
+	done   chan struct{}
+}
+
+func (a *eventAgent) Run(ctx context.Context, invocation *agent.Invocation) (<-chan *event.Event, error) {
+	a.ctx = ctx
+
+	events := make(chan *event.Event)
+	go func() {
+		defer close(events)
+		if a.done != nil {
+			defer close(a.done)
+		}
+		for i := 0; i < a.events; i++ {
+			events <- &event.Event{Author: a.name, ID: uuid.NewString()}
+		}
+	}()
+	return events, nil
+}
+
+func (a *eventAgent) Tools() []tool.Tool                   { return nil }
+func (a *eventAgent) Info() agent.Info                     { return agent.Info{Name: a.name} }
+func (a *eventAgent) SubAgents() []agent.Agent             { return nil }
+func (a *eventAgent) FindSubAgent(name string) agent.Agent { return nil }
+
+func collectEvents(t *testing.T, a agent.Agent, invocation *agent.Invocation) []*event.Event {
+	t.Helper()
+
+	events, err := a.Run(context.Background(), invocation)
+	require.NoError(t, err)
+
+	var collected []*event.Event
+	for ev := range events {
+		collected = append(collected, ev)
+	}
+	return collected
+}
+
+func TestPromptVersionedAgent_RecordsVersion(t *testing.T) {
+	agentID := uuid.New()
+
+	for name, tc := range map[string]struct {
+		selection provider.PromptSelection
+		variants  []provider.PromptVariant
+		version   int
+	}{
+		"latest": {
+			selection: provider.PromptSelection{},
+			variants:  []provider.PromptVariant{{Version: 3, Weight: 1}},
+			version:   3,
+		},
+		"pinned": {
+			selection: provider.PromptSelection{Policy: provider.PromptPolicyPinned, Version: 1},
+			variants:  []provider.PromptVariant{{Version: 1, Weight: 1}, {Version: 2, Weight: 1}},
+			version:   1,
+		},
+		"weighted": {
+			selection: provider.PromptSelection{Policy: provider.PromptPolicyWeighted},
+			variants:  []provider.PromptVariant{{Version: 1, Weight: 0}, {Version: 2, Weight: 1}},
+			version:   2,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			agents := make(map[int]agent.Agent, len(tc.variants))
+			for _, variant := range tc.variants {
+				agents[variant.Version] = &replyAgent{name: "coder", reply: func(string) string { return "done" }}
+			}
+			versioned := newPromptVersionedAgent(agentID, tc.selection, tc.variants, agents)
+
+			events := collectEvents(t, versioned, &agent.Invocation{
+				InvocationID: "invocation",
+				Session:      &session.Session{ID: "session"},
+			})
+			require.Len(t, events, 2)
+
+			announcement := events[0]
+			assert.True(t, shared.IsPromptVersionResponse(announcement.Response))
+			assert.True(t, announcement.IsPartial)
+			assert.Equal(t, "coder", announcement.Author)
+			assert.Equal(t, "invocation", announcement.InvocationID)
+
+			// The version is recorded on the complete event, partial events are not stored
+			version, ok := PromptVersion(events[1].StateDelta, agentID)
+			assert.True(t, ok)
+			assert.Equal(t, tc.version, version)
+
+			_, ok = PromptVersion(events[1].StateDelta, uuid.New())
+			assert.False(t, ok)
+		})
+	}
+}
+
+func TestPromptVersionedAgent_RunnerStoresVersion(t *testing.T) {
+	agentID := uuid.New()
+	coder := &replyAgent{name: "coder", reply: func(input string) string { return "fixed " + input }}
+	versioned := newPromptVersionedAgent(agentID,
+		provider.PromptSelection{Policy: provider.PromptPolicyPinned, Version: 2},
+		[]provider.PromptVariant{{Version: 1, Weight: 1}, {Version: 2, Weight: 1}},
+		map[int]agent.Agent{1: coder, 2: coder},
+	)
+
+	sessionService := inmemory.NewSessionService()
+	r := runner.NewRunner("agents", versioned, runner.WithSessionService(sessionService))
+
+	events, err := r.Run(context.Background(), "user", "session", model.NewUserMessage("the build"))
+	require.NoError(t, err)
+	for range events {
+	}
+
+	sess, err := sessionService.GetSession(context.Background(), session.Key{
+		AppName:   "agents",
+		UserID:    "user",
+		SessionID: "session",
+	})
+	require.NoError(t, err)
+	require.NotNil(t, sess)
+
+	version, ok := PromptVersion(sess.State, agentID)
+	assert.True(t, ok)
+	assert.Equal(t, 2, version)
+}
+
+func TestPromptVersionedAgent_Cancel(t *testing.T) {
+	coder := &eventAgent{name: "coder", events: 100, done: make(chan struct{})}
+	versioned := newPromptVersionedAgent(uuid.New(), provider.PromptSelection{},
+		[]provider.PromptVariant{{Version: 1, Weight: 1}},
+		map[int]agent.Agent{1: coder},
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := versioned.Run(ctx, &agent.Invocation{})
+	require.NoError(t, err)
+
+	// The events of the version agent are drained once the caller goes away
+	assert.True(t, shared.IsPromptVersionResponse((<-events).Response))
+	cancel()
+	for range events {
+	}
+	<-coder.done
+}
+
+func TestPromptVersion(t *testing.T) {
+	agentID := uuid.New()
+
+	version, ok := PromptVersion(session.StateMap{PromptVersionStateKey(agentID): []byte("4")}, agentID)
+	assert.True(t, ok)
+	assert.Equal(t, 4, version)
+
+	_, ok = PromptVersion(session.StateMap{PromptVersionStateKey(agentID): []byte("four")}, agentID)
+	assert.False(t, ok)
+
+	_, ok = PromptVersion(nil, agentID)
+	assert.False(t, ok)
+	assert.False(t, shared.IsPromptVersionResponse(&model.Response{Object: model.ObjectTypeChatCompletion}))
+}
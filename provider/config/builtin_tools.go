@@ -0,0 +1,160 @@
+package config
+
+import (
+	"time"
+
+	"github.com/denkhaus/agents/tools/calculator"
+	"github.com/denkhaus/agents/tools/fetch"
+	"github.com/denkhaus/agents/tools/project"
+	"github.com/denkhaus/agents/tools/shell"
+	"github.com/denkhaus/agents/tools/tavily"
+	timetools "github.com/denkhaus/agents/tools/time"
+	"trpc.group/trpc-go/trpc-agent-go/tool"
+	"trpc.group/trpc-go/trpc-agent-go/tool/duckduckgo"
+	"trpc.group/trpc-go/trpc-agent-go/tool/file"
+)
+
+const (
+	// DuckDuckGoToolName is the registry name of the duckduckgo search tool
+	DuckDuckGoToolName = "duckduckgo"
+	// FileToolSetName is the registry name of the file toolset
+	FileToolSetName = "file"
+)
+
+// register the built-in tools and toolsets in the default registry
+func init() {
+	MustRegisterTool(calculator.ToolName, ConfigSchema{}, newCalculatorTool)
+	MustRegisterTool(fetch.ToolName, ConfigSchema{}, newFetchTool)
+	MustRegisterTool(timetools.ToolName, ConfigSchema{}, newTimeTool)
+	MustRegisterTool(DuckDuckGoToolName, ConfigSchema{}, newDuckDuckGoTool)
+
+	MustRegisterToolSet(tavily.ToolSetName, ConfigSchema{
+		"api_key": {Type: ConfigTypeString, Description: "Tavily API key"},
+	}, newTavilyToolSet)
+	MustRegisterToolSet(project.ToolSetName, ConfigSchema{}, newProjectToolSet)
+	MustRegisterToolSet(shell.ToolSetName, ConfigSchema{
+		"base_dir":         {Type: ConfigTypeString, Description: "Directory the shell is confined to"},
+		"timeout":          {Type: ConfigTypeInt, Description: "Command timeout in seconds"},
+		"allowed_commands": {Type: ConfigTypeStringList, Description: "Commands the shell may execute"},
+		"execute_enabled":  {Type: ConfigTypeBool, Description: "Whether command execution is enabled", Default: true},
+	}, newShellToolSet)
+	MustRegisterToolSet(FileToolSetName, ConfigSchema{
+		"base_dir":      {Type: ConfigTypeString, Description: "Directory the file tools are confined to"},
+		"read_only":     {Type: ConfigTypeBool, Description: "Disable tools that modify files", Default: false},
+		"max_file_size": {Type: ConfigTypeInt, Description: "Maximum file size in bytes"},
+	}, newFileToolSet)
+}
+
+// getConfigValue safely extracts a typed value from a config map
+func getConfigValue(config map[string]interface{}, key string, defaultValue interface{}) interface{} {
+	if config == nil {
+		return defaultValue
+	}
+	if value, exists := config[key]; exists {
+		return value
+	}
+	return defaultValue
+}
+
+// Individual tool constructors
+func newCalculatorTool(config map[string]interface{}) (tool.Tool, error) {
+	return calculator.NewTool()
+}
+
+func newFetchTool(config map[string]interface{}) (tool.Tool, error) {
+	return fetch.NewTool()
+}
+
+func newTimeTool(config map[string]interface{}) (tool.Tool, error) {
+	return timetools.NewTool()
+}
+
+func newDuckDuckGoTool(config map[string]interface{}) (tool.Tool, error) {
+	return duckduckgo.NewTool(), nil
+}
+
+// Toolset constructors
+func newTavilyToolSet(config map[string]interface{}) (tool.ToolSet, error) {
+	var options []tavily.Option
+
+	if apiKey := getConfigValue(config, "api_key", ""); apiKey != "" {
+		if keyStr, ok := apiKey.(string); ok {
+			options = append(options, tavily.WithAPIKey(keyStr))
+		}
+	}
+
+	return tavily.NewToolSet(options...)
+}
+
+func newProjectToolSet(config map[string]interface{}) (tool.ToolSet, error) {
+	var options []project.Option
+	return project.NewToolSet(options...)
+}
+
+func newShellToolSet(config map[string]interface{}) (tool.ToolSet, error) {
+	var options []shell.Option
+
+	if baseDir := getConfigValue(config, "base_dir", ""); baseDir != "" {
+		if dirStr, ok := baseDir.(string); ok {
+			options = append(options, shell.WithBaseDir(dirStr))
+		}
+	}
+
+	// timeout is coerced to int by the toolset schema, however CUE decoded it
+	if timeout := getConfigValue(config, "timeout", 0); timeout != 0 {
+		if timeoutInt, ok := timeout.(int); ok {
+			options = append(options, shell.WithTimeout(time.Duration(timeoutInt)*time.Second))
+		}
+	}
+
+	// allowed_commands is coerced to []string by the toolset schema
+	if commands, ok := getConfigValue(config, "allowed_commands", nil).([]string); ok && len(commands) > 0 {
+		options = append(options, shell.WithAllowedCommands(commands))
+	}
+
+	executeEnabled := getConfigValue(config, "execute_enabled", true)
+	if enabled, ok := executeEnabled.(bool); ok {
+		options = append(options, shell.WithExecuteCommandEnabled(enabled))
+	}
+
+	return shell.NewToolSet(options...)
+}
+
+func newFileToolSet(config map[string]interface{}) (tool.ToolSet, error) {
+	var options []file.Option
+
+	if baseDir := getConfigValue(config, "base_dir", ""); baseDir != "" {
+		if dirStr, ok := baseDir.(string); ok {
+			options = append(options, file.WithBaseDir(dirStr))
+		}
+	}
+
+	readOnly := getConfigValue(config, "read_only", false)
+	if ro, ok := readOnly.(bool); ok && ro {
+		options = append(options,
+			file.WithListFileEnabled(true),
+			file.WithReadFileEnabled(true),
+			file.WithSearchFileEnabled(true),
+			file.WithSearchContentEnabled(true),
+			file.WithReplaceContentEnabled(false),
+			file.WithSaveFileEnabled(false),
+		)
+	} else {
+		options = append(options,
+			file.WithListFileEnabled(true),
+			file.WithReadFileEnabled(true),
+			file.WithSearchFileEnabled(true),
+			file.WithSearchContentEnabled(true),
+			file.WithReplaceContentEnabled(true),
+			file.WithSaveFileEnabled(true),
+		)
+	}
+
+	if maxSize := getConfigValue(config, "max_file_size", 0); maxSize != 0 {
+		if sizeInt, ok := maxSize.(int); ok {
+			options = append(options, file.WithMaxFileSize(int64(sizeInt)))
+		}
+	}
+
+	return file.NewToolSet(options...)
+}
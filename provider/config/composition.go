@@ -0,0 +1,86 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// Fields of an environment composition that reference other configuration files
+const (
+	// fieldExtends names the base environment an environment inherits its agents from
+	fieldExtends = "extends"
+	// fieldPromptVersion selects prompts/<version>/<agent>.cue
+	fieldPromptVersion = "prompt_version"
+	// fieldSettingsProfile selects settings/<profile>/<agent>.cue
+	fieldSettingsProfile = "settings_profile"
+	// fieldToolProfile selects tools/profiles/<profile>.cue
+	fieldToolProfile = "tool_profile"
+	// fieldPromptContent and fieldPromptGlobalInstruction are the prompt texts, taken verbatim
+	fieldPromptContent           = "content"
+	fieldPromptGlobalInstruction = "global_instruction"
+)
+
+// mergeMaps returns a deep copy of base with override applied on top.
+// Nested maps are merged recursively, all other values of override replace those of base.
+func mergeMaps(base, override map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(base)+len(override))
+	for key, value := range base {
+		merged[key] = value
+	}
+
+	for key, value := range override {
+		overrideMap, isMap := value.(map[string]interface{})
+		baseMap, baseIsMap := merged[key].(map[string]interface{})
+		if isMap && baseIsMap {
+			merged[key] = mergeMaps(baseMap, overrideMap)
+			continue
+		}
+		if isMap {
+			merged[key] = mergeMaps(nil, overrideMap)
+			continue
+		}
+		merged[key] = value
+	}
+
+	return merged
+}
+
+// DumpAgentConfigs renders fully resolved agent configurations as indented JSON
+// with stable key order, suitable for diffing environments. Resolved secrets are redacted.
+func DumpAgentConfigs(configs map[string]*AgentConfig) ([]byte, error) {
+	data, err := json.MarshalIndent(configs, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode agent configs: %w", err)
+	}
+	return data, nil
+}
+
+// AgentIndex maps the agent IDs of an environment to their composition names
+type AgentIndex map[uuid.UUID]string
+
+// NewAgentIndex indexes the agent configurations of an environment by agent ID.
+// It fails if two agents share an ID. Agents without an ID are not indexed.
+func NewAgentIndex(environment string, configs map[string]*AgentConfig) (AgentIndex, error) {
+	index := make(AgentIndex, len(configs))
+	for _, agentName := range sortedKeys(configs) {
+		agentID := configs[agentName].AgentID
+		if agentID == uuid.Nil {
+			continue
+		}
+
+		if existing, exists := index[agentID]; exists {
+			return nil, fmt.Errorf("duplicate agent ID %s in environment %s: used by %s and %s", agentID, environment, existing, agentName)
+		}
+		index[agentID] = agentName
+	}
+
+	return index, nil
+}
+
+// Lookup returns the composition name of the agent with the given ID
+func (i AgentIndex) Lookup(agentID uuid.UUID) (string, bool) {
+	agentName, exists := i[agentID]
+	return agentName, exists
+}
@@ -0,0 +1,475 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/denkhaus/agents/provider"
+	"github.com/denkhaus/agents/provider/prompt"
+	"github.com/denkhaus/agents/shared"
+	"github.com/denkhaus/agents/utils"
+	"github.com/xeipuuv/gojsonschema"
+	"trpc.group/trpc-go/trpc-agent-go/agent"
+	"trpc.group/trpc-go/trpc-agent-go/agent/chainagent"
+	"trpc.group/trpc-go/trpc-agent-go/agent/cycleagent"
+	"trpc.group/trpc-go/trpc-agent-go/agent/llmagent"
+	"trpc.group/trpc-go/trpc-agent-go/agent/parallelagent"
+	"trpc.group/trpc-go/trpc-agent-go/planner/react"
+	"trpc.group/trpc-go/trpc-agent-go/session"
+	"trpc.group/trpc-go/trpc-agent-go/tool"
+)
+
+// agentConfigurationImpl exposes a CUE agent composition as a provider.AgentConfiguration,
+// so agents can be built by the provider/agent stack from CUE configs
+type agentConfigurationImpl struct {
+	config           *AgentConfig
+	compositions     map[string]*AgentConfig
+	environment      string
+	settingsProvider provider.SettingsProvider
+	contextProvider  provider.PromptContextProvider
+	workspace        provider.Workspace
+}
+
+// AgentConfigurationOption configures an agent configuration
+type AgentConfigurationOption func(*agentConfigurationImpl)
+
+// WithContextProvider sets the contributors of the prompt render context and
+// the workspace they expose, prompt.NewDefaultContextProvider by default.
+// The workspace may be nil.
+func WithContextProvider(contextProvider provider.PromptContextProvider, workspace provider.Workspace) AgentConfigurationOption {
+	return func(p *agentConfigurationImpl) {
+		p.contextProvider = contextProvider
+		p.workspace = workspace
+	}
+}
+
+// NewAgentConfiguration adapts an agent composition to a provider.AgentConfiguration.
+// Sub-agent references are resolved within compositions, the agents of the same environment.
+func NewAgentConfiguration(
+	agentConfig *AgentConfig,
+	compositions map[string]*AgentConfig,
+	environment string,
+	settingsProvider provider.SettingsProvider,
+	opts ...AgentConfigurationOption,
+) provider.AgentConfiguration {
+	p := &agentConfigurationImpl{
+		config:           agentConfig,
+		compositions:     compositions,
+		environment:      environment,
+		settingsProvider: settingsProvider,
+		contextProvider:  prompt.NewDefaultContextProvider(),
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return p
+}
+
+func (p *agentConfigurationImpl) GetName() string {
+	return p.config.Name
+}
+
+func (p *agentConfigurationImpl) GetType() shared.AgentType {
+	return p.config.Type
+}
+
+func (p *agentConfigurationImpl) IsStreamingEnabled() bool {
+	return p.config.Settings.Agent.StreamingEnabled
+}
+
+func (p *agentConfigurationImpl) GetRole() shared.AgentRole {
+	return p.config.Settings.Agent.Role
+}
+
+func (p *agentConfigurationImpl) GetDescription() string {
+	return p.config.Description
+}
+
+func (p *agentConfigurationImpl) GetInputSchema() map[string]interface{} {
+	return p.config.Settings.Agent.InputSchema
+}
+
+func (p *agentConfigurationImpl) GetOutputSchema() map[string]interface{} {
+	return p.config.Settings.Agent.OutputSchema
+}
+
+func (p *agentConfigurationImpl) GetCapabilities() shared.AgentCapabilities {
+	return shared.AgentCapabilities{
+		Streaming: p.config.Settings.Agent.StreamingEnabled,
+		Planning:  p.config.Settings.Agent.PlanningEnabled,
+	}
+}
+
+// GetGraph returns the graph of the composition with the node agents resolved to their UUIDs
+func (p *agentConfigurationImpl) GetGraph() (provider.GraphSettings, error) {
+	graph := p.config.Settings.Agent.Graph
+	graph.Nodes = append([]provider.GraphNode(nil), graph.Nodes...)
+
+	for i, node := range graph.Nodes {
+		if node.Agent == "" {
+			continue
+		}
+		nodeConfig, err := resolveAgentRef(p.compositions, node.Agent)
+		if err != nil {
+			return provider.GraphSettings{}, fmt.Errorf("failed to resolve agent %q of graph node %s in environment %s: %w",
+				node.Agent, node.ID, p.environment, err)
+		}
+		graph.Nodes[i].Agent = nodeConfig.AgentID.String()
+	}
+
+	return graph, nil
+}
+
+// GetRouter returns the router of the composition with the agents resolved to their UUIDs
+func (p *agentConfigurationImpl) GetRouter() (provider.RouterSettings, error) {
+	router := p.config.Settings.Agent.Router
+	router.Routes = append([]provider.RouterRoute(nil), router.Routes...)
+
+	for i, route := range router.Routes {
+		routeConfig, err := resolveAgentRef(p.compositions, route.Agent)
+		if err != nil {
+			return provider.RouterSettings{}, fmt.Errorf("failed to resolve agent %q of router route in environment %s: %w",
+				route.Agent, p.environment, err)
+		}
+		router.Routes[i].Agent = routeConfig.AgentID.String()
+	}
+
+	if router.Fallback != "" {
+		fallbackConfig, err := resolveAgentRef(p.compositions, router.Fallback)
+		if err != nil {
+			return provider.RouterSettings{}, fmt.Errorf("failed to resolve router fallback %q in environment %s: %w",
+				router.Fallback, p.environment, err)
+		}
+		router.Fallback = fallbackConfig.AgentID.String()
+	}
+
+	return router, nil
+}
+
+// getSubAgents resolves the sub-agent references of the composition and
+// creates the sub-agents through the agent provider
+func (p *agentConfigurationImpl) getSubAgents(
+	ctx context.Context,
+	agentProvider provider.AgentProvider,
+) ([]agent.Agent, error) {
+
+	var subAgents []agent.Agent
+	for _, ref := range p.config.Settings.Agent.SubAgents {
+		subAgentConfig, err := resolveAgentRef(p.compositions, ref)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve sub-agent %q in environment %s: %w", ref, p.environment, err)
+		}
+
+		subAgent, err := agentProvider.GetAgent(ctx, subAgentConfig.AgentID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get agent with id %s: %w", subAgentConfig.AgentID, err)
+		}
+
+		subAgents = append(subAgents, subAgent)
+	}
+
+	return subAgents, nil
+}
+
+// getInstruction renders the prompt content as a template with the same
+// context the YAML prompt templates receive
+func (p *agentConfigurationImpl) getInstruction(ctx context.Context, options ...llmagent.Option) (string, error) {
+	promptContext, err := p.getPromptContext(ctx, nil, getToolsFromOptions(ctx, options...))
+	if err != nil {
+		return "", err
+	}
+
+	return renderPrompt(p.config.Name, p.config.Prompt, promptContext)
+}
+
+// getToolsFromOptions extracts the tools of the options and deduplicates them
+func getToolsFromOptions(ctx context.Context, options ...llmagent.Option) []tool.Tool {
+	var llmOptions llmagent.Options
+	for _, opt := range options {
+		opt(&llmOptions)
+	}
+
+	return utils.UniqueTools(ctx, llmOptions.Tools, llmOptions.ToolSets)
+}
+
+// getPromptContext prepares the data the prompt is rendered with, the session is nil outside of an invocation
+func (p *agentConfigurationImpl) getPromptContext(
+	ctx context.Context,
+	session *session.Session,
+	tools []tool.Tool,
+) (map[string]interface{}, error) {
+	//TODO: make the includeHumanAgent setting configurable
+	availableAgentsVal, err := p.settingsProvider.GetActiveAgents(true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get active agents: %w", err)
+	}
+
+	availableAgentsPtr := make([]*shared.AgentInfo, len(availableAgentsVal))
+	for i := range availableAgentsVal {
+		availableAgentsPtr[i] = &availableAgentsVal[i]
+	}
+
+	request := provider.PromptContextRequest{
+		AgentID:  p.config.AgentID,
+		Settings: p.config.Settings.Agent.PromptContext,
+		Session:  session,
+	}
+
+	if p.workspace != nil {
+		if request.Workspace, err = p.workspace.GetPath(); err != nil {
+			return nil, fmt.Errorf("failed to get workspace path: %w", err)
+		}
+		if session != nil {
+			if request.Scratch, err = p.workspace.GetScratchDir(session.ID); err != nil {
+				return nil, fmt.Errorf("failed to get scratch directory: %w", err)
+			}
+		}
+	}
+
+	promptContext, err := p.contextProvider.GetContext(ctx, prompt.SchemaKeys(p.config.Prompt.Schema), request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get prompt context: %w", err)
+	}
+
+	promptContext[shared.ContextKeyToolInfo] = utils.GetToolInfo(tools...)
+	promptContext[shared.ContextKeyAgentInfo] = utils.GetAgentInfoForAgent(p.config.AgentID, availableAgentsPtr...)
+	return promptContext, nil
+}
+
+func (p *agentConfigurationImpl) GetDefaultOptions(
+	ctx context.Context,
+	agentProvider provider.AgentProvider,
+	opt ...llmagent.Option,
+) ([]llmagent.Option, error) {
+
+	options := []llmagent.Option{}
+	options = append(options, opt...)
+
+	options = append(options, llmagent.WithGenerationConfig(generationConfig(p.config.Settings.Agent)))
+
+	instruction, err := p.getInstruction(ctx, options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get instruction prompt for agent [%s]-[%s]: %w", p.config.Name, p.config.AgentID, err)
+	}
+
+	options = append(options, llmagent.WithInstruction(instruction))
+	options = append(options, llmagent.WithGlobalInstruction(p.config.Prompt.GlobalInstruction))
+
+	model, err := newModel(p.config.Settings.Agent.LLM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get model for agent [%s]-[%s]: %w", p.config.Name, p.config.AgentID, err)
+	}
+
+	options = append(options, llmagent.WithModel(model))
+
+	if p.config.Settings.Agent.PlanningEnabled {
+		reactPlanner := react.New()
+		options = append(options, llmagent.WithPlanner(reactPlanner))
+	}
+
+	subAgents, err := p.getSubAgents(ctx, agentProvider)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get subagents for agent [%s]-[%s]: %w", p.config.Name, p.config.AgentID, err)
+	}
+
+	if len(subAgents) > 0 {
+		options = append(options, llmagent.WithSubAgents(subAgents))
+	}
+
+	if p.config.Settings.Agent.InputSchema != nil {
+		options = append(options, llmagent.WithInputSchema(p.config.Settings.Agent.InputSchema))
+	}
+
+	if p.config.Settings.Agent.OutputSchema != nil {
+		options = append(options, llmagent.WithOutputSchema(p.config.Settings.Agent.OutputSchema))
+	}
+
+	if p.config.Settings.Agent.OutputKey != "" {
+		options = append(options, llmagent.WithOutputKey(p.config.Settings.Agent.OutputKey))
+	}
+
+	options = append(options, llmagent.WithDescription(p.config.Description))
+
+	if p.config.Settings.Agent.ChannelBufferSize > 0 {
+		options = append(options, llmagent.WithChannelBufferSize(p.config.Settings.Agent.ChannelBufferSize))
+	}
+
+	return options, nil
+}
+
+func (p *agentConfigurationImpl) GetCycleOptions(
+	ctx context.Context,
+	agentProvider provider.AgentProvider,
+	opt ...cycleagent.Option,
+) ([]cycleagent.Option, error) {
+
+	options := []cycleagent.Option{}
+	options = append(options, opt...)
+
+	subAgents, err := p.getSubAgents(ctx, agentProvider)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get subagents for agent [%s]-[%s]: %w", p.config.Name, p.config.AgentID, err)
+	}
+
+	if len(subAgents) > 0 {
+		options = append(options, cycleagent.WithSubAgents(subAgents))
+	}
+
+	if p.config.Settings.Agent.MaxIterations > 0 {
+		options = append(options, cycleagent.WithMaxIterations(p.config.Settings.Agent.MaxIterations))
+	}
+
+	if p.config.Settings.Agent.ChannelBufferSize > 0 {
+		options = append(options, cycleagent.WithChannelBufferSize(p.config.Settings.Agent.ChannelBufferSize))
+	}
+
+	return options, nil
+}
+
+func (p *agentConfigurationImpl) GetChainOptions(
+	ctx context.Context,
+	agentProvider provider.AgentProvider,
+	opt ...chainagent.Option,
+) ([]chainagent.Option, error) {
+
+	options := []chainagent.Option{}
+	options = append(options, opt...)
+
+	subAgents, err := p.getSubAgents(ctx, agentProvider)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get subagents for agent [%s]-[%s]: %w", p.config.Name, p.config.AgentID, err)
+	}
+
+	if len(subAgents) > 0 {
+		options = append(options, chainagent.WithSubAgents(subAgents))
+	}
+
+	if p.config.Settings.Agent.ChannelBufferSize > 0 {
+		options = append(options, chainagent.WithChannelBufferSize(p.config.Settings.Agent.ChannelBufferSize))
+	}
+
+	return options, nil
+}
+
+func (p *agentConfigurationImpl) GetParallelOptions(
+	ctx context.Context,
+	agentProvider provider.AgentProvider,
+	opt ...parallelagent.Option,
+) ([]parallelagent.Option, error) {
+
+	options := []parallelagent.Option{}
+	options = append(options, opt...)
+
+	subAgents, err := p.getSubAgents(ctx, agentProvider)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get subagents for agent [%s]-[%s]: %w", p.config.Name, p.config.AgentID, err)
+	}
+
+	if len(subAgents) > 0 {
+		options = append(options, parallelagent.WithSubAgents(subAgents))
+	}
+
+	if p.config.Settings.Agent.ChannelBufferSize > 0 {
+		options = append(options, parallelagent.WithChannelBufferSize(p.config.Settings.Agent.ChannelBufferSize))
+	}
+
+	return options, nil
+}
+
+// GetPromptVariants returns no variants, a composition references a single
+// prompt and is versioned through its environment instead
+func (p *agentConfigurationImpl) GetPromptVariants(
+	ctx context.Context,
+	agentProvider provider.AgentProvider,
+	opt ...llmagent.Option,
+) ([]provider.PromptVariant, provider.PromptSelection, error) {
+	return nil, provider.PromptSelection{}, nil
+}
+
+// GetInstructionRenderer returns a renderer re-rendering the prompt with the
+// active agents and session state of each invocation. The tools are resolved
+// once, they are fixed for the lifetime of the agent.
+func (p *agentConfigurationImpl) GetInstructionRenderer(
+	ctx context.Context,
+	opt ...llmagent.Option,
+) (provider.InstructionRenderer, error) {
+	// A template that fails to parse is reported by the first render
+	timeDependent := false
+	if tpl, err := template.New(p.config.Name).Funcs(prompt.FuncMap()).Parse(p.config.Prompt.Content); err == nil {
+		timeDependent = prompt.UsesTimeFuncs(tpl)
+	}
+
+	return &instructionRendererImpl{
+		config:        p,
+		tools:         getToolsFromOptions(ctx, opt...),
+		timeDependent: timeDependent,
+		cache:         prompt.NewInstructionCache(),
+	}, nil
+}
+
+// instructionRendererImpl implements provider.InstructionRenderer, a
+// composition has a single prompt so the version is ignored
+type instructionRendererImpl struct {
+	config        *agentConfigurationImpl
+	tools         []tool.Tool
+	timeDependent bool
+	cache         *prompt.InstructionCache
+}
+
+func (r *instructionRendererImpl) Render(ctx context.Context, version int, session *session.Session) (string, error) {
+	promptContext, err := r.config.getPromptContext(ctx, session, r.tools)
+	if err != nil {
+		return "", err
+	}
+
+	render := func(data map[string]interface{}) (string, error) {
+		return renderPrompt(r.config.config.Name, r.config.config.Prompt, data)
+	}
+	if r.timeDependent {
+		return render(promptContext)
+	}
+	return r.cache.Render(0, promptContext, render)
+}
+
+// renderPrompt validates data against the prompt schema, if any, and renders
+// the prompt content as a text/template
+func renderPrompt(name string, promptConfig PromptConfig, data interface{}) (string, error) {
+	schemaDocument := promptConfig.Schema
+	if schemaDocument == nil {
+		schemaDocument = map[string]interface{}{}
+	}
+
+	schema, err := gojsonschema.NewSchema(gojsonschema.NewGoLoader(schemaDocument))
+	if err != nil {
+		return "", fmt.Errorf("invalid prompt schema: %w", err)
+	}
+
+	processedData, result, err := utils.ValidateJSON(data, schema)
+	if err != nil {
+		return "", fmt.Errorf("prompt data validation failed: %w", err)
+	}
+	if !result.Valid() {
+		var validationErrors []string
+		for _, desc := range result.Errors() {
+			validationErrors = append(validationErrors, fmt.Sprintf("- %s", desc))
+		}
+		return "", fmt.Errorf("prompt data validation failed: %s", strings.Join(validationErrors, "; "))
+	}
+
+	tpl, err := template.New(name).Funcs(prompt.FuncMap()).Parse(promptConfig.Content)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse prompt template: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, processedData); err != nil {
+		return "", fmt.Errorf("failed to render prompt: %w", err)
+	}
+
+	return buf.String(), nil
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -13,313 +14,480 @@ import (
 
 // cueConfigProviderImpl loads agent configurations from CUE files
 type cueConfigProviderImpl struct {
-	ctx        *cue.Context
-	configPath string
+	ctx          *cue.Context
+	configPath   string
+	toolRegistry *ToolRegistry
+	secrets      *SecretRegistry
+	mode         InterpolationMode
+	// secretVariables are the ${VAR} variables whose values are redacted
+	secretVariables []string
+	interpolator    *Interpolator
+}
+
+// CUEConfigProviderOption configures a CUE configuration provider
+type CUEConfigProviderOption func(*cueConfigProviderImpl)
+
+// WithInterpolationMode sets how unset variables without default are handled, strict by default
+func WithInterpolationMode(mode InterpolationMode) CUEConfigProviderOption {
+	return func(p *cueConfigProviderImpl) {
+		p.mode = mode
+	}
+}
+
+// WithSecretVariables declares ${VAR} variables as secret, strings containing
+// their values are redacted like secret references
+func WithSecretVariables(names ...string) CUEConfigProviderOption {
+	return func(p *cueConfigProviderImpl) {
+		p.secretVariables = append(p.secretVariables, names...)
+	}
+}
+
+// WithSecretRegistry sets the registry resolving secret references, DefaultSecretRegistry by default
+func WithSecretRegistry(secrets *SecretRegistry) CUEConfigProviderOption {
+	return func(p *cueConfigProviderImpl) {
+		p.secrets = secrets
+	}
+}
+
+// WithToolRegistry sets the registry whose config schemas are enforced, DefaultToolRegistry by default
+func WithToolRegistry(registry *ToolRegistry) CUEConfigProviderOption {
+	return func(p *cueConfigProviderImpl) {
+		p.toolRegistry = registry
+	}
 }
 
 // NewCUEConfigProvider creates a new CUE configuration provider
-func NewCUEConfigProvider(configPath string) ConfigProvider {
-	return &cueConfigProviderImpl{
-		ctx:        cuecontext.New(),
-		configPath: configPath,
+func NewCUEConfigProvider(configPath string, opts ...CUEConfigProviderOption) ConfigProvider {
+	p := &cueConfigProviderImpl{
+		ctx:          cuecontext.New(),
+		configPath:   configPath,
+		toolRegistry: DefaultToolRegistry,
+		secrets:      DefaultSecretRegistry,
+		mode:         InterpolationStrict,
 	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	p.interpolator = NewInterpolator(p.mode, p.secrets, p.secretVariables...)
+	return p
 }
 
 // LoadAgentComposition loads a complete agent configuration from environment
 func (p *cueConfigProviderImpl) LoadAgentComposition(environment, agentName string) (*AgentConfig, error) {
-	// Load environment-specific composition
-	envPath := filepath.Join(p.configPath, "compositions", "environments", fmt.Sprintf("%s.cue", environment))
+	rawAgents, err := p.loadRawAgents(environment, nil)
+	if err != nil {
+		return nil, err
+	}
 
-	if _, err := os.Stat(envPath); os.IsNotExist(err) {
-		return nil, fmt.Errorf("environment configuration not found: %s", environment)
+	rawAgent, exists := rawAgents[agentName]
+	if !exists {
+		return nil, fmt.Errorf("agent %s not found in environment %s", agentName, environment)
 	}
 
-	instances := load.Instances([]string{envPath}, &load.Config{
-		Dir: p.configPath,
-	})
+	return p.resolveAgentConfig(environment, agentName, rawAgent)
+}
 
-	if len(instances) == 0 {
-		return nil, fmt.Errorf("no CUE instances found for environment: %s", environment)
+// LoadAgentCompositions loads all agent configurations of an environment, keyed by agent name.
+// It fails if two agents of the environment share an agent ID.
+func (p *cueConfigProviderImpl) LoadAgentCompositions(environment string) (map[string]*AgentConfig, error) {
+	rawAgents, err := p.loadRawAgents(environment, nil)
+	if err != nil {
+		return nil, err
 	}
 
-	values, err := p.ctx.BuildInstances(instances)
-	if err != nil {
-		return nil, fmt.Errorf("failed to build instances: %w", err)
+	if len(rawAgents) == 0 {
+		return nil, fmt.Errorf("no agents found in environment %s", environment)
 	}
-	if len(values) == 0 {
-		return nil, fmt.Errorf("no CUE instances built")
+
+	configs := make(map[string]*AgentConfig, len(rawAgents))
+	for agentName, rawAgent := range rawAgents {
+		config, err := p.resolveAgentConfig(environment, agentName, rawAgent)
+		if err != nil {
+			return nil, err
+		}
+		configs[agentName] = config
 	}
-	value := values[0]
-	if value.Err() != nil {
-		return nil, fmt.Errorf("failed to build CUE instance: %w", value.Err())
+
+	// Agent IDs must be unique within an environment
+	if _, err := NewAgentIndex(environment, configs); err != nil {
+		return nil, err
 	}
 
-	// Extract specific agent configuration
-	agentPath := fmt.Sprintf("%s.agents.%s", environment, agentName)
-	agentValue := value.LookupPath(cue.ParsePath(agentPath))
+	return configs, nil
+}
 
-	if !agentValue.Exists() {
-		return nil, fmt.Errorf("agent %s not found in environment %s", agentName, environment)
-	}
+// ListEnvironments returns the names of all environment compositions
+func (p *cueConfigProviderImpl) ListEnvironments() ([]string, error) {
+	envDir := filepath.Join(p.configPath, "compositions", "environments")
 
-	// Decode the agent configuration
-	var config AgentConfig
-	if err := agentValue.Decode(&config); err != nil {
-		return nil, fmt.Errorf("failed to decode agent config: %w", err)
+	entries, err := os.ReadDir(envDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read environments directory: %w", err)
 	}
 
-	// Resolve environment variables in the configuration
-	if err := p.resolveEnvironmentVariables(&config); err != nil {
-		return nil, fmt.Errorf("failed to resolve environment variables: %w", err)
+	var environments []string
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".cue" {
+			continue
+		}
+		environments = append(environments, strings.TrimSuffix(entry.Name(), ".cue"))
 	}
 
-	return &config, nil
+	return environments, nil
 }
 
-// LoadPrompt loads a specific prompt configuration
-func (p *cueConfigProviderImpl) LoadPrompt(agentName, version string) (*PromptConfig, error) {
-	promptPath := filepath.Join(p.configPath, "prompts", version, fmt.Sprintf("%s.cue", agentName))
-
-	instances := load.Instances([]string{promptPath}, &load.Config{
-		Dir: p.configPath,
-	})
+// loadRawAgents returns the undecoded agent compositions of an environment with
+// the compositions of its base environments applied underneath. chain holds the
+// environments that extend this one and is used to detect inheritance cycles.
+func (p *cueConfigProviderImpl) loadRawAgents(environment string, chain []string) (map[string]map[string]interface{}, error) {
+	for i, extending := range chain {
+		if extending == environment {
+			cycle := append(append([]string{}, chain[i:]...), environment)
+			return nil, fmt.Errorf("environment inheritance cycle detected: %s", strings.Join(cycle, " -> "))
+		}
+	}
 
-	if len(instances) == 0 {
-		return nil, fmt.Errorf("no CUE instances found for prompt: %s/%s", version, agentName)
+	envPath := filepath.Join(p.configPath, "compositions", "environments", fmt.Sprintf("%s.cue", environment))
+	if _, err := os.Stat(envPath); os.IsNotExist(err) {
+		return nil, fmt.Errorf("environment configuration not found: %s", environment)
 	}
 
-	values, err := p.ctx.BuildInstances(instances)
+	value, err := p.loadFile(envPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to build instances: %w", err)
+		return nil, fmt.Errorf("environment %s: %w", environment, err)
 	}
-	if len(values) == 0 {
-		return nil, fmt.Errorf("no prompt CUE instances built")
+
+	envValue := value.LookupPath(cue.MakePath(cue.Str(environment)))
+	if !envValue.Exists() {
+		return nil, fmt.Errorf("environment %s is not defined in %s", environment, envPath)
 	}
-	value := values[0]
-	if value.Err() != nil {
-		return nil, fmt.Errorf("failed to build prompt CUE instance: %w", value.Err())
+
+	agents := make(map[string]map[string]interface{})
+
+	// Apply the base environment first, so this environment overrides it
+	if extendsValue := envValue.LookupPath(cue.MakePath(cue.Str(fieldExtends))); extendsValue.Exists() {
+		base, err := extendsValue.String()
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s in environment %s: %w", fieldExtends, environment, err)
+		}
+
+		agents, err = p.loadRawAgents(base, append(chain, environment))
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	// Extract prompt configuration
-	promptValue := value.LookupPath(cue.ParsePath(agentName))
-	if !promptValue.Exists() {
-		return nil, fmt.Errorf("prompt %s not found", agentName)
+	agentsValue := envValue.LookupPath(cue.MakePath(cue.Str("agents")))
+	if !agentsValue.Exists() {
+		return agents, nil
 	}
 
-	var prompt PromptConfig
-	if err := promptValue.Decode(&prompt); err != nil {
-		return nil, fmt.Errorf("failed to decode prompt config: %w", err)
+	var ownAgents map[string]interface{}
+	if err := agentsValue.Decode(&ownAgents); err != nil {
+		return nil, fmt.Errorf("failed to decode agents of environment %s: %w", environment, err)
 	}
 
-	return &prompt, nil
+	for agentName, rawAgent := range ownAgents {
+		agentMap, ok := rawAgent.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("agent %s in environment %s is not a struct", agentName, environment)
+		}
+		agents[agentName] = mergeMaps(agents[agentName], agentMap)
+	}
+
+	return agents, nil
 }
 
-// LoadSettings loads agent settings
-func (p *cueConfigProviderImpl) LoadSettings(agentName, profile string) (*SettingsConfig, error) {
-	settingsPath := filepath.Join(p.configPath, "settings", profile, fmt.Sprintf("%s.cue", agentName))
+// RequiredVariables lists every environment variable referenced by the agents of
+// an environment, including its base environments and referenced profiles
+func (p *cueConfigProviderImpl) RequiredVariables(environment string) ([]VariableRequirement, error) {
+	rawAgents, err := p.loadRawAgents(environment, nil)
+	if err != nil {
+		return nil, err
+	}
 
-	instances := load.Instances([]string{settingsPath}, &load.Config{
-		Dir: p.configPath,
-	})
+	collector := newVariableCollector()
+	for _, agentName := range sortedKeys(rawAgents) {
+		merged, err := p.applyProfiles(environment, agentName, rawAgents[agentName])
+		if err != nil {
+			return nil, err
+		}
+		detachPromptText(merged)
 
-	if len(instances) == 0 {
-		return nil, fmt.Errorf("no CUE instances found for settings: %s/%s", profile, agentName)
+		if err := collector.collect(merged, joinPath(environment, "agents", agentName)); err != nil {
+			return nil, err
+		}
 	}
 
-	values, err := p.ctx.BuildInstances(instances)
+	return collector.requirements(), nil
+}
+
+// resolveAgentConfig applies the referenced profiles, resolves all variables and
+// secret references and decodes the result
+func (p *cueConfigProviderImpl) resolveAgentConfig(environment, agentName string, rawAgent map[string]interface{}) (*AgentConfig, error) {
+	merged, err := p.applyProfiles(environment, agentName, rawAgent)
 	if err != nil {
-		return nil, fmt.Errorf("failed to build instances: %w", err)
+		return nil, err
 	}
-	if len(values) == 0 {
-		return nil, fmt.Errorf("no settings CUE instances built")
+
+	agentPath := joinPath(environment, "agents", agentName)
+
+	promptText := detachPromptText(merged)
+
+	var config AgentConfig
+	secretPaths, err := p.decodeResolved(merged, agentPath, &config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode agent config %s in environment %s: %w", agentName, environment, err)
 	}
-	value := values[0]
-	if value.Err() != nil {
-		return nil, fmt.Errorf("failed to build settings CUE instance: %w", value.Err())
+	config.secretPaths = secretPaths
+	config.Prompt.Content = promptText[fieldPromptContent]
+	config.Prompt.GlobalInstruction = promptText[fieldPromptGlobalInstruction]
+
+	// Enforce the config schemas of the referenced tools
+	toolsPath := joinPath(agentPath, "tools")
+	if err := p.toolRegistry.ApplySchemas(&config.Tools, toolsPath); err != nil {
+		return nil, fmt.Errorf("invalid tool config: %w", err)
 	}
 
-	// Extract settings configuration
-	settingsValue := value.LookupPath(cue.ParsePath(agentName))
-	if !settingsValue.Exists() {
-		return nil, fmt.Errorf("settings %s not found", agentName)
+	return &config, nil
+}
+
+// applyProfiles returns the raw composition with the referenced prompt, settings
+// and tool profiles applied underneath its inline values
+func (p *cueConfigProviderImpl) applyProfiles(environment, agentName string, rawAgent map[string]interface{}) (map[string]interface{}, error) {
+	profiles := make(map[string]interface{})
+
+	if version, _ := rawAgent[fieldPromptVersion].(string); version != "" {
+		prompt, err := p.loadRawPrompt(agentName, version)
+		if err != nil {
+			return nil, fmt.Errorf("agent %s in environment %s: %w", agentName, environment, err)
+		}
+		profiles["prompt"] = prompt
 	}
 
-	var settings SettingsConfig
-	if err := settingsValue.Decode(&settings); err != nil {
-		return nil, fmt.Errorf("failed to decode settings config: %w", err)
+	if profile, _ := rawAgent[fieldSettingsProfile].(string); profile != "" {
+		settings, err := p.loadRawSettings(agentName, profile)
+		if err != nil {
+			return nil, fmt.Errorf("agent %s in environment %s: %w", agentName, environment, err)
+		}
+		profiles["settings"] = settings
 	}
 
-	return &settings, nil
+	if profile, _ := rawAgent[fieldToolProfile].(string); profile != "" {
+		tools, err := p.loadRawToolProfile(profile)
+		if err != nil {
+			return nil, fmt.Errorf("agent %s in environment %s: %w", agentName, environment, err)
+		}
+		profiles["tools"] = tools
+	}
+
+	return mergeMaps(profiles, rawAgent), nil
 }
 
-// LoadToolProfile loads tool profile configuration
-func (p *cueConfigProviderImpl) LoadToolProfile(profileName string) (*ToolsConfig, error) {
-	toolsPath := filepath.Join(p.configPath, "tools", "profiles", fmt.Sprintf("%s.cue", profileName))
+// detachPromptText removes the prompt texts from a raw composition and returns
+// them by field. Prompts are taken verbatim, they may contain ${VAR} in shell
+// snippets, and are neither interpolated nor checked for required variables.
+func detachPromptText(raw map[string]interface{}) map[string]string {
+	prompt, _ := raw["prompt"].(map[string]interface{})
+
+	text := make(map[string]string, 2)
+	for _, field := range []string{fieldPromptContent, fieldPromptGlobalInstruction} {
+		if value, ok := prompt[field].(string); ok {
+			text[field] = value
+			delete(prompt, field)
+		}
+	}
+	return text
+}
 
-	instances := load.Instances([]string{toolsPath}, &load.Config{
+// decodeResolved resolves all variables and secret references of a raw
+// configuration map and decodes it into target. It returns the paths of the
+// resolved secrets.
+func (p *cueConfigProviderImpl) decodeResolved(raw map[string]interface{}, basePath string, target interface{}) ([]string, error) {
+	secretPaths, err := p.interpolator.ResolveMap(raw)
+	if err != nil {
+		return nil, withPathPrefix(basePath, err)
+	}
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s: %w", basePath, err)
+	}
+
+	if err := json.Unmarshal(data, target); err != nil {
+		return nil, err
+	}
+
+	return secretPaths, nil
+}
+
+// loadFile builds the CUE value of a single file
+func (p *cueConfigProviderImpl) loadFile(path string) (cue.Value, error) {
+	instances := load.Instances([]string{path}, &load.Config{
 		Dir: p.configPath,
 	})
 
 	if len(instances) == 0 {
-		return nil, fmt.Errorf("no CUE instances found for tool profile: %s", profileName)
+		return cue.Value{}, fmt.Errorf("no CUE instances found in %s", path)
 	}
 
 	values, err := p.ctx.BuildInstances(instances)
 	if err != nil {
-		return nil, fmt.Errorf("failed to build instances: %w", err)
+		return cue.Value{}, fmt.Errorf("failed to build instances: %w", err)
 	}
 	if len(values) == 0 {
-		return nil, fmt.Errorf("no tools CUE instances built")
+		return cue.Value{}, fmt.Errorf("no CUE instances built for %s", path)
 	}
 	value := values[0]
 	if value.Err() != nil {
-		return nil, fmt.Errorf("failed to build tools CUE instance: %w", value.Err())
+		return cue.Value{}, fmt.Errorf("failed to build CUE instance: %w", value.Err())
 	}
 
-	// Extract tools configuration
-	toolsValue := value.LookupPath(cue.ParsePath(profileName))
-	if !toolsValue.Exists() {
-		return nil, fmt.Errorf("tool profile %s not found", profileName)
-	}
+	return value, nil
+}
 
-	var tools ToolsConfig
-	if err := toolsValue.Decode(&tools); err != nil {
-		return nil, fmt.Errorf("failed to decode tools config: %w", err)
+// loadField builds a CUE file and returns the value of one of its top-level fields
+func (p *cueConfigProviderImpl) loadField(path, field, kind string) (cue.Value, error) {
+	value, err := p.loadFile(path)
+	if err != nil {
+		return cue.Value{}, fmt.Errorf("%s %s: %w", kind, field, err)
 	}
 
-	// Resolve environment variables in tool configurations
-	if err := p.resolveToolEnvironmentVariables(&tools); err != nil {
-		return nil, fmt.Errorf("failed to resolve tool environment variables: %w", err)
+	fieldValue := value.LookupPath(cue.MakePath(cue.Str(field)))
+	if !fieldValue.Exists() {
+		return cue.Value{}, fmt.Errorf("%s %s not found", kind, field)
 	}
 
-	return &tools, nil
+	return fieldValue, nil
 }
 
-// ValidateConfiguration validates all CUE configurations
-func (p *cueConfigProviderImpl) ValidateConfiguration() error {
-	// Load all CUE files and validate them
-	instances := load.Instances([]string{"./..."}, &load.Config{
-		Dir: p.configPath,
-	})
-
-	values, err := p.ctx.BuildInstances(instances)
+// loadRawField loads a top-level field of a CUE file as an undecoded map
+func (p *cueConfigProviderImpl) loadRawField(path, field, kind string) (map[string]interface{}, error) {
+	value, err := p.loadField(path, field, kind)
 	if err != nil {
-		return fmt.Errorf("failed to build instances: %w", err)
+		return nil, err
 	}
 
-	for _, instance := range values {
-		if instance.Err() != nil {
-			return fmt.Errorf("CUE validation failed: %w", instance.Err())
-		}
+	var raw map[string]interface{}
+	if err := value.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("failed to decode %s %s: %w", kind, field, err)
 	}
 
-	return nil
+	return raw, nil
 }
 
-// resolveEnvironmentVariables resolves environment variables in the agent configuration
-func (p *cueConfigProviderImpl) resolveEnvironmentVariables(config *AgentConfig) error {
-	return p.resolveToolEnvironmentVariables(&config.Tools)
+func (p *cueConfigProviderImpl) promptPath(agentName, version string) string {
+	return filepath.Join(p.configPath, "prompts", version, fmt.Sprintf("%s.cue", agentName))
 }
 
-// resolveToolEnvironmentVariables resolves environment variables in tool configurations
-func (p *cueConfigProviderImpl) resolveToolEnvironmentVariables(tools *ToolsConfig) error {
-	// Resolve environment variables in tool configs
-	for toolName, toolConfig := range tools.Tools {
-		if err := p.resolveConfigMap(toolConfig.Config); err != nil {
-			return fmt.Errorf("failed to resolve environment variables for tool %s: %w", toolName, err)
-		}
+func (p *cueConfigProviderImpl) settingsPath(agentName, profile string) string {
+	return filepath.Join(p.configPath, "settings", profile, fmt.Sprintf("%s.cue", agentName))
+}
+
+func (p *cueConfigProviderImpl) toolProfilePath(profileName string) string {
+	return filepath.Join(p.configPath, "tools", "profiles", fmt.Sprintf("%s.cue", profileName))
+}
+
+func (p *cueConfigProviderImpl) loadRawPrompt(agentName, version string) (map[string]interface{}, error) {
+	return p.loadRawField(p.promptPath(agentName, version), agentName, "prompt")
+}
+
+func (p *cueConfigProviderImpl) loadRawSettings(agentName, profile string) (map[string]interface{}, error) {
+	return p.loadRawField(p.settingsPath(agentName, profile), agentName, "settings")
+}
+
+func (p *cueConfigProviderImpl) loadRawToolProfile(profileName string) (map[string]interface{}, error) {
+	return p.loadRawField(p.toolProfilePath(profileName), profileName, "tool profile")
+}
+
+// LoadPrompt loads a specific prompt configuration
+func (p *cueConfigProviderImpl) LoadPrompt(agentName, version string) (*PromptConfig, error) {
+	promptValue, err := p.loadField(p.promptPath(agentName, version), agentName, "prompt")
+	if err != nil {
+		return nil, err
 	}
 
-	// Resolve environment variables in toolset configs
-	for toolSetName, toolSetConfig := range tools.ToolSets {
-		if err := p.resolveConfigMap(toolSetConfig.Config); err != nil {
-			return fmt.Errorf("failed to resolve environment variables for toolset %s: %w", toolSetName, err)
-		}
+	var prompt PromptConfig
+	if err := promptValue.Decode(&prompt); err != nil {
+		return nil, fmt.Errorf("failed to decode prompt config: %w", err)
 	}
 
-	return nil
+	return &prompt, nil
 }
 
-// resolveConfigMap resolves environment variables in a configuration map
-func (p *cueConfigProviderImpl) resolveConfigMap(config map[string]interface{}) error {
-	for key, value := range config {
-		resolved, err := p.resolveValue(value)
-		if err != nil {
-			return fmt.Errorf("failed to resolve config key %s: %w", key, err)
-		}
-		config[key] = resolved
+// LoadSettings loads agent settings
+func (p *cueConfigProviderImpl) LoadSettings(agentName, profile string) (*SettingsConfig, error) {
+	settingsValue, err := p.loadField(p.settingsPath(agentName, profile), agentName, "settings")
+	if err != nil {
+		return nil, err
 	}
-	return nil
-}
 
-// resolveValue resolves environment variables in a single value
-func (p *cueConfigProviderImpl) resolveValue(value interface{}) (interface{}, error) {
-	switch v := value.(type) {
-	case string:
-		return p.resolveStringValue(v)
-	case map[string]interface{}:
-		return p.resolveMapValue(v)
-	case []interface{}:
-		return p.resolveSliceValue(v)
-	default:
-		return value, nil
+	var settings SettingsConfig
+	if err := settingsValue.Decode(&settings); err != nil {
+		return nil, fmt.Errorf("failed to decode settings config: %w", err)
 	}
+
+	return &settings, nil
 }
 
-// resolveStringValue resolves environment variables in string values
-func (p *cueConfigProviderImpl) resolveStringValue(value string) (interface{}, error) {
-	if !strings.HasPrefix(value, "env:") {
-		return value, nil
+// LoadToolProfile loads tool profile configuration
+func (p *cueConfigProviderImpl) LoadToolProfile(profileName string) (*ToolsConfig, error) {
+	raw, err := p.loadRawToolProfile(profileName)
+	if err != nil {
+		return nil, err
 	}
 
-	// Parse env:VAR_NAME or env:VAR_NAME:default
-	parts := strings.SplitN(value, ":", 3)
-	if len(parts) < 2 {
-		return nil, fmt.Errorf("invalid environment variable format: %s", value)
+	var tools ToolsConfig
+	if _, err := p.decodeResolved(raw, profileName, &tools); err != nil {
+		return nil, fmt.Errorf("failed to decode tools config: %w", err)
 	}
 
-	envVarName := parts[1]
-	if envVarName == "" {
-		return nil, fmt.Errorf("empty environment variable name")
+	// Enforce the config schemas of the referenced tools
+	if err := p.toolRegistry.ApplySchemas(&tools, profileName); err != nil {
+		return nil, fmt.Errorf("invalid tool config: %w", err)
 	}
 
-	envValue := os.Getenv(envVarName)
+	return &tools, nil
+}
 
-	// If no value and we have a default
-	if envValue == "" && len(parts) == 3 {
-		return parts[2], nil // Return default value
+// ValidateConfiguration validates all CUE configurations and checks that the
+// tools and toolsets of every agent of every environment are registered
+func (p *cueConfigProviderImpl) ValidateConfiguration() error {
+	// Load all CUE files and validate them
+	instances := load.Instances([]string{"./..."}, &load.Config{
+		Dir: p.configPath,
+	})
+
+	values, err := p.ctx.BuildInstances(instances)
+	if err != nil {
+		return fmt.Errorf("failed to build instances: %w", err)
 	}
 
-	// If no value and no default
-	if envValue == "" {
-		return nil, fmt.Errorf("environment variable %s is not set", envVarName)
+	for _, instance := range values {
+		if instance.Err() != nil {
+			return fmt.Errorf("CUE validation failed: %w", instance.Err())
+		}
 	}
 
-	return envValue, nil
-}
+	environments, err := p.ListEnvironments()
+	if err != nil {
+		return fmt.Errorf("failed to list environments: %w", err)
+	}
 
-// resolveMapValue recursively resolves environment variables in a map
-func (p *cueConfigProviderImpl) resolveMapValue(value map[string]interface{}) (interface{}, error) {
-	resolved := make(map[string]interface{})
-	for key, val := range value {
-		resolvedVal, err := p.resolveValue(val)
+	for _, environment := range environments {
+		compositions, err := p.LoadAgentCompositions(environment)
 		if err != nil {
-			return nil, fmt.Errorf("failed to resolve map key %s: %w", key, err)
+			return fmt.Errorf("failed to load compositions for environment %s: %w", environment, err)
 		}
-		resolved[key] = resolvedVal
-	}
-	return resolved, nil
-}
 
-// resolveSliceValue recursively resolves environment variables in a slice
-func (p *cueConfigProviderImpl) resolveSliceValue(value []interface{}) (interface{}, error) {
-	resolved := make([]interface{}, len(value))
-	for i, val := range value {
-		resolvedVal, err := p.resolveValue(val)
-		if err != nil {
-			return nil, fmt.Errorf("failed to resolve slice index %d: %w", i, err)
+		for _, agentName := range sortedKeys(compositions) {
+			if err := p.toolRegistry.Validate(compositions[agentName].Tools); err != nil {
+				return fmt.Errorf("invalid tools for agent %s in environment %s: %w", agentName, environment, err)
+			}
 		}
-		resolved[i] = resolvedVal
 	}
-	return resolved, nil
+
+	return nil
 }
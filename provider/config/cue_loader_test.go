@@ -0,0 +1,126 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/denkhaus/agents/shared"
+	"github.com/denkhaus/agents/tools/shell"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"trpc.group/trpc-go/trpc-agent-go/tool"
+)
+
+const testConfigPath = "testdata/config"
+
+func TestCUEConfigProvider_LoadAgentComposition_Profiles(t *testing.T) {
+	provider := NewCUEConfigProvider(testConfigPath)
+
+	config, err := provider.LoadAgentComposition("production", "coder")
+	require.NoError(t, err)
+
+	assert.Equal(t, shared.AgentIDCoder, config.AgentID)
+	assert.Equal(t, "You write idiomatic Go code.", config.Prompt.Content)
+	assert.Equal(t, "gpt-4o", config.Settings.Agent.LLM.Model)
+	assert.Equal(t, 0.7, config.Settings.Agent.LLM.Temperature)
+	assert.True(t, config.Settings.Agent.StreamingEnabled)
+	assert.True(t, config.Tools.Tools["calculator"].Enabled)
+	assert.Equal(t, 30, config.Tools.ToolSets[shell.ToolSetName].Config["timeout"])
+}
+
+func TestCUEConfigProvider_LoadAgentComposition_Extends(t *testing.T) {
+	provider := NewCUEConfigProvider(testConfigPath)
+
+	configs, err := provider.LoadAgentCompositions("development")
+	require.NoError(t, err)
+	require.Contains(t, configs, "coder")
+	require.Contains(t, configs, "researcher")
+
+	coder := configs["coder"]
+	assert.Equal(t, "gpt-4o-mini", coder.Settings.Agent.LLM.Model)
+	assert.Equal(t, 0.7, coder.Settings.Agent.LLM.Temperature)
+	assert.Equal(t, "You write idiomatic Go code.", coder.Prompt.Content)
+
+	assert.Equal(t, "gpt-4o", configs["researcher"].Settings.Agent.LLM.Model)
+}
+
+func TestCUEConfigProvider_ExtendsCycle(t *testing.T) {
+	provider := NewCUEConfigProvider(testConfigPath)
+
+	_, err := provider.LoadAgentCompositions("staging")
+
+	assert.ErrorContains(t, err, "environment inheritance cycle detected: staging -> canary -> staging")
+}
+
+func TestDumpAgentConfigs(t *testing.T) {
+	provider := NewCUEConfigProvider(testConfigPath)
+
+	configs, err := provider.LoadAgentCompositions("development")
+	require.NoError(t, err)
+
+	data, err := DumpAgentConfigs(configs)
+	require.NoError(t, err)
+
+	var dumped map[string]AgentConfig
+	require.NoError(t, json.Unmarshal(data, &dumped))
+	assert.Equal(t, "gpt-4o-mini", dumped["coder"].Settings.Agent.LLM.Model)
+}
+
+func TestCUEConfigProvider_PromptTextVerbatim(t *testing.T) {
+	configPath := t.TempDir()
+	environments := filepath.Join(configPath, "compositions", "environments")
+	require.NoError(t, os.MkdirAll(environments, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(environments, "production.cue"), []byte(`production: agents: ops: {
+	agent_id: "550e8400-e29b-41d4-a716-446655440009"
+	name:     "ops"
+	prompt: {
+		global_instruction: "Never print ${TEST_PROMPT_UNSET}."
+		content:            "Clean up with rm -rf ${HOME}/.cache/build."
+	}
+	settings: agent: llm: {
+		model:    "${TEST_PROMPT_MODEL:-gpt-4o}"
+		provider: "openai"
+	}
+}
+`), 0o600))
+
+	// Strict mode fails on unset variables, but prompts are not interpolated
+	provider := NewCUEConfigProvider(configPath)
+	config, err := provider.LoadAgentComposition("production", "ops")
+	require.NoError(t, err)
+
+	assert.Equal(t, "Clean up with rm -rf ${HOME}/.cache/build.", config.Prompt.Content)
+	assert.Equal(t, "Never print ${TEST_PROMPT_UNSET}.", config.Prompt.GlobalInstruction)
+	assert.Equal(t, "gpt-4o", config.Settings.Agent.LLM.Model)
+
+	requirements, err := provider.RequiredVariables("production")
+	require.NoError(t, err)
+	require.Len(t, requirements, 1)
+	assert.Equal(t, "TEST_PROMPT_MODEL", requirements[0].Name)
+}
+
+func TestCUEConfigProvider_ValidateConfiguration_UnknownTool(t *testing.T) {
+	configPath := t.TempDir()
+	environments := filepath.Join(configPath, "compositions", "environments")
+	require.NoError(t, os.MkdirAll(environments, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(environments, "production.cue"), []byte(`production: agents: ops: {
+	agent_id: "550e8400-e29b-41d4-a716-446655440010"
+	name:     "ops"
+	prompt: content: "You keep the build green."
+	tools: tools: shovel: enabled: true
+}
+`), 0o600))
+
+	registry := NewToolRegistry()
+	provider := NewCUEConfigProvider(configPath, WithToolRegistry(registry))
+
+	// The settings provider validates through the config provider, without the agent factory
+	assert.ErrorContains(t, provider.ValidateConfiguration(), "unknown tool: shovel")
+
+	require.NoError(t, registry.RegisterTool("shovel", nil, func(config map[string]interface{}) (tool.Tool, error) {
+		return &fakeTool{name: "shovel"}, nil
+	}))
+	assert.NoError(t, provider.ValidateConfiguration())
+}
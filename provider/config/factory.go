@@ -3,8 +3,14 @@ package config
 import (
 	"context"
 	"fmt"
+	"strings"
 
+	"github.com/denkhaus/agents/logger"
+	"github.com/denkhaus/agents/provider"
+	"github.com/denkhaus/agents/provider/llm"
+	"github.com/denkhaus/agents/provider/workspace"
 	"github.com/denkhaus/agents/shared"
+	"github.com/denkhaus/agents/tools/shell"
 	"github.com/denkhaus/agents/utils"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
@@ -14,23 +20,69 @@ import (
 	"trpc.group/trpc-go/trpc-agent-go/agent/llmagent"
 	"trpc.group/trpc-go/trpc-agent-go/agent/parallelagent"
 	"trpc.group/trpc-go/trpc-agent-go/model"
-	"trpc.group/trpc-go/trpc-agent-go/model/openai"
 	"trpc.group/trpc-go/trpc-agent-go/planner/react"
 	"trpc.group/trpc-go/trpc-agent-go/tool"
 )
 
+// DefaultEnvironment is the environment used by CreateAgentByID unless WithDefaultEnvironment is given
+const DefaultEnvironment = "production"
+
 // UnifiedAgentFactory provides a unified implementation for creating agents
 type UnifiedAgentFactory struct {
-	configProvider ConfigProvider
-	toolFactory    ToolFactory
+	configProvider     ConfigProvider
+	toolFactory        ToolFactory
+	workspaceProvider  provider.WorkspaceProvider
+	defaultEnvironment string
+}
+
+// FactoryOption configures a UnifiedAgentFactory
+type FactoryOption func(*UnifiedAgentFactory)
+
+// WithDefaultEnvironment sets the environment CreateAgentByID looks agents up in
+func WithDefaultEnvironment(environment string) FactoryOption {
+	return func(f *UnifiedAgentFactory) {
+		f.defaultEnvironment = environment
+	}
+}
+
+// WithConfigProvider replaces the CUE configuration provider
+func WithConfigProvider(configProvider ConfigProvider) FactoryOption {
+	return func(f *UnifiedAgentFactory) {
+		f.configProvider = configProvider
+	}
+}
+
+// WithWorkspaces sets the provider of the workspaces the shell and file
+// toolsets are confined to, by default one with the root read from AGENTS_WORKSPACE_ROOT
+func WithWorkspaces(workspaceProvider provider.WorkspaceProvider) FactoryOption {
+	return func(f *UnifiedAgentFactory) {
+		f.workspaceProvider = workspaceProvider
+	}
 }
 
 // NewUnifiedAgentFactory creates a new unified agent factory
-func NewUnifiedAgentFactory(configPath string) AgentFactory {
-	return &UnifiedAgentFactory{
-		configProvider: NewCUEConfigProvider(configPath),
-		toolFactory:    NewCUEToolFactory(),
+func NewUnifiedAgentFactory(configPath string, opts ...FactoryOption) AgentFactory {
+	f := &UnifiedAgentFactory{
+		configProvider:     NewCUEConfigProvider(configPath),
+		toolFactory:        NewCUEToolFactory(),
+		defaultEnvironment: DefaultEnvironment,
+	}
+
+	for _, opt := range opts {
+		opt(f)
 	}
+
+	if f.workspaceProvider == nil {
+		workspaceProvider, err := workspace.NewFromEnv()
+		if err != nil {
+			logger.Log.Warn("failed to create workspace provider, agents with shell or file tools cannot be built",
+				zap.Error(err),
+			)
+		}
+		f.workspaceProvider = workspaceProvider
+	}
+
+	return f
 }
 
 // CreateAgent creates an agent using configuration
@@ -41,55 +93,260 @@ func (f *UnifiedAgentFactory) CreateAgent(ctx context.Context, environment, agen
 		return nil, fmt.Errorf("failed to load agent config: %w", err)
 	}
 
+	return f.buildAgent(ctx, newBuildScope(environment, nil), agentConfig)
+}
+
+// buildAgent creates an agent and, recursively, all of its sub-agents
+func (f *UnifiedAgentFactory) buildAgent(ctx context.Context, scope *buildScope, agentConfig *AgentConfig) (shared.TheAgent, error) {
+	scope, err := scope.enter(agentConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := llm.ValidateGenerationConfig(generationConfig(agentConfig.Settings.Agent)); err != nil {
+		return nil, fmt.Errorf("invalid generation settings for agent %s: %w", agentConfig.Name, err)
+	}
+
+	toolsConfig, err := f.workspaceToolsConfig(agentConfig)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create tools based on configuration
-	tools, toolsets, err := f.toolFactory.CreateTools(agentConfig.Tools)
+	tools, toolsets, err := f.toolFactory.CreateTools(toolsConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create tools: %w", err)
 	}
 
 	// Get all tools (including those from toolsets)
-	allTools := f.getAllTools(tools, toolsets)
+	allTools := f.getAllTools(ctx, tools, toolsets)
 
 	// Create the appropriate agent type based on configuration
 	var ag agent.Agent
 	switch agentConfig.Type {
-	case shared.AgentTypeDefault:
-		ag, err = f.createLLMAgent(ctx, agentConfig, allTools)
+	case "", shared.AgentTypeDefault:
+		ag, err = f.createLLMAgent(ctx, scope, agentConfig, allTools)
 	case shared.AgentTypeChain:
-		ag, err = f.createChainAgent(ctx, agentConfig)
+		ag, err = f.createChainAgent(ctx, scope, agentConfig)
 	case shared.AgentTypeCycle:
-		ag, err = f.createCycleAgent(ctx, agentConfig)
+		ag, err = f.createCycleAgent(ctx, scope, agentConfig)
 	case shared.AgentTypeParallel:
-		ag, err = f.createParallelAgent(ctx, agentConfig)
+		ag, err = f.createParallelAgent(ctx, scope, agentConfig)
+	case shared.AgentTypeGraph:
+		err = fmt.Errorf("graph agents are built by the agent provider, use NewSettingsProvider with provider/agent")
+	case shared.AgentTypeRouter:
+		err = fmt.Errorf("router agents are built by the agent provider, use NewSettingsProvider with provider/agent")
 	default:
-		// Default to LLM agent if type is not specified or unknown
-		ag, err = f.createLLMAgent(ctx, agentConfig, allTools)
+		// Custom types are registered with a constructor of the agent provider
+		err = fmt.Errorf("agent type %s cannot be built by the CUE factory, use NewSettingsProvider with provider/agent", agentConfig.Type)
 	}
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to create agent: %w", err)
+		closeToolSets(toolsets)
+		return nil, fmt.Errorf("failed to create agent %s: %w", agentConfig.Name, err)
 	}
 
+	// The agent owns its toolsets and closes them when it is torn down
 	return shared.NewAgent(
 		ag,
 		agentConfig.AgentID,
 		agentConfig.Settings.Agent.StreamingEnabled,
+		shared.WithOwnedToolSets(toolsets...),
+		shared.WithDescriptor(shared.AgentDescriptor{
+			Name:         agentConfig.Name,
+			Description:  agentConfig.Description,
+			Role:         agentConfig.Settings.Agent.Role,
+			Type:         agentConfig.Type,
+			InputSchema:  agentConfig.Settings.Agent.InputSchema,
+			OutputSchema: agentConfig.Settings.Agent.OutputSchema,
+			Capabilities: shared.AgentCapabilities{
+				Planning: agentConfig.Settings.Agent.PlanningEnabled,
+			},
+		}),
 	), nil
 }
 
-// CreateAgentByID creates an agent by its UUID using default environment
+// workspaceToolsConfig confines the shell and file toolsets without a base_dir
+// to the workspace of the agent, the isolated copy if the agent is isolated
+func (f *UnifiedAgentFactory) workspaceToolsConfig(agentConfig *AgentConfig) (ToolsConfig, error) {
+	toolsConfig := agentConfig.Tools
+
+	confined := false
+	for name, toolSetConfig := range toolsConfig.ToolSets {
+		if name == shell.ToolSetName || name == FileToolSetName {
+			_, hasBaseDir := toolSetConfig.Config["base_dir"]
+			confined = confined || !hasBaseDir
+		}
+	}
+	if !confined {
+		return toolsConfig, nil
+	}
+
+	if f.workspaceProvider == nil {
+		return ToolsConfig{}, fmt.Errorf("no workspace provider to confine the tools of agent %s", agentConfig.Name)
+	}
+
+	err := f.workspaceProvider.Configure(agentConfig.AgentID, agentConfig.Settings.Agent.Workspace)
+	if err != nil {
+		return ToolsConfig{}, fmt.Errorf("failed to configure workspace of agent %s: %w", agentConfig.Name, err)
+	}
+
+	agentWorkspace, err := f.workspaceProvider.GetWorkspace(agentConfig.AgentID)
+	if err != nil {
+		return ToolsConfig{}, fmt.Errorf("failed to get workspace of agent %s: %w", agentConfig.Name, err)
+	}
+
+	root, err := agentWorkspace.GetPath()
+	if err != nil {
+		return ToolsConfig{}, fmt.Errorf("failed to get workspace path of agent %s: %w", agentConfig.Name, err)
+	}
+
+	toolSets := make(map[string]ToolSetConfig, len(toolsConfig.ToolSets))
+	for name, toolSetConfig := range toolsConfig.ToolSets {
+		if name == shell.ToolSetName || name == FileToolSetName {
+			if _, ok := toolSetConfig.Config["base_dir"]; !ok {
+				config := make(map[string]interface{}, len(toolSetConfig.Config)+1)
+				for key, value := range toolSetConfig.Config {
+					config[key] = value
+				}
+				config["base_dir"] = root
+				toolSetConfig.Config = config
+			}
+		}
+		toolSets[name] = toolSetConfig
+	}
+
+	toolsConfig.ToolSets = toolSets
+	return toolsConfig, nil
+}
+
+// CreateAgentByID creates the agent with the given UUID from the default environment.
+// The agent IDs declared in the environment's compositions are the source of truth.
 func (f *UnifiedAgentFactory) CreateAgentByID(ctx context.Context, agentID uuid.UUID) (shared.TheAgent, error) {
-	agentName := getAgentNameFromID(agentID)
-	if agentName == "unknown" {
-		return nil, fmt.Errorf("unknown agent ID: %s", agentID)
+	environment := f.environment()
+
+	compositions, err := f.configProvider.LoadAgentCompositions(environment)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load compositions for environment %s: %w", environment, err)
+	}
+
+	index, err := NewAgentIndex(environment, compositions)
+	if err != nil {
+		return nil, err
 	}
 
-	return f.CreateAgent(ctx, "production", agentName)
+	agentName, exists := index.Lookup(agentID)
+	if !exists {
+		return nil, fmt.Errorf("unknown agent ID %s in environment %s", agentID, environment)
+	}
+
+	return f.buildAgent(ctx, newBuildScope(environment, compositions), compositions[agentName])
 }
 
-// ValidateConfiguration validates all configurations
+// DefaultEnvironment returns the environment used by CreateAgentByID
+func (f *UnifiedAgentFactory) DefaultEnvironment() string {
+	return f.environment()
+}
+
+func (f *UnifiedAgentFactory) environment() string {
+	if f.defaultEnvironment == "" {
+		return DefaultEnvironment
+	}
+	return f.defaultEnvironment
+}
+
+// ValidateConfiguration validates all configurations and rejects
+// compositions that reference unregistered tools or toolsets
 func (f *UnifiedAgentFactory) ValidateConfiguration() error {
-	return f.configProvider.ValidateConfiguration()
+	if err := f.configProvider.ValidateConfiguration(); err != nil {
+		return err
+	}
+
+	environments, err := f.configProvider.ListEnvironments()
+	if err != nil {
+		return fmt.Errorf("failed to list environments: %w", err)
+	}
+
+	for _, environment := range environments {
+		if err := f.validateEnvironment(environment); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ValidateEnvironment validates the configurations of a single environment, so
+// a broken environment does not block the others. Only the files the
+// environment loads are checked, ValidateConfiguration checks all of them.
+func (f *UnifiedAgentFactory) ValidateEnvironment(environment string) error {
+	return f.validateEnvironment(environment)
+}
+
+func (f *UnifiedAgentFactory) validateEnvironment(environment string) error {
+	compositions, err := f.configProvider.LoadAgentCompositions(environment)
+	if err != nil {
+		return fmt.Errorf("failed to load compositions for environment %s: %w", environment, err)
+	}
+
+	for agentName, agentConfig := range compositions {
+		if agentConfig.Type != "" {
+			if err := agentConfig.Type.Validate(); err != nil {
+				return fmt.Errorf("invalid type of agent %s in environment %s: %w", agentName, environment, err)
+			}
+		}
+
+		if agentConfig.Type == shared.AgentTypeGraph {
+			if err := agentConfig.Settings.Agent.Graph.Validate(); err != nil {
+				return fmt.Errorf("invalid graph of agent %s in environment %s: %w", agentName, environment, err)
+			}
+			for _, node := range agentConfig.Settings.Agent.Graph.Nodes {
+				if node.Agent == "" {
+					continue
+				}
+				if _, err := resolveAgentRef(compositions, node.Agent); err != nil {
+					return fmt.Errorf("invalid graph node %s of agent %s in environment %s: %w", node.ID, agentName, environment, err)
+				}
+			}
+		}
+
+		if agentConfig.Type == shared.AgentTypeRouter {
+			if err := validateRouter(compositions, agentConfig.Settings.Agent); err != nil {
+				return fmt.Errorf("invalid router of agent %s in environment %s: %w", agentName, environment, err)
+			}
+		}
+
+		if role := agentConfig.Settings.Agent.Role; role != "" {
+			if err := role.Validate(); err != nil {
+				return fmt.Errorf("invalid role of agent %s in environment %s: %w", agentName, environment, err)
+			}
+		}
+
+		if err := f.toolFactory.ValidateTools(agentConfig.Tools); err != nil {
+			return fmt.Errorf("invalid tools for agent %s in environment %s: %w", agentName, environment, err)
+		}
+
+		if err := llm.ValidateGenerationConfig(generationConfig(agentConfig.Settings.Agent)); err != nil {
+			return fmt.Errorf("invalid generation settings for agent %s in environment %s: %w", agentName, environment, err)
+		}
+
+		if err := agentConfig.Settings.Agent.PromptContext.Validate(); err != nil {
+			return fmt.Errorf("invalid prompt context settings for agent %s in environment %s: %w", agentName, environment, err)
+		}
+
+		if err := agentConfig.Settings.Agent.Workspace.Validate(); err != nil {
+			return fmt.Errorf("invalid workspace settings for agent %s in environment %s: %w", agentName, environment, err)
+		}
+
+		if agentConfig.Settings.Agent.LLM.Provider == "" {
+			continue
+		}
+		if err := llm.Validate(modelConfig(agentConfig.Settings.Agent.LLM)); err != nil {
+			return fmt.Errorf("invalid model for agent %s in environment %s: %w", agentName, environment, err)
+		}
+	}
+
+	return nil
 }
 
 // GetAgentConfig returns the raw configuration for an agent
@@ -97,76 +354,81 @@ func (f *UnifiedAgentFactory) GetAgentConfig(environment, agentName string) (*Ag
 	return f.configProvider.LoadAgentComposition(environment, agentName)
 }
 
-// getAgentNameFromID maps agent UUIDs to their names
-func getAgentNameFromID(agentID uuid.UUID) string {
-	agentMap := map[uuid.UUID]string{
-		shared.AgentIDCoder:          "coder",
-		shared.AgentIDProjectManager: "project-manager",
-		shared.AgentIDResearcher:     "researcher",
+// GetAgentConfigs returns the configurations of all agents of an environment, keyed by agent name
+func (f *UnifiedAgentFactory) GetAgentConfigs(environment string) (map[string]*AgentConfig, error) {
+	return f.configProvider.LoadAgentCompositions(environment)
+}
+
+// DumpConfiguration returns the fully resolved configurations of all agents of
+// an environment, with base environments and referenced profiles applied
+func (f *UnifiedAgentFactory) DumpConfiguration(environment string) ([]byte, error) {
+	compositions, err := f.configProvider.LoadAgentCompositions(environment)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load compositions for environment %s: %w", environment, err)
 	}
 
-	if name, exists := agentMap[agentID]; exists {
-		return name
+	return DumpAgentConfigs(compositions)
+}
+
+// RequiredVariables lists every environment variable the agents of an
+// environment reference, so deployments can check them before starting
+func (f *UnifiedAgentFactory) RequiredVariables(environment string) ([]VariableRequirement, error) {
+	variables, err := f.configProvider.RequiredVariables(environment)
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect variables for environment %s: %w", environment, err)
 	}
 
-	return "unknown"
+	return variables, nil
+}
+
+// getAllTools combines tools and tools from toolsets into a single slice,
+// skipping tools whose name is already registered
+func (f *UnifiedAgentFactory) getAllTools(ctx context.Context, tools []tool.Tool, toolsets []tool.ToolSet) []tool.Tool {
+	return utils.UniqueTools(ctx, tools, toolsets)
 }
 
-// getAllTools combines tools and tools from toolsets into a single slice
-func (f *UnifiedAgentFactory) getAllTools(tools []tool.Tool, toolsets []tool.ToolSet) []tool.Tool {
-	var allTools []tool.Tool
-	
-	// Add direct tools
-	allTools = append(allTools, tools...)
-	
-	// Add tools from toolsets
-	for _, _ = range toolsets {
-		// In a real implementation, you'd get the tools from the toolset
-		// This is a simplified version
+// closeToolSets closes toolsets of an agent that could not be created
+func closeToolSets(toolsets []tool.ToolSet) {
+	for _, toolset := range toolsets {
+		if err := toolset.Close(); err != nil {
+			logger.Log.Warn("closeToolSets: failed to close toolset", zap.Error(err))
+		}
 	}
-	
-	return allTools
 }
 
 // createLLMAgent creates an LLM agent with the provided configuration
-func (f *UnifiedAgentFactory) createLLMAgent(ctx context.Context, agentConfig *AgentConfig, tools []tool.Tool) (agent.Agent, error) {
+func (f *UnifiedAgentFactory) createLLMAgent(ctx context.Context, scope *buildScope, agentConfig *AgentConfig, tools []tool.Tool) (agent.Agent, error) {
 	options := []llmagent.Option{}
-	
+
 	// Add generation config
-	generationConfig := model.GenerationConfig{
-		MaxTokens:   utils.IntPtr(agentConfig.Settings.Agent.LLM.MaxTokens),
-		Temperature: utils.FloatPtr(agentConfig.Settings.Agent.LLM.Temperature),
-		Stream:      agentConfig.Settings.Agent.StreamingEnabled,
-	}
-	
-	options = append(options, llmagent.WithGenerationConfig(generationConfig))
-	
+	options = append(options, llmagent.WithGenerationConfig(generationConfig(agentConfig.Settings.Agent)))
+
 	// Add model
-	modelInstance, err := f.getModel(agentConfig)
+	modelInstance, err := newModel(agentConfig.Settings.Agent.LLM)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get model: %w", err)
 	}
 	options = append(options, llmagent.WithModel(modelInstance))
-	
+
 	// Add instruction
 	promptContent := agentConfig.Prompt.Content
 	if agentConfig.Prompt.GlobalInstruction != "" {
 		promptContent = agentConfig.Prompt.GlobalInstruction + "\n\n" + promptContent
 	}
 	options = append(options, llmagent.WithInstruction(promptContent))
-	
+
 	// Add global instruction
 	options = append(options, llmagent.WithGlobalInstruction(agentConfig.Prompt.GlobalInstruction))
-	
+
 	// Add planner if enabled
 	if agentConfig.Settings.Agent.PlanningEnabled {
 		reactPlanner := react.New()
 		options = append(options, llmagent.WithPlanner(reactPlanner))
 	}
-	
+
 	// Add sub-agents if any
 	if len(agentConfig.Settings.Agent.SubAgents) > 0 {
-		subAgents, err := f.getSubAgents(ctx, agentConfig.Settings.Agent.SubAgents)
+		subAgents, err := f.getSubAgents(ctx, scope, agentConfig.Settings.Agent.SubAgents)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get sub agents: %w", err)
 		}
@@ -174,40 +436,40 @@ func (f *UnifiedAgentFactory) createLLMAgent(ctx context.Context, agentConfig *A
 			options = append(options, llmagent.WithSubAgents(subAgents))
 		}
 	}
-	
+
 	// Add schemas and other configurations
 	if agentConfig.Settings.Agent.InputSchema != nil {
 		options = append(options, llmagent.WithInputSchema(agentConfig.Settings.Agent.InputSchema))
 	}
-	
+
 	if agentConfig.Settings.Agent.OutputSchema != nil {
 		options = append(options, llmagent.WithOutputSchema(agentConfig.Settings.Agent.OutputSchema))
 	}
-	
+
 	if agentConfig.Settings.Agent.OutputKey != "" {
 		options = append(options, llmagent.WithOutputKey(agentConfig.Settings.Agent.OutputKey))
 	}
-	
+
 	if agentConfig.Settings.Agent.ChannelBufferSize > 0 {
 		options = append(options, llmagent.WithChannelBufferSize(agentConfig.Settings.Agent.ChannelBufferSize))
 	}
-	
+
 	// Add tools
 	if len(tools) > 0 {
 		options = append(options, llmagent.WithTools(tools))
 	}
-	
+
 	// Create and return the LLM agent
 	return llmagent.New(agentConfig.Name, options...), nil
 }
 
 // createChainAgent creates a chain agent with the provided configuration
-func (f *UnifiedAgentFactory) createChainAgent(ctx context.Context, agentConfig *AgentConfig) (agent.Agent, error) {
+func (f *UnifiedAgentFactory) createChainAgent(ctx context.Context, scope *buildScope, agentConfig *AgentConfig) (agent.Agent, error) {
 	options := []chainagent.Option{}
-	
+
 	// Add sub-agents
 	if len(agentConfig.Settings.Agent.SubAgents) > 0 {
-		subAgents, err := f.getSubAgents(ctx, agentConfig.Settings.Agent.SubAgents)
+		subAgents, err := f.getSubAgents(ctx, scope, agentConfig.Settings.Agent.SubAgents)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get sub agents: %w", err)
 		}
@@ -215,23 +477,23 @@ func (f *UnifiedAgentFactory) createChainAgent(ctx context.Context, agentConfig
 			options = append(options, chainagent.WithSubAgents(subAgents))
 		}
 	}
-	
+
 	// Add channel buffer size if specified
 	if agentConfig.Settings.Agent.ChannelBufferSize > 0 {
 		options = append(options, chainagent.WithChannelBufferSize(agentConfig.Settings.Agent.ChannelBufferSize))
 	}
-	
+
 	// Create and return the chain agent
 	return chainagent.New(agentConfig.Name, options...), nil
 }
 
 // createCycleAgent creates a cycle agent with the provided configuration
-func (f *UnifiedAgentFactory) createCycleAgent(ctx context.Context, agentConfig *AgentConfig) (agent.Agent, error) {
+func (f *UnifiedAgentFactory) createCycleAgent(ctx context.Context, scope *buildScope, agentConfig *AgentConfig) (agent.Agent, error) {
 	options := []cycleagent.Option{}
-	
+
 	// Add sub-agents
 	if len(agentConfig.Settings.Agent.SubAgents) > 0 {
-		subAgents, err := f.getSubAgents(ctx, agentConfig.Settings.Agent.SubAgents)
+		subAgents, err := f.getSubAgents(ctx, scope, agentConfig.Settings.Agent.SubAgents)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get sub agents: %w", err)
 		}
@@ -239,28 +501,28 @@ func (f *UnifiedAgentFactory) createCycleAgent(ctx context.Context, agentConfig
 			options = append(options, cycleagent.WithSubAgents(subAgents))
 		}
 	}
-	
+
 	// Add max iterations if specified
 	if agentConfig.Settings.Agent.MaxIterations > 0 {
 		options = append(options, cycleagent.WithMaxIterations(agentConfig.Settings.Agent.MaxIterations))
 	}
-	
+
 	// Add channel buffer size if specified
 	if agentConfig.Settings.Agent.ChannelBufferSize > 0 {
 		options = append(options, cycleagent.WithChannelBufferSize(agentConfig.Settings.Agent.ChannelBufferSize))
 	}
-	
+
 	// Create and return the cycle agent
 	return cycleagent.New(agentConfig.Name, options...), nil
 }
 
 // createParallelAgent creates a parallel agent with the provided configuration
-func (f *UnifiedAgentFactory) createParallelAgent(ctx context.Context, agentConfig *AgentConfig) (agent.Agent, error) {
+func (f *UnifiedAgentFactory) createParallelAgent(ctx context.Context, scope *buildScope, agentConfig *AgentConfig) (agent.Agent, error) {
 	options := []parallelagent.Option{}
-	
+
 	// Add sub-agents
 	if len(agentConfig.Settings.Agent.SubAgents) > 0 {
-		subAgents, err := f.getSubAgents(ctx, agentConfig.Settings.Agent.SubAgents)
+		subAgents, err := f.getSubAgents(ctx, scope, agentConfig.Settings.Agent.SubAgents)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get sub agents: %w", err)
 		}
@@ -268,67 +530,223 @@ func (f *UnifiedAgentFactory) createParallelAgent(ctx context.Context, agentConf
 			options = append(options, parallelagent.WithSubAgents(subAgents))
 		}
 	}
-	
+
 	// Add channel buffer size if specified
 	if agentConfig.Settings.Agent.ChannelBufferSize > 0 {
 		options = append(options, parallelagent.WithChannelBufferSize(agentConfig.Settings.Agent.ChannelBufferSize))
 	}
-	
+
 	// Create and return the parallel agent
 	return parallelagent.New(agentConfig.Name, options...), nil
 }
 
-// getModel creates a model instance based on the configuration
-func (f *UnifiedAgentFactory) getModel(agentConfig *AgentConfig) (model.Model, error) {
-	switch agentConfig.Settings.Agent.LLM.Provider {
-	case shared.ModelProviderOpenAI:
-		modelOptions := []openai.Option{}
+// generationConfig creates the generation config of an LLM agent
+func generationConfig(settings AgentSettings) model.GenerationConfig {
+	return model.GenerationConfig{
+		MaxTokens:        utils.IntPtr(settings.LLM.MaxTokens),
+		Temperature:      utils.FloatPtr(settings.LLM.Temperature),
+		TopP:             settings.LLM.TopP,
+		FrequencyPenalty: settings.LLM.FrequencyPenalty,
+		PresencePenalty:  settings.LLM.PresencePenalty,
+		Stop:             settings.LLM.Stop,
+		ReasoningEffort:  settings.LLM.ReasoningEffort,
+		ThinkingEnabled:  settings.LLM.ThinkingEnabled,
+		ThinkingTokens:   settings.LLM.ThinkingTokens,
+		Stream:           settings.StreamingEnabled,
+	}
+}
 
-		if len(agentConfig.Settings.Agent.LLM.BaseURL) > 0 {
-			modelOptions = append(modelOptions,
-				openai.WithBaseURL(
-					agentConfig.Settings.Agent.LLM.BaseURL,
-				),
-			)
+// modelConfig converts the LLM settings into the configuration of the llm registry
+func modelConfig(settings LLMSettings) llm.Config {
+	config := llm.Config{
+		Provider:          settings.Provider,
+		Model:             settings.Model,
+		BaseURL:           settings.BaseURL,
+		APIKey:            settings.APIKey,
+		ChannelBufferSize: settings.ChannelBufferSize,
+		Options:           settings.Options,
+		Retry:             settings.Retry,
+		FailoverOn:        settings.FailoverOn,
+	}
+
+	for _, fallback := range settings.Fallbacks {
+		config.Fallbacks = append(config.Fallbacks, modelConfig(fallback))
+	}
+
+	return config
+}
+
+// newModel creates a model instance based on the LLM settings
+func newModel(settings LLMSettings) (model.Model, error) {
+	return llm.New(modelConfig(settings))
+}
+
+// getSubAgents creates sub-agent instances by resolving each reference,
+// either an agent UUID or an agent name, within the environment of the parent
+// agent and building it recursively with its own prompt, tools and model.
+// The sub-agents already built are closed if one of them fails.
+func (f *UnifiedAgentFactory) getSubAgents(ctx context.Context, scope *buildScope, subAgentRefs []string) ([]agent.Agent, error) {
+	compositions, err := scope.loadCompositions(f.configProvider)
+	if err != nil {
+		return nil, err
+	}
+
+	var subAgents []agent.Agent
+	for _, ref := range subAgentRefs {
+		subAgentConfig, err := resolveAgentRef(compositions, ref)
+		if err != nil {
+			closeSubAgents(subAgents)
+			return nil, fmt.Errorf("failed to resolve sub-agent %q in environment %s: %w", ref, scope.environment, err)
 		}
 
-		if len(agentConfig.Settings.Agent.LLM.APIKey) > 0 {
-			modelOptions = append(modelOptions,
-				openai.WithAPIKey(
-					agentConfig.Settings.Agent.LLM.APIKey,
-				),
-			)
+		subAgent, err := f.buildAgent(ctx, scope, subAgentConfig)
+		if err != nil {
+			closeSubAgents(subAgents)
+			return nil, fmt.Errorf("failed to create sub-agent %q: %w", ref, err)
 		}
 
-		if agentConfig.Settings.Agent.LLM.ChannelBufferSize > 0 {
-			modelOptions = append(modelOptions,
-				openai.WithChannelBufferSize(
-					agentConfig.Settings.Agent.LLM.ChannelBufferSize,
-				),
-			)
+		subAgents = append(subAgents, subAgent)
+	}
+
+	return subAgents, nil
+}
+
+// closeSubAgents closes sub-agents that are dropped because their parent cannot be built
+func closeSubAgents(agents []agent.Agent) {
+	for _, a := range agents {
+		if err := shared.CloseAgent(a); err != nil {
+			logger.Log.Warn("failed to close sub-agent", zap.String("agent", a.Info().Name), zap.Error(err))
 		}
+	}
+}
+
+// resolveAgentRef finds the agent configuration referenced by a UUID or a name
+func resolveAgentRef(compositions map[string]*AgentConfig, ref string) (*AgentConfig, error) {
+	if agentID, err := uuid.Parse(ref); err == nil {
+		for _, agentConfig := range compositions {
+			if agentConfig.AgentID == agentID {
+				return agentConfig, nil
+			}
+		}
+		return nil, fmt.Errorf("no agent with ID %s", agentID)
+	}
 
-		modelInstance := openai.New(agentConfig.Settings.Agent.LLM.Model, modelOptions...)
-		return modelInstance, nil
+	if agentConfig, exists := compositions[ref]; exists {
+		return agentConfig, nil
 	}
 
-	return nil, fmt.Errorf("model provider %s is unknown", agentConfig.Settings.Agent.LLM.Provider)
+	for _, agentConfig := range compositions {
+		if agentConfig.Name == ref {
+			return agentConfig, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no agent named %s", ref)
 }
 
-// getSubAgents creates sub-agent instances based on their UUIDs
-// Note: In a complete implementation, this would recursively create sub-agents
-func (f *UnifiedAgentFactory) getSubAgents(ctx context.Context, subAgentIDs []uuid.UUID) ([]agent.Agent, error) {
-	// This is a simplified implementation that returns empty agents
-	// In a real implementation, you would create the actual sub-agents
-	var subAgents []agent.Agent
-	for _, id := range subAgentIDs {
-		// Create a simple placeholder agent for now
-		subAgent := llmagent.New(fmt.Sprintf("sub-agent-%s", id.String()))
-		subAgents = append(subAgents, subAgent)
+// validateRouter checks the router and that its routes lead to sub-agents of the composition
+func validateRouter(compositions map[string]*AgentConfig, settings AgentSettings) error {
+	if err := settings.Router.Validate(); err != nil {
+		return err
 	}
-	
-	// Log that this is a simplified implementation
-	zap.L().Warn("getSubAgents: using simplified implementation - sub-agents are placeholders")
-	
-	return subAgents, nil
+	if len(settings.SubAgents) == 0 {
+		return fmt.Errorf("router has no sub-agents")
+	}
+
+	isSubAgent := make(map[uuid.UUID]bool, len(settings.SubAgents))
+	for _, ref := range settings.SubAgents {
+		subAgentConfig, err := resolveAgentRef(compositions, ref)
+		if err != nil {
+			return fmt.Errorf("invalid sub-agent %q: %w", ref, err)
+		}
+		isSubAgent[subAgentConfig.AgentID] = true
+	}
+
+	for _, route := range settings.Router.Routes {
+		routeConfig, err := resolveAgentRef(compositions, route.Agent)
+		if err != nil {
+			return fmt.Errorf("invalid route: %w", err)
+		}
+		if !isSubAgent[routeConfig.AgentID] {
+			return fmt.Errorf("route to agent %s, which is no sub-agent", route.Agent)
+		}
+	}
+
+	if settings.Router.Fallback != "" {
+		if _, err := resolveAgentRef(compositions, settings.Router.Fallback); err != nil {
+			return fmt.Errorf("invalid fallback: %w", err)
+		}
+	}
+	return nil
+}
+
+// buildScope tracks the environment and the chain of agents currently under
+// construction, so that sub-agent cycles can be detected.
+type buildScope struct {
+	environment string
+	path        []*AgentConfig
+	// build is shared by the scopes of all agents of one build
+	build *buildState
+}
+
+// buildState holds the compositions of the environment, they are loaded once per build
+type buildState struct {
+	compositions map[string]*AgentConfig
+}
+
+// newBuildScope returns the scope of a build, compositions may be nil if they are not loaded yet
+func newBuildScope(environment string, compositions map[string]*AgentConfig) *buildScope {
+	return &buildScope{
+		environment: environment,
+		build:       &buildState{compositions: compositions},
+	}
+}
+
+// loadCompositions returns the compositions of the environment, loading them on first use
+func (s *buildScope) loadCompositions(configProvider ConfigProvider) (map[string]*AgentConfig, error) {
+	if s.build.compositions != nil {
+		return s.build.compositions, nil
+	}
+
+	compositions, err := configProvider.LoadAgentCompositions(s.environment)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load compositions for environment %s: %w", s.environment, err)
+	}
+
+	s.build.compositions = compositions
+	return compositions, nil
+}
+
+// enter returns a new scope with the given agent appended to the construction
+// path, or an error if the agent is already being constructed.
+func (s *buildScope) enter(agentConfig *AgentConfig) (*buildScope, error) {
+	for i, ancestor := range s.path {
+		if isSameAgent(ancestor, agentConfig) {
+			names := make([]string, 0, len(s.path)-i+1)
+			for _, a := range s.path[i:] {
+				names = append(names, a.Name)
+			}
+			names = append(names, agentConfig.Name)
+
+			return nil, fmt.Errorf("sub-agent cycle detected in environment %s: %s",
+				s.environment, strings.Join(names, " -> "))
+		}
+	}
+
+	path := make([]*AgentConfig, len(s.path), len(s.path)+1)
+	copy(path, s.path)
+
+	return &buildScope{
+		environment: s.environment,
+		path:        append(path, agentConfig),
+		build:       s.build,
+	}, nil
+}
+
+// isSameAgent reports whether two configurations describe the same agent
+func isSameAgent(a, b *AgentConfig) bool {
+	if a.AgentID != uuid.Nil || b.AgentID != uuid.Nil {
+		return a.AgentID == b.AgentID
+	}
+	return a.Name == b.Name
 }
@@ -2,12 +2,19 @@ package config
 
 import (
 	"context"
+	"errors"
+	"os/exec"
+	"path/filepath"
 	"testing"
 
+	"github.com/denkhaus/agents/provider"
+	"github.com/denkhaus/agents/provider/workspace"
 	"github.com/denkhaus/agents/shared"
+	"github.com/denkhaus/agents/tools/shell"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	"trpc.group/trpc-go/trpc-agent-go/tool"
 )
 
@@ -21,6 +28,21 @@ func (m *mockConfigProvider) LoadAgentComposition(environment, agentName string)
 	return args.Get(0).(*AgentConfig), args.Error(1)
 }
 
+func (m *mockConfigProvider) LoadAgentCompositions(environment string) (map[string]*AgentConfig, error) {
+	args := m.Called(environment)
+	return args.Get(0).(map[string]*AgentConfig), args.Error(1)
+}
+
+func (m *mockConfigProvider) ListEnvironments() ([]string, error) {
+	args := m.Called()
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *mockConfigProvider) RequiredVariables(environment string) ([]VariableRequirement, error) {
+	args := m.Called(environment)
+	return args.Get(0).([]VariableRequirement), args.Error(1)
+}
+
 func (m *mockConfigProvider) LoadPrompt(agentName, version string) (*PromptConfig, error) {
 	args := m.Called(agentName, version)
 	return args.Get(0).(*PromptConfig), args.Error(1)
@@ -50,6 +72,39 @@ func (m *mockToolFactory) CreateTools(toolsConfig ToolsConfig) ([]tool.Tool, []t
 	return args.Get(0).([]tool.Tool), args.Get(1).([]tool.ToolSet), args.Error(2)
 }
 
+func (m *mockToolFactory) ValidateTools(toolsConfig ToolsConfig) error {
+	args := m.Called(toolsConfig)
+	return args.Error(0)
+}
+
+type fakeTool struct {
+	name string
+}
+
+func (t *fakeTool) Declaration() *tool.Declaration {
+	return &tool.Declaration{Name: t.name}
+}
+
+func (t *fakeTool) Call(ctx context.Context, jsonArgs []byte) (any, error) {
+	return nil, nil
+}
+
+type fakeToolSet struct {
+	tools  []tool.CallableTool
+	closed bool
+	listed int
+}
+
+func (s *fakeToolSet) Tools(ctx context.Context) []tool.CallableTool {
+	s.listed++
+	return s.tools
+}
+
+func (s *fakeToolSet) Close() error {
+	s.closed = true
+	return nil
+}
+
 func TestUnifiedAgentFactory_CreateAgent(t *testing.T) {
 	// Create mocks
 	mockConfigProvider := new(mockConfigProvider)
@@ -64,14 +119,18 @@ func TestUnifiedAgentFactory_CreateAgent(t *testing.T) {
 	// Set up test data
 	agentID := uuid.New()
 	agentConfig := &AgentConfig{
-		AgentID: agentID,
-		Name:    "test-agent",
-		Type:    shared.AgentTypeDefault,
+		AgentID:     agentID,
+		Name:        "test-agent",
+		Description: "Writes tests",
+		Type:        shared.AgentTypeDefault,
 		Prompt: PromptConfig{
 			Content: "Test prompt",
 		},
 		Settings: SettingsConfig{
 			Agent: AgentSettings{
+				Role:             shared.AgentRoleCoder,
+				StreamingEnabled: true,
+				OutputSchema:     map[string]interface{}{"type": "object"},
 				LLM: LLMSettings{
 					Model:    "gpt-3.5-turbo",
 					Provider: shared.ModelProviderOpenAI,
@@ -91,6 +150,19 @@ func TestUnifiedAgentFactory_CreateAgent(t *testing.T) {
 	// Assertions
 	assert.NoError(t, err)
 	assert.NotNil(t, agent)
+
+	// The descriptor is taken from the configuration
+	assert.Equal(t, shared.AgentRoleCoder, agent.GetRole())
+	assert.True(t, agent.IsStreaming())
+	descriptor := agent.Descriptor()
+	assert.Equal(t, agentID, descriptor.ID)
+	assert.Equal(t, "Writes tests", descriptor.Description)
+	assert.Equal(t, map[string]interface{}{"type": "object"}, descriptor.OutputSchema)
+	assert.Equal(t, shared.AgentRoleCoder, shared.TheAgentToInfo(agent).Role())
+
+	// Changing a returned descriptor leaves the agent unchanged
+	descriptor.OutputSchema["type"] = "array"
+	assert.Equal(t, map[string]interface{}{"type": "object"}, agent.Descriptor().OutputSchema)
 	
 	// Verify mock expectations
 	mockConfigProvider.AssertExpectations(t)
@@ -128,7 +200,10 @@ func TestUnifiedAgentFactory_CreateAgentByID(t *testing.T) {
 	}
 
 	// Set up mock expectations
-	mockConfigProvider.On("LoadAgentComposition", "production", "coder").Return(agentConfig, nil)
+	mockConfigProvider.On("LoadAgentCompositions", "production").Return(map[string]*AgentConfig{
+		"coder":      agentConfig,
+		"researcher": newTestAgentConfig("researcher", shared.AgentTypeDefault),
+	}, nil)
 	mockToolFactory.On("CreateTools", agentConfig.Tools).Return([]tool.Tool{}, []tool.ToolSet{}, nil)
 
 	// Test CreateAgentByID
@@ -143,4 +218,397 @@ func TestUnifiedAgentFactory_CreateAgentByID(t *testing.T) {
 	// Verify mock expectations
 	mockConfigProvider.AssertExpectations(t)
 	mockToolFactory.AssertExpectations(t)
-}
\ No newline at end of file
+}
+
+func newTestAgentConfig(name string, agentType shared.AgentType, subAgents ...string) *AgentConfig {
+	return &AgentConfig{
+		AgentID: uuid.New(),
+		Name:    name,
+		Type:    agentType,
+		Prompt: PromptConfig{
+			Content: name + " prompt",
+		},
+		Settings: SettingsConfig{
+			Agent: AgentSettings{
+				SubAgents: subAgents,
+				LLM: LLMSettings{
+					Model:    "gpt-3.5-turbo",
+					Provider: shared.ModelProviderOpenAI,
+				},
+			},
+		},
+	}
+}
+
+func TestUnifiedAgentFactory_CreateAgent_SubAgents(t *testing.T) {
+	mockConfigProvider := new(mockConfigProvider)
+	mockToolFactory := new(mockToolFactory)
+
+	factory := &UnifiedAgentFactory{
+		configProvider: mockConfigProvider,
+		toolFactory:    mockToolFactory,
+	}
+
+	coder := newTestAgentConfig("coder", shared.AgentTypeDefault)
+	researcher := newTestAgentConfig("researcher", shared.AgentTypeDefault)
+	pipeline := newTestAgentConfig("pipeline", shared.AgentTypeChain, "researcher", coder.AgentID.String())
+
+	compositions := map[string]*AgentConfig{
+		"coder":      coder,
+		"researcher": researcher,
+		"pipeline":   pipeline,
+	}
+
+	mockConfigProvider.On("LoadAgentComposition", "production", "pipeline").Return(pipeline, nil)
+	mockConfigProvider.On("LoadAgentCompositions", "production").Return(compositions, nil)
+	mockToolFactory.On("CreateTools", mock.Anything).Return([]tool.Tool{}, []tool.ToolSet{}, nil)
+
+	agent, err := factory.CreateAgent(context.Background(), "production", "pipeline")
+
+	assert.NoError(t, err)
+	assert.Equal(t, pipeline.AgentID, agent.ID())
+
+	subAgents := agent.SubAgents()
+	if assert.Len(t, subAgents, 2) {
+		assert.Equal(t, "researcher", subAgents[0].Info().Name)
+		assert.Equal(t, "coder", subAgents[1].Info().Name)
+	}
+}
+
+func TestUnifiedAgentFactory_CreateAgent_SubAgentCycle(t *testing.T) {
+	mockConfigProvider := new(mockConfigProvider)
+	mockToolFactory := new(mockToolFactory)
+
+	factory := &UnifiedAgentFactory{
+		configProvider: mockConfigProvider,
+		toolFactory:    mockToolFactory,
+	}
+
+	first := newTestAgentConfig("first", shared.AgentTypeChain, "second")
+	second := newTestAgentConfig("second", shared.AgentTypeCycle, "first")
+
+	compositions := map[string]*AgentConfig{
+		"first":  first,
+		"second": second,
+	}
+
+	mockConfigProvider.On("LoadAgentComposition", "production", "first").Return(first, nil)
+	mockConfigProvider.On("LoadAgentCompositions", "production").Return(compositions, nil)
+	mockToolFactory.On("CreateTools", mock.Anything).Return([]tool.Tool{}, []tool.ToolSet{}, nil)
+
+	agent, err := factory.CreateAgent(context.Background(), "production", "first")
+
+	assert.Nil(t, agent)
+	assert.ErrorContains(t, err, "sub-agent cycle detected in environment production: first -> second -> first")
+}
+
+func TestUnifiedAgentFactory_CreateAgent_UnknownSubAgent(t *testing.T) {
+	mockConfigProvider := new(mockConfigProvider)
+	mockToolFactory := new(mockToolFactory)
+
+	factory := &UnifiedAgentFactory{
+		configProvider: mockConfigProvider,
+		toolFactory:    mockToolFactory,
+	}
+
+	parent := newTestAgentConfig("parent", shared.AgentTypeParallel, "missing")
+
+	mockConfigProvider.On("LoadAgentComposition", "production", "parent").Return(parent, nil)
+	mockConfigProvider.On("LoadAgentCompositions", "production").Return(map[string]*AgentConfig{"parent": parent}, nil)
+	mockToolFactory.On("CreateTools", mock.Anything).Return([]tool.Tool{}, []tool.ToolSet{}, nil)
+
+	_, err := factory.CreateAgent(context.Background(), "production", "parent")
+
+	assert.ErrorContains(t, err, `failed to resolve sub-agent "missing"`)
+}
+
+func TestUnifiedAgentFactory_CreateAgent_SubAgentFails(t *testing.T) {
+	mockConfigProvider := new(mockConfigProvider)
+	mockToolFactory := new(mockToolFactory)
+
+	factory := &UnifiedAgentFactory{
+		configProvider: mockConfigProvider,
+		toolFactory:    mockToolFactory,
+	}
+
+	coder := newTestAgentConfig("coder", shared.AgentTypeDefault)
+	coder.Tools.Name = "coder"
+	researcher := newTestAgentConfig("researcher", shared.AgentTypeDefault)
+	researcher.Tools.Name = "researcher"
+	review := newTestAgentConfig("review", shared.AgentTypeChain, "researcher")
+	review.Tools.Name = "review"
+	broken := newTestAgentConfig("broken", shared.AgentTypeDefault)
+	broken.Tools.Name = "broken"
+	pipeline := newTestAgentConfig("pipeline", shared.AgentTypeChain, "coder", "review", "broken")
+	pipeline.Tools.Name = "pipeline"
+
+	compositions := map[string]*AgentConfig{
+		"coder":      coder,
+		"researcher": researcher,
+		"review":     review,
+		"broken":     broken,
+		"pipeline":   pipeline,
+	}
+
+	coderToolSet, researcherToolSet, pipelineToolSet := &fakeToolSet{}, &fakeToolSet{}, &fakeToolSet{}
+	mockConfigProvider.On("LoadAgentComposition", "production", "pipeline").Return(pipeline, nil)
+	mockConfigProvider.On("LoadAgentCompositions", "production").Return(compositions, nil)
+	mockToolFactory.On("CreateTools", coder.Tools).Return([]tool.Tool{}, []tool.ToolSet{coderToolSet}, nil)
+	mockToolFactory.On("CreateTools", researcher.Tools).Return([]tool.Tool{}, []tool.ToolSet{researcherToolSet}, nil)
+	mockToolFactory.On("CreateTools", review.Tools).Return([]tool.Tool{}, []tool.ToolSet{}, nil)
+	mockToolFactory.On("CreateTools", broken.Tools).Return([]tool.Tool{}, []tool.ToolSet{}, errors.New("toolset failed"))
+	mockToolFactory.On("CreateTools", pipeline.Tools).Return([]tool.Tool{}, []tool.ToolSet{pipelineToolSet}, nil)
+
+	agent, err := factory.CreateAgent(context.Background(), "production", "pipeline")
+
+	assert.Nil(t, agent)
+	assert.ErrorContains(t, err, `failed to create sub-agent "broken"`)
+
+	// The sub-agents built before the failing one are closed with their sub-agents
+	assert.True(t, coderToolSet.closed)
+	assert.True(t, researcherToolSet.closed)
+	assert.True(t, pipelineToolSet.closed)
+
+	// The compositions are loaded once for the whole build
+	mockConfigProvider.AssertNumberOfCalls(t, "LoadAgentCompositions", 1)
+}
+
+func TestUnifiedAgentFactory_CreateAgent_ToolSets(t *testing.T) {
+	mockConfigProvider := new(mockConfigProvider)
+	mockToolFactory := new(mockToolFactory)
+
+	factory := &UnifiedAgentFactory{
+		configProvider: mockConfigProvider,
+		toolFactory:    mockToolFactory,
+	}
+
+	agentConfig := newTestAgentConfig("coder", shared.AgentTypeDefault)
+	toolSet := &fakeToolSet{
+		tools: []tool.CallableTool{
+			&fakeTool{name: "execute_command"},
+			&fakeTool{name: "calculator"},
+		},
+	}
+
+	mockConfigProvider.On("LoadAgentComposition", "production", "coder").Return(agentConfig, nil)
+	mockToolFactory.On("CreateTools", agentConfig.Tools).Return(
+		[]tool.Tool{&fakeTool{name: "calculator"}},
+		[]tool.ToolSet{toolSet},
+		nil,
+	)
+
+	agent, err := factory.CreateAgent(context.Background(), "production", "coder")
+	assert.NoError(t, err)
+
+	var names []string
+	for _, agentTool := range agent.Tools() {
+		names = append(names, agentTool.Declaration().Name)
+	}
+	assert.ElementsMatch(t, []string{"calculator", "execute_command"}, names)
+
+	assert.NoError(t, shared.CloseAgent(agent))
+	assert.True(t, toolSet.closed)
+}
+
+func TestUnifiedAgentFactory_CreateAgent_CustomType(t *testing.T) {
+	mockConfigProvider := new(mockConfigProvider)
+	mockToolFactory := new(mockToolFactory)
+
+	factory := &UnifiedAgentFactory{
+		configProvider: mockConfigProvider,
+		toolFactory:    mockToolFactory,
+	}
+
+	// Custom types pass the validation but only the agent provider has their constructor
+	customType := shared.AgentType("factory-test-loop")
+	_ = shared.RegisterAgentType(customType, "Registered without a constructor of the CUE factory")
+
+	agentConfig := newTestAgentConfig("loop", customType)
+	toolSet := &fakeToolSet{}
+
+	mockConfigProvider.On("LoadAgentComposition", "production", "loop").Return(agentConfig, nil)
+	mockToolFactory.On("CreateTools", agentConfig.Tools).Return([]tool.Tool{}, []tool.ToolSet{toolSet}, nil)
+
+	agent, err := factory.CreateAgent(context.Background(), "production", "loop")
+
+	assert.Nil(t, agent)
+	assert.ErrorContains(t, err, "agent type factory-test-loop cannot be built by the CUE factory")
+	assert.True(t, toolSet.closed)
+}
+
+func TestUnifiedAgentFactory_WorkspaceToolsConfig(t *testing.T) {
+	root := t.TempDir()
+	workspaces, err := workspace.NewProvider(workspace.WithRoot(root))
+	require.NoError(t, err)
+
+	factory := &UnifiedAgentFactory{workspaceProvider: workspaces}
+
+	for name, tc := range map[string]struct {
+		root     string
+		expected string
+	}{
+		"provider root": {"", root},
+		"relative root": {"coder", filepath.Join(root, "coder")},
+	} {
+		t.Run(name, func(t *testing.T) {
+			agentConfig := newTestAgentConfig("coder", shared.AgentTypeDefault)
+			agentConfig.Settings.Agent.Workspace.Root = tc.root
+			agentConfig.Tools.ToolSets = map[string]ToolSetConfig{
+				FileToolSetName:   {Enabled: true},
+				shell.ToolSetName: {Enabled: true, Config: map[string]interface{}{"base_dir": "/srv"}},
+			}
+
+			toolsConfig, err := factory.workspaceToolsConfig(agentConfig)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, toolsConfig.ToolSets[FileToolSetName].Config["base_dir"])
+			assert.Equal(t, "/srv", toolsConfig.ToolSets[shell.ToolSetName].Config["base_dir"])
+		})
+	}
+
+	// Confining the tools requires a workspace provider
+	agentConfig := newTestAgentConfig("coder", shared.AgentTypeDefault)
+	agentConfig.Tools.ToolSets = map[string]ToolSetConfig{FileToolSetName: {Enabled: true}}
+	_, err = (&UnifiedAgentFactory{}).workspaceToolsConfig(agentConfig)
+	assert.ErrorContains(t, err, "no workspace provider")
+}
+
+func TestUnifiedAgentFactory_WorkspaceToolsConfig_Isolated(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	root := t.TempDir()
+	isolationDir := t.TempDir()
+	workspaces, err := workspace.NewProvider(workspace.WithRoot(root), workspace.WithIsolationDir(isolationDir))
+	require.NoError(t, err)
+
+	agentConfig := newTestAgentConfig("coder", shared.AgentTypeDefault)
+	agentConfig.Settings.Agent.Workspace.Isolation = provider.WorkspaceIsolationCopy
+	agentConfig.Tools.ToolSets = map[string]ToolSetConfig{FileToolSetName: {Enabled: true}}
+
+	// The tools of an isolated agent work in its copy, not in the root
+	toolsConfig, err := (&UnifiedAgentFactory{workspaceProvider: workspaces}).workspaceToolsConfig(agentConfig)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(isolationDir, agentConfig.AgentID.String()), toolsConfig.ToolSets[FileToolSetName].Config["base_dir"])
+}
+
+func TestUnifiedAgentFactory_ValidateConfiguration_UnknownTool(t *testing.T) {
+	mockConfigProvider := new(mockConfigProvider)
+
+	factory := &UnifiedAgentFactory{
+		configProvider: mockConfigProvider,
+		toolFactory:    NewCUEToolFactoryWithRegistry(NewToolRegistry()),
+	}
+
+	agentConfig := newTestAgentConfig("coder", shared.AgentTypeDefault)
+	agentConfig.Tools.Tools = map[string]ToolConfig{"inhouse": {Enabled: true}}
+
+	mockConfigProvider.On("ValidateConfiguration").Return(nil)
+	mockConfigProvider.On("ListEnvironments").Return([]string{"production"}, nil)
+	mockConfigProvider.On("LoadAgentCompositions", "production").Return(map[string]*AgentConfig{"coder": agentConfig}, nil)
+
+	err := factory.ValidateConfiguration()
+
+	assert.ErrorContains(t, err, "invalid tools for agent coder in environment production: unknown tool: inhouse")
+}
+
+func TestUnifiedAgentFactory_ValidateConfiguration_GenerationRange(t *testing.T) {
+	mockConfigProvider := new(mockConfigProvider)
+
+	factory := &UnifiedAgentFactory{
+		configProvider: mockConfigProvider,
+		toolFactory:    NewCUEToolFactoryWithRegistry(NewToolRegistry()),
+	}
+
+	topP := 1.5
+	agentConfig := newTestAgentConfig("coder", shared.AgentTypeDefault)
+	agentConfig.Settings.Agent.LLM.TopP = &topP
+
+	mockConfigProvider.On("ValidateConfiguration").Return(nil)
+	mockConfigProvider.On("ListEnvironments").Return([]string{"production"}, nil)
+	mockConfigProvider.On("LoadAgentCompositions", "production").Return(map[string]*AgentConfig{"coder": agentConfig}, nil)
+
+	err := factory.ValidateConfiguration()
+
+	assert.ErrorContains(t, err, "invalid generation settings for agent coder in environment production: top_p must be between 0 and 1, got 1.5")
+}
+
+func TestUnifiedAgentFactory_CreateAgent_GenerationRange(t *testing.T) {
+	mockConfigProvider := new(mockConfigProvider)
+	mockToolFactory := new(mockToolFactory)
+
+	factory := &UnifiedAgentFactory{
+		configProvider: mockConfigProvider,
+		toolFactory:    mockToolFactory,
+	}
+
+	agentConfig := newTestAgentConfig("coder", shared.AgentTypeDefault)
+	agentConfig.Settings.Agent.LLM.Temperature = 3.0
+
+	mockConfigProvider.On("LoadAgentComposition", "production", "coder").Return(agentConfig, nil)
+
+	// Out-of-range values are rejected before any tool is created
+	_, err := factory.CreateAgent(context.Background(), "production", "coder")
+	assert.ErrorContains(t, err, "invalid generation settings for agent coder: temperature")
+	mockToolFactory.AssertNotCalled(t, "CreateTools", mock.Anything)
+}
+
+func TestUnifiedAgentFactory_ValidateEnvironment(t *testing.T) {
+	mockConfigProvider := new(mockConfigProvider)
+
+	factory := &UnifiedAgentFactory{
+		configProvider: mockConfigProvider,
+		toolFactory:    NewCUEToolFactoryWithRegistry(NewToolRegistry()),
+	}
+
+	mockConfigProvider.On("ValidateConfiguration").Return(nil)
+	mockConfigProvider.On("ListEnvironments").Return([]string{"production", "staging"}, nil)
+	mockConfigProvider.On("LoadAgentCompositions", "production").Return(map[string]*AgentConfig{
+		"coder": newTestAgentConfig("coder", shared.AgentTypeDefault),
+	}, nil)
+	mockConfigProvider.On("LoadAgentCompositions", "staging").Return(map[string]*AgentConfig(nil),
+		errors.New("environment inheritance cycle detected: staging -> canary -> staging"))
+
+	// A broken environment only fails its own validation
+	assert.NoError(t, factory.ValidateEnvironment("production"))
+	assert.ErrorContains(t, factory.ValidateEnvironment("staging"), "inheritance cycle")
+	assert.ErrorContains(t, factory.ValidateConfiguration(), "failed to load compositions for environment staging")
+}
+
+func TestUnifiedAgentFactory_CreateAgentByID_DefaultEnvironment(t *testing.T) {
+	mockConfigProvider := new(mockConfigProvider)
+	mockToolFactory := new(mockToolFactory)
+
+	factory := NewUnifiedAgentFactory("unused",
+		WithConfigProvider(mockConfigProvider),
+		WithDefaultEnvironment("development"),
+	).(*UnifiedAgentFactory)
+	factory.toolFactory = mockToolFactory
+
+	reviewer := newTestAgentConfig("reviewer", shared.AgentTypeDefault)
+	mockConfigProvider.On("LoadAgentCompositions", "development").Return(map[string]*AgentConfig{
+		"reviewer": reviewer,
+	}, nil)
+	mockToolFactory.On("CreateTools", reviewer.Tools).Return([]tool.Tool{}, []tool.ToolSet{}, nil)
+
+	agent, err := factory.CreateAgentByID(context.Background(), reviewer.AgentID)
+	require.NoError(t, err)
+	assert.Equal(t, reviewer.AgentID, agent.ID())
+	assert.Equal(t, "development", factory.DefaultEnvironment())
+
+	_, err = factory.CreateAgentByID(context.Background(), shared.AgentIDCoder)
+	assert.ErrorContains(t, err, "unknown agent ID "+shared.AgentIDCoder.String()+" in environment development")
+}
+
+func TestNewAgentIndex_DuplicateIDs(t *testing.T) {
+	coder := newTestAgentConfig("coder", shared.AgentTypeDefault)
+	reviewer := newTestAgentConfig("reviewer", shared.AgentTypeDefault)
+	reviewer.AgentID = coder.AgentID
+
+	_, err := NewAgentIndex("production", map[string]*AgentConfig{
+		"coder":    coder,
+		"reviewer": reviewer,
+	})
+
+	assert.EqualError(t, err, "duplicate agent ID "+coder.AgentID.String()+" in environment production: used by coder and reviewer")
+}
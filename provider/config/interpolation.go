@@ -0,0 +1,296 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// InterpolationMode controls how variables without value and default are handled
+type InterpolationMode int
+
+const (
+	// InterpolationStrict fails on a variable that is not set and has no default
+	InterpolationStrict InterpolationMode = iota
+	// InterpolationLenient substitutes an empty string for a variable that is not set and has no default
+	InterpolationLenient
+)
+
+// MissingVariableError reports an environment variable that is not set and has no default
+type MissingVariableError struct {
+	Name string
+}
+
+func (e *MissingVariableError) Error() string {
+	return fmt.Sprintf("environment variable %s is not set", e.Name)
+}
+
+// Interpolator is the single engine resolving configuration strings. It
+// substitutes ${VAR} and ${VAR:-default} anywhere inside a string, then
+// resolves the string through the secret registry if it is a scheme:reference
+// such as env:VAR[:default], file:/path or keyring:name. $${ escapes a literal ${.
+type Interpolator struct {
+	mode            InterpolationMode
+	secrets         *SecretRegistry
+	secretVariables map[string]bool
+}
+
+// NewInterpolator creates an interpolator resolving secret references with the
+// given registry. Strings containing the value of one of the secret variables
+// are redacted like secret references.
+func NewInterpolator(mode InterpolationMode, secrets *SecretRegistry, secretVariables ...string) *Interpolator {
+	i := &Interpolator{
+		mode:            mode,
+		secrets:         secrets,
+		secretVariables: make(map[string]bool, len(secretVariables)),
+	}
+	for _, name := range secretVariables {
+		i.secretVariables[name] = true
+	}
+	return i
+}
+
+// ResolveString resolves a single configuration string. The second return
+// value reports whether the string was a secret reference or contains the
+// value of a set ${VAR} declared secret.
+func (i *Interpolator) ResolveString(value string) (string, bool, error) {
+	interpolated, fromSecret, err := i.interpolate(value)
+	if err != nil {
+		return "", false, err
+	}
+
+	resolved, isSecret, err := i.secrets.Resolve(interpolated)
+	var missing *MissingVariableError
+	if errors.As(err, &missing) && i.mode == InterpolationLenient {
+		return "", true, nil
+	}
+
+	return resolved, isSecret || fromSecret, err
+}
+
+// ResolveMap resolves every string in a decoded configuration map in place,
+// descending into nested maps and slices. It returns the dotted paths of all
+// values that were secret references.
+func (i *Interpolator) ResolveMap(config map[string]interface{}) ([]string, error) {
+	_, secretPaths, err := i.resolveValue(config, "")
+	return secretPaths, err
+}
+
+func (i *Interpolator) resolveValue(value interface{}, path string) (interface{}, []string, error) {
+	switch v := value.(type) {
+	case string:
+		resolved, isSecret, err := i.ResolveString(v)
+		if err != nil {
+			return nil, nil, &ConfigValueError{Path: path, Err: err}
+		}
+		if isSecret {
+			return resolved, []string{path}, nil
+		}
+		return resolved, nil, nil
+	case map[string]interface{}:
+		var secretPaths []string
+		for _, key := range sortedKeys(v) {
+			resolved, paths, err := i.resolveValue(v[key], joinPath(path, key))
+			if err != nil {
+				return nil, nil, err
+			}
+			v[key] = resolved
+			secretPaths = append(secretPaths, paths...)
+		}
+		return v, secretPaths, nil
+	case []interface{}:
+		var secretPaths []string
+		for index, item := range v {
+			resolved, paths, err := i.resolveValue(item, joinPath(path, strconv.Itoa(index)))
+			if err != nil {
+				return nil, nil, err
+			}
+			v[index] = resolved
+			secretPaths = append(secretPaths, paths...)
+		}
+		return v, secretPaths, nil
+	default:
+		return value, nil, nil
+	}
+}
+
+// interpolate substitutes all ${VAR} and ${VAR:-default} occurrences. It
+// reports whether a value was taken from a set secret variable.
+func (i *Interpolator) interpolate(value string) (string, bool, error) {
+	if !strings.Contains(value, "${") {
+		return value, false, nil
+	}
+
+	var result strings.Builder
+	fromSecret := false
+	err := scanVariables(value, func(literal string) {
+		result.WriteString(literal)
+	}, func(ref variableRef) error {
+		fromSecret = fromSecret || (i.secretVariables[ref.name] && os.Getenv(ref.name) != "")
+		resolved, err := ref.lookup()
+		var missing *MissingVariableError
+		if errors.As(err, &missing) && i.mode == InterpolationLenient {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		result.WriteString(resolved)
+		return nil
+	})
+	if err != nil {
+		return "", false, err
+	}
+
+	return result.String(), fromSecret, nil
+}
+
+// variableRef is a single environment variable reference
+type variableRef struct {
+	name         string
+	defaultValue string
+	hasDefault   bool
+}
+
+// lookup returns the value of the variable. Like env: references, an empty
+// variable counts as unset and falls back to the default.
+func (r variableRef) lookup() (string, error) {
+	if value := os.Getenv(r.name); value != "" {
+		return value, nil
+	}
+	if r.hasDefault {
+		return r.defaultValue, nil
+	}
+	return "", &MissingVariableError{Name: r.name}
+}
+
+// scanVariables splits value into literal text and ${...} references
+func scanVariables(value string, onLiteral func(string), onVariable func(variableRef) error) error {
+	rest := value
+	for {
+		start := strings.Index(rest, "${")
+		if start < 0 {
+			onLiteral(rest)
+			return nil
+		}
+
+		if start > 0 && rest[start-1] == '$' {
+			onLiteral(rest[:start-1] + "${")
+			rest = rest[start+2:]
+			continue
+		}
+
+		end := strings.IndexByte(rest[start:], '}')
+		if end < 0 {
+			return fmt.Errorf("unterminated variable reference in %q", value)
+		}
+
+		onLiteral(rest[:start])
+
+		expression := rest[start+2 : start+end]
+		name, defaultValue, hasDefault := strings.Cut(expression, ":-")
+		if name == "" {
+			return fmt.Errorf("empty variable name in %q", value)
+		}
+		if err := onVariable(variableRef{name: name, defaultValue: defaultValue, hasDefault: hasDefault}); err != nil {
+			return err
+		}
+
+		rest = rest[start+end+1:]
+	}
+}
+
+// VariableRequirement describes an environment variable referenced by a configuration
+type VariableRequirement struct {
+	Name       string   `json:"name"`
+	Default    string   `json:"default,omitempty"`
+	HasDefault bool     `json:"has_default"`
+	Set        bool     `json:"set"`
+	Paths      []string `json:"paths"`
+}
+
+// Missing reports whether the variable is neither set nor has a default
+func (r VariableRequirement) Missing() bool {
+	return !r.Set && !r.HasDefault
+}
+
+// variableCollector accumulates the variables referenced by raw configuration maps
+type variableCollector struct {
+	variables map[string]*VariableRequirement
+}
+
+func newVariableCollector() *variableCollector {
+	return &variableCollector{
+		variables: make(map[string]*VariableRequirement),
+	}
+}
+
+// collect records all env: and ${VAR} references found below path
+func (c *variableCollector) collect(value interface{}, path string) error {
+	switch v := value.(type) {
+	case string:
+		return c.collectString(v, path)
+	case map[string]interface{}:
+		for _, key := range sortedKeys(v) {
+			if err := c.collect(v[key], joinPath(path, key)); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for index, item := range v {
+			if err := c.collect(item, joinPath(path, strconv.Itoa(index))); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (c *variableCollector) collectString(value, path string) error {
+	err := scanVariables(value, func(string) {}, func(ref variableRef) error {
+		c.add(ref, path)
+		return nil
+	})
+	if err != nil {
+		return &ConfigValueError{Path: path, Err: err}
+	}
+
+	if reference, isEnv := strings.CutPrefix(value, SecretSchemeEnv+":"); isEnv {
+		name, defaultValue, hasDefault := strings.Cut(reference, ":")
+		if name != "" && !strings.Contains(name, "${") {
+			c.add(variableRef{name: name, defaultValue: defaultValue, hasDefault: hasDefault}, path)
+		}
+	}
+
+	return nil
+}
+
+func (c *variableCollector) add(ref variableRef, path string) {
+	requirement, exists := c.variables[ref.name]
+	if !exists {
+		requirement = &VariableRequirement{
+			Name: ref.name,
+			Set:  os.Getenv(ref.name) != "",
+		}
+		c.variables[ref.name] = requirement
+	}
+
+	// A single reference without default makes the variable required
+	if !exists || requirement.HasDefault {
+		requirement.HasDefault = ref.hasDefault
+		requirement.Default = ref.defaultValue
+	}
+
+	requirement.Paths = append(requirement.Paths, path)
+}
+
+// requirements returns the collected variables sorted by name
+func (c *variableCollector) requirements() []VariableRequirement {
+	requirements := make([]VariableRequirement, 0, len(c.variables))
+	for _, name := range sortedKeys(c.variables) {
+		requirements = append(requirements, *c.variables[name])
+	}
+	return requirements
+}
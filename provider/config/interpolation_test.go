@@ -0,0 +1,74 @@
+package config
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterpolator_ResolveString(t *testing.T) {
+	t.Setenv("TEST_HOST", "localhost")
+	t.Setenv("TEST_TOKEN", "secret")
+
+	tests := []struct {
+		value    string
+		expected string
+		isSecret bool
+	}{
+		{"http://${TEST_HOST}:8080/v1", "http://localhost:8080/v1", false},
+		{"Bearer ${TEST_TOKEN}", "Bearer secret", true},
+		{"http://${TEST_HOST_MISSING:-127.0.0.1}:8080", "http://127.0.0.1:8080", false},
+		{"env:TEST_TOKEN", "secret", true},
+		{"env:TEST_${TEST_HOST_MISSING:-TOKEN}", "secret", true},
+		{"cost: $${price}", "cost: ${price}", false},
+		{"no variables", "no variables", false},
+	}
+
+	interpolator := NewInterpolator(InterpolationStrict, DefaultSecretRegistry, "TEST_TOKEN")
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			resolved, isSecret, err := interpolator.ResolveString(tt.value)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, resolved)
+			assert.Equal(t, tt.isSecret, isSecret)
+		})
+	}
+}
+
+func TestInterpolator_Modes(t *testing.T) {
+	strict := NewInterpolator(InterpolationStrict, DefaultSecretRegistry)
+	lenient := NewInterpolator(InterpolationLenient, DefaultSecretRegistry)
+
+	for _, value := range []string{"env:TEST_UNSET_VAR", "prefix-${TEST_UNSET_VAR}"} {
+		_, _, err := strict.ResolveString(value)
+		var missing *MissingVariableError
+		require.True(t, errors.As(err, &missing), value)
+		assert.Equal(t, "TEST_UNSET_VAR", missing.Name)
+	}
+
+	resolved, _, err := lenient.ResolveString("env:TEST_UNSET_VAR")
+	require.NoError(t, err)
+	assert.Empty(t, resolved)
+
+	resolved, _, err = lenient.ResolveString("prefix-${TEST_UNSET_VAR}")
+	require.NoError(t, err)
+	assert.Equal(t, "prefix-", resolved)
+
+	_, _, err = lenient.ResolveString("${TEST_UNTERMINATED")
+	assert.ErrorContains(t, err, "unterminated variable reference")
+}
+
+func TestCUEConfigProvider_RequiredVariables(t *testing.T) {
+	t.Setenv("TEST_RESEARCHER_API_KEY", "")
+
+	variables, err := NewCUEConfigProvider(testConfigPath).RequiredVariables("development")
+	require.NoError(t, err)
+
+	require.Len(t, variables, 1)
+	assert.Equal(t, "TEST_RESEARCHER_API_KEY", variables[0].Name)
+	assert.True(t, variables[0].HasDefault)
+	assert.False(t, variables[0].Missing())
+	assert.Equal(t, []string{"development.agents.researcher.settings.agent.llm.api_key"}, variables[0].Paths)
+}
@@ -0,0 +1,400 @@
+package config
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// Built-in secret reference schemes
+const (
+	// SecretSchemeEnv resolves env:VAR_NAME or env:VAR_NAME:default
+	SecretSchemeEnv = "env"
+	// SecretSchemeFile resolves file:/path/to/secret, e.g. a mounted secret
+	SecretSchemeFile = "file"
+	// SecretSchemeKeyring resolves keyring:name from the local encrypted vault
+	SecretSchemeKeyring = "keyring"
+)
+
+// Environment variables configuring the local encrypted vault
+const (
+	// VaultFileEnv overrides the vault location, defaults to ~/.config/agents/vault.enc
+	VaultFileEnv = "AGENTS_VAULT_FILE"
+	// VaultPassphraseEnv holds the passphrase the vault is sealed with
+	VaultPassphraseEnv = "AGENTS_VAULT_PASSPHRASE"
+)
+
+// RedactedValue replaces resolved secrets when a configuration is dumped
+const RedactedValue = "<redacted>"
+
+// SecretResolver resolves the reference part of a scheme:reference string
+type SecretResolver interface {
+	Resolve(reference string) (string, error)
+}
+
+// SecretResolverFunc adapts a function to a SecretResolver
+type SecretResolverFunc func(reference string) (string, error)
+
+// Resolve calls f(reference)
+func (f SecretResolverFunc) Resolve(reference string) (string, error) {
+	return f(reference)
+}
+
+// SecretRegistry maps reference schemes to secret resolvers
+type SecretRegistry struct {
+	mu        sync.RWMutex
+	resolvers map[string]SecretResolver
+}
+
+// DefaultSecretRegistry is used by NewCUEConfigProvider unless WithSecretRegistry is given. It resolves env:, file: and keyring: references.
+var DefaultSecretRegistry = NewSecretRegistry()
+
+func init() {
+	DefaultSecretRegistry.MustRegister(SecretSchemeEnv, SecretResolverFunc(resolveEnvSecret))
+	DefaultSecretRegistry.MustRegister(SecretSchemeFile, SecretResolverFunc(resolveFileSecret))
+	DefaultSecretRegistry.MustRegister(SecretSchemeKeyring, NewVaultResolver("", nil))
+}
+
+// NewSecretRegistry creates an empty secret registry
+func NewSecretRegistry() *SecretRegistry {
+	return &SecretRegistry{
+		resolvers: make(map[string]SecretResolver),
+	}
+}
+
+// Register registers a resolver for the given scheme
+func (r *SecretRegistry) Register(scheme string, resolver SecretResolver) error {
+	if scheme == "" || strings.Contains(scheme, ":") {
+		return fmt.Errorf("invalid secret scheme: %q", scheme)
+	}
+	if resolver == nil {
+		return fmt.Errorf("resolver for secret scheme %s cannot be nil", scheme)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.resolvers[scheme]; exists {
+		return fmt.Errorf("secret scheme %s is already registered", scheme)
+	}
+
+	r.resolvers[scheme] = resolver
+	return nil
+}
+
+// MustRegister registers a resolver for the given scheme and panics on error
+func (r *SecretRegistry) MustRegister(scheme string, resolver SecretResolver) {
+	if err := r.Register(scheme, resolver); err != nil {
+		panic(err.Error())
+	}
+}
+
+// Schemes returns all registered schemes sorted by name
+func (r *SecretRegistry) Schemes() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return sortedKeys(r.resolvers)
+}
+
+// Resolve resolves value if it starts with a registered scheme. The second
+// return value reports whether value was a secret reference.
+func (r *SecretRegistry) Resolve(value string) (string, bool, error) {
+	scheme, reference, found := strings.Cut(value, ":")
+	if !found {
+		return value, false, nil
+	}
+
+	r.mu.RLock()
+	resolver, exists := r.resolvers[scheme]
+	r.mu.RUnlock()
+
+	if !exists {
+		return value, false, nil
+	}
+
+	resolved, err := resolver.Resolve(reference)
+	if err != nil {
+		return "", true, fmt.Errorf("failed to resolve %s secret: %w", scheme, err)
+	}
+
+	return resolved, true, nil
+}
+
+// resolveEnvSecret parses VAR_NAME or VAR_NAME:default
+func resolveEnvSecret(reference string) (string, error) {
+	name, defaultValue, hasDefault := strings.Cut(reference, ":")
+	if name == "" {
+		return "", fmt.Errorf("empty environment variable name")
+	}
+
+	return variableRef{name: name, defaultValue: defaultValue, hasDefault: hasDefault}.lookup()
+}
+
+// resolveFileSecret reads a secret file, trailing newlines are trimmed
+func resolveFileSecret(reference string) (string, error) {
+	if reference == "" {
+		return "", fmt.Errorf("empty secret file path")
+	}
+
+	path, err := expandHome(reference)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret file: %w", err)
+	}
+
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+func expandHome(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve home directory: %w", err)
+	}
+
+	return filepath.Join(home, strings.TrimPrefix(path, "~")), nil
+}
+
+func defaultVaultPath() string {
+	if path := os.Getenv(VaultFileEnv); path != "" {
+		return path
+	}
+	return filepath.Join("~", ".config", "agents", "vault.enc")
+}
+
+// VaultResolver resolves secrets from a local vault file: a JSON object of
+// name/value pairs sealed with AES-256-GCM under a passphrase derived key.
+// The vault is read on first successful use.
+type VaultResolver struct {
+	path       string
+	passphrase []byte
+
+	mu      sync.Mutex
+	secrets map[string]string
+}
+
+// NewVaultResolver creates a resolver for the vault at path. An empty path and a
+// nil passphrase are read from AGENTS_VAULT_FILE and AGENTS_VAULT_PASSPHRASE
+// when the vault is opened.
+func NewVaultResolver(path string, passphrase []byte) *VaultResolver {
+	return &VaultResolver{
+		path:       path,
+		passphrase: passphrase,
+	}
+}
+
+// Resolve returns the secret stored under name
+func (v *VaultResolver) Resolve(name string) (string, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.secrets == nil {
+		secrets, err := v.open()
+		if err != nil {
+			return "", err
+		}
+		v.secrets = secrets
+	}
+
+	secret, exists := v.secrets[name]
+	if !exists {
+		return "", fmt.Errorf("secret %s not found in vault", name)
+	}
+
+	return secret, nil
+}
+
+func (v *VaultResolver) open() (map[string]string, error) {
+	passphrase := v.passphrase
+	if passphrase == nil {
+		passphrase = []byte(os.Getenv(VaultPassphraseEnv))
+	}
+	if len(passphrase) == 0 {
+		return nil, fmt.Errorf("vault passphrase is not set, export %s", VaultPassphraseEnv)
+	}
+
+	path := v.path
+	if path == "" {
+		path = defaultVaultPath()
+	}
+
+	path, err := expandHome(path)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vault: %w", err)
+	}
+
+	return OpenVault(sealed, passphrase)
+}
+
+// The vault file starts with vaultMagic and the random scrypt salt, followed
+// by the AES-GCM nonce and ciphertext
+var vaultMagic = []byte("AGV1")
+
+const vaultSaltSize = 16
+
+// scrypt cost parameters deriving the vault key from the passphrase
+const (
+	vaultScryptN = 1 << 15
+	vaultScryptR = 8
+	vaultScryptP = 1
+)
+
+// SealVault encrypts secrets into the vault file format read by VaultResolver
+func SealVault(secrets map[string]string, passphrase []byte) ([]byte, error) {
+	plaintext, err := json.Marshal(secrets)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode vault: %w", err)
+	}
+
+	salt := make([]byte, vaultSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	gcm, err := vaultCipher(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	header := append(append(append([]byte(nil), vaultMagic...), salt...), nonce...)
+	return gcm.Seal(header, nonce, plaintext, nil), nil
+}
+
+// OpenVault decrypts a vault sealed with SealVault
+func OpenVault(sealed []byte, passphrase []byte) (map[string]string, error) {
+	if !bytes.HasPrefix(sealed, vaultMagic) || len(sealed) < len(vaultMagic)+vaultSaltSize {
+		return nil, fmt.Errorf("vault is corrupted")
+	}
+	sealed = sealed[len(vaultMagic):]
+	salt, sealed := sealed[:vaultSaltSize], sealed[vaultSaltSize:]
+
+	gcm, err := vaultCipher(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < gcm.NonceSize() {
+		return nil, fmt.Errorf("vault is corrupted")
+	}
+
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt vault, wrong passphrase?")
+	}
+
+	var secrets map[string]string
+	if err := json.Unmarshal(plaintext, &secrets); err != nil {
+		return nil, fmt.Errorf("failed to decode vault: %w", err)
+	}
+
+	return secrets, nil
+}
+
+// vaultCipher derives the vault key from the passphrase and the salt with scrypt
+func vaultCipher(passphrase, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key(passphrase, salt, vaultScryptN, vaultScryptR, vaultScryptP, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive vault key: %w", err)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create vault cipher: %w", err)
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// redactPaths replaces the values at the given dotted paths of a decoded JSON document
+func redactPaths(document map[string]interface{}, paths []string) {
+	for _, path := range paths {
+		redactPath(document, strings.Split(path, "."))
+	}
+}
+
+func redactPath(value interface{}, segments []string) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		child, exists := v[segments[0]]
+		if !exists {
+			return
+		}
+		if len(segments) == 1 {
+			v[segments[0]] = RedactedValue
+			return
+		}
+		redactPath(child, segments[1:])
+	case []interface{}:
+		index, err := strconv.Atoi(segments[0])
+		if err != nil || index < 0 || index >= len(v) {
+			return
+		}
+		if len(segments) == 1 {
+			v[index] = RedactedValue
+			return
+		}
+		redactPath(v[index], segments[1:])
+	}
+}
+
+// SecretPaths returns the JSON paths of all values that were resolved from secret references
+func (c *AgentConfig) SecretPaths() []string {
+	return append([]string(nil), c.secretPaths...)
+}
+
+// plainAgentConfig encodes an AgentConfig without redaction
+type plainAgentConfig AgentConfig
+
+// MarshalJSON encodes the configuration with all resolved secrets redacted
+func (c AgentConfig) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(plainAgentConfig(c))
+	if err != nil || len(c.secretPaths) == 0 {
+		return data, err
+	}
+
+	var document map[string]interface{}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+
+	redactPaths(document, c.secretPaths)
+	return json.Marshal(document)
+}
+
+// String renders the configuration as JSON with all resolved secrets redacted, safe for logging
+func (c AgentConfig) String() string {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Sprintf("AgentConfig{Name: %s, error: %v}", c.Name, err)
+	}
+	return string(data)
+}
@@ -0,0 +1,138 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecretRegistry_Resolve(t *testing.T) {
+	t.Setenv("TEST_SECRET", "from-env")
+
+	secretFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(secretFile, []byte("from-file\n"), 0o600))
+
+	tests := []struct {
+		value    string
+		expected string
+		isSecret bool
+	}{
+		{"env:TEST_SECRET", "from-env", true},
+		{"env:TEST_SECRET_MISSING:fallback", "fallback", true},
+		{"file:" + secretFile, "from-file", true},
+		{"https://api.example.com", "https://api.example.com", false},
+		{"plain value", "plain value", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			resolved, isSecret, err := DefaultSecretRegistry.Resolve(tt.value)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, resolved)
+			assert.Equal(t, tt.isSecret, isSecret)
+		})
+	}
+
+	_, _, err := DefaultSecretRegistry.Resolve("env:TEST_SECRET_MISSING")
+	assert.ErrorContains(t, err, "environment variable TEST_SECRET_MISSING is not set")
+}
+
+func TestInterpolator_ResolveMap(t *testing.T) {
+	registry := NewSecretRegistry()
+	require.NoError(t, registry.Register("static", SecretResolverFunc(func(reference string) (string, error) {
+		if reference == "broken" {
+			return "", errors.New("no such secret")
+		}
+		return strings.ToUpper(reference), nil
+	})))
+
+	config := map[string]interface{}{
+		"settings": map[string]interface{}{
+			"api_key": "static:key",
+			"headers": []interface{}{"plain", "static:header"},
+		},
+		"name": "coder",
+	}
+
+	interpolator := NewInterpolator(InterpolationStrict, registry)
+
+	paths, err := interpolator.ResolveMap(config)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"settings.api_key", "settings.headers.1"}, paths)
+	assert.Equal(t, "KEY", config["settings"].(map[string]interface{})["api_key"])
+
+	_, err = interpolator.ResolveMap(map[string]interface{}{"llm": map[string]interface{}{"api_key": "static:broken"}})
+	var valueErr *ConfigValueError
+	require.True(t, errors.As(err, &valueErr))
+	assert.Equal(t, "llm.api_key", valueErr.Path)
+}
+
+func TestInterpolator_ResolveMapRedactsSecretVariables(t *testing.T) {
+	t.Setenv("TEST_INTERPOLATED_KEY", "sk-env")
+	t.Setenv("TEST_INTERPOLATED_HOST", "http://llm.internal")
+
+	config := map[string]interface{}{
+		"llm": map[string]interface{}{
+			"api_key":  "Bearer ${TEST_INTERPOLATED_KEY}",
+			"base_url": "${TEST_INTERPOLATED_HOST}/v1",
+		},
+	}
+
+	// Only variables declared secret are redacted, other set variables are kept for diffing
+	paths, err := NewInterpolator(InterpolationStrict, DefaultSecretRegistry, "TEST_INTERPOLATED_KEY").ResolveMap(config)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"llm.api_key"}, paths)
+
+	redactPaths(config, paths)
+	assert.Equal(t, RedactedValue, config["llm"].(map[string]interface{})["api_key"])
+	assert.Equal(t, "http://llm.internal/v1", config["llm"].(map[string]interface{})["base_url"])
+}
+
+func TestVaultResolver(t *testing.T) {
+	passphrase := []byte("correct horse battery staple")
+	sealed, err := SealVault(map[string]string{"openai": "sk-vault"}, passphrase)
+	require.NoError(t, err)
+
+	vaultFile := filepath.Join(t.TempDir(), "vault.enc")
+	require.NoError(t, os.WriteFile(vaultFile, sealed, 0o600))
+
+	secret, err := NewVaultResolver(vaultFile, passphrase).Resolve("openai")
+	require.NoError(t, err)
+	assert.Equal(t, "sk-vault", secret)
+
+	_, err = NewVaultResolver(vaultFile, passphrase).Resolve("anthropic")
+	assert.ErrorContains(t, err, "secret anthropic not found in vault")
+
+	_, err = NewVaultResolver(vaultFile, []byte("wrong")).Resolve("openai")
+	assert.ErrorContains(t, err, "failed to decrypt vault")
+
+	// Every vault is sealed with its own salt
+	resealed, err := SealVault(map[string]string{"openai": "sk-vault"}, passphrase)
+	require.NoError(t, err)
+	assert.NotEqual(t, sealed[:len(vaultMagic)+vaultSaltSize], resealed[:len(vaultMagic)+vaultSaltSize])
+
+	_, err = OpenVault(sealed[len(vaultMagic):], passphrase)
+	assert.ErrorContains(t, err, "vault is corrupted")
+}
+
+func TestCUEConfigProvider_ResolvesAndRedactsSecrets(t *testing.T) {
+	t.Setenv("TEST_RESEARCHER_API_KEY", "sk-live")
+
+	configs, err := NewCUEConfigProvider(testConfigPath).LoadAgentCompositions("production")
+	require.NoError(t, err)
+
+	researcher := configs["researcher"]
+	assert.Equal(t, "sk-live", researcher.Settings.Agent.LLM.APIKey)
+	assert.Equal(t, []string{"settings.agent.llm.api_key"}, researcher.SecretPaths())
+
+	data, err := DumpAgentConfigs(configs)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "sk-live")
+	assert.Contains(t, string(data), RedactedValue)
+	assert.NotContains(t, researcher.String(), "sk-live")
+}
@@ -0,0 +1,178 @@
+package config
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/denkhaus/agents/provider"
+	"github.com/denkhaus/agents/provider/llm"
+	"github.com/denkhaus/agents/provider/prompt"
+	"github.com/denkhaus/agents/shared"
+	"github.com/google/uuid"
+	"github.com/samber/do"
+)
+
+// Environment variables configuring the settings provider registered in the DI container
+const (
+	// ConfigPathEnv points to the CUE configuration directory, defaults to ./config
+	ConfigPathEnv = "AGENTS_CONFIG_PATH"
+	// EnvironmentEnv selects the environment agents are loaded from, defaults to production
+	EnvironmentEnv = "AGENTS_ENVIRONMENT"
+)
+
+// DefaultConfigPath is the CUE configuration directory used unless AGENTS_CONFIG_PATH is set
+const DefaultConfigPath = "./config"
+
+// settingsProviderImpl implements provider.SettingsProvider on top of the
+// agent compositions of a single environment
+type settingsProviderImpl struct {
+	configProvider    ConfigProvider
+	environment       string
+	contextProvider   provider.PromptContextProvider
+	workspaceProvider provider.WorkspaceProvider
+}
+
+// SettingsProviderOption configures a CUE settings provider
+type SettingsProviderOption func(*settingsProviderImpl)
+
+// WithPromptContextProvider sets the contributors of the prompt render context,
+// prompt.NewDefaultContextProvider by default
+func WithPromptContextProvider(contextProvider provider.PromptContextProvider) SettingsProviderOption {
+	return func(p *settingsProviderImpl) {
+		p.contextProvider = contextProvider
+	}
+}
+
+// WithWorkspaceProvider sets the provider of the agent workspaces exposed to
+// the prompts, without it the workspace path is empty. The workspace settings
+// of the compositions are applied to it.
+func WithWorkspaceProvider(workspaceProvider provider.WorkspaceProvider) SettingsProviderOption {
+	return func(p *settingsProviderImpl) {
+		p.workspaceProvider = workspaceProvider
+	}
+}
+
+// NewSettingsProvider creates a CUE based SettingsProvider using dependency injection.
+// The configuration directory and environment are read from AGENTS_CONFIG_PATH and AGENTS_ENVIRONMENT.
+func NewSettingsProvider(i *do.Injector) (provider.SettingsProvider, error) {
+	configProvider := NewCUEConfigProvider(getenv(ConfigPathEnv, DefaultConfigPath))
+	if err := configProvider.ValidateConfiguration(); err != nil {
+		return nil, fmt.Errorf("failed to validate configuration: %w", err)
+	}
+
+	return NewCUESettingsProvider(
+		configProvider,
+		getenv(EnvironmentEnv, DefaultEnvironment),
+		WithPromptContextProvider(do.MustInvoke[provider.PromptContextProvider](i)),
+		WithWorkspaceProvider(do.MustInvoke[provider.WorkspaceProvider](i)),
+	), nil
+}
+
+// NewCUESettingsProvider creates a SettingsProvider serving the agents of the given environment
+func NewCUESettingsProvider(
+	configProvider ConfigProvider,
+	environment string,
+	opts ...SettingsProviderOption,
+) provider.SettingsProvider {
+	p := &settingsProviderImpl{
+		configProvider:  configProvider,
+		environment:     environment,
+		contextProvider: prompt.NewDefaultContextProvider(),
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return p
+}
+
+// GetActiveAgents returns all agents composed in the environment, sorted by name
+func (p *settingsProviderImpl) GetActiveAgents(includeHumanAgent bool) ([]shared.AgentInfo, error) {
+	compositions, err := p.configProvider.LoadAgentCompositions(p.environment)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load compositions for environment %s: %w", p.environment, err)
+	}
+
+	agents := make([]shared.AgentInfo, 0, len(compositions)+1)
+	for _, agentName := range sortedKeys(compositions) {
+		agentConfig := compositions[agentName]
+		agentInfo := shared.NewAgentInfo(
+			agentConfig.AgentID,
+			agentConfig.Settings.Agent.Role,
+			agentConfig.Settings.Agent.StreamingEnabled,
+			agentConfig.Name,
+			agentConfig.Description,
+		)
+
+		agentInfo.InputSchema = agentConfig.Settings.Agent.InputSchema
+		agentInfo.OutputSchema = agentConfig.Settings.Agent.OutputSchema
+		agents = append(agents, agentInfo)
+	}
+
+	if includeHumanAgent {
+		agents = append(agents, shared.AgentInfoHuman)
+	}
+
+	return agents, nil
+}
+
+// GetAgentConfiguration returns the configuration of the agent with the given ID
+func (p *settingsProviderImpl) GetAgentConfiguration(agentID uuid.UUID) (provider.AgentConfiguration, error) {
+	compositions, err := p.configProvider.LoadAgentCompositions(p.environment)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load compositions for environment %s: %w", p.environment, err)
+	}
+
+	index, err := NewAgentIndex(p.environment, compositions)
+	if err != nil {
+		return nil, err
+	}
+
+	agentName, exists := index.Lookup(agentID)
+	if !exists {
+		return nil, fmt.Errorf("unknown agent ID %s in environment %s", agentID, p.environment)
+	}
+
+	if err := llm.ValidateGenerationConfig(generationConfig(compositions[agentName].Settings.Agent)); err != nil {
+		return nil, fmt.Errorf("invalid generation settings for agent %s in environment %s: %w", agentName, p.environment, err)
+	}
+
+	workspace, err := p.getWorkspace(compositions[agentName])
+	if err != nil {
+		return nil, err
+	}
+
+	return NewAgentConfiguration(
+		compositions[agentName],
+		compositions,
+		p.environment,
+		p,
+		WithContextProvider(p.contextProvider, workspace),
+	), nil
+}
+
+// getWorkspace configures and returns the workspace of the agent, nil without a workspace provider
+func (p *settingsProviderImpl) getWorkspace(agentConfig *AgentConfig) (provider.Workspace, error) {
+	if p.workspaceProvider == nil {
+		return nil, nil
+	}
+
+	err := p.workspaceProvider.Configure(agentConfig.AgentID, agentConfig.Settings.Agent.Workspace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure workspace for agent %s: %w", agentConfig.AgentID, err)
+	}
+
+	workspace, err := p.workspaceProvider.GetWorkspace(agentConfig.AgentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get workspace for agent %s: %w", agentConfig.AgentID, err)
+	}
+	return workspace, nil
+}
+
+func getenv(name, defaultValue string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,173 @@
+package config
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/denkhaus/agents/provider"
+	"github.com/denkhaus/agents/shared"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"trpc.group/trpc-go/trpc-agent-go/agent/llmagent"
+	"trpc.group/trpc-go/trpc-agent-go/session"
+	"trpc.group/trpc-go/trpc-agent-go/tool"
+)
+
+type recordingAgentProvider struct {
+	requested []uuid.UUID
+}
+
+func (p *recordingAgentProvider) GetAgent(ctx context.Context, agentID uuid.UUID, opt ...provider.AgentProviderOption) (shared.TheAgent, error) {
+	p.requested = append(p.requested, agentID)
+	return shared.NewAgent(llmagent.New(agentID.String()), agentID, false), nil
+}
+
+func (p *recordingAgentProvider) Close(agentID uuid.UUID) error {
+	return nil
+}
+
+func (p *recordingAgentProvider) Reload(ctx context.Context, agentID uuid.UUID) error {
+	return nil
+}
+
+func TestSettingsProvider_GetActiveAgents(t *testing.T) {
+	settingsProvider := NewCUESettingsProvider(NewCUEConfigProvider(testConfigPath), "production")
+
+	agents, err := settingsProvider.GetActiveAgents(true)
+	require.NoError(t, err)
+	require.Len(t, agents, 3)
+
+	assert.Equal(t, "coder", agents[0].Name)
+	assert.Equal(t, shared.AgentIDCoder, agents[0].ID())
+	assert.True(t, agents[0].IsStreaming())
+	assert.Equal(t, "researcher", agents[1].Name)
+	assert.True(t, agents[2].Equal(shared.AgentInfoHuman))
+}
+
+func TestSettingsProvider_GetAgentConfiguration(t *testing.T) {
+	settingsProvider := NewCUESettingsProvider(NewCUEConfigProvider(testConfigPath), "production")
+
+	agentConfig, err := settingsProvider.GetAgentConfiguration(shared.AgentIDCoder)
+	require.NoError(t, err)
+
+	assert.Equal(t, "coder", agentConfig.GetName())
+	assert.Equal(t, shared.AgentTypeDefault, agentConfig.GetType())
+	assert.True(t, agentConfig.IsStreamingEnabled())
+
+	_, err = settingsProvider.GetAgentConfiguration(uuid.New())
+	assert.ErrorContains(t, err, "unknown agent ID")
+}
+
+func TestAgentConfiguration_GetDefaultOptions(t *testing.T) {
+	researcherID := uuid.New()
+	compositions := map[string]*AgentConfig{
+		"lead": {
+			AgentID: uuid.New(),
+			Name:    "lead",
+			Type:    shared.AgentTypeDefault,
+			Prompt:  PromptConfig{Content: "Tools:{{range .tool_info}} {{.Name}}{{end}}"},
+			Settings: SettingsConfig{Agent: AgentSettings{
+				LLM:       LLMSettings{Model: "gpt-4o", Provider: shared.ModelProviderOpenAI},
+				SubAgents: []string{"researcher"},
+			}},
+		},
+		"researcher": {AgentID: researcherID, Name: "researcher"},
+	}
+
+	configProvider := new(mockConfigProvider)
+	configProvider.On("LoadAgentCompositions", "production").Return(compositions, nil)
+	settingsProvider := NewCUESettingsProvider(configProvider, "production")
+
+	agentProvider := &recordingAgentProvider{}
+	agentConfig := NewAgentConfiguration(compositions["lead"], compositions, "production", settingsProvider)
+
+	options, err := agentConfig.GetDefaultOptions(context.Background(), agentProvider,
+		llmagent.WithTools([]tool.Tool{&fakeTool{name: "calculator"}}),
+	)
+	require.NoError(t, err)
+
+	var llmOptions llmagent.Options
+	for _, opt := range options {
+		opt(&llmOptions)
+	}
+
+	assert.Equal(t, "Tools: calculator", llmOptions.Instruction)
+	assert.Len(t, llmOptions.SubAgents, 1)
+	assert.Equal(t, []uuid.UUID{researcherID}, agentProvider.requested)
+}
+
+func TestAgentConfiguration_GetInstructionRenderer(t *testing.T) {
+	compositions := map[string]*AgentConfig{
+		"lead": {
+			AgentID: uuid.New(),
+			Name:    "lead",
+			Type:    shared.AgentTypeDefault,
+			Prompt: PromptConfig{
+				Content: "Agents:{{range .agent_info}} {{.Name}}{{end}} Ticket: {{.session_state.ticket}}",
+				Schema: map[string]interface{}{
+					"type":       "object",
+					"properties": map[string]interface{}{"session_state": map[string]interface{}{"type": "object"}},
+				},
+			},
+			Settings: SettingsConfig{Agent: AgentSettings{
+				PromptContext: provider.PromptContextSettings{SessionState: []string{"ticket"}},
+			}},
+		},
+		"researcher": {AgentID: uuid.New(), Name: "researcher"},
+	}
+
+	configProvider := new(mockConfigProvider)
+	configProvider.On("LoadAgentCompositions", "production").Return(compositions, nil)
+	settingsProvider := NewCUESettingsProvider(configProvider, "production")
+	agentConfig := NewAgentConfiguration(compositions["lead"], compositions, "production", settingsProvider)
+
+	renderer, err := agentConfig.GetInstructionRenderer(context.Background())
+	require.NoError(t, err)
+
+	sess := &session.Session{State: session.StateMap{"ticket": []byte("AG-42")}}
+	instruction, err := renderer.Render(context.Background(), 0, sess)
+	require.NoError(t, err)
+	assert.Contains(t, instruction, " researcher")
+	assert.Contains(t, instruction, "Ticket: AG-42")
+
+	// The instruction follows the agents and the session state of the invocation
+	delete(compositions, "researcher")
+	sess.State["ticket"] = []byte("AG-43")
+
+	instruction, err = renderer.Render(context.Background(), 0, sess)
+	require.NoError(t, err)
+	assert.NotContains(t, instruction, "researcher")
+	assert.Contains(t, instruction, "Ticket: AG-43")
+}
+
+func TestAgentConfiguration_GetInstructionRenderer_TimeDependent(t *testing.T) {
+	compositions := map[string]*AgentConfig{
+		"lead": {
+			AgentID: uuid.New(),
+			Name:    "lead",
+			Type:    shared.AgentTypeDefault,
+			Prompt:  PromptConfig{Content: "Rendered at {{(now).UnixNano}}"},
+		},
+	}
+
+	configProvider := new(mockConfigProvider)
+	configProvider.On("LoadAgentCompositions", "production").Return(compositions, nil)
+	settingsProvider := NewCUESettingsProvider(configProvider, "production")
+	agentConfig := NewAgentConfiguration(compositions["lead"], compositions, "production", settingsProvider)
+
+	docs := &fakeToolSet{}
+	renderer, err := agentConfig.GetInstructionRenderer(context.Background(), llmagent.WithToolSets([]tool.ToolSet{docs}))
+	require.NoError(t, err)
+
+	first, err := renderer.Render(context.Background(), 0, nil)
+	require.NoError(t, err)
+	time.Sleep(time.Millisecond)
+	second, err := renderer.Render(context.Background(), 0, nil)
+	require.NoError(t, err)
+
+	// Templates calling now are rendered on every invocation, the tools are listed once
+	assert.NotEqual(t, first, second)
+	assert.Equal(t, 1, docs.listed)
+}
@@ -0,0 +1,3 @@
+canary: {
+	extends: "staging"
+}
@@ -0,0 +1,4 @@
+development: {
+	extends: "production"
+	agents: coder: settings: agent: llm: model: "gpt-4o-mini"
+}
@@ -0,0 +1,23 @@
+production: {
+	agents: {
+		coder: {
+			agent_id:         "550e8400-e29b-41d4-a716-446655440001"
+			name:             "coder"
+			type:             "default"
+			prompt_version:   "v1"
+			settings_profile: "default"
+			tool_profile:     "coder"
+		}
+		researcher: {
+			agent_id: "550e8400-e29b-41d4-a716-446655440004"
+			name:     "researcher"
+			type:     "default"
+			prompt: content: "You research topics on the web."
+			settings: agent: llm: {
+				model:    "gpt-4o"
+				provider: "openai"
+				api_key:  "env:TEST_RESEARCHER_API_KEY:sk-test-default"
+			}
+		}
+	}
+}
@@ -0,0 +1,3 @@
+staging: {
+	extends: "canary"
+}
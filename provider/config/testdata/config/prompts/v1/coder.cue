@@ -0,0 +1,4 @@
+coder: {
+	name:    "coder-prompt"
+	content: "You write idiomatic Go code."
+}
@@ -0,0 +1,12 @@
+coder: {
+	name: "coder-settings"
+	agent: {
+		max_tokens:        2000
+		streaming_enabled: true
+		llm: {
+			model:       "gpt-4o"
+			provider:    "openai"
+			temperature: 0.7
+		}
+	}
+}
@@ -0,0 +1,8 @@
+coder: {
+	name: "coder-tools"
+	tools: calculator: enabled: true
+	toolsets: shell_toolset: {
+		enabled: true
+		config: timeout: 30
+	}
+}
@@ -0,0 +1,52 @@
+package config
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"trpc.group/trpc-go/trpc-agent-go/tool"
+)
+
+func TestCUEToolFactory_CreateTools(t *testing.T) {
+	registry := NewToolRegistry()
+	docs := &fakeToolSet{}
+	require.NoError(t, registry.RegisterTool("calculator", nil, func(config map[string]interface{}) (tool.Tool, error) {
+		return &fakeTool{name: "calculator"}, nil
+	}))
+	require.NoError(t, registry.RegisterToolSet("docs", nil, func(config map[string]interface{}) (tool.ToolSet, error) {
+		return docs, nil
+	}))
+
+	tools, toolsets, err := NewCUEToolFactoryWithRegistry(registry).CreateTools(ToolsConfig{
+		Tools:    map[string]ToolConfig{"calculator": {Enabled: true}},
+		ToolSets: map[string]ToolSetConfig{"docs": {Enabled: true}, "search": {Enabled: false}},
+	})
+
+	require.NoError(t, err)
+	assert.Len(t, tools, 1)
+	assert.Equal(t, []tool.ToolSet{docs}, toolsets)
+	assert.False(t, docs.closed)
+}
+
+func TestCUEToolFactory_CreateTools_ToolSetFails(t *testing.T) {
+	registry := NewToolRegistry()
+	docs := &fakeToolSet{}
+	require.NoError(t, registry.RegisterToolSet("docs", nil, func(config map[string]interface{}) (tool.ToolSet, error) {
+		return docs, nil
+	}))
+	require.NoError(t, registry.RegisterToolSet("search", nil, func(config map[string]interface{}) (tool.ToolSet, error) {
+		return nil, errors.New("index unavailable")
+	}))
+
+	// Toolsets are created in the order of their names, docs before search
+	tools, toolsets, err := NewCUEToolFactoryWithRegistry(registry).CreateTools(ToolsConfig{
+		ToolSets: map[string]ToolSetConfig{"docs": {Enabled: true}, "search": {Enabled: true}},
+	})
+
+	assert.ErrorContains(t, err, "failed to create toolset search: index unavailable")
+	assert.Nil(t, tools)
+	assert.Nil(t, toolsets)
+	assert.True(t, docs.closed)
+}
@@ -0,0 +1,228 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"trpc.group/trpc-go/trpc-agent-go/tool"
+)
+
+// ToolConstructor creates a tool from its resolved configuration map
+type ToolConstructor func(config map[string]interface{}) (tool.Tool, error)
+
+// ToolSetConstructor creates a toolset from its resolved configuration map
+type ToolSetConstructor func(config map[string]interface{}) (tool.ToolSet, error)
+
+// ConfigType names the type of a tool configuration value
+type ConfigType string
+
+const (
+	ConfigTypeString     ConfigType = "string"
+	ConfigTypeInt        ConfigType = "int"
+	ConfigTypeFloat      ConfigType = "float"
+	ConfigTypeBool       ConfigType = "bool"
+	ConfigTypeStringList ConfigType = "string_list"
+)
+
+// ConfigField describes a single key of a tool configuration block
+type ConfigField struct {
+	Type        ConfigType  `json:"type"`
+	Description string      `json:"description,omitempty"`
+	Required    bool        `json:"required,omitempty"`
+	Default     interface{} `json:"default,omitempty"`
+}
+
+// ConfigSchema describes the configuration block accepted by a tool or toolset, keyed by config key.
+// A nil schema disables validation, an empty schema accepts no config keys.
+type ConfigSchema map[string]ConfigField
+
+// ToolRegistration describes a registered tool or toolset constructor
+type ToolRegistration struct {
+	Name   string       `json:"name"`
+	Schema ConfigSchema `json:"schema"`
+}
+
+// ToolRegistry holds the tool and toolset constructors available to CUE tool profiles
+type ToolRegistry struct {
+	mu       sync.RWMutex
+	tools    map[string]toolEntry
+	toolSets map[string]toolSetEntry
+}
+
+type toolEntry struct {
+	schema      ConfigSchema
+	constructor ToolConstructor
+}
+
+type toolSetEntry struct {
+	schema      ConfigSchema
+	constructor ToolSetConstructor
+}
+
+// DefaultToolRegistry is the registry used by NewCUEToolFactory. The built-in tools register into it.
+var DefaultToolRegistry = NewToolRegistry()
+
+// NewToolRegistry creates an empty tool registry
+func NewToolRegistry() *ToolRegistry {
+	return &ToolRegistry{
+		tools:    make(map[string]toolEntry),
+		toolSets: make(map[string]toolSetEntry),
+	}
+}
+
+// RegisterTool registers a tool constructor under the given name
+func (r *ToolRegistry) RegisterTool(name string, schema ConfigSchema, constructor ToolConstructor) error {
+	if name == "" {
+		return fmt.Errorf("tool name cannot be empty")
+	}
+	if constructor == nil {
+		return fmt.Errorf("constructor for tool %s cannot be nil", name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.tools[name]; exists {
+		return fmt.Errorf("tool %s is already registered", name)
+	}
+
+	r.tools[name] = toolEntry{schema: schema, constructor: constructor}
+	return nil
+}
+
+// RegisterToolSet registers a toolset constructor under the given name
+func (r *ToolRegistry) RegisterToolSet(name string, schema ConfigSchema, constructor ToolSetConstructor) error {
+	if name == "" {
+		return fmt.Errorf("toolset name cannot be empty")
+	}
+	if constructor == nil {
+		return fmt.Errorf("constructor for toolset %s cannot be nil", name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.toolSets[name]; exists {
+		return fmt.Errorf("toolset %s is already registered", name)
+	}
+
+	r.toolSets[name] = toolSetEntry{schema: schema, constructor: constructor}
+	return nil
+}
+
+// NewTool creates the tool registered under the given name.
+// The configuration is validated against the tool's schema before the constructor is called.
+func (r *ToolRegistry) NewTool(name string, config map[string]interface{}) (tool.Tool, error) {
+	r.mu.RLock()
+	entry, exists := r.tools[name]
+	r.mu.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("unknown tool: %s", name)
+	}
+
+	applied, err := entry.schema.Apply(config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config for tool %s: %w", name, err)
+	}
+
+	return entry.constructor(applied)
+}
+
+// NewToolSet creates the toolset registered under the given name.
+// The configuration is validated against the toolset's schema before the constructor is called.
+func (r *ToolRegistry) NewToolSet(name string, config map[string]interface{}) (tool.ToolSet, error) {
+	r.mu.RLock()
+	entry, exists := r.toolSets[name]
+	r.mu.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("unknown toolset: %s", name)
+	}
+
+	applied, err := entry.schema.Apply(config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config for toolset %s: %w", name, err)
+	}
+
+	return entry.constructor(applied)
+}
+
+// Tools returns all registered tools sorted by name
+func (r *ToolRegistry) Tools() []ToolRegistration {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	registrations := make([]ToolRegistration, 0, len(r.tools))
+	for name, entry := range r.tools {
+		registrations = append(registrations, ToolRegistration{Name: name, Schema: entry.schema})
+	}
+
+	sortRegistrations(registrations)
+	return registrations
+}
+
+// ToolSets returns all registered toolsets sorted by name
+func (r *ToolRegistry) ToolSets() []ToolRegistration {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	registrations := make([]ToolRegistration, 0, len(r.toolSets))
+	for name, entry := range r.toolSets {
+		registrations = append(registrations, ToolRegistration{Name: name, Schema: entry.schema})
+	}
+
+	sortRegistrations(registrations)
+	return registrations
+}
+
+// Validate checks that every tool and toolset referenced by the configuration is registered
+func (r *ToolRegistry) Validate(toolsConfig ToolsConfig) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for toolName, toolConfig := range toolsConfig.Tools {
+		if _, exists := r.tools[toolName]; !exists && toolConfig.Enabled {
+			return fmt.Errorf("unknown tool: %s", toolName)
+		}
+	}
+
+	for toolSetName, toolSetConfig := range toolsConfig.ToolSets {
+		if _, exists := r.toolSets[toolSetName]; !exists && toolSetConfig.Enabled {
+			return fmt.Errorf("unknown toolset: %s", toolSetName)
+		}
+	}
+
+	return nil
+}
+
+func sortRegistrations(registrations []ToolRegistration) {
+	sort.Slice(registrations, func(i, j int) bool {
+		return registrations[i].Name < registrations[j].Name
+	})
+}
+
+// RegisterTool registers a tool constructor in the default registry
+func RegisterTool(name string, schema ConfigSchema, constructor ToolConstructor) error {
+	return DefaultToolRegistry.RegisterTool(name, schema, constructor)
+}
+
+// RegisterToolSet registers a toolset constructor in the default registry
+func RegisterToolSet(name string, schema ConfigSchema, constructor ToolSetConstructor) error {
+	return DefaultToolRegistry.RegisterToolSet(name, schema, constructor)
+}
+
+// MustRegisterTool registers a tool constructor in the default registry and panics on error
+func MustRegisterTool(name string, schema ConfigSchema, constructor ToolConstructor) {
+	if err := RegisterTool(name, schema, constructor); err != nil {
+		panic(err.Error())
+	}
+}
+
+// MustRegisterToolSet registers a toolset constructor in the default registry and panics on error
+func MustRegisterToolSet(name string, schema ConfigSchema, constructor ToolSetConstructor) {
+	if err := RegisterToolSet(name, schema, constructor); err != nil {
+		panic(err.Error())
+	}
+}
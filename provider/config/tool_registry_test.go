@@ -0,0 +1,80 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/denkhaus/agents/tools/calculator"
+	"github.com/denkhaus/agents/tools/shell"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"trpc.group/trpc-go/trpc-agent-go/tool"
+)
+
+func TestToolRegistry_RegisterAndCreate(t *testing.T) {
+	registry := NewToolRegistry()
+
+	var received map[string]interface{}
+	err := registry.RegisterTool("inhouse", ConfigSchema{
+		"endpoint": {Type: ConfigTypeString, Required: true},
+	}, func(config map[string]interface{}) (tool.Tool, error) {
+		received = config
+		return &fakeTool{name: "inhouse"}, nil
+	})
+	require.NoError(t, err)
+
+	created, err := registry.NewTool("inhouse", map[string]interface{}{"endpoint": "http://localhost"})
+	require.NoError(t, err)
+	assert.Equal(t, "inhouse", created.Declaration().Name)
+	assert.Equal(t, "http://localhost", received["endpoint"])
+
+	registrations := registry.Tools()
+	require.Len(t, registrations, 1)
+	assert.Equal(t, "inhouse", registrations[0].Name)
+	assert.Equal(t, ConfigTypeString, registrations[0].Schema["endpoint"].Type)
+}
+
+func TestToolRegistry_DuplicateRegistration(t *testing.T) {
+	registry := NewToolRegistry()
+	constructor := func(config map[string]interface{}) (tool.ToolSet, error) {
+		return &fakeToolSet{}, nil
+	}
+
+	require.NoError(t, registry.RegisterToolSet("inhouse", nil, constructor))
+	assert.ErrorContains(t, registry.RegisterToolSet("inhouse", nil, constructor), "already registered")
+}
+
+func TestToolRegistry_Validate(t *testing.T) {
+	registry := NewToolRegistry()
+	require.NoError(t, registry.RegisterToolSet("inhouse", nil, func(config map[string]interface{}) (tool.ToolSet, error) {
+		return &fakeToolSet{}, nil
+	}))
+
+	assert.NoError(t, registry.Validate(ToolsConfig{
+		ToolSets: map[string]ToolSetConfig{"inhouse": {Enabled: true}},
+	}))
+
+	assert.ErrorContains(t, registry.Validate(ToolsConfig{
+		Tools: map[string]ToolConfig{"missing": {Enabled: true}},
+	}), "unknown tool: missing")
+
+	// Disabled tools need not be registered
+	assert.NoError(t, registry.Validate(ToolsConfig{
+		Tools:    map[string]ToolConfig{"missing": {Enabled: false}},
+		ToolSets: map[string]ToolSetConfig{"optional": {Enabled: false}},
+	}))
+}
+
+func TestDefaultToolRegistry_BuiltinTools(t *testing.T) {
+	var toolNames, toolSetNames []string
+	for _, registration := range DefaultToolRegistry.Tools() {
+		toolNames = append(toolNames, registration.Name)
+	}
+	for _, registration := range DefaultToolRegistry.ToolSets() {
+		toolSetNames = append(toolSetNames, registration.Name)
+	}
+
+	assert.Contains(t, toolNames, calculator.ToolName)
+	assert.Contains(t, toolNames, DuckDuckGoToolName)
+	assert.Contains(t, toolSetNames, shell.ToolSetName)
+	assert.Contains(t, toolSetNames, FileToolSetName)
+}
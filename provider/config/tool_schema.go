@@ -0,0 +1,288 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ConfigValueError reports an invalid value in a tool configuration block
+type ConfigValueError struct {
+	// Path is the CUE path of the offending value, e.g. production.agents.coder.tools.toolsets.shell_toolset.config.timeout
+	Path string
+	Err  error
+}
+
+func (e *ConfigValueError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Err.Error())
+}
+
+func (e *ConfigValueError) Unwrap() error {
+	return e.Err
+}
+
+// Apply validates a configuration map against the schema. It returns a new map
+// with defaults filled in and values coerced to their declared types.
+// A nil schema accepts any configuration unchanged.
+func (s ConfigSchema) Apply(config map[string]interface{}) (map[string]interface{}, error) {
+	if s == nil {
+		return config, nil
+	}
+
+	applied := make(map[string]interface{}, len(s))
+	for _, key := range sortedKeys(config) {
+		field, exists := s[key]
+		if !exists {
+			return nil, &ConfigValueError{Path: key, Err: fmt.Errorf("unknown config key, expected one of [%s]", strings.Join(s.keys(), ", "))}
+		}
+
+		value, err := field.Type.Coerce(config[key])
+		if err != nil {
+			return nil, &ConfigValueError{Path: key, Err: err}
+		}
+		applied[key] = value
+	}
+
+	for _, key := range s.keys() {
+		if _, exists := applied[key]; exists {
+			continue
+		}
+
+		field := s[key]
+		if field.Required {
+			return nil, &ConfigValueError{Path: key, Err: fmt.Errorf("required config key is missing")}
+		}
+		if field.Default != nil {
+			applied[key] = field.Default
+		}
+	}
+
+	return applied, nil
+}
+
+// JSONSchema returns the schema as a JSON schema document
+func (s ConfigSchema) JSONSchema() map[string]interface{} {
+	properties := make(map[string]interface{}, len(s))
+	required := []string{}
+
+	for _, key := range s.keys() {
+		field := s[key]
+		property := map[string]interface{}{}
+
+		switch field.Type {
+		case ConfigTypeString:
+			property["type"] = "string"
+		case ConfigTypeInt:
+			property["type"] = "integer"
+		case ConfigTypeFloat:
+			property["type"] = "number"
+		case ConfigTypeBool:
+			property["type"] = "boolean"
+		case ConfigTypeStringList:
+			property["type"] = "array"
+			property["items"] = map[string]interface{}{"type": "string"}
+		}
+
+		if field.Description != "" {
+			property["description"] = field.Description
+		}
+		if field.Default != nil {
+			property["default"] = field.Default
+		}
+		if field.Required {
+			required = append(required, key)
+		}
+
+		properties[key] = property
+	}
+
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}
+
+func (s ConfigSchema) keys() []string {
+	return sortedKeys(s)
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Coerce converts a decoded configuration value to the Go type of the config type.
+// Numbers are accepted regardless of how they were decoded, numeric and boolean
+// strings (e.g. resolved from environment variables) are parsed.
+func (t ConfigType) Coerce(value interface{}) (interface{}, error) {
+	switch t {
+	case ConfigTypeString:
+		if v, ok := value.(string); ok {
+			return v, nil
+		}
+	case ConfigTypeInt:
+		if v, ok := toFloat(value); ok {
+			if v != math.Trunc(v) {
+				return nil, fmt.Errorf("expected int, got fractional number %v", value)
+			}
+			return int(v), nil
+		}
+	case ConfigTypeFloat:
+		if v, ok := toFloat(value); ok {
+			return v, nil
+		}
+	case ConfigTypeBool:
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case string:
+			if b, err := strconv.ParseBool(v); err == nil {
+				return b, nil
+			}
+		}
+	case ConfigTypeStringList:
+		switch v := value.(type) {
+		case []string:
+			return v, nil
+		case []interface{}:
+			list := make([]string, 0, len(v))
+			for i, item := range v {
+				str, ok := item.(string)
+				if !ok {
+					return nil, fmt.Errorf("expected string at index %d, got %T", i, item)
+				}
+				list = append(list, str)
+			}
+			return list, nil
+		}
+	default:
+		return nil, fmt.Errorf("unsupported config type %s", t)
+	}
+
+	return nil, fmt.Errorf("expected %s, got %T %v", t, value, value)
+}
+
+// toFloat converts any numeric value or numeric string to float64
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// ApplySchemas validates every tool and toolset configuration block against
+// the schema of its registered constructor, filling in defaults and coercing
+// values in place. basePath is the CUE path of the tools block and is used to
+// report the exact location of an invalid value. Disabled entries are skipped,
+// so configs may disable tools that are not registered in this build.
+func (r *ToolRegistry) ApplySchemas(toolsConfig *ToolsConfig, basePath string) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, toolName := range sortedKeys(toolsConfig.Tools) {
+		toolConfig := toolsConfig.Tools[toolName]
+		if !toolConfig.Enabled {
+			continue
+		}
+
+		entry, exists := r.tools[toolName]
+		path := joinPath(basePath, "tools", toolName)
+		if !exists {
+			return &ConfigValueError{Path: path, Err: fmt.Errorf("unknown tool: %s", toolName)}
+		}
+
+		applied, err := applySchemaAt(entry.schema, toolConfig.Config, joinPath(path, "config"))
+		if err != nil {
+			return err
+		}
+		toolConfig.Config = applied
+		toolsConfig.Tools[toolName] = toolConfig
+	}
+
+	for _, toolSetName := range sortedKeys(toolsConfig.ToolSets) {
+		toolSetConfig := toolsConfig.ToolSets[toolSetName]
+		if !toolSetConfig.Enabled {
+			continue
+		}
+
+		entry, exists := r.toolSets[toolSetName]
+		path := joinPath(basePath, "toolsets", toolSetName)
+		if !exists {
+			return &ConfigValueError{Path: path, Err: fmt.Errorf("unknown toolset: %s", toolSetName)}
+		}
+
+		applied, err := applySchemaAt(entry.schema, toolSetConfig.Config, joinPath(path, "config"))
+		if err != nil {
+			return err
+		}
+		toolSetConfig.Config = applied
+		toolsConfig.ToolSets[toolSetName] = toolSetConfig
+	}
+
+	return nil
+}
+
+// applySchemaAt applies a schema and prefixes errors with the given path
+func applySchemaAt(schema ConfigSchema, config map[string]interface{}, path string) (map[string]interface{}, error) {
+	applied, err := schema.Apply(config)
+	if err != nil {
+		return nil, withPathPrefix(path, err)
+	}
+	return applied, nil
+}
+
+// withPathPrefix reports err at path, a ConfigValueError keeps its relative path below it
+func withPathPrefix(path string, err error) error {
+	if valueErr, ok := err.(*ConfigValueError); ok {
+		return &ConfigValueError{Path: joinPath(path, valueErr.Path), Err: valueErr.Err}
+	}
+	return &ConfigValueError{Path: path, Err: err}
+}
+
+func joinPath(elements ...string) string {
+	var parts []string
+	for _, element := range elements {
+		if element != "" {
+			parts = append(parts, element)
+		}
+	}
+	return strings.Join(parts, ".")
+}
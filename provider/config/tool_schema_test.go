@@ -0,0 +1,109 @@
+package config
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/denkhaus/agents/tools/shell"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigSchema_Apply(t *testing.T) {
+	schema := ConfigSchema{
+		"timeout":          {Type: ConfigTypeInt},
+		"ratio":            {Type: ConfigTypeFloat},
+		"execute_enabled":  {Type: ConfigTypeBool, Default: true},
+		"allowed_commands": {Type: ConfigTypeStringList},
+		"endpoint":         {Type: ConfigTypeString, Required: true},
+	}
+
+	applied, err := schema.Apply(map[string]interface{}{
+		"timeout":          float64(30),
+		"ratio":            "0.5",
+		"allowed_commands": []interface{}{"ls", "cat"},
+		"endpoint":         "http://localhost",
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, 30, applied["timeout"])
+	assert.Equal(t, 0.5, applied["ratio"])
+	assert.Equal(t, true, applied["execute_enabled"])
+	assert.Equal(t, []string{"ls", "cat"}, applied["allowed_commands"])
+	assert.Equal(t, "http://localhost", applied["endpoint"])
+}
+
+func TestConfigSchema_ApplyErrors(t *testing.T) {
+	schema := ConfigSchema{
+		"timeout":  {Type: ConfigTypeInt},
+		"endpoint": {Type: ConfigTypeString, Required: true},
+	}
+
+	tests := []struct {
+		name   string
+		config map[string]interface{}
+		err    string
+	}{
+		{"fractional int", map[string]interface{}{"endpoint": "x", "timeout": 1.5}, "timeout: expected int, got fractional number 1.5"},
+		{"wrong type", map[string]interface{}{"endpoint": "x", "timeout": true}, "timeout: expected int, got bool true"},
+		{"unknown key", map[string]interface{}{"endpoint": "x", "retries": 3}, "retries: unknown config key, expected one of [endpoint, timeout]"},
+		{"missing required", map[string]interface{}{"timeout": 3}, "endpoint: required config key is missing"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := schema.Apply(tt.config)
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
+
+func TestToolRegistry_ApplySchemas(t *testing.T) {
+	toolsConfig := ToolsConfig{
+		ToolSets: map[string]ToolSetConfig{
+			shell.ToolSetName: {
+				Enabled: true,
+				Config:  map[string]interface{}{"timeout": "soon"},
+			},
+		},
+	}
+
+	err := DefaultToolRegistry.ApplySchemas(&toolsConfig, "production.agents.coder.tools")
+
+	var valueErr *ConfigValueError
+	require.True(t, errors.As(err, &valueErr))
+	assert.Equal(t, "production.agents.coder.tools.toolsets.shell_toolset.config.timeout", valueErr.Path)
+}
+
+func TestToolRegistry_ApplySchemasSkipsDisabled(t *testing.T) {
+	toolsConfig := ToolsConfig{
+		Tools: map[string]ToolConfig{
+			"optional": {Enabled: false, Config: map[string]interface{}{"any": "value"}},
+		},
+		ToolSets: map[string]ToolSetConfig{
+			"inhouse": {Enabled: false},
+		},
+	}
+
+	assert.NoError(t, DefaultToolRegistry.ApplySchemas(&toolsConfig, "profile"))
+
+	toolsConfig.Tools["optional"] = ToolConfig{Enabled: true}
+	assert.ErrorContains(t, DefaultToolRegistry.ApplySchemas(&toolsConfig, "profile"), "unknown tool: optional")
+}
+
+func TestToolRegistry_ShellTimeoutCoercion(t *testing.T) {
+	toolsConfig := ToolsConfig{
+		ToolSets: map[string]ToolSetConfig{
+			shell.ToolSetName: {
+				Enabled: true,
+				Config:  map[string]interface{}{"timeout": float64(30)},
+			},
+		},
+	}
+
+	require.NoError(t, DefaultToolRegistry.ApplySchemas(&toolsConfig, "profile"))
+
+	config := toolsConfig.ToolSets[shell.ToolSetName].Config
+	assert.Equal(t, 30, config["timeout"])
+	assert.Equal(t, true, config["execute_enabled"])
+}
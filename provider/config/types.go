@@ -3,6 +3,8 @@ package config
 import (
 	"context"
 
+	"github.com/denkhaus/agents/provider"
+	"github.com/denkhaus/agents/provider/llm"
 	"github.com/denkhaus/agents/shared"
 	"github.com/google/uuid"
 	"trpc.group/trpc-go/trpc-agent-go/tool"
@@ -10,13 +12,20 @@ import (
 
 // AgentConfig represents a complete agent configuration
 type AgentConfig struct {
-	AgentID     uuid.UUID      `json:"agent_id"`
-	Name        string         `json:"name"`
-	Description string         `json:"description,omitempty"`
+	AgentID     uuid.UUID        `json:"agent_id"`
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
 	Type        shared.AgentType `json:"type"`
-	Prompt      PromptConfig   `json:"prompt"`
-	Settings    SettingsConfig `json:"settings"`
-	Tools       ToolsConfig    `json:"tools"`
+	// References to shared profiles, inline values of the composition override them
+	PromptVersion   string         `json:"prompt_version,omitempty"`
+	SettingsProfile string         `json:"settings_profile,omitempty"`
+	ToolProfile     string         `json:"tool_profile,omitempty"`
+	Prompt          PromptConfig   `json:"prompt"`
+	Settings        SettingsConfig `json:"settings"`
+	Tools           ToolsConfig    `json:"tools"`
+
+	// secretPaths lists the JSON paths of values resolved from secret references
+	secretPaths []string
 }
 
 // PromptConfig represents prompt configuration
@@ -39,35 +48,72 @@ type SettingsConfig struct {
 
 // AgentSettings represents the agent runtime settings
 type AgentSettings struct {
-	ApplicationName   string      `json:"application_name"`
-	PlanningEnabled   bool        `json:"planning_enabled"`
-	ReactEnabled      bool        `json:"react_enabled"`
-	MaxIterations     int         `json:"max_iterations"`
-	Timeout           int         `json:"timeout"`
-	StreamingEnabled  bool        `json:"streaming_enabled"`
-	ChannelBufferSize int         `json:"channel_buffer_size"`
-	MaxTokens         int         `json:"max_tokens"`
-	Temperature       float64     `json:"temperature"`
-	LLM               LLMSettings `json:"llm"`
+	Role              shared.AgentRole `json:"role,omitempty"`
+	ApplicationName   string           `json:"application_name"`
+	PlanningEnabled   bool             `json:"planning_enabled"`
+	ReactEnabled      bool             `json:"react_enabled"`
+	MaxIterations     int              `json:"max_iterations"`
+	Timeout           int              `json:"timeout"`
+	StreamingEnabled  bool             `json:"streaming_enabled"`
+	ChannelBufferSize int              `json:"channel_buffer_size"`
+	MaxTokens         int              `json:"max_tokens"`
+	Temperature       float64          `json:"temperature"`
+	LLM               LLMSettings      `json:"llm"`
 	// Fields specific to different agent types
-	SubAgents         []uuid.UUID `json:"sub_agents,omitempty"`
-	InputSchema       map[string]interface{} `json:"input_schema,omitempty"`
-	OutputSchema      map[string]interface{} `json:"output_schema,omitempty"`
-	OutputKey         string      `json:"output_key,omitempty"`
+	// SubAgents references agents of the same environment by UUID or by name
+	SubAgents    []string               `json:"sub_agents,omitempty"`
+	InputSchema  map[string]interface{} `json:"input_schema,omitempty"`
+	OutputSchema map[string]interface{} `json:"output_schema,omitempty"`
+	OutputKey    string                 `json:"output_key,omitempty"`
+	// PromptContext configures the context contributors the prompt is rendered with
+	PromptContext provider.PromptContextSettings `json:"prompt_context,omitempty"`
+	// Workspace configures the directory the agent and its shell and file toolsets work in
+	Workspace provider.WorkspaceSettings `json:"workspace,omitempty"`
+	// Graph declares the workflow of a graph agent, nodes reference agents by UUID or by name
+	Graph provider.GraphSettings `json:"graph,omitempty"`
+	// Router declares how a router agent delegates to its sub-agents, agents are referenced by UUID or by name
+	Router provider.RouterSettings `json:"router,omitempty"`
+}
+
+// agentRefs returns the references to other agents, the sub-agents, the
+// router fallback and the agents of the graph nodes
+func (s AgentSettings) agentRefs() []string {
+	refs := append([]string(nil), s.SubAgents...)
+	if s.Router.Fallback != "" {
+		refs = append(refs, s.Router.Fallback)
+	}
+	for _, node := range s.Graph.Nodes {
+		if node.Agent != "" {
+			refs = append(refs, node.Agent)
+		}
+	}
+	return refs
 }
 
 // LLMSettings represents LLM configuration
 type LLMSettings struct {
-	Model             string  `json:"model"`
-	Temperature       float64 `json:"temperature"`
-	MaxTokens         int     `json:"max_tokens"`
-	TopP              float64 `json:"top_p"`
-	FrequencyPenalty  float64 `json:"frequency_penalty"`
-	PresencePenalty   float64 `json:"presence_penalty"`
+	Model             string               `json:"model"`
+	Temperature       float64              `json:"temperature"`
+	MaxTokens         int                  `json:"max_tokens"`
+	TopP              *float64             `json:"top_p,omitempty"`
+	FrequencyPenalty  *float64             `json:"frequency_penalty,omitempty"`
+	PresencePenalty   *float64             `json:"presence_penalty,omitempty"`
+	Stop              []string             `json:"stop,omitempty"`
+	ReasoningEffort   *string              `json:"reasoning_effort,omitempty"`
+	ThinkingEnabled   *bool                `json:"thinking_enabled,omitempty"`
+	ThinkingTokens    *int                 `json:"thinking_tokens,omitempty"`
 	Provider          shared.ModelProvider `json:"provider"`
-	BaseURL           string  `json:"base_url,omitempty"`
-	APIKey            string  `json:"api_key,omitempty"`
-	ChannelBufferSize int     `json:"channel_buffer_size,omitempty"`
+	BaseURL           string               `json:"base_url,omitempty"`
+	APIKey            string               `json:"api_key,omitempty"`
+	ChannelBufferSize int                  `json:"channel_buffer_size,omitempty"`
+	// Options holds the provider specific options, see the llm package
+	Options map[string]interface{} `json:"options,omitempty"`
+	// Retry controls how often the model is retried before a fallback is taken
+	Retry llm.RetryPolicy `json:"retry,omitempty"`
+	// FailoverOn lists the error classes that move on to the next fallback
+	FailoverOn []llm.ErrorClass `json:"failover_on,omitempty"`
+	// Fallbacks are tried in order when the model fails, only the model fields are used
+	Fallbacks []LLMSettings `json:"fallbacks,omitempty"`
 }
 
 // ToolsConfig represents tool configuration
@@ -94,18 +140,28 @@ type ToolSetConfig struct {
 type AgentFactory interface {
 	CreateAgent(ctx context.Context, environment, agentName string) (shared.TheAgent, error)
 	CreateAgentByID(ctx context.Context, agentID uuid.UUID) (shared.TheAgent, error)
+	DefaultEnvironment() string
 	ValidateConfiguration() error
+	// ValidateEnvironment validates the configurations of a single environment
+	ValidateEnvironment(environment string) error
 	GetAgentConfig(environment, agentName string) (*AgentConfig, error)
+	GetAgentConfigs(environment string) (map[string]*AgentConfig, error)
+	DumpConfiguration(environment string) ([]byte, error)
+	RequiredVariables(environment string) ([]VariableRequirement, error)
 }
 
 // ToolFactory creates tools from configuration
 type ToolFactory interface {
 	CreateTools(toolsConfig ToolsConfig) ([]tool.Tool, []tool.ToolSet, error)
+	ValidateTools(toolsConfig ToolsConfig) error
 }
 
 // ConfigProvider loads configurations from various sources
 type ConfigProvider interface {
 	LoadAgentComposition(environment, agentName string) (*AgentConfig, error)
+	LoadAgentCompositions(environment string) (map[string]*AgentConfig, error)
+	ListEnvironments() ([]string, error)
+	RequiredVariables(environment string) ([]VariableRequirement, error)
 	LoadPrompt(agentName, version string) (*PromptConfig, error)
 	LoadSettings(agentName, profile string) (*SettingsConfig, error)
 	LoadToolProfile(profileName string) (*ToolsConfig, error)
@@ -0,0 +1,387 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/denkhaus/agents/logger"
+	"github.com/denkhaus/agents/shared"
+	"go.uber.org/zap"
+)
+
+// DefaultWatchInterval is the interval at which a ConfigWatcher polls the config directory
+const DefaultWatchInterval = 2 * time.Second
+
+// AgentSwapper receives the agents rebuilt by a configuration reload.
+// multi.ChatProcessor implements it.
+type AgentSwapper interface {
+	ReplaceAgents(agents ...shared.TheAgent) error
+}
+
+// AgentSwapperFunc adapts a function to an AgentSwapper, e.g. to register
+// rebuilt agents with an A2A server's MultiAgentManager
+type AgentSwapperFunc func(agents ...shared.TheAgent) error
+
+// ReplaceAgents calls f(agents...)
+func (f AgentSwapperFunc) ReplaceAgents(agents ...shared.TheAgent) error {
+	return f(agents...)
+}
+
+// ReloadResult describes an applied configuration reload
+type ReloadResult struct {
+	Environment string
+	// Rebuilt lists the agents that were rebuilt and swapped in
+	Rebuilt []string
+	// Removed lists agents no longer present in the configuration, they keep running
+	Removed []string
+}
+
+// WatcherOption configures a ConfigWatcher
+type WatcherOption func(*ConfigWatcher)
+
+// WithWatchInterval sets the polling interval
+func WithWatchInterval(interval time.Duration) WatcherOption {
+	return func(w *ConfigWatcher) {
+		w.interval = interval
+	}
+}
+
+// WithWatchedAgents restricts reloads to the named agents, by default all agents of the environment are rebuilt
+func WithWatchedAgents(agentNames ...string) WatcherOption {
+	return func(w *ConfigWatcher) {
+		w.agentNames = agentNames
+	}
+}
+
+// WithOnReload sets a callback invoked after a reload was applied
+func WithOnReload(onReload func(result ReloadResult)) WatcherOption {
+	return func(w *ConfigWatcher) {
+		w.onReload = onReload
+	}
+}
+
+// WithOnReloadError sets a callback invoked when a reload was rejected
+func WithOnReloadError(onError func(err error)) WatcherOption {
+	return func(w *ConfigWatcher) {
+		w.onError = onError
+	}
+}
+
+// ConfigWatcher watches a configuration directory and hot reloads the agents
+// of one environment. On change the configuration is validated, agents whose
+// resolved configuration or sub-agents changed are rebuilt and swapped in. If
+// validation or any rebuild fails the reload is rejected and the running
+// agents are kept.
+type ConfigWatcher struct {
+	factory     AgentFactory
+	configPath  string
+	environment string
+	swapper     AgentSwapper
+	interval    time.Duration
+	agentNames  []string
+	onReload    func(result ReloadResult)
+	onError     func(err error)
+
+	mu          sync.Mutex
+	stamps      map[string]fileStamp
+	fingerprint map[string]string
+}
+
+// fileStamp identifies the version of a file
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// NewConfigWatcher creates a watcher reloading the agents of environment
+// from configPath into swapper
+func NewConfigWatcher(factory AgentFactory, configPath, environment string, swapper AgentSwapper, opts ...WatcherOption) *ConfigWatcher {
+	w := &ConfigWatcher{
+		factory:     factory,
+		configPath:  configPath,
+		environment: environment,
+		swapper:     swapper,
+		interval:    DefaultWatchInterval,
+		onReload: func(result ReloadResult) {
+			logger.Log.Info("configuration reloaded",
+				zap.String("environment", result.Environment),
+				zap.Strings("rebuilt", result.Rebuilt),
+				zap.Strings("removed", result.Removed),
+			)
+		},
+		onError: func(err error) {
+			logger.Log.Error("configuration reload rejected", zap.String("environment", environment), zap.Error(err))
+		},
+	}
+
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	return w
+}
+
+// Start records the current configuration as baseline and polls for changes
+// until ctx is done. The agents running in the swapper are expected to have
+// been built from this baseline.
+func (w *ConfigWatcher) Start(ctx context.Context) error {
+	if err := w.init(); err != nil {
+		return err
+	}
+
+	w.watch(ctx)
+	return nil
+}
+
+// watch polls for changes and reloads until ctx is done
+func (w *ConfigWatcher) watch(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			stamps, changed, err := w.changed()
+			if err != nil {
+				w.onError(err)
+				continue
+			}
+			if !changed {
+				continue
+			}
+
+			// The stamps only advance once the reload succeeded, so a rejected
+			// reload is retried on the next tick
+			result, err := w.Reload(ctx)
+			if err != nil {
+				w.onError(err)
+				continue
+			}
+			w.setStamps(stamps)
+			w.onReload(*result)
+		}
+	}
+}
+
+// init records the file stamps and agent configurations of the baseline
+func (w *ConfigWatcher) init() error {
+	stamps, err := w.scan()
+	if err != nil {
+		return err
+	}
+
+	configs, err := w.factory.GetAgentConfigs(w.environment)
+	if err != nil {
+		return fmt.Errorf("failed to load environment %s: %w", w.environment, err)
+	}
+
+	fingerprint, err := fingerprintConfigs(configs)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.stamps = stamps
+	w.fingerprint = fingerprint
+	return nil
+}
+
+// changed reports whether any file below the config directory changed since
+// the last applied reload and returns the current stamps
+func (w *ConfigWatcher) changed() (map[string]fileStamp, bool, error) {
+	stamps, err := w.scan()
+	if err != nil {
+		return nil, false, err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(stamps) != len(w.stamps) {
+		return stamps, true, nil
+	}
+	for path, stamp := range stamps {
+		if w.stamps[path] != stamp {
+			return stamps, true, nil
+		}
+	}
+	return stamps, false, nil
+}
+
+// setStamps records the stamps of an applied reload
+func (w *ConfigWatcher) setStamps(stamps map[string]fileStamp) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.stamps = stamps
+}
+
+// scan stamps all files below the config directory
+func (w *ConfigWatcher) scan() (map[string]fileStamp, error) {
+	stamps := make(map[string]fileStamp)
+	err := filepath.WalkDir(w.configPath, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		stamps[path] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan config directory %s: %w", w.configPath, err)
+	}
+
+	return stamps, nil
+}
+
+// Reload validates the configuration, rebuilds the affected agents and swaps
+// them in. On error nothing is swapped and the running agents are kept.
+func (w *ConfigWatcher) Reload(ctx context.Context) (*ReloadResult, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.factory.ValidateEnvironment(w.environment); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	configs, err := w.factory.GetAgentConfigs(w.environment)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load environment %s: %w", w.environment, err)
+	}
+
+	fingerprint, err := fingerprintConfigs(configs)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ReloadResult{Environment: w.environment}
+	for agentName := range w.fingerprint {
+		if _, exists := configs[agentName]; !exists && w.isWatched(agentName) {
+			result.Removed = append(result.Removed, agentName)
+		}
+	}
+	sort.Strings(result.Removed)
+
+	var agents []shared.TheAgent
+	for _, agentName := range affectedAgents(configs, w.fingerprint, fingerprint) {
+		if !w.isWatched(agentName) {
+			continue
+		}
+
+		ag, err := w.factory.CreateAgent(ctx, w.environment, agentName)
+		if err != nil {
+			closeAgents(agents)
+			return nil, fmt.Errorf("failed to rebuild agent %s: %w", agentName, err)
+		}
+
+		agents = append(agents, ag)
+		result.Rebuilt = append(result.Rebuilt, agentName)
+	}
+
+	if len(agents) > 0 {
+		if err := w.swapper.ReplaceAgents(agents...); err != nil {
+			closeAgents(agents)
+			return nil, fmt.Errorf("failed to swap agents: %w", err)
+		}
+	}
+
+	w.fingerprint = fingerprint
+	return result, nil
+}
+
+func (w *ConfigWatcher) isWatched(agentName string) bool {
+	if len(w.agentNames) == 0 {
+		return true
+	}
+
+	for _, name := range w.agentNames {
+		if name == agentName {
+			return true
+		}
+	}
+	return false
+}
+
+// fingerprintConfigs encodes every agent configuration, secrets included, for change detection
+func fingerprintConfigs(configs map[string]*AgentConfig) (map[string]string, error) {
+	fingerprint := make(map[string]string, len(configs))
+	for agentName, agentConfig := range configs {
+		data, err := json.Marshal(plainAgentConfig(*agentConfig))
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode agent config %s: %w", agentName, err)
+		}
+		fingerprint[agentName] = string(data)
+	}
+	return fingerprint, nil
+}
+
+// affectedAgents returns, sorted by name, the agents whose configuration
+// changed and all agents that use one of them as a direct or indirect sub-agent
+func affectedAgents(configs map[string]*AgentConfig, before, after map[string]string) []string {
+	affected := make(map[string]bool)
+	for agentName, fingerprint := range after {
+		if before[agentName] != fingerprint {
+			affected[agentName] = true
+		}
+	}
+
+	// Propagate changes to parents until no new agent is affected
+	for grown := true; grown; {
+		grown = false
+		for agentName, agentConfig := range configs {
+			if affected[agentName] {
+				continue
+			}
+
+			for _, ref := range agentConfig.Settings.Agent.agentRefs() {
+				subAgent, err := resolveAgentRef(configs, ref)
+				if err != nil {
+					continue
+				}
+				if affected[agentNameOf(configs, subAgent)] {
+					affected[agentName] = true
+					grown = true
+					break
+				}
+			}
+		}
+	}
+
+	return sortedKeys(affected)
+}
+
+// agentNameOf returns the composition key of an agent configuration
+func agentNameOf(configs map[string]*AgentConfig, agentConfig *AgentConfig) string {
+	for agentName, candidate := range configs {
+		if candidate == agentConfig {
+			return agentName
+		}
+	}
+	return ""
+}
+
+func closeAgents(agents []shared.TheAgent) {
+	for _, ag := range agents {
+		if err := shared.CloseAgent(ag); err != nil {
+			logger.Log.Warn("failed to close agent", zap.Error(err))
+		}
+	}
+}
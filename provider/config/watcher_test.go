@@ -0,0 +1,252 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/denkhaus/agents/shared"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"trpc.group/trpc-go/trpc-agent-go/agent/llmagent"
+)
+
+// fakeAgentFactory serves agent configs from memory
+type fakeAgentFactory struct {
+	mu          sync.Mutex
+	configs     map[string]*AgentConfig
+	validateErr error
+	createErr   map[string]error
+	created     []string
+}
+
+func (f *fakeAgentFactory) CreateAgent(ctx context.Context, environment, agentName string) (shared.TheAgent, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.createErr[agentName]; err != nil {
+		return nil, err
+	}
+	f.created = append(f.created, agentName)
+
+	agentConfig := f.configs[agentName]
+	return shared.NewAgent(llmagent.New(agentName), agentConfig.AgentID, false), nil
+}
+
+func (f *fakeAgentFactory) CreateAgentByID(ctx context.Context, agentID uuid.UUID) (shared.TheAgent, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *fakeAgentFactory) DefaultEnvironment() string {
+	return DefaultEnvironment
+}
+
+func (f *fakeAgentFactory) ValidateConfiguration() error {
+	return errors.New("other environments are broken")
+}
+
+func (f *fakeAgentFactory) ValidateEnvironment(environment string) error {
+	return f.validateErr
+}
+
+func (f *fakeAgentFactory) GetAgentConfig(environment, agentName string) (*AgentConfig, error) {
+	return f.configs[agentName], nil
+}
+
+func (f *fakeAgentFactory) GetAgentConfigs(environment string) (map[string]*AgentConfig, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	configs := make(map[string]*AgentConfig, len(f.configs))
+	for name, agentConfig := range f.configs {
+		copied := *agentConfig
+		configs[name] = &copied
+	}
+	return configs, nil
+}
+
+func (f *fakeAgentFactory) DumpConfiguration(environment string) ([]byte, error) {
+	return nil, nil
+}
+
+func (f *fakeAgentFactory) RequiredVariables(environment string) ([]VariableRequirement, error) {
+	return nil, nil
+}
+
+func (f *fakeAgentFactory) setModel(agentName, model string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	copied := *f.configs[agentName]
+	copied.Settings.Agent.LLM.Model = model
+	f.configs[agentName] = &copied
+}
+
+// recordingSwapper records swapped agent names
+type recordingSwapper struct {
+	mu      sync.Mutex
+	swapped [][]string
+}
+
+func (s *recordingSwapper) ReplaceAgents(agents ...shared.TheAgent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var names []string
+	for _, ag := range agents {
+		names = append(names, ag.Info().Name)
+	}
+	s.swapped = append(s.swapped, names)
+	return nil
+}
+
+func newWatcherTestFactory() *fakeAgentFactory {
+	return &fakeAgentFactory{
+		configs: map[string]*AgentConfig{
+			"coordinator": newTestAgentConfig("coordinator", shared.AgentTypeChain, "coder"),
+			"coder":       newTestAgentConfig("coder", shared.AgentTypeDefault),
+			"researcher":  newTestAgentConfig("researcher", shared.AgentTypeDefault),
+		},
+		createErr: make(map[string]error),
+	}
+}
+
+func TestConfigWatcher_ReloadRebuildsAffectedAgents(t *testing.T) {
+	factory := newWatcherTestFactory()
+	swapper := &recordingSwapper{}
+	watcher := NewConfigWatcher(factory, t.TempDir(), "production", swapper)
+	require.NoError(t, watcher.init())
+
+	factory.setModel("coder", "gpt-4o")
+
+	result, err := watcher.Reload(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"coder", "coordinator"}, result.Rebuilt)
+	require.Len(t, swapper.swapped, 1)
+	assert.ElementsMatch(t, []string{"coder", "coordinator"}, swapper.swapped[0])
+
+	// Nothing changed since the last reload
+	result, err = watcher.Reload(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, result.Rebuilt)
+	assert.Len(t, swapper.swapped, 1)
+}
+
+func TestConfigWatcher_RejectsInvalidReload(t *testing.T) {
+	factory := newWatcherTestFactory()
+	swapper := &recordingSwapper{}
+	watcher := NewConfigWatcher(factory, t.TempDir(), "production", swapper)
+	require.NoError(t, watcher.init())
+
+	factory.setModel("researcher", "gpt-4o")
+	factory.validateErr = errors.New("unknown tool: shovel")
+
+	_, err := watcher.Reload(context.Background())
+	assert.ErrorContains(t, err, "invalid configuration: unknown tool: shovel")
+
+	factory.validateErr = nil
+	factory.createErr["researcher"] = errors.New("model unavailable")
+
+	_, err = watcher.Reload(context.Background())
+	assert.ErrorContains(t, err, "failed to rebuild agent researcher: model unavailable")
+	assert.Empty(t, swapper.swapped)
+
+	// Rejected reloads are retried against the running agents
+	delete(factory.createErr, "researcher")
+
+	result, err := watcher.Reload(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"researcher"}, result.Rebuilt)
+}
+
+func TestConfigWatcher_StartReloadsOnFileChange(t *testing.T) {
+	configPath := t.TempDir()
+	configFile := filepath.Join(configPath, "production.cue")
+	require.NoError(t, os.WriteFile(configFile, []byte("production: {}"), 0o600))
+
+	factory := newWatcherTestFactory()
+	reloaded := make(chan ReloadResult, 1)
+	watcher := NewConfigWatcher(factory, configPath, "production", &recordingSwapper{},
+		WithWatchInterval(10*time.Millisecond),
+		WithWatchedAgents("coder"),
+		WithOnReload(func(result ReloadResult) {
+			reloaded <- result
+		}),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	require.NoError(t, watcher.init())
+	go watcher.watch(ctx)
+
+	factory.setModel("coder", "gpt-4o")
+	factory.setModel("researcher", "gpt-4o")
+	require.NoError(t, os.WriteFile(configFile, []byte("production: { agents: {} }"), 0o600))
+
+	select {
+	case result := <-reloaded:
+		assert.Equal(t, []string{"coder"}, result.Rebuilt)
+	case <-time.After(2 * time.Second):
+		t.Fatal("watcher did not reload after the config file changed")
+	}
+}
+
+func TestConfigWatcher_RetriesRejectedReload(t *testing.T) {
+	configPath := t.TempDir()
+	configFile := filepath.Join(configPath, "production.cue")
+	require.NoError(t, os.WriteFile(configFile, []byte("production: {}"), 0o600))
+
+	factory := newWatcherTestFactory()
+	factory.createErr["coder"] = errors.New("model unavailable")
+
+	rejected := make(chan error, 1)
+	reloaded := make(chan ReloadResult, 1)
+	watcher := NewConfigWatcher(factory, configPath, "production", &recordingSwapper{},
+		WithWatchInterval(10*time.Millisecond),
+		WithWatchedAgents("coder"),
+		WithOnReload(func(result ReloadResult) {
+			reloaded <- result
+		}),
+		WithOnReloadError(func(err error) {
+			select {
+			case rejected <- err:
+			default:
+			}
+		}),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	require.NoError(t, watcher.init())
+	go watcher.watch(ctx)
+
+	factory.setModel("coder", "gpt-4o")
+	require.NoError(t, os.WriteFile(configFile, []byte("production: { agents: {} }"), 0o600))
+
+	select {
+	case err := <-rejected:
+		assert.ErrorContains(t, err, "failed to rebuild agent coder: model unavailable")
+	case <-time.After(2 * time.Second):
+		t.Fatal("watcher did not reject the reload")
+	}
+
+	// The files are unchanged since the rejection, the reload is retried anyway
+	factory.mu.Lock()
+	delete(factory.createErr, "coder")
+	factory.mu.Unlock()
+
+	select {
+	case result := <-reloaded:
+		assert.Equal(t, []string{"coder"}, result.Rebuilt)
+	case <-time.After(2 * time.Second):
+		t.Fatal("watcher did not retry the rejected reload")
+	}
+}
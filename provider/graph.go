@@ -0,0 +1,138 @@
+package provider
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// GraphEnd is the edge target finishing a graph
+const GraphEnd = "end"
+
+// GraphSettings declares the workflow of a graph agent. The nodes run agents
+// or tools, the edges connect them and may depend on their outputs.
+type GraphSettings struct {
+	// Entry is the node the graph starts with, it defaults to the first node
+	Entry string      `yaml:"entry" json:"entry,omitempty"`
+	Nodes []GraphNode `yaml:"nodes" json:"nodes,omitempty"`
+	Edges []GraphEdge `yaml:"edges" json:"edges,omitempty"`
+}
+
+// GraphNode runs either an agent or a tool
+type GraphNode struct {
+	ID string `yaml:"id" json:"id"`
+	// Agent references the agent run by the node, by UUID. CUE configs may also use its name.
+	Agent string `yaml:"agent" json:"agent,omitempty"`
+	// Tool names a tool of the graph agent run by the node
+	Tool string `yaml:"tool" json:"tool,omitempty"`
+	// Args are the JSON arguments of the tool. String values starting with $
+	// are replaced by the state value of the key following it, e.g. $review or $user_input.
+	Args map[string]interface{} `yaml:"args" json:"args,omitempty"`
+	// Inputs are the state keys passed to the agent as message, by default the
+	// output of the previous node or the user input for the entry node.
+	// Several keys are passed as sections headed by the key, e.g. to join branches.
+	Inputs []string `yaml:"inputs" json:"inputs,omitempty"`
+	// OutputKey is the state key receiving the output of the node, the node ID by default
+	OutputKey string `yaml:"output_key" json:"output_key,omitempty"`
+}
+
+// GetOutputKey returns the state key receiving the output of the node
+func (n GraphNode) GetOutputKey() string {
+	if n.OutputKey != "" {
+		return n.OutputKey
+	}
+	return n.ID
+}
+
+// GraphEdge leads from a node to one or more nodes, which run in parallel.
+// If no edge leaving a node has a condition, all of them are taken. Otherwise
+// they are checked in order and the first edge whose condition holds is taken,
+// edges without condition always hold. The graph ends if no edge is taken.
+type GraphEdge struct {
+	From string          `yaml:"from" json:"from"`
+	To   []string        `yaml:"to" json:"to"`
+	When *GraphCondition `yaml:"when" json:"when,omitempty"`
+}
+
+// GraphCondition checks the output of a node or a session state value.
+// Without a comparison it holds if the value is not empty.
+type GraphCondition struct {
+	// Key is a state key, usually the output key of a node
+	Key string `yaml:"key" json:"key,omitempty"`
+	// SessionKey is a key of the session state
+	SessionKey string `yaml:"session_key" json:"session_key,omitempty"`
+	Equals     string `yaml:"equals" json:"equals,omitempty"`
+	Contains   string `yaml:"contains" json:"contains,omitempty"`
+	// Matches is a regular expression
+	Matches string `yaml:"matches" json:"matches,omitempty"`
+	// Not negates the condition
+	Not bool `yaml:"not" json:"not,omitempty"`
+}
+
+// Validate checks that the condition reads exactly one value
+func (c GraphCondition) Validate() error {
+	if (c.Key == "") == (c.SessionKey == "") {
+		return fmt.Errorf("condition needs either a key or a session_key")
+	}
+	if c.Matches != "" {
+		if _, err := regexp.Compile(c.Matches); err != nil {
+			return fmt.Errorf("invalid condition pattern %q: %w", c.Matches, err)
+		}
+	}
+	return nil
+}
+
+// GetEntry returns the node the graph starts with
+func (s GraphSettings) GetEntry() string {
+	if s.Entry == "" && len(s.Nodes) > 0 {
+		return s.Nodes[0].ID
+	}
+	return s.Entry
+}
+
+// Validate checks that the nodes are unique and the edges connect known nodes
+func (s GraphSettings) Validate() error {
+	if len(s.Nodes) == 0 {
+		return fmt.Errorf("graph has no nodes")
+	}
+
+	nodes := make(map[string]bool, len(s.Nodes))
+	for _, node := range s.Nodes {
+		switch {
+		case node.ID == "":
+			return fmt.Errorf("graph node without id")
+		case node.ID == GraphEnd || strings.Contains(node.ID, "#"):
+			return fmt.Errorf("graph node id %s is reserved", node.ID)
+		case nodes[node.ID]:
+			return fmt.Errorf("graph node %s is declared twice", node.ID)
+		case (node.Agent == "") == (node.Tool == ""):
+			return fmt.Errorf("graph node %s needs either an agent or a tool", node.ID)
+		}
+		nodes[node.ID] = true
+	}
+
+	if !nodes[s.GetEntry()] {
+		return fmt.Errorf("graph entry %s is no node", s.GetEntry())
+	}
+
+	for _, edge := range s.Edges {
+		if !nodes[edge.From] {
+			return fmt.Errorf("graph edge from unknown node %s", edge.From)
+		}
+		if len(edge.To) == 0 {
+			return fmt.Errorf("graph edge from %s has no target", edge.From)
+		}
+		for _, to := range edge.To {
+			if !nodes[to] && to != GraphEnd {
+				return fmt.Errorf("graph edge from %s to unknown node %s", edge.From, to)
+			}
+		}
+		if edge.When != nil {
+			if err := edge.When.Validate(); err != nil {
+				return fmt.Errorf("graph edge from %s: %w", edge.From, err)
+			}
+		}
+	}
+
+	return nil
+}
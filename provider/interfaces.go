@@ -2,8 +2,11 @@ package provider
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"github.com/denkhaus/agents/shared"
+	"github.com/denkhaus/agents/shared/resource"
 	"github.com/google/uuid"
 	"trpc.group/trpc-go/trpc-agent-go/agent/chainagent"
 	"trpc.group/trpc-go/trpc-agent-go/agent/cycleagent"
@@ -17,23 +20,56 @@ type AgentProviderOptions struct {
 	CycleOpt    []cycleagent.Option
 	ParallelOpt []parallelagent.Option
 	ChainOpt    []chainagent.Option
+	// InstanceKey distinguishes instances of one agent built with different options
+	InstanceKey string
 }
 
 // Option is a function that configures an Agent.
 type AgentProviderOption func(*AgentProviderOptions)
 
 type AgentProvider interface {
+	// GetAgent returns the cached instance of the agent with the instance key of
+	// the options, it is built with the options of the first call
 	GetAgent(ctx context.Context, agentID uuid.UUID, opt ...AgentProviderOption) (shared.TheAgent, error)
+	// Close evicts and closes all instances of the agent and of the agents using it as sub-agent
+	Close(agentID uuid.UUID) error
+	// Reload rebuilds all instances of the agent and of the agents using it as sub-agent
+	Reload(ctx context.Context, agentID uuid.UUID) error
 }
 
 type AgentConfiguration interface {
 	GetName() string
 	GetType() shared.AgentType
 	IsStreamingEnabled() bool
+	GetRole() shared.AgentRole
+	GetDescription() string
+	GetInputSchema() map[string]interface{}
+	GetOutputSchema() map[string]interface{}
+	// GetCapabilities returns the configured capabilities, the skills default
+	// to the tools of the built agent
+	GetCapabilities() shared.AgentCapabilities
 	GetDefaultOptions(ctx context.Context, provider AgentProvider, opt ...llmagent.Option) ([]llmagent.Option, error)
 	GetCycleOptions(ctx context.Context, provider AgentProvider, opt ...cycleagent.Option) ([]cycleagent.Option, error)
 	GetChainOptions(ctx context.Context, provider AgentProvider, opt ...chainagent.Option) ([]chainagent.Option, error)
 	GetParallelOptions(ctx context.Context, provider AgentProvider, opt ...parallelagent.Option) ([]parallelagent.Option, error)
+	// GetGraph returns the workflow of a graph agent, the node agents are referenced by UUID
+	GetGraph() (GraphSettings, error)
+	// GetRouter returns the routes of a router agent, the agents are referenced by UUID
+	GetRouter() (RouterSettings, error)
+	// GetPromptVariants renders every version of the prompt of the agent. It returns no
+	// variants if the prompt has a single version, the instruction of GetDefaultOptions is used then
+	// and the selection carries its version, 0 if the prompt is not versioned.
+	GetPromptVariants(ctx context.Context, provider AgentProvider, opt ...llmagent.Option) ([]PromptVariant, PromptSelection, error)
+	// GetInstructionRenderer returns the renderer refreshing the instruction of the
+	// agent per invocation, the tools are taken from the given options
+	GetInstructionRenderer(ctx context.Context, opt ...llmagent.Option) (InstructionRenderer, error)
+}
+
+// InstructionRenderer renders the instruction of an agent from live data
+type InstructionRenderer interface {
+	// Render renders the given prompt version, 0 is the version used by
+	// GetDefaultOptions. The session is nil outside of an invocation.
+	Render(ctx context.Context, version int, session *session.Session) (string, error)
 }
 
 type SettingsProvider interface {
@@ -43,10 +79,75 @@ type SettingsProvider interface {
 
 type Workspace interface {
 	GetPath() (string, error)
+	// GetScratchDir returns the scratch directory of a session below the workspace,
+	// it is created on first use. It returns an empty path if scratch directories are disabled.
+	GetScratchDir(sessionID string) (string, error)
+	// ReleaseScratchDir removes the scratch directory of a session
+	ReleaseScratchDir(sessionID string) error
+	// Close removes all scratch directories of the workspace, an isolated
+	// workspace also removes its copy and with it all changes that were not merged
+	Close() error
 }
 
 type WorkspaceProvider interface {
 	GetWorkspace(agentID uuid.UUID) (Workspace, error)
+	// Configure sets the workspace settings of an agent. It fails if the
+	// workspace was already created with different settings.
+	Configure(agentID uuid.UUID, settings WorkspaceSettings) error
+	// ReleaseSession removes the scratch directories of an ended session from all workspaces
+	ReleaseSession(sessionID string) error
+}
+
+// WorkspaceSettings configures the workspace of an agent
+type WorkspaceSettings struct {
+	// Root is the workspace directory, ~ and environment variables are expanded.
+	// It defaults to the root of the workspace provider, relative roots are resolved against it.
+	Root string `yaml:"root" json:"root,omitempty"`
+	// Scratch enables a scratch directory per session below the workspace
+	Scratch bool `yaml:"scratch" json:"scratch,omitempty"`
+	// Isolation gives the agent its own copy of the root, see IsolatedWorkspace
+	Isolation WorkspaceIsolation `yaml:"isolation" json:"isolation,omitempty"`
+}
+
+// Validate checks the isolation of the settings
+func (s WorkspaceSettings) Validate() error {
+	return s.Isolation.Validate()
+}
+
+// WorkspaceIsolation selects how an agent is isolated from the root of its workspace
+type WorkspaceIsolation string
+
+const (
+	// WorkspaceIsolationNone works in the root directly
+	WorkspaceIsolationNone WorkspaceIsolation = ""
+	// WorkspaceIsolationWorktree works in a git worktree of the repository containing the root
+	WorkspaceIsolationWorktree WorkspaceIsolation = "worktree"
+	// WorkspaceIsolationCopy works in a copy of the root
+	WorkspaceIsolationCopy WorkspaceIsolation = "copy"
+)
+
+// Validate checks that the isolation is known
+func (i WorkspaceIsolation) Validate() error {
+	switch i {
+	case WorkspaceIsolationNone, WorkspaceIsolationWorktree, WorkspaceIsolationCopy:
+		return nil
+	default:
+		return fmt.Errorf("invalid workspace isolation: %s", i)
+	}
+}
+
+// IsolatedWorkspace keeps the changes of an agent apart from its base workspace
+// until they are merged back or discarded
+type IsolatedWorkspace interface {
+	Workspace
+	// GetBasePath returns the workspace the changes are based on
+	GetBasePath() string
+	// Diff returns the changes that were not merged yet as a git patch
+	Diff(ctx context.Context) (string, error)
+	// Merge applies the changes to the base workspace
+	Merge(ctx context.Context) error
+	// Discard drops the changes that were not merged yet
+	Discard(ctx context.Context) error
 }
 
 type Prompt interface {
@@ -54,15 +155,146 @@ type Prompt interface {
 	GetDescription() string
 	GetGlobalInstruction() string
 	GetInstruction(data interface{}) (string, error)
+	// GetVersion returns the version declared in the front matter of the prompt
+	GetVersion() int
+	// GetWeight returns the share of invocations the version receives in a weighted split
+	GetWeight() int
+	// GetContextKeys returns the top-level properties of the prompt schema, the
+	// context contributors of these keys run when the prompt is rendered
+	GetContextKeys() []string
+	// IsTimeDependent reports whether the instruction calls now or date, it
+	// then changes between renders with the same data
+	IsTimeDependent() bool
+}
+
+// PromptKey identifies one version of the prompt of an agent
+type PromptKey struct {
+	AgentID uuid.UUID
+	Version int
+}
+
+func (k PromptKey) String() string {
+	return fmt.Sprintf("%s version %d", k.AgentID, k.Version)
+}
+
+// PromptPolicy decides which prompt version an invocation uses
+type PromptPolicy string
+
+const (
+	// PromptPolicyLatest always uses the highest version
+	PromptPolicyLatest PromptPolicy = "latest"
+	// PromptPolicyPinned always uses the configured version
+	PromptPolicyPinned PromptPolicy = "pinned"
+	// PromptPolicyWeighted picks a version per invocation according to the version weights
+	PromptPolicyWeighted PromptPolicy = "weighted"
+	// PromptPolicySticky picks a version according to the weights once per session
+	PromptPolicySticky PromptPolicy = "sticky"
+)
+
+// Validate checks if the PromptPolicy is a known policy, an empty policy means latest
+func (p PromptPolicy) Validate() error {
+	switch p {
+	case "", PromptPolicyLatest, PromptPolicyPinned, PromptPolicyWeighted, PromptPolicySticky:
+		return nil
+	default:
+		return fmt.Errorf("invalid prompt policy: %s. Valid policies are: %s, %s, %s, %s", p,
+			PromptPolicyLatest, PromptPolicyPinned, PromptPolicyWeighted, PromptPolicySticky)
+	}
+}
+
+// PromptSelection configures the prompt version of an agent. It is passed as
+// data to PromptProvider.GetPrompt, SessionID keys the sticky policy.
+type PromptSelection struct {
+	Policy    PromptPolicy `yaml:"policy" json:"policy,omitempty"`
+	Version   int          `yaml:"version" json:"version,omitempty"`
+	SessionID string       `yaml:"-" json:"-"`
+}
+
+// Validate checks the policy and that a pinned selection names a version
+func (s PromptSelection) Validate() error {
+	if err := s.Policy.Validate(); err != nil {
+		return err
+	}
+	if s.Policy == PromptPolicyPinned && s.Version <= 0 {
+		return fmt.Errorf("prompt policy %s requires a version", PromptPolicyPinned)
+	}
+	return nil
+}
+
+// PromptVariant is the rendered instruction of one prompt version
+type PromptVariant struct {
+	Version           int
+	Weight            int
+	Instruction       string
+	GlobalInstruction string
 }
 
 // PromptManager defines the interface for managing and rendering prompts.
 type PromptManager interface {
+	// GetPrompt returns the latest version of the prompt of an agent
 	GetPrompt(agentID uuid.UUID) (Prompt, error)
+	GetPromptVersion(agentID uuid.UUID, version int) (Prompt, error)
+	// GetPromptVersions returns all versions of the prompt of an agent, ordered by version
+	GetPromptVersions(agentID uuid.UUID) ([]Prompt, error)
+	// GetOrigin reports the file a prompt version was loaded from
+	GetOrigin(key PromptKey) (resource.Origin, bool)
+	GetOrigins() map[PromptKey]resource.Origin
 }
 
 type PromptProvider interface {
+	// GetPrompt selects a version of the prompt of an agent, data may be a PromptSelection
 	GetPrompt(agentID uuid.UUID, data interface{}) (Prompt, error)
+	GetPromptVersions(agentID uuid.UUID) ([]Prompt, error)
+}
+
+// PromptContextSettings configures the context contributors of an agent
+type PromptContextSettings struct {
+	// Project names the project the agent is attached to
+	Project string `yaml:"project" json:"project,omitempty"`
+	// Timezone of the time contributor, e.g. Europe/Berlin, defaults to the local timezone
+	Timezone string `yaml:"timezone" json:"timezone,omitempty"`
+	// SessionState lists the session state keys exposed to the prompt
+	SessionState []string `yaml:"session_state" json:"session_state,omitempty"`
+	// Variables are arbitrary user supplied values
+	Variables map[string]interface{} `yaml:"variables" json:"variables,omitempty"`
+}
+
+// Validate checks that the timezone is known
+func (s PromptContextSettings) Validate() error {
+	if s.Timezone == "" {
+		return nil
+	}
+	if _, err := time.LoadLocation(s.Timezone); err != nil {
+		return fmt.Errorf("invalid timezone %q: %w", s.Timezone, err)
+	}
+	return nil
+}
+
+// PromptContextRequest describes the agent a prompt render context is built for
+type PromptContextRequest struct {
+	AgentID  uuid.UUID
+	Settings PromptContextSettings
+	// Workspace is the workspace path of the agent, empty if it has none
+	Workspace string
+	// Scratch is the scratch directory of the session, empty if it has none
+	Scratch string
+	// Session is the session of the invocation, nil outside of an invocation
+	Session *session.Session
+}
+
+// PromptContextContributor adds keys to the data prompts are rendered with
+type PromptContextContributor interface {
+	// Keys lists the context keys the contributor adds
+	Keys() []string
+	// Contribute returns the values of its keys for the request
+	Contribute(ctx context.Context, request PromptContextRequest) (map[string]interface{}, error)
+}
+
+// PromptContextProvider builds prompt render contexts from registered contributors
+type PromptContextProvider interface {
+	Register(contributor PromptContextContributor) error
+	// GetContext runs the contributors of the requested keys, keys nobody contributes are skipped
+	GetContext(ctx context.Context, keys []string, request PromptContextRequest) (map[string]interface{}, error)
 }
 
 type ChatProviderOptions struct {
@@ -0,0 +1,400 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/denkhaus/agents/logger"
+	"github.com/denkhaus/agents/shared"
+	"go.uber.org/zap"
+	"trpc.group/trpc-go/trpc-agent-go/model"
+)
+
+// ErrorClass classifies model errors for retries and failover
+type ErrorClass string
+
+const (
+	ErrorClassRateLimit      ErrorClass = "rate_limit"
+	ErrorClassServer         ErrorClass = "server"
+	ErrorClassTimeout        ErrorClass = "timeout"
+	ErrorClassNetwork        ErrorClass = "network"
+	ErrorClassAuth           ErrorClass = "auth"
+	ErrorClassInvalidRequest ErrorClass = "invalid_request"
+	ErrorClassUnknown        ErrorClass = "unknown"
+)
+
+var errorClasses = []ErrorClass{
+	ErrorClassRateLimit,
+	ErrorClassServer,
+	ErrorClassTimeout,
+	ErrorClassNetwork,
+	ErrorClassAuth,
+	ErrorClassInvalidRequest,
+	ErrorClassUnknown,
+}
+
+// DefaultErrorClasses are retried and fail over unless retry_on or failover_on are given
+var DefaultErrorClasses = []ErrorClass{
+	ErrorClassRateLimit,
+	ErrorClassServer,
+	ErrorClassTimeout,
+	ErrorClassNetwork,
+}
+
+// Validate checks if the ErrorClass is a known error class
+func (c ErrorClass) Validate() error {
+	for _, class := range errorClasses {
+		if c == class {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid error class: %s. Valid classes are: %s", c, joinClasses(errorClasses))
+}
+
+// Default backoff of a RetryPolicy
+const (
+	DefaultInitialBackoff = time.Second
+	DefaultMaxBackoff     = 30 * time.Second
+)
+
+// RetryPolicy controls how often a model is retried before the next fallback is taken
+type RetryPolicy struct {
+	// MaxRetries is the number of retries after the first attempt
+	MaxRetries int `json:"max_retries,omitempty" yaml:"max_retries"`
+	// InitialBackoff is the delay before the first retry, doubled for every further retry
+	InitialBackoff string `json:"initial_backoff,omitempty" yaml:"initial_backoff"`
+	// MaxBackoff caps the delay between retries
+	MaxBackoff string `json:"max_backoff,omitempty" yaml:"max_backoff"`
+	// RetryOn lists the error classes that are retried, defaults to DefaultErrorClasses
+	RetryOn []ErrorClass `json:"retry_on,omitempty" yaml:"retry_on"`
+}
+
+func (p RetryPolicy) validate() error {
+	if p.MaxRetries < 0 {
+		return fmt.Errorf("max_retries must not be negative, got %d", p.MaxRetries)
+	}
+
+	if _, _, err := p.backoffs(); err != nil {
+		return err
+	}
+
+	return validateClasses(p.RetryOn)
+}
+
+func (p RetryPolicy) backoffs() (time.Duration, time.Duration, error) {
+	initial, err := parseDuration(p.InitialBackoff, DefaultInitialBackoff)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid initial_backoff %q: %w", p.InitialBackoff, err)
+	}
+
+	maximum, err := parseDuration(p.MaxBackoff, DefaultMaxBackoff)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid max_backoff %q: %w", p.MaxBackoff, err)
+	}
+
+	return initial, maximum, nil
+}
+
+// FallbackEvent describes a failover from one model to the next
+type FallbackEvent struct {
+	From  string
+	To    string
+	Class ErrorClass
+	Error string
+}
+
+func (e FallbackEvent) String() string {
+	return fmt.Sprintf("model %s failed (%s), falling back to %s: %s", e.From, e.Class, e.To, e.Error)
+}
+
+func fallbackResponse(event FallbackEvent) *model.Response {
+	return &model.Response{
+		Object:    shared.ObjectTypeModelFallback,
+		Created:   time.Now().Unix(),
+		Model:     event.To,
+		Timestamp: time.Now(),
+		IsPartial: true,
+		Choices: []model.Choice{{
+			Delta: model.Message{Role: model.RoleSystem, Content: event.String()},
+		}},
+	}
+}
+
+// candidate is one model of a fallback chain
+type candidate struct {
+	name           string
+	model          model.Model
+	maxRetries     int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	retryOn        []ErrorClass
+}
+
+func newCandidate(config Config, m model.Model) (candidate, error) {
+	initial, maximum, err := config.Retry.backoffs()
+	if err != nil {
+		return candidate{}, err
+	}
+
+	return candidate{
+		name:           fmt.Sprintf("%s/%s", config.Provider, config.Model),
+		model:          m,
+		maxRetries:     config.Retry.MaxRetries,
+		initialBackoff: initial,
+		maxBackoff:     maximum,
+		retryOn:        classesOrDefault(config.Retry.RetryOn),
+	}, nil
+}
+
+func (c candidate) backoff(retry int) time.Duration {
+	backoff := c.initialBackoff
+	for i := 0; i < retry && backoff < c.maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > c.maxBackoff {
+		return c.maxBackoff
+	}
+	return backoff
+}
+
+// fallbackModel tries the models of a chain in order. Each model is retried
+// according to its retry policy, failover happens for the configured error
+// classes only and only as long as no response was passed on.
+type fallbackModel struct {
+	candidates        []candidate
+	failoverOn        []ErrorClass
+	channelBufferSize int
+	sleep             func(ctx context.Context, d time.Duration) error
+}
+
+func (m *fallbackModel) Info() model.Info {
+	return m.candidates[0].model.Info()
+}
+
+func (m *fallbackModel) GenerateContent(ctx context.Context, request *model.Request) (<-chan *model.Response, error) {
+	if request == nil {
+		return nil, fmt.Errorf("request cannot be nil")
+	}
+
+	responseChan := make(chan *model.Response, m.channelBufferSize)
+
+	go func() {
+		defer close(responseChan)
+		m.run(ctx, request, responseChan)
+	}()
+
+	return responseChan, nil
+}
+
+func (m *fallbackModel) run(ctx context.Context, request *model.Request, out chan<- *model.Response) {
+	send := func(response *model.Response) bool {
+		select {
+		case out <- response:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	for index, current := range m.candidates {
+		for retry := 0; ; retry++ {
+			class, failure, done := m.attempt(ctx, current, request, send)
+			if done {
+				return
+			}
+
+			if ctx.Err() != nil {
+				send(failure)
+				return
+			}
+
+			if retry < current.maxRetries && containsClass(current.retryOn, class) {
+				backoff := current.backoff(retry)
+				logger.Log.Warn("model request failed, retrying",
+					zap.String("model", current.name),
+					zap.String("class", string(class)),
+					zap.Int("retry", retry+1),
+					zap.Duration("backoff", backoff),
+				)
+
+				if err := m.sleep(ctx, backoff); err != nil {
+					send(failure)
+					return
+				}
+				continue
+			}
+
+			if index+1 < len(m.candidates) && containsClass(m.failoverOn, class) {
+				event := FallbackEvent{
+					From:  current.name,
+					To:    m.candidates[index+1].name,
+					Class: class,
+					Error: failure.Error.Message,
+				}
+				logger.Log.Warn("model failed, falling back",
+					zap.String("from", event.From),
+					zap.String("to", event.To),
+					zap.String("class", string(class)),
+					zap.String("error", event.Error),
+				)
+
+				if !send(fallbackResponse(event)) {
+					return
+				}
+				break
+			}
+
+			send(failure)
+			return
+		}
+	}
+}
+
+// attempt runs the request against a single model. Unless done is set, the
+// model failed before any response was passed on and failure holds the error.
+func (m *fallbackModel) attempt(
+	ctx context.Context,
+	current candidate,
+	request *model.Request,
+	send func(*model.Response) bool,
+) (class ErrorClass, failure *model.Response, done bool) {
+
+	responses, err := current.model.GenerateContent(ctx, request)
+	if err != nil {
+		return ClassifyError(err), errorResponse(err), false
+	}
+
+	forwarded := false
+	for response := range responses {
+		if response.Error != nil && !forwarded {
+			go drain(responses)
+			return classifyMessage(response.Error.Type + " " + response.Error.Message), response, false
+		}
+
+		forwarded = true
+		if !send(response) {
+			go drain(responses)
+			return "", nil, true
+		}
+	}
+
+	return "", nil, true
+}
+
+func drain(responses <-chan *model.Response) {
+	for range responses {
+	}
+}
+
+// ClassifyError maps an error returned by a model to an error class
+func ClassifyError(err error) ErrorClass {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrorClassTimeout
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return ErrorClassTimeout
+		}
+		return ErrorClassNetwork
+	}
+
+	return classifyMessage(err.Error())
+}
+
+var (
+	rateLimitPattern = regexp.MustCompile(`\b429\b|rate.?limit|too many requests|quota`)
+	timeoutPattern   = regexp.MustCompile(`timeout|timed out|deadline exceeded|\b408\b`)
+	authPattern      = regexp.MustCompile(`\b40[13]\b|unauthori[sz]ed|forbidden|authentication|api key`)
+	serverPattern    = regexp.MustCompile(`\b5\d\d\b|server error|server_error|bad gateway|service unavailable|overloaded`)
+	networkPattern   = regexp.MustCompile(`connection refused|connection reset|no such host|broken pipe|\beof\b`)
+	invalidPattern   = regexp.MustCompile(`\b4\d\d\b|bad request|invalid`)
+)
+
+// classifyMessage classifies an error by the status codes and phrases of its message
+func classifyMessage(message string) ErrorClass {
+	message = strings.ToLower(message)
+
+	switch {
+	case rateLimitPattern.MatchString(message):
+		return ErrorClassRateLimit
+	case timeoutPattern.MatchString(message):
+		return ErrorClassTimeout
+	case authPattern.MatchString(message):
+		return ErrorClassAuth
+	case serverPattern.MatchString(message):
+		return ErrorClassServer
+	case networkPattern.MatchString(message):
+		return ErrorClassNetwork
+	case invalidPattern.MatchString(message):
+		return ErrorClassInvalidRequest
+	default:
+		return ErrorClassUnknown
+	}
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func parseDuration(value string, defaultValue time.Duration) (time.Duration, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if duration < 0 {
+		return 0, fmt.Errorf("duration must not be negative")
+	}
+	return duration, nil
+}
+
+func validateClasses(classes []ErrorClass) error {
+	for _, class := range classes {
+		if err := class.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func classesOrDefault(classes []ErrorClass) []ErrorClass {
+	if len(classes) == 0 {
+		return DefaultErrorClasses
+	}
+	return classes
+}
+
+func containsClass(classes []ErrorClass, class ErrorClass) bool {
+	for _, c := range classes {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+func joinClasses(classes []ErrorClass) string {
+	names := make([]string, len(classes))
+	for i, class := range classes {
+		names[i] = string(class)
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,161 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/denkhaus/agents/shared"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"trpc.group/trpc-go/trpc-agent-go/model"
+)
+
+// scriptedModel answers each request with the next error message of its
+// script, an empty message yields a successful response
+type scriptedModel struct {
+	name   string
+	script []string
+	calls  int
+}
+
+func (m *scriptedModel) Info() model.Info {
+	return model.Info{Name: m.name}
+}
+
+func (m *scriptedModel) GenerateContent(ctx context.Context, request *model.Request) (<-chan *model.Response, error) {
+	message := ""
+	if m.calls < len(m.script) {
+		message = m.script[m.calls]
+	}
+	m.calls++
+
+	responseChan := make(chan *model.Response, 1)
+	if message != "" {
+		responseChan <- errorResponse(errors.New(message))
+	} else {
+		responseChan <- &model.Response{
+			Model: m.name,
+			Done:  true,
+			Choices: []model.Choice{{
+				Message: model.NewAssistantMessage("answer of " + m.name),
+			}},
+		}
+	}
+	close(responseChan)
+
+	return responseChan, nil
+}
+
+func newTestChain(maxRetries int, models ...*scriptedModel) (*fallbackModel, *[]time.Duration) {
+	var sleeps []time.Duration
+	chain := &fallbackModel{
+		failoverOn:        DefaultErrorClasses,
+		channelBufferSize: 8,
+		sleep: func(ctx context.Context, d time.Duration) error {
+			sleeps = append(sleeps, d)
+			return nil
+		},
+	}
+
+	for _, m := range models {
+		chain.candidates = append(chain.candidates, candidate{
+			name:           m.name,
+			model:          m,
+			maxRetries:     maxRetries,
+			initialBackoff: time.Second,
+			maxBackoff:     3 * time.Second,
+			retryOn:        DefaultErrorClasses,
+		})
+	}
+
+	return chain, &sleeps
+}
+
+func TestFallbackModel_RetriesWithBackoff(t *testing.T) {
+	primary := &scriptedModel{name: "primary", script: []string{"503 service unavailable", "503 service unavailable", "503 service unavailable"}}
+	chain, sleeps := newTestChain(3, primary)
+
+	responses := collect(t, chain, &model.Request{})
+
+	require.Len(t, responses, 1)
+	assert.Equal(t, "answer of primary", responses[0].Choices[0].Message.Content)
+	assert.Equal(t, 4, primary.calls)
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 3 * time.Second}, *sleeps)
+}
+
+func TestFallbackModel_FailsOver(t *testing.T) {
+	primary := &scriptedModel{name: "primary", script: []string{"429 too many requests", "429 too many requests"}}
+	secondary := &scriptedModel{name: "secondary"}
+	chain, _ := newTestChain(1, primary, secondary)
+
+	responses := collect(t, chain, &model.Request{})
+
+	require.Len(t, responses, 2)
+	assert.True(t, shared.IsFallbackResponse(responses[0]))
+	assert.Equal(t, "secondary", responses[0].Model)
+	assert.Contains(t, responses[0].Choices[0].Delta.Content, "rate_limit")
+	assert.Equal(t, "answer of secondary", responses[1].Choices[0].Message.Content)
+	assert.Equal(t, 2, primary.calls)
+}
+
+func TestFallbackModel_NoFailoverOnAuthError(t *testing.T) {
+	primary := &scriptedModel{name: "primary", script: []string{"401 unauthorized"}}
+	secondary := &scriptedModel{name: "secondary"}
+	chain, sleeps := newTestChain(2, primary, secondary)
+
+	responses := collect(t, chain, &model.Request{})
+
+	require.Len(t, responses, 1)
+	require.NotNil(t, responses[0].Error)
+	assert.Contains(t, responses[0].Error.Message, "unauthorized")
+	assert.Empty(t, *sleeps)
+	assert.Zero(t, secondary.calls)
+}
+
+func TestRegistry_NewWrapsFallbacks(t *testing.T) {
+	config := Config{
+		Provider:   shared.ModelProviderOpenAI,
+		Model:      "gpt-4o",
+		Retry:      RetryPolicy{MaxRetries: 2, InitialBackoff: "500ms"},
+		FailoverOn: []ErrorClass{ErrorClassRateLimit},
+		Fallbacks: []Config{
+			{Provider: shared.ModelProviderOllama, Model: "llama3.1"},
+		},
+	}
+
+	m, err := New(config)
+	require.NoError(t, err)
+
+	chain, ok := m.(*fallbackModel)
+	require.True(t, ok)
+	require.Len(t, chain.candidates, 2)
+	assert.Equal(t, "openai/gpt-4o", chain.candidates[0].name)
+	assert.Equal(t, 500*time.Millisecond, chain.candidates[0].initialBackoff)
+	assert.Equal(t, "ollama/llama3.1", chain.candidates[1].name)
+	assert.Equal(t, []ErrorClass{ErrorClassRateLimit}, chain.failoverOn)
+
+	config.FailoverOn = []ErrorClass{"sometimes"}
+	assert.ErrorContains(t, Validate(config), "invalid error class: sometimes")
+
+	config.FailoverOn = nil
+	config.Fallbacks[0].Fallbacks = []Config{{Provider: shared.ModelProviderOpenAI, Model: "gpt-4o-mini"}}
+	assert.ErrorContains(t, Validate(config), "fallbacks cannot declare fallbacks")
+}
+
+func TestClassifyMessage(t *testing.T) {
+	tests := map[string]ErrorClass{
+		"status 429: Rate limit reached for gpt-4o": ErrorClassRateLimit,
+		"502 Bad Gateway":                              ErrorClassServer,
+		"context deadline exceeded":                    ErrorClassTimeout,
+		"dial tcp 127.0.0.1:11434: connection refused": ErrorClassNetwork,
+		"401 Unauthorized: Incorrect API key provided": ErrorClassAuth,
+		"400 Bad Request: messages must not be empty":  ErrorClassInvalidRequest,
+		"something odd happened":                       ErrorClassUnknown,
+	}
+
+	for message, expected := range tests {
+		assert.Equal(t, expected, classifyMessage(message), message)
+	}
+}
@@ -0,0 +1,99 @@
+package llm
+
+import (
+	"fmt"
+	"strings"
+
+	"trpc.group/trpc-go/trpc-agent-go/model"
+)
+
+// Reasoning efforts accepted by reasoning models
+const (
+	ReasoningEffortLow    = "low"
+	ReasoningEffortMedium = "medium"
+	ReasoningEffortHigh   = "high"
+)
+
+var reasoningEfforts = []string{ReasoningEffortLow, ReasoningEffortMedium, ReasoningEffortHigh}
+
+// Ranges of the generation parameters
+const (
+	MinTemperature = 0.0
+	MaxTemperature = 2.0
+	MinPenalty     = -2.0
+	MaxPenalty     = 2.0
+	MaxStop        = 4
+)
+
+// ValidateGenerationConfig checks the generation parameters against the
+// ranges the providers accept, so invalid settings fail when they are loaded
+func ValidateGenerationConfig(config model.GenerationConfig) error {
+	if config.MaxTokens != nil && *config.MaxTokens < 0 {
+		return fmt.Errorf("max_tokens must not be negative, got %d", *config.MaxTokens)
+	}
+
+	if config.Temperature != nil {
+		if err := checkRange("temperature", *config.Temperature, MinTemperature, MaxTemperature); err != nil {
+			return err
+		}
+	}
+
+	if config.TopP != nil {
+		if err := checkRange("top_p", *config.TopP, 0, 1); err != nil {
+			return err
+		}
+	}
+
+	if config.FrequencyPenalty != nil {
+		if err := checkRange("frequency_penalty", *config.FrequencyPenalty, MinPenalty, MaxPenalty); err != nil {
+			return err
+		}
+	}
+
+	if config.PresencePenalty != nil {
+		if err := checkRange("presence_penalty", *config.PresencePenalty, MinPenalty, MaxPenalty); err != nil {
+			return err
+		}
+	}
+
+	if len(config.Stop) > MaxStop {
+		return fmt.Errorf("stop accepts at most %d sequences, got %d", MaxStop, len(config.Stop))
+	}
+	for _, stop := range config.Stop {
+		if stop == "" {
+			return fmt.Errorf("stop sequences cannot be empty")
+		}
+	}
+
+	if config.ReasoningEffort != nil && !contains(reasoningEfforts, *config.ReasoningEffort) {
+		return fmt.Errorf("invalid reasoning_effort: %s. Valid efforts are: %s",
+			*config.ReasoningEffort, strings.Join(reasoningEfforts, ", "))
+	}
+
+	if config.ThinkingTokens != nil {
+		if *config.ThinkingTokens <= 0 {
+			return fmt.Errorf("thinking_tokens must be positive, got %d", *config.ThinkingTokens)
+		}
+		if config.ThinkingEnabled != nil && !*config.ThinkingEnabled {
+			return fmt.Errorf("thinking_tokens requires thinking to be enabled")
+		}
+	}
+
+	return nil
+}
+
+func checkRange(name string, value, min, max float64) error {
+	if value < min || value > max {
+		return fmt.Errorf("%s must be between %g and %g, got %g", name, min, max, value)
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
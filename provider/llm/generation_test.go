@@ -0,0 +1,49 @@
+package llm
+
+import (
+	"testing"
+
+	"github.com/denkhaus/agents/utils"
+	"github.com/stretchr/testify/assert"
+	"trpc.group/trpc-go/trpc-agent-go/model"
+)
+
+func TestValidateGenerationConfig(t *testing.T) {
+	effort := "extreme"
+	disabled := false
+
+	tests := []struct {
+		name   string
+		config model.GenerationConfig
+		err    string
+	}{
+		{"negative max tokens", model.GenerationConfig{MaxTokens: utils.IntPtr(-1)}, "max_tokens must not be negative"},
+		{"temperature", model.GenerationConfig{Temperature: utils.FloatPtr(2.5)}, "temperature must be between 0 and 2, got 2.5"},
+		{"top p", model.GenerationConfig{TopP: utils.FloatPtr(-0.1)}, "top_p must be between 0 and 1"},
+		{"frequency penalty", model.GenerationConfig{FrequencyPenalty: utils.FloatPtr(3)}, "frequency_penalty must be between -2 and 2"},
+		{"presence penalty", model.GenerationConfig{PresencePenalty: utils.FloatPtr(-3)}, "presence_penalty must be between -2 and 2"},
+		{"too many stops", model.GenerationConfig{Stop: []string{"a", "b", "c", "d", "e"}}, "stop accepts at most 4 sequences"},
+		{"empty stop", model.GenerationConfig{Stop: []string{""}}, "stop sequences cannot be empty"},
+		{"reasoning effort", model.GenerationConfig{ReasoningEffort: &effort}, "invalid reasoning_effort: extreme"},
+		{"thinking tokens", model.GenerationConfig{ThinkingTokens: utils.IntPtr(0)}, "thinking_tokens must be positive"},
+		{"thinking disabled", model.GenerationConfig{ThinkingEnabled: &disabled, ThinkingTokens: utils.IntPtr(1024)}, "requires thinking to be enabled"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorContains(t, ValidateGenerationConfig(tt.config), tt.err)
+		})
+	}
+
+	medium := ReasoningEffortMedium
+	assert.NoError(t, ValidateGenerationConfig(model.GenerationConfig{
+		MaxTokens:        utils.IntPtr(2000),
+		Temperature:      utils.FloatPtr(0.7),
+		TopP:             utils.FloatPtr(0.9),
+		FrequencyPenalty: utils.FloatPtr(0.5),
+		PresencePenalty:  utils.FloatPtr(-0.5),
+		Stop:             []string{"\n\n"},
+		ReasoningEffort:  &medium,
+		ThinkingTokens:   utils.IntPtr(1024),
+	}))
+}
@@ -0,0 +1,423 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"trpc.group/trpc-go/trpc-agent-go/model"
+	"trpc.group/trpc-go/trpc-agent-go/tool"
+)
+
+// DefaultOllamaURL is used by the ollama provider unless base_url is set
+const DefaultOllamaURL = "http://localhost:11434"
+
+const defaultChannelBufferSize = 256
+
+// OllamaOptions are the provider specific options of the ollama provider
+type OllamaOptions struct {
+	// KeepAlive controls how long the model stays loaded after a request, e.g. "10m"
+	KeepAlive string `json:"keep_alive,omitempty"`
+	// NumCtx sets the size of the context window
+	NumCtx int `json:"num_ctx,omitempty"`
+	// Seed makes sampling reproducible
+	Seed *int `json:"seed,omitempty"`
+	// Timeout bounds a single request, e.g. "2m". Requests do not time out by default.
+	Timeout string `json:"timeout,omitempty"`
+}
+
+func (o OllamaOptions) validate() error {
+	if o.KeepAlive != "" {
+		if _, err := time.ParseDuration(o.KeepAlive); err != nil {
+			return fmt.Errorf("invalid keep_alive %q: %w", o.KeepAlive, err)
+		}
+	}
+
+	if o.NumCtx < 0 {
+		return fmt.Errorf("num_ctx must not be negative, got %d", o.NumCtx)
+	}
+
+	if o.Timeout != "" {
+		if _, err := time.ParseDuration(o.Timeout); err != nil {
+			return fmt.Errorf("invalid timeout %q: %w", o.Timeout, err)
+		}
+	}
+
+	return nil
+}
+
+// ollamaProvider serves models through the native Ollama chat API
+type ollamaProvider struct{}
+
+func (p *ollamaProvider) Validate(config Config) error {
+	var options OllamaOptions
+	if err := DecodeOptions(config.Options, &options); err != nil {
+		return err
+	}
+
+	if err := options.validate(); err != nil {
+		return err
+	}
+
+	if config.BaseURL != "" {
+		if _, err := url.ParseRequestURI(config.BaseURL); err != nil {
+			return fmt.Errorf("invalid base_url %q: %w", config.BaseURL, err)
+		}
+	}
+
+	return nil
+}
+
+func (p *ollamaProvider) New(config Config) (model.Model, error) {
+	var options OllamaOptions
+	if err := DecodeOptions(config.Options, &options); err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{}
+	if options.Timeout != "" {
+		timeout, err := time.ParseDuration(options.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeout %q: %w", options.Timeout, err)
+		}
+		client.Timeout = timeout
+	}
+
+	baseURL := config.BaseURL
+	if baseURL == "" {
+		baseURL = DefaultOllamaURL
+	}
+
+	channelBufferSize := config.ChannelBufferSize
+	if channelBufferSize <= 0 {
+		channelBufferSize = defaultChannelBufferSize
+	}
+
+	return &ollamaModel{
+		name:              config.Model,
+		baseURL:           strings.TrimRight(baseURL, "/"),
+		options:           options,
+		client:            client,
+		channelBufferSize: channelBufferSize,
+	}, nil
+}
+
+// ollamaModel implements model.Model on top of the Ollama /api/chat endpoint
+type ollamaModel struct {
+	name              string
+	baseURL           string
+	options           OllamaOptions
+	client            *http.Client
+	channelBufferSize int
+}
+
+func (m *ollamaModel) Info() model.Info {
+	return model.Info{Name: m.name}
+}
+
+// GenerateContent sends the request to Ollama. Streaming requests yield a
+// partial response per chunk followed by a final response with the full message.
+func (m *ollamaModel) GenerateContent(ctx context.Context, request *model.Request) (<-chan *model.Response, error) {
+	if request == nil {
+		return nil, fmt.Errorf("request cannot be nil")
+	}
+
+	body, err := json.Marshal(m.buildRequest(request))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode ollama request: %w", err)
+	}
+
+	httpRequest, err := http.NewRequestWithContext(ctx, http.MethodPost, m.baseURL+"/api/chat", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ollama request: %w", err)
+	}
+	httpRequest.Header.Set("Content-Type", "application/json")
+
+	responseChan := make(chan *model.Response, m.channelBufferSize)
+
+	go func() {
+		defer close(responseChan)
+
+		send := func(response *model.Response) bool {
+			select {
+			case responseChan <- response:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		httpResponse, err := m.client.Do(httpRequest)
+		if err != nil {
+			send(errorResponse(fmt.Errorf("ollama request failed: %w", err)))
+			return
+		}
+		defer httpResponse.Body.Close()
+
+		if httpResponse.StatusCode != http.StatusOK {
+			send(errorResponse(readError(httpResponse)))
+			return
+		}
+
+		m.readResponses(httpResponse.Body, request.Stream, send)
+	}()
+
+	return responseChan, nil
+}
+
+// readResponses decodes the newline delimited chunks of a chat response
+func (m *ollamaModel) readResponses(body io.Reader, stream bool, send func(*model.Response) bool) {
+	var (
+		content   strings.Builder
+		toolCalls []model.ToolCall
+	)
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var chunk ollamaChatResponse
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			send(errorResponse(fmt.Errorf("failed to decode ollama response: %w", err)))
+			return
+		}
+
+		if chunk.Error != "" {
+			send(errorResponse(fmt.Errorf("ollama: %s", chunk.Error)))
+			return
+		}
+
+		content.WriteString(chunk.Message.Content)
+		toolCalls = append(toolCalls, convertToolCalls(chunk.Message.ToolCalls)...)
+
+		if stream && chunk.Message.Content != "" {
+			partial := &model.Response{
+				Object:    model.ObjectTypeChatCompletionChunk,
+				Created:   chunk.CreatedAt.Unix(),
+				Model:     chunk.Model,
+				Timestamp: time.Now(),
+				IsPartial: true,
+				Choices: []model.Choice{{
+					Delta: model.Message{Role: model.RoleAssistant, Content: chunk.Message.Content},
+				}},
+			}
+			if !send(partial) {
+				return
+			}
+		}
+
+		if chunk.Done {
+			finishReason := chunk.DoneReason
+			send(&model.Response{
+				Object:    model.ObjectTypeChatCompletion,
+				Created:   chunk.CreatedAt.Unix(),
+				Model:     chunk.Model,
+				Timestamp: time.Now(),
+				Done:      true,
+				Choices: []model.Choice{{
+					Message: model.Message{
+						Role:      model.RoleAssistant,
+						Content:   content.String(),
+						ToolCalls: toolCalls,
+					},
+					FinishReason: &finishReason,
+				}},
+				Usage: &model.Usage{
+					PromptTokens:     chunk.PromptEvalCount,
+					CompletionTokens: chunk.EvalCount,
+					TotalTokens:      chunk.PromptEvalCount + chunk.EvalCount,
+				},
+			})
+			return
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		send(errorResponse(fmt.Errorf("failed to read ollama response: %w", err)))
+		return
+	}
+
+	send(errorResponse(fmt.Errorf("ollama response ended before completion")))
+}
+
+func (m *ollamaModel) buildRequest(request *model.Request) ollamaChatRequest {
+	chatRequest := ollamaChatRequest{
+		Model:     m.name,
+		Stream:    request.Stream,
+		KeepAlive: m.options.KeepAlive,
+		Options:   map[string]interface{}{},
+		Think:     request.ThinkingEnabled,
+	}
+
+	for _, message := range request.Messages {
+		chatRequest.Messages = append(chatRequest.Messages, ollamaMessage{
+			Role:      string(message.Role),
+			Content:   message.Content,
+			ToolCalls: toOllamaToolCalls(message.ToolCalls),
+		})
+	}
+
+	for _, t := range request.Tools {
+		declaration := t.Declaration()
+		chatRequest.Tools = append(chatRequest.Tools, ollamaTool{
+			Type: "function",
+			Function: ollamaFunction{
+				Name:        declaration.Name,
+				Description: declaration.Description,
+				Parameters:  declaration.InputSchema,
+			},
+		})
+	}
+
+	if request.Temperature != nil {
+		chatRequest.Options["temperature"] = *request.Temperature
+	}
+	if request.TopP != nil {
+		chatRequest.Options["top_p"] = *request.TopP
+	}
+	if request.MaxTokens != nil {
+		chatRequest.Options["num_predict"] = *request.MaxTokens
+	}
+	if request.FrequencyPenalty != nil {
+		chatRequest.Options["frequency_penalty"] = *request.FrequencyPenalty
+	}
+	if request.PresencePenalty != nil {
+		chatRequest.Options["presence_penalty"] = *request.PresencePenalty
+	}
+	if len(request.Stop) > 0 {
+		chatRequest.Options["stop"] = request.Stop
+	}
+	if m.options.NumCtx > 0 {
+		chatRequest.Options["num_ctx"] = m.options.NumCtx
+	}
+	if m.options.Seed != nil {
+		chatRequest.Options["seed"] = *m.options.Seed
+	}
+
+	// Ollama constrains the answer to the JSON schema passed as format
+	if output := request.StructuredOutput; output != nil && output.JSONSchema != nil {
+		chatRequest.Format = output.JSONSchema.Schema
+	}
+
+	return chatRequest
+}
+
+func toOllamaToolCalls(toolCalls []model.ToolCall) []ollamaToolCall {
+	var result []ollamaToolCall
+	for _, toolCall := range toolCalls {
+		arguments := json.RawMessage(toolCall.Function.Arguments)
+		if !json.Valid(arguments) {
+			arguments = json.RawMessage("{}")
+		}
+
+		result = append(result, ollamaToolCall{
+			Function: ollamaFunctionCall{
+				Name:      toolCall.Function.Name,
+				Arguments: arguments,
+			},
+		})
+	}
+	return result
+}
+
+// convertToolCalls assigns IDs to the tool calls, Ollama does not provide any
+func convertToolCalls(toolCalls []ollamaToolCall) []model.ToolCall {
+	var result []model.ToolCall
+	for _, toolCall := range toolCalls {
+		result = append(result, model.ToolCall{
+			Type: "function",
+			ID:   "call_" + uuid.NewString(),
+			Function: model.FunctionDefinitionParam{
+				Name:      toolCall.Function.Name,
+				Arguments: []byte(toolCall.Function.Arguments),
+			},
+		})
+	}
+	return result
+}
+
+func errorResponse(err error) *model.Response {
+	return &model.Response{
+		Object:    "error",
+		Timestamp: time.Now(),
+		Done:      true,
+		Error: &model.ResponseError{
+			Message: err.Error(),
+			Type:    "api_error",
+		},
+	}
+}
+
+func readError(response *http.Response) error {
+	data, _ := io.ReadAll(io.LimitReader(response.Body, 4096))
+
+	var body struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(data, &body) == nil && body.Error != "" {
+		return fmt.Errorf("ollama returned %s: %s", response.Status, body.Error)
+	}
+
+	return fmt.Errorf("ollama returned %s", response.Status)
+}
+
+type ollamaChatRequest struct {
+	Model     string                 `json:"model"`
+	Messages  []ollamaMessage        `json:"messages"`
+	Tools     []ollamaTool           `json:"tools,omitempty"`
+	Stream    bool                   `json:"stream"`
+	Options   map[string]interface{} `json:"options,omitempty"`
+	KeepAlive string                 `json:"keep_alive,omitempty"`
+	Think     *bool                  `json:"think,omitempty"`
+	Format    map[string]interface{} `json:"format,omitempty"`
+}
+
+type ollamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+}
+
+type ollamaToolCall struct {
+	Function ollamaFunctionCall `json:"function"`
+}
+
+type ollamaFunctionCall struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+type ollamaTool struct {
+	Type     string         `json:"type"`
+	Function ollamaFunction `json:"function"`
+}
+
+type ollamaFunction struct {
+	Name        string       `json:"name"`
+	Description string       `json:"description,omitempty"`
+	Parameters  *tool.Schema `json:"parameters,omitempty"`
+}
+
+type ollamaChatResponse struct {
+	Model           string        `json:"model"`
+	CreatedAt       time.Time     `json:"created_at"`
+	Message         ollamaMessage `json:"message"`
+	Done            bool          `json:"done"`
+	DoneReason      string        `json:"done_reason,omitempty"`
+	PromptEvalCount int           `json:"prompt_eval_count,omitempty"`
+	EvalCount       int           `json:"eval_count,omitempty"`
+	Error           string        `json:"error,omitempty"`
+}
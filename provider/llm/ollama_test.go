@@ -0,0 +1,122 @@
+package llm
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/denkhaus/agents/shared"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"trpc.group/trpc-go/trpc-agent-go/model"
+)
+
+func newOllamaTestModel(t *testing.T, handler http.HandlerFunc) model.Model {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	m, err := New(Config{
+		Provider: shared.ModelProviderOllama,
+		Model:    "llama3.1",
+		BaseURL:  server.URL,
+		Options:  map[string]interface{}{"keep_alive": "10m", "num_ctx": 8192},
+	})
+	require.NoError(t, err)
+	return m
+}
+
+func TestOllama_GenerateContentStream(t *testing.T) {
+	var requested ollamaChatRequest
+	m := newOllamaTestModel(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/chat", r.URL.Path)
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&requested))
+
+		_, _ = w.Write([]byte(`{"model":"llama3.1","created_at":"2024-07-22T20:33:28Z","message":{"role":"assistant","content":"Hel"},"done":false}
+{"model":"llama3.1","created_at":"2024-07-22T20:33:28Z","message":{"role":"assistant","content":"lo"},"done":false}
+{"model":"llama3.1","created_at":"2024-07-22T20:33:28Z","message":{"role":"assistant","content":""},"done":true,"done_reason":"stop","prompt_eval_count":5,"eval_count":2}
+`))
+	})
+
+	temperature := 0.2
+	responses := collect(t, m, &model.Request{
+		Messages:         []model.Message{model.NewSystemMessage("be brief"), model.NewUserMessage("hi")},
+		GenerationConfig: model.GenerationConfig{Stream: true, Temperature: &temperature},
+	})
+
+	require.Len(t, responses, 3)
+	assert.True(t, responses[0].IsPartial)
+	assert.Equal(t, "Hel", responses[0].Choices[0].Delta.Content)
+	assert.Equal(t, "lo", responses[1].Choices[0].Delta.Content)
+
+	final := responses[2]
+	assert.True(t, final.Done)
+	assert.Equal(t, "Hello", final.Choices[0].Message.Content)
+	assert.Equal(t, 7, final.Usage.TotalTokens)
+
+	assert.Equal(t, "llama3.1", requested.Model)
+	assert.True(t, requested.Stream)
+	assert.Equal(t, "10m", requested.KeepAlive)
+	assert.Equal(t, 0.2, requested.Options["temperature"])
+	assert.Equal(t, float64(8192), requested.Options["num_ctx"])
+	require.Len(t, requested.Messages, 2)
+	assert.Equal(t, "system", requested.Messages[0].Role)
+}
+
+func TestOllama_GenerateContentToolCall(t *testing.T) {
+	m := newOllamaTestModel(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"model":"llama3.1","created_at":"2024-07-22T20:33:28Z","message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"calculator","arguments":{"expression":"1+1"}}}]},"done":true,"done_reason":"stop"}`))
+	})
+
+	responses := collect(t, m, &model.Request{
+		Messages: []model.Message{model.NewUserMessage("what is 1+1?")},
+	})
+
+	require.Len(t, responses, 1)
+	toolCalls := responses[0].Choices[0].Message.ToolCalls
+	require.Len(t, toolCalls, 1)
+	assert.Equal(t, "calculator", toolCalls[0].Function.Name)
+	assert.JSONEq(t, `{"expression":"1+1"}`, string(toolCalls[0].Function.Arguments))
+	assert.NotEmpty(t, toolCalls[0].ID)
+}
+
+func TestOllama_GenerateContentStructuredOutput(t *testing.T) {
+	var requested ollamaChatRequest
+	m := newOllamaTestModel(t, func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&requested))
+		_, _ = w.Write([]byte(`{"model":"llama3.1","created_at":"2024-07-22T20:33:28Z","message":{"role":"assistant","content":"{\"agent\":\"coder\"}"},"done":true,"done_reason":"stop"}`))
+	})
+
+	responses := collect(t, m, &model.Request{
+		Messages: []model.Message{model.NewUserMessage("who fixes the build?")},
+		StructuredOutput: &model.StructuredOutput{
+			Type: model.StructuredOutputJSONSchema,
+			JSONSchema: &model.JSONSchemaConfig{
+				Name:   "route_decision",
+				Schema: map[string]interface{}{"type": "object", "required": []string{"agent"}},
+			},
+		},
+	})
+
+	require.Len(t, responses, 1)
+	assert.Equal(t, `{"agent":"coder"}`, responses[0].Choices[0].Message.Content)
+	assert.Equal(t, "object", requested.Format["type"])
+	assert.Equal(t, []interface{}{"agent"}, requested.Format["required"])
+}
+
+func TestOllama_GenerateContentError(t *testing.T) {
+	m := newOllamaTestModel(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"error":"model \"llama3.1\" not found, try pulling it first"}`))
+	})
+
+	responses := collect(t, m, &model.Request{
+		Messages: []model.Message{model.NewUserMessage("hi")},
+	})
+
+	require.Len(t, responses, 1)
+	require.NotNil(t, responses[0].Error)
+	assert.Contains(t, responses[0].Error.Message, "not found, try pulling it first")
+}
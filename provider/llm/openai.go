@@ -0,0 +1,65 @@
+package llm
+
+import (
+	"fmt"
+	"net/url"
+
+	"trpc.group/trpc-go/trpc-agent-go/model"
+	"trpc.group/trpc-go/trpc-agent-go/model/openai"
+)
+
+// localAPIKey is sent to OpenAI-compatible servers without an API key, so the
+// client does not fall back to the OPENAI_API_KEY of the environment
+const localAPIKey = "unused"
+
+// OpenAIOptions are the provider specific options of the openai and
+// openai-compatible providers. They take no options yet, unknown keys are rejected.
+type OpenAIOptions struct{}
+
+// openAIProvider serves the OpenAI API and servers implementing its wire
+// format, such as vLLM, LM Studio or llama.cpp, when compatible is set
+type openAIProvider struct {
+	compatible bool
+}
+
+func (p *openAIProvider) Validate(config Config) error {
+	var options OpenAIOptions
+	if err := DecodeOptions(config.Options, &options); err != nil {
+		return err
+	}
+
+	if p.compatible && config.BaseURL == "" {
+		return fmt.Errorf("base_url is required")
+	}
+
+	if config.BaseURL != "" {
+		if _, err := url.ParseRequestURI(config.BaseURL); err != nil {
+			return fmt.Errorf("invalid base_url %q: %w", config.BaseURL, err)
+		}
+	}
+
+	return nil
+}
+
+func (p *openAIProvider) New(config Config) (model.Model, error) {
+	modelOptions := []openai.Option{}
+
+	if len(config.BaseURL) > 0 {
+		modelOptions = append(modelOptions, openai.WithBaseURL(config.BaseURL))
+	}
+
+	apiKey := config.APIKey
+	if apiKey == "" && p.compatible {
+		apiKey = localAPIKey
+	}
+
+	if len(apiKey) > 0 {
+		modelOptions = append(modelOptions, openai.WithAPIKey(apiKey))
+	}
+
+	if config.ChannelBufferSize > 0 {
+		modelOptions = append(modelOptions, openai.WithChannelBufferSize(config.ChannelBufferSize))
+	}
+
+	return openai.New(config.Model, modelOptions...), nil
+}
@@ -0,0 +1,300 @@
+// Package llm provides a registry of model providers, so agent settings can
+// select the backend serving an agent's model by name.
+package llm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/denkhaus/agents/shared"
+	"trpc.group/trpc-go/trpc-agent-go/model"
+)
+
+// Config is the backend independent model configuration of an agent
+type Config struct {
+	Provider          shared.ModelProvider
+	Model             string
+	BaseURL           string
+	APIKey            string
+	ChannelBufferSize int
+	// Options holds the provider specific options, decoded by the provider into its typed options
+	Options map[string]interface{}
+	// Retry controls how often the model is retried before the next fallback is taken
+	Retry RetryPolicy
+	// FailoverOn lists the error classes that move on to the next fallback, defaults to DefaultErrorClasses
+	FailoverOn []ErrorClass
+	// Fallbacks are tried in order when the model fails, they cannot declare fallbacks themselves
+	Fallbacks []Config
+}
+
+// ModelProvider creates models of a single backend
+type ModelProvider interface {
+	// Validate checks the configuration, including the provider specific options
+	Validate(config Config) error
+	// New creates a model from a validated configuration
+	New(config Config) (model.Model, error)
+}
+
+// Registry maps provider names to model providers
+type Registry struct {
+	mu        sync.RWMutex
+	providers map[shared.ModelProvider]ModelProvider
+}
+
+// DefaultRegistry is used by New and Validate. The built-in providers register into it.
+var DefaultRegistry = NewRegistry()
+
+func init() {
+	DefaultRegistry.MustRegister(shared.ModelProviderOpenAI, &openAIProvider{})
+	DefaultRegistry.MustRegister(shared.ModelProviderOpenAICompatible, &openAIProvider{compatible: true})
+	DefaultRegistry.MustRegister(shared.ModelProviderOllama, &ollamaProvider{})
+}
+
+// NewRegistry creates an empty model provider registry
+func NewRegistry() *Registry {
+	return &Registry{
+		providers: make(map[shared.ModelProvider]ModelProvider),
+	}
+}
+
+// Register registers a model provider under the given name and makes the
+// name valid for shared.ModelProvider.Validate
+func (r *Registry) Register(name shared.ModelProvider, provider ModelProvider) error {
+	if name == "" {
+		return fmt.Errorf("model provider name cannot be empty")
+	}
+	if provider == nil {
+		return fmt.Errorf("model provider %s cannot be nil", name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.providers[name]; exists {
+		return fmt.Errorf("model provider %s is already registered", name)
+	}
+
+	r.providers[name] = provider
+	shared.RegisterModelProvider(name)
+	return nil
+}
+
+// MustRegister registers a model provider and panics on error
+func (r *Registry) MustRegister(name shared.ModelProvider, provider ModelProvider) {
+	if err := r.Register(name, provider); err != nil {
+		panic(err.Error())
+	}
+}
+
+// Providers returns the names of all registered providers sorted by name
+func (r *Registry) Providers() []shared.ModelProvider {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]shared.ModelProvider, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+
+	sort.Slice(names, func(i, j int) bool {
+		return names[i] < names[j]
+	})
+	return names
+}
+
+// Validate checks the configuration and its fallbacks against the providers they select
+func (r *Registry) Validate(config Config) error {
+	if err := r.validateModel(config); err != nil {
+		return err
+	}
+
+	if err := validateClasses(config.FailoverOn); err != nil {
+		return fmt.Errorf("invalid failover_on: %w", err)
+	}
+
+	for index, fallback := range config.Fallbacks {
+		if len(fallback.Fallbacks) > 0 || len(fallback.FailoverOn) > 0 {
+			return fmt.Errorf("fallback %d: fallbacks cannot declare fallbacks or failover_on", index)
+		}
+		if err := r.validateModel(fallback); err != nil {
+			return fmt.Errorf("fallback %d: %w", index, err)
+		}
+	}
+
+	return nil
+}
+
+func (r *Registry) validateModel(config Config) error {
+	provider, err := r.provider(config.Provider)
+	if err != nil {
+		return err
+	}
+
+	if config.Model == "" {
+		return fmt.Errorf("model name cannot be empty for provider %s", config.Provider)
+	}
+
+	if err := config.Retry.validate(); err != nil {
+		return fmt.Errorf("invalid retry policy for model %s: %w", config.Model, err)
+	}
+
+	if err := provider.Validate(config); err != nil {
+		return fmt.Errorf("invalid config for model provider %s: %w", config.Provider, err)
+	}
+
+	return nil
+}
+
+// New validates the configuration and creates the model. A model with
+// fallbacks or retries is wrapped into a model trying the chain in order.
+func (r *Registry) New(config Config) (model.Model, error) {
+	if err := r.Validate(config); err != nil {
+		return nil, err
+	}
+
+	if len(config.Fallbacks) == 0 && config.Retry.MaxRetries == 0 {
+		return r.newModel(config)
+	}
+
+	chain := &fallbackModel{
+		failoverOn:        classesOrDefault(config.FailoverOn),
+		channelBufferSize: config.ChannelBufferSize,
+		sleep:             sleepContext,
+	}
+	if chain.channelBufferSize <= 0 {
+		chain.channelBufferSize = defaultChannelBufferSize
+	}
+
+	for _, chainConfig := range append([]Config{config}, config.Fallbacks...) {
+		m, err := r.newModel(chainConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		c, err := newCandidate(chainConfig, m)
+		if err != nil {
+			return nil, err
+		}
+		chain.candidates = append(chain.candidates, c)
+	}
+
+	return chain, nil
+}
+
+func (r *Registry) newModel(config Config) (model.Model, error) {
+	provider, err := r.provider(config.Provider)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := provider.New(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create model %s of provider %s: %w", config.Model, config.Provider, err)
+	}
+
+	return m, nil
+}
+
+func (r *Registry) provider(name shared.ModelProvider) (ModelProvider, error) {
+	r.mu.RLock()
+	provider, exists := r.providers[name]
+	r.mu.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("model provider %s is unknown", name)
+	}
+
+	return provider, nil
+}
+
+// Register registers a model provider in the default registry
+func Register(name shared.ModelProvider, provider ModelProvider) error {
+	return DefaultRegistry.Register(name, provider)
+}
+
+// MustRegister registers a model provider in the default registry and panics on error
+func MustRegister(name shared.ModelProvider, provider ModelProvider) {
+	DefaultRegistry.MustRegister(name, provider)
+}
+
+// Validate checks the configuration against the default registry
+func Validate(config Config) error {
+	return DefaultRegistry.Validate(config)
+}
+
+// New creates a model using the default registry
+func New(config Config) (model.Model, error) {
+	return DefaultRegistry.New(config)
+}
+
+// DecodeOptions decodes provider specific options into target, a pointer to
+// the provider's typed options. Unknown keys are rejected.
+func DecodeOptions(options map[string]interface{}, target interface{}) error {
+	if len(options) == 0 {
+		return nil
+	}
+
+	normalized, err := normalize(options)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(normalized)
+	if err != nil {
+		return fmt.Errorf("failed to encode options: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(target); err != nil {
+		return fmt.Errorf("invalid options: %w", err)
+	}
+
+	return nil
+}
+
+// normalize converts the map[interface{}]interface{} values produced by the
+// YAML decoder into maps that can be encoded as JSON
+func normalize(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			name, ok := key.(string)
+			if !ok {
+				return nil, fmt.Errorf("option key %v is not a string", key)
+			}
+			normalized, err := normalize(item)
+			if err != nil {
+				return nil, err
+			}
+			result[name] = normalized
+		}
+		return result, nil
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			normalized, err := normalize(item)
+			if err != nil {
+				return nil, err
+			}
+			result[key] = normalized
+		}
+		return result, nil
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			normalized, err := normalize(item)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = normalized
+		}
+		return result, nil
+	default:
+		return value, nil
+	}
+}
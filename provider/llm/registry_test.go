@@ -0,0 +1,101 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/denkhaus/agents/shared"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"trpc.group/trpc-go/trpc-agent-go/model"
+)
+
+func TestRegistry_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		err    string
+	}{
+		{"unknown provider", Config{Provider: "acme", Model: "m"}, "model provider acme is unknown"},
+		{"missing model", Config{Provider: shared.ModelProviderOpenAI}, "model name cannot be empty for provider openai"},
+		{"compatible without base url", Config{Provider: shared.ModelProviderOpenAICompatible, Model: "qwen"}, "base_url is required"},
+		{"unknown option", Config{Provider: shared.ModelProviderOpenAI, Model: "gpt-4o", Options: map[string]interface{}{"foo": 1}}, "unknown field \"foo\""},
+		{"invalid ollama option", Config{Provider: shared.ModelProviderOllama, Model: "llama3", Options: map[string]interface{}{"keep_alive": "soon"}}, "invalid keep_alive \"soon\""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorContains(t, Validate(tt.config), tt.err)
+		})
+	}
+
+	assert.NoError(t, Validate(Config{
+		Provider: shared.ModelProviderOllama,
+		Model:    "llama3",
+		Options:  map[string]interface{}{"keep_alive": "10m", "num_ctx": 8192},
+	}))
+}
+
+func TestRegistry_RegisterMakesProviderValid(t *testing.T) {
+	registry := NewRegistry()
+	name := shared.ModelProvider("test-registry-provider")
+
+	assert.Error(t, name.Validate())
+	require.NoError(t, registry.Register(name, &openAIProvider{}))
+	assert.NoError(t, name.Validate())
+	assert.Equal(t, []shared.ModelProvider{name}, registry.Providers())
+	assert.ErrorContains(t, registry.Register(name, &openAIProvider{}), "already registered")
+}
+
+func TestOpenAICompatible_GenerateContent(t *testing.T) {
+	var requested map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/chat/completions", r.URL.Path)
+		assert.Equal(t, "Bearer "+localAPIKey, r.Header.Get("Authorization"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&requested))
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{
+			"id": "chatcmpl-1",
+			"object": "chat.completion",
+			"created": 1700000000,
+			"model": "qwen2.5-coder",
+			"choices": [{"index": 0, "message": {"role": "assistant", "content": "hello"}, "finish_reason": "stop"}],
+			"usage": {"prompt_tokens": 3, "completion_tokens": 1, "total_tokens": 4}
+		}`))
+	}))
+	defer server.Close()
+
+	m, err := New(Config{
+		Provider: shared.ModelProviderOpenAICompatible,
+		Model:    "qwen2.5-coder",
+		BaseURL:  server.URL + "/v1",
+	})
+	require.NoError(t, err)
+
+	responses := collect(t, m, &model.Request{
+		Messages: []model.Message{model.NewUserMessage("hi")},
+	})
+
+	require.NotEmpty(t, responses)
+	final := responses[len(responses)-1]
+	require.Nil(t, final.Error)
+	assert.Equal(t, "hello", final.Choices[0].Message.Content)
+	assert.Equal(t, "qwen2.5-coder", requested["model"])
+}
+
+func collect(t *testing.T, m model.Model, request *model.Request) []*model.Response {
+	t.Helper()
+
+	responseChan, err := m.GenerateContent(context.Background(), request)
+	require.NoError(t, err)
+
+	var responses []*model.Response
+	for response := range responseChan {
+		responses = append(responses, response)
+	}
+	return responses
+}
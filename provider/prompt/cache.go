@@ -0,0 +1,60 @@
+package prompt
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"sync"
+)
+
+// InstructionCache keeps the last instruction rendered per prompt version and
+// reuses it while the render context is unchanged
+type InstructionCache struct {
+	mu      sync.Mutex
+	entries map[int]instructionCacheEntry
+}
+
+type instructionCacheEntry struct {
+	digest      [sha256.Size]byte
+	instruction string
+}
+
+// NewInstructionCache creates an empty InstructionCache
+func NewInstructionCache() *InstructionCache {
+	return &InstructionCache{
+		entries: make(map[int]instructionCacheEntry),
+	}
+}
+
+// Render returns the cached instruction of the version if data equals the data
+// it was rendered with, otherwise it renders and caches the instruction.
+// Data that cannot be marshaled to JSON is rendered without caching.
+func (c *InstructionCache) Render(
+	version int,
+	data map[string]interface{},
+	render func(data map[string]interface{}) (string, error),
+) (string, error) {
+
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return render(data)
+	}
+	digest := sha256.Sum256(encoded)
+
+	c.mu.Lock()
+	entry, ok := c.entries[version]
+	c.mu.Unlock()
+	if ok && entry.digest == digest {
+		return entry.instruction, nil
+	}
+
+	instruction, err := render(data)
+	if err != nil {
+		return "", err
+	}
+
+	c.mu.Lock()
+	c.entries[version] = instructionCacheEntry{digest: digest, instruction: instruction}
+	c.mu.Unlock()
+
+	return instruction, nil
+}
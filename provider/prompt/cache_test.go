@@ -0,0 +1,44 @@
+package prompt
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstructionCache_Render(t *testing.T) {
+	cache := NewInstructionCache()
+
+	renders := 0
+	render := func(data map[string]interface{}) (string, error) {
+		renders++
+		return fmt.Sprintf("agents: %v", data["agents"]), nil
+	}
+
+	instruction, err := cache.Render(1, map[string]interface{}{"agents": []string{"coder"}}, render)
+	require.NoError(t, err)
+	assert.Equal(t, "agents: [coder]", instruction)
+
+	// Unchanged data is served from the cache
+	_, err = cache.Render(1, map[string]interface{}{"agents": []string{"coder"}}, render)
+	require.NoError(t, err)
+	assert.Equal(t, 1, renders)
+
+	// Versions are cached separately
+	_, err = cache.Render(2, map[string]interface{}{"agents": []string{"coder"}}, render)
+	require.NoError(t, err)
+	assert.Equal(t, 2, renders)
+
+	instruction, err = cache.Render(1, map[string]interface{}{"agents": []string{"coder", "debugger"}}, render)
+	require.NoError(t, err)
+	assert.Equal(t, "agents: [coder debugger]", instruction)
+	assert.Equal(t, 3, renders)
+
+	// Errors are not cached
+	_, err = cache.Render(1, map[string]interface{}{"agents": nil}, func(map[string]interface{}) (string, error) {
+		return "", fmt.Errorf("invalid data")
+	})
+	assert.ErrorContains(t, err, "invalid data")
+}
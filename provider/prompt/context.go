@@ -0,0 +1,251 @@
+package prompt
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/denkhaus/agents/provider"
+	"github.com/denkhaus/agents/shared"
+	"github.com/samber/do"
+)
+
+// Context keys of the default contributors
+const (
+	ContextKeyWorkspace    = "workspace"
+	ContextKeyTime         = "time"
+	ContextKeyProject      = "project"
+	ContextKeySessionState = "session_state"
+	ContextKeyVariables    = "variables"
+)
+
+// reservedContextKeys are set by the agent configurations themselves
+var reservedContextKeys = []string{shared.ContextKeyToolInfo, shared.ContextKeyAgentInfo}
+
+// contextProviderImpl implements provider.PromptContextProvider
+type contextProviderImpl struct {
+	mu           sync.RWMutex
+	contributors map[string]provider.PromptContextContributor
+}
+
+// NewContextProvider creates the PromptContextProvider with the default
+// contributors using dependency injection. Further contributors are added with Register.
+func NewContextProvider(i *do.Injector) (provider.PromptContextProvider, error) {
+	return NewDefaultContextProvider(), nil
+}
+
+// NewDefaultContextProvider creates a PromptContextProvider with the workspace,
+// time, project, session state and variables contributors
+func NewDefaultContextProvider() provider.PromptContextProvider {
+	contextProvider := NewContextProviderWith()
+	for _, contributor := range []provider.PromptContextContributor{
+		&workspaceContributor{},
+		&timeContributor{now: time.Now},
+		&projectContributor{},
+		&sessionStateContributor{},
+		&variablesContributor{},
+	} {
+		if err := contextProvider.Register(contributor); err != nil {
+			panic(err)
+		}
+	}
+	return contextProvider
+}
+
+// NewContextProviderWith creates a PromptContextProvider with the given contributors only
+func NewContextProviderWith(contributors ...provider.PromptContextContributor) provider.PromptContextProvider {
+	contextProvider := &contextProviderImpl{
+		contributors: make(map[string]provider.PromptContextContributor),
+	}
+	for _, contributor := range contributors {
+		if err := contextProvider.Register(contributor); err != nil {
+			panic(err)
+		}
+	}
+	return contextProvider
+}
+
+// Register adds a contributor. A key can be contributed by a single contributor only.
+func (p *contextProviderImpl) Register(contributor provider.PromptContextContributor) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	keys := contributor.Keys()
+	if len(keys) == 0 {
+		return fmt.Errorf("prompt context contributor %T declares no keys", contributor)
+	}
+
+	for _, key := range keys {
+		for _, reserved := range reservedContextKeys {
+			if key == reserved {
+				return fmt.Errorf("prompt context key %s is reserved", key)
+			}
+		}
+		if _, exists := p.contributors[key]; exists {
+			return fmt.Errorf("prompt context key %s is already contributed", key)
+		}
+	}
+
+	for _, key := range keys {
+		p.contributors[key] = contributor
+	}
+	return nil
+}
+
+// GetContext runs every contributor of the requested keys once and rejects
+// values for keys a contributor did not declare
+func (p *contextProviderImpl) GetContext(
+	ctx context.Context,
+	keys []string,
+	request provider.PromptContextRequest,
+) (map[string]interface{}, error) {
+
+	p.mu.RLock()
+	var contributors []provider.PromptContextContributor
+	seen := make(map[provider.PromptContextContributor]bool)
+	for _, key := range keys {
+		contributor, ok := p.contributors[key]
+		if !ok || seen[contributor] {
+			continue
+		}
+		seen[contributor] = true
+		contributors = append(contributors, contributor)
+	}
+	p.mu.RUnlock()
+
+	result := make(map[string]interface{})
+	for _, contributor := range contributors {
+		values, err := contributor.Contribute(ctx, request)
+		if err != nil {
+			return nil, fmt.Errorf("prompt context contributor %T failed: %w", contributor, err)
+		}
+
+		declared := contributor.Keys()
+		for key, value := range values {
+			if !containsKey(declared, key) {
+				return nil, fmt.Errorf("prompt context contributor %T returned undeclared key %s", contributor, key)
+			}
+			result[key] = value
+		}
+	}
+
+	return result, nil
+}
+
+// SchemaKeys returns the sorted top-level property names of a JSON schema
+func SchemaKeys(schema map[string]interface{}) []string {
+	properties, ok := schema["properties"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	keys := make([]string, 0, len(properties))
+	for key := range properties {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func containsKey(keys []string, key string) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// workspaceContributor exposes the workspace of the agent as {"path": ..., "scratch": ...}
+type workspaceContributor struct{}
+
+func (c *workspaceContributor) Keys() []string {
+	return []string{ContextKeyWorkspace}
+}
+
+func (c *workspaceContributor) Contribute(ctx context.Context, request provider.PromptContextRequest) (map[string]interface{}, error) {
+	return map[string]interface{}{
+		ContextKeyWorkspace: map[string]interface{}{
+			"path":    request.Workspace,
+			"scratch": request.Scratch,
+		},
+	}, nil
+}
+
+// timeContributor exposes the current time in the configured timezone
+type timeContributor struct {
+	now func() time.Time
+}
+
+func (c *timeContributor) Keys() []string {
+	return []string{ContextKeyTime}
+}
+
+func (c *timeContributor) Contribute(ctx context.Context, request provider.PromptContextRequest) (map[string]interface{}, error) {
+	location := time.Local
+	if request.Settings.Timezone != "" {
+		loaded, err := time.LoadLocation(request.Settings.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timezone %q: %w", request.Settings.Timezone, err)
+		}
+		location = loaded
+	}
+
+	now := c.now().In(location)
+	return map[string]interface{}{
+		ContextKeyTime: map[string]interface{}{
+			"now":      now.Format(time.RFC3339),
+			"date":     now.Format("2006-01-02"),
+			"weekday":  now.Weekday().String(),
+			"timezone": location.String(),
+		},
+	}, nil
+}
+
+// projectContributor exposes the project the agent is attached to
+type projectContributor struct{}
+
+func (c *projectContributor) Keys() []string {
+	return []string{ContextKeyProject}
+}
+
+func (c *projectContributor) Contribute(ctx context.Context, request provider.PromptContextRequest) (map[string]interface{}, error) {
+	return map[string]interface{}{ContextKeyProject: request.Settings.Project}, nil
+}
+
+// sessionStateContributor exposes the selected session state keys as strings
+type sessionStateContributor struct{}
+
+func (c *sessionStateContributor) Keys() []string {
+	return []string{ContextKeySessionState}
+}
+
+func (c *sessionStateContributor) Contribute(ctx context.Context, request provider.PromptContextRequest) (map[string]interface{}, error) {
+	state := make(map[string]interface{}, len(request.Settings.SessionState))
+	for _, key := range request.Settings.SessionState {
+		state[key] = ""
+		if request.Session != nil {
+			if value, ok := request.Session.State[key]; ok {
+				state[key] = string(value)
+			}
+		}
+	}
+	return map[string]interface{}{ContextKeySessionState: state}, nil
+}
+
+// variablesContributor exposes the user supplied variables
+type variablesContributor struct{}
+
+func (c *variablesContributor) Keys() []string {
+	return []string{ContextKeyVariables}
+}
+
+func (c *variablesContributor) Contribute(ctx context.Context, request provider.PromptContextRequest) (map[string]interface{}, error) {
+	variables := request.Settings.Variables
+	if variables == nil {
+		variables = map[string]interface{}{}
+	}
+	return map[string]interface{}{ContextKeyVariables: variables}, nil
+}
@@ -0,0 +1,106 @@
+package prompt
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/denkhaus/agents/provider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"trpc.group/trpc-go/trpc-agent-go/session"
+)
+
+type staticContributor struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (c *staticContributor) Keys() []string {
+	return c.keys
+}
+
+func (c *staticContributor) Contribute(ctx context.Context, request provider.PromptContextRequest) (map[string]interface{}, error) {
+	return c.values, nil
+}
+
+func TestContextProvider_DefaultContributors(t *testing.T) {
+	contextProvider := NewContextProviderWith(
+		&workspaceContributor{},
+		&timeContributor{now: func() time.Time { return time.Date(2025, 8, 27, 22, 30, 0, 0, time.UTC) }},
+		&projectContributor{},
+		&sessionStateContributor{},
+		&variablesContributor{},
+	)
+
+	request := provider.PromptContextRequest{
+		AgentID: testAgentID,
+		Settings: provider.PromptContextSettings{
+			Project:      "agents",
+			Timezone:     "Europe/Berlin",
+			SessionState: []string{"ticket", "missing"},
+			Variables:    map[string]interface{}{"team": "platform"},
+		},
+		Workspace: "/tmp/workspace",
+		Session:   &session.Session{State: session.StateMap{"ticket": []byte("AG-42"), "secret": []byte("x")}},
+	}
+
+	promptContext, err := contextProvider.GetContext(context.Background(),
+		[]string{ContextKeyWorkspace, ContextKeyTime, ContextKeyProject, ContextKeySessionState, ContextKeyVariables, "topic"},
+		request,
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]interface{}{"path": "/tmp/workspace", "scratch": ""}, promptContext[ContextKeyWorkspace])
+	assert.Equal(t, map[string]interface{}{
+		"now":      "2025-08-28T00:30:00+02:00",
+		"date":     "2025-08-28",
+		"weekday":  "Thursday",
+		"timezone": "Europe/Berlin",
+	}, promptContext[ContextKeyTime])
+	assert.Equal(t, "agents", promptContext[ContextKeyProject])
+	assert.Equal(t, map[string]interface{}{"ticket": "AG-42", "missing": ""}, promptContext[ContextKeySessionState])
+	assert.Equal(t, map[string]interface{}{"team": "platform"}, promptContext[ContextKeyVariables])
+	assert.NotContains(t, promptContext, "topic")
+
+	// Only the contributors of the requested keys run
+	promptContext, err = contextProvider.GetContext(context.Background(), []string{ContextKeyProject}, request)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{ContextKeyProject: "agents"}, promptContext)
+
+	request.Settings.Timezone = "Mars/Olympus"
+	_, err = contextProvider.GetContext(context.Background(), []string{ContextKeyTime}, request)
+	assert.ErrorContains(t, err, "invalid timezone")
+}
+
+func TestContextProvider_Register(t *testing.T) {
+	contextProvider := NewDefaultContextProvider()
+
+	err := contextProvider.Register(&staticContributor{keys: []string{ContextKeyProject}})
+	assert.ErrorContains(t, err, "prompt context key project is already contributed")
+
+	err = contextProvider.Register(&staticContributor{keys: []string{"tool_info"}})
+	assert.ErrorContains(t, err, "prompt context key tool_info is reserved")
+
+	err = contextProvider.Register(&staticContributor{})
+	assert.ErrorContains(t, err, "declares no keys")
+
+	// Contributors may only return the keys they declare
+	require.NoError(t, contextProvider.Register(&staticContributor{
+		keys:   []string{"ticket"},
+		values: map[string]interface{}{"ticket": "AG-42", "project": "other"},
+	}))
+	_, err = contextProvider.GetContext(context.Background(), []string{"ticket"}, provider.PromptContextRequest{})
+	assert.ErrorContains(t, err, "returned undeclared key project")
+}
+
+func TestSchemaKeys(t *testing.T) {
+	assert.Equal(t, []string{"agent_info", "time"}, SchemaKeys(map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"time":       map[string]interface{}{"type": "object"},
+			"agent_info": map[string]interface{}{"type": "array"},
+		},
+	}))
+	assert.Empty(t, SchemaKeys(nil))
+}
@@ -16,6 +16,25 @@ func (p *promptEntry) GetName() string {
 	return p.metadata.Name
 }
 
+func (p *promptEntry) GetVersion() int {
+	return p.metadata.Version
+}
+
+func (p *promptEntry) GetWeight() int {
+	if p.metadata.Weight == nil {
+		return DefaultWeight
+	}
+	return *p.metadata.Weight
+}
+
+func (p *promptEntry) GetContextKeys() []string {
+	return SchemaKeys(p.metadata.Schema)
+}
+
+func (p *promptEntry) IsTimeDependent() bool {
+	return p.timeDependent
+}
+
 func (p *promptEntry) GetGlobalInstruction() string {
 	return p.metadata.GlobalInstruction
 }
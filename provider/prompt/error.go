@@ -23,3 +23,12 @@ func (e *PromptError) Error() string {
 func (e *PromptError) Unwrap() error {
 	return e.Err
 }
+
+// VersionError reports a prompt version that does not exist
+type VersionError struct {
+	Version int
+}
+
+func (e *VersionError) Error() string {
+	return fmt.Sprintf("unknown version %d", e.Version)
+}
@@ -0,0 +1,167 @@
+package prompt
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+)
+
+// timeFuncs are the helpers whose result depends on the time of the render
+var timeFuncs = map[string]bool{"now": true, "date": true}
+
+// FuncMap returns the helper functions available to prompt templates and partials
+func FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"join":    join,
+		"indent":  indent,
+		"upper":   strings.ToUpper,
+		"lower":   strings.ToLower,
+		"trim":    strings.TrimSpace,
+		"default": defaultValue,
+		"now":     time.Now,
+		"date":    date,
+	}
+}
+
+// UsesTimeFuncs reports whether the template, or a template it invokes, calls
+// now or date. Its instruction changes without the render data changing and
+// must not be cached.
+func UsesTimeFuncs(tpl *template.Template) bool {
+	visited := map[string]bool{}
+
+	var walk func(name string) bool
+	walk = func(name string) bool {
+		if visited[name] {
+			return false
+		}
+		visited[name] = true
+
+		t := tpl.Lookup(name)
+		if t == nil || t.Tree == nil {
+			return false
+		}
+		return usesTimeFuncs(t.Tree.Root, walk)
+	}
+
+	return walk(tpl.Name())
+}
+
+// usesTimeFuncs walks a parse tree for calls of the time funcs, invoked
+// templates are followed through walkTemplate
+func usesTimeFuncs(node parse.Node, walkTemplate func(name string) bool) bool {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return false
+		}
+		for _, child := range n.Nodes {
+			if usesTimeFuncs(child, walkTemplate) {
+				return true
+			}
+		}
+	case *parse.ActionNode:
+		return usesTimeFuncs(n.Pipe, walkTemplate)
+	case *parse.PipeNode:
+		if n == nil {
+			return false
+		}
+		for _, cmd := range n.Cmds {
+			if usesTimeFuncs(cmd, walkTemplate) {
+				return true
+			}
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			if usesTimeFuncs(arg, walkTemplate) {
+				return true
+			}
+		}
+	case *parse.ChainNode:
+		return usesTimeFuncs(n.Node, walkTemplate)
+	case *parse.IdentifierNode:
+		return timeFuncs[n.Ident]
+	case *parse.IfNode:
+		return usesTimeFuncs(&n.BranchNode, walkTemplate)
+	case *parse.RangeNode:
+		return usesTimeFuncs(&n.BranchNode, walkTemplate)
+	case *parse.WithNode:
+		return usesTimeFuncs(&n.BranchNode, walkTemplate)
+	case *parse.BranchNode:
+		return usesTimeFuncs(n.Pipe, walkTemplate) ||
+			usesTimeFuncs(n.List, walkTemplate) ||
+			usesTimeFuncs(n.ElseList, walkTemplate)
+	case *parse.TemplateNode:
+		return usesTimeFuncs(n.Pipe, walkTemplate) || walkTemplate(n.Name)
+	}
+	return false
+}
+
+// join concatenates the elements of a slice, {{.items | join ", "}}
+func join(sep string, items interface{}) string {
+	value := reflect.ValueOf(items)
+	if value.Kind() != reflect.Slice && value.Kind() != reflect.Array {
+		return fmt.Sprint(items)
+	}
+
+	parts := make([]string, value.Len())
+	for i := range parts {
+		parts[i] = fmt.Sprint(value.Index(i).Interface())
+	}
+	return strings.Join(parts, sep)
+}
+
+// indent prefixes every non-empty line with the given number of spaces, {{.text | indent 4}}
+func indent(spaces int, text string) string {
+	prefix := strings.Repeat(" ", spaces)
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// defaultValue returns def if value is empty, {{.name | default "unknown"}}
+func defaultValue(def interface{}, value interface{}) interface{} {
+	if value == nil {
+		return def
+	}
+
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		if v.Len() == 0 {
+			return def
+		}
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return def
+		}
+	}
+	return value
+}
+
+// date formats a time with a Go layout, {{now | date "2006-01-02"}}
+func date(layout string, value interface{}) (string, error) {
+	switch t := value.(type) {
+	case time.Time:
+		return t.Format(layout), nil
+	case *time.Time:
+		if t == nil {
+			return "", nil
+		}
+		return t.Format(layout), nil
+	case string:
+		parsed, err := time.Parse(time.RFC3339, t)
+		if err != nil {
+			return "", fmt.Errorf("date expects an RFC 3339 time, got %q", t)
+		}
+		return parsed.Format(layout), nil
+	default:
+		return "", fmt.Errorf("date expects a time, got %T", value)
+	}
+}
@@ -3,87 +3,186 @@ package prompt
 import (
 	"embed"
 	"fmt"
-	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
 	"strings"
 	"text/template"
 
 	"github.com/denkhaus/agents/provider"
+	"github.com/denkhaus/agents/shared/resource"
 	"github.com/google/uuid"
 	"github.com/xeipuuv/gojsonschema"
 	"gopkg.in/yaml.v2"
 )
 
-//go:embed templates/*.md
+//go:embed templates/*.md templates/partials/*.tmpl templates/schemas/*.yaml
 var promptFS embed.FS
 
+// Subdirectories of a prompt source holding shared partials and schema fragments.
+// A partial file agents.tmpl is included with {{template "agents" .}}, a
+// fragment file agent_info.yaml is referenced in a schema with $fragment: agent_info.
+const (
+	PartialsDir  = "partials"
+	FragmentsDir = "schemas"
+)
+
+// EmbeddedSource returns the source of the compiled-in prompt templates
+func EmbeddedSource() resource.Source {
+	return resource.Source{
+		Name: resource.SourceEmbedded,
+		FS:   promptFS,
+		Root: "templates",
+	}
+}
+
+// Defaults of the version and weight front matter keys
+const (
+	DefaultVersion = 1
+	DefaultWeight  = 1
+)
+
 // NewPromptManager creates a new instance of PromptManager.
-// It takes an embed.FS for loading prompt templates.
-func NewPromptManager(fsys embed.FS, rootPath string) (provider.PromptManager, error) {
-	prompts := make(map[uuid.UUID]*promptEntry)
+// Prompts are loaded from the sources in order, a prompt of a later source
+// replaces the prompt with the same agent ID and version of an earlier source.
+// Partials and schema fragments are shared by the prompts of all sources, a
+// partial or fragment of a later source replaces the one with the same name.
+func NewPromptManager(sources ...resource.Source) (provider.PromptManager, error) {
+	partials, _, err := resource.LoadKeyedSources(subSources(sources, PartialsDir), []string{".tmpl"}, parsePartial)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load prompt partials: %w", err)
+	}
 
-	// Use fs.WalkDir to correctly traverse the embedded directory
-	err := fs.WalkDir(fsys, rootPath, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-		if d.IsDir() {
-			return nil
-		}
+	fragments, _, err := resource.LoadKeyedSources(subSources(sources, FragmentsDir), []string{".yaml", ".yml"}, parseFragment)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load schema fragments: %w", err)
+	}
 
-		if !strings.HasSuffix(path, ".md") {
-			return nil
-		}
+	base, err := newBaseTemplate(partials)
+	if err != nil {
+		return nil, err
+	}
 
-		content, err := fsys.ReadFile(path)
-		if err != nil {
-			return fmt.Errorf("failed to read embedded prompt file %s: %w", path, err)
-		}
+	parser := &promptParser{base: base, fragments: fragments}
+	prompts, origins, err := resource.LoadKeyedSources(sources, []string{".md"}, parser.parse)
+	if err != nil {
+		return nil, err
+	}
 
-		parts := strings.SplitN(string(content), "---", 3)
-		if len(parts) < 3 {
-			return fmt.Errorf("invalid Markdown front matter in %s", path)
-		}
+	return NewManager(prompts, origins), nil
+}
 
-		var metadata PromptMetadata
-		if err := yaml.Unmarshal([]byte(parts[1]), &metadata); err != nil {
-			return fmt.Errorf("failed to parse YAML front matter in %s: %w", path, err)
-		}
+// subSources returns the optional sources of a subdirectory of the given sources
+func subSources(sources []resource.Source, dir string) []resource.Source {
+	result := make([]resource.Source, len(sources))
+	for i, source := range sources {
+		source.Root = path.Join(source.Root, dir)
+		source.Optional = true
+		result[i] = source
+	}
+	return result
+}
 
-		if metadata.Name == "" {
-			return fmt.Errorf("prompt name cannot be empty in %s", path)
-		}
+// parsePartial reads a partial, named after its file name without extension
+func parsePartial(filePath string, content []byte) (string, string, error) {
+	name := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+	return name, strings.TrimRight(string(content), "\n"), nil
+}
 
-		if metadata.AgentID == uuid.Nil {
-			return fmt.Errorf("agent ID cannot be empty in %s", path)
-		}
+// parseFragment reads a schema fragment, named after its file name without extension
+func parseFragment(filePath string, content []byte) (string, JSONSchema, error) {
+	var fragment JSONSchema
+	if err := yaml.Unmarshal(content, &fragment); err != nil {
+		return "", nil, fmt.Errorf("failed to parse schema fragment %s: %w", filePath, err)
+	}
 
-		templateContent := strings.TrimSpace(parts[2])
-		tpl, err := template.New(metadata.Name).Parse(templateContent)
-		if err != nil {
-			return fmt.Errorf("failed to parse template %s: %w", metadata.Name, err)
-		}
+	name := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+	return name, fragment, nil
+}
 
-		schemaLoader := gojsonschema.NewGoLoader(metadata.Schema)
-		schema, err := gojsonschema.NewSchema(schemaLoader)
-		if err != nil {
-			return fmt.Errorf("failed to compile schema for [%s]-[%s]: %w", metadata.Name, metadata.AgentID, err)
-		}
+// newBaseTemplate parses the partials into a template set every prompt is cloned from
+func newBaseTemplate(partials map[string]string) (*template.Template, error) {
+	names := make([]string, 0, len(partials))
+	for name := range partials {
+		names = append(names, name)
+	}
+	sort.Strings(names)
 
-		if _, exists := prompts[metadata.AgentID]; exists {
-			return fmt.Errorf("duplicate agent id on prompt %s: prompt with agent id %s already exists", metadata.Name, metadata.AgentID)
+	base := template.New("").Funcs(FuncMap())
+	for _, name := range names {
+		if _, err := base.New(name).Parse(partials[name]); err != nil {
+			return nil, fmt.Errorf("failed to parse partial %s: %w", name, err)
 		}
+	}
+
+	return base, nil
+}
+
+// promptParser parses prompts with the partials and schema fragments of all sources
+type promptParser struct {
+	base      *template.Template
+	fragments map[string]JSONSchema
+}
+
+// parse parses a Markdown prompt template with YAML front matter
+func (p *promptParser) parse(path string, content []byte) (provider.PromptKey, *promptEntry, error) {
+	parts := strings.SplitN(string(content), "---", 3)
+	if len(parts) < 3 {
+		return provider.PromptKey{}, nil, fmt.Errorf("invalid Markdown front matter in %s", path)
+	}
+
+	var metadata PromptMetadata
+	if err := yaml.Unmarshal([]byte(parts[1]), &metadata); err != nil {
+		return provider.PromptKey{}, nil, fmt.Errorf("failed to parse YAML front matter in %s: %w", path, err)
+	}
+
+	if metadata.Name == "" {
+		return provider.PromptKey{}, nil, fmt.Errorf("prompt name cannot be empty in %s", path)
+	}
+
+	if metadata.AgentID == uuid.Nil {
+		return provider.PromptKey{}, nil, fmt.Errorf("agent ID cannot be empty in %s", path)
+	}
+
+	if metadata.Version == 0 {
+		metadata.Version = DefaultVersion
+	}
+	if metadata.Version < 0 {
+		return provider.PromptKey{}, nil, fmt.Errorf("prompt version must be positive in %s", path)
+	}
+	if metadata.Weight != nil && *metadata.Weight < 0 {
+		return provider.PromptKey{}, nil, fmt.Errorf("prompt weight must not be negative in %s", path)
+	}
+
+	templateContent := strings.TrimSpace(parts[2])
+	tpl, err := p.base.Clone()
+	if err != nil {
+		return provider.PromptKey{}, nil, fmt.Errorf("failed to clone partials for template %s: %w", metadata.Name, err)
+	}
+
+	tpl, err = tpl.New(metadata.Name).Parse(templateContent)
+	if err != nil {
+		return provider.PromptKey{}, nil, fmt.Errorf("failed to parse template %s: %w", metadata.Name, err)
+	}
 
-		prompts[metadata.AgentID] = &promptEntry{
-			template: tpl,
-			metadata: metadata,
-			schema:   schema,
+	if metadata.Schema != nil {
+		resolved, err := resolveFragments(map[string]interface{}(metadata.Schema), p.fragments, nil)
+		if err != nil {
+			return provider.PromptKey{}, nil, fmt.Errorf("failed to resolve schema of [%s]-[%s]: %w", metadata.Name, metadata.AgentID, err)
 		}
-		return nil
-	})
+		metadata.Schema = resolved.(map[string]interface{})
+	}
 
+	schemaLoader := gojsonschema.NewGoLoader(metadata.Schema)
+	schema, err := gojsonschema.NewSchema(schemaLoader)
 	if err != nil {
-		return nil, err
+		return provider.PromptKey{}, nil, fmt.Errorf("failed to compile schema for [%s]-[%s]: %w", metadata.Name, metadata.AgentID, err)
 	}
 
-	return NewManager(prompts), nil
+	return provider.PromptKey{AgentID: metadata.AgentID, Version: metadata.Version}, &promptEntry{
+		template:      tpl,
+		metadata:      metadata,
+		schema:        schema,
+		timeDependent: UsesTimeFuncs(tpl),
+	}, nil
 }
@@ -0,0 +1,119 @@
+package prompt
+
+import (
+	"testing"
+	"testing/fstest"
+	"text/template"
+	"time"
+
+	"github.com/denkhaus/agents/shared/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPromptManager_EmbeddedTemplates(t *testing.T) {
+	manager, err := NewPromptManager(EmbeddedSource())
+	require.NoError(t, err)
+
+	prompt, err := manager.GetPrompt(testAgentID)
+	require.NoError(t, err)
+
+	instruction, err := prompt.GetInstruction(map[string]interface{}{
+		"tool_info":  []map[string]interface{}{{"Name": "calculator", "Description": "Evaluates expressions"}},
+		"agent_info": []map[string]interface{}{{"Name": "debugger", "Role": "debugger", "ID": "42", "Description": "Fixes bugs"}},
+	})
+	require.NoError(t, err)
+	assert.Contains(t, instruction, " - calculator: Evaluates expressions\n")
+	assert.Contains(t, instruction, " - debugger: Role: debugger | ID: 42 | Fixes bugs\n")
+
+	// The referenced fragment requires the fields of an agent
+	_, err = prompt.GetInstruction(map[string]interface{}{
+		"tool_info":  []map[string]interface{}{},
+		"agent_info": []map[string]interface{}{{"Name": "debugger"}},
+	})
+	assert.ErrorContains(t, err, "prompt data validation failed")
+}
+
+func TestNewPromptManager_PartialsAndFragments(t *testing.T) {
+	embedded := resource.Source{
+		Name: resource.SourceEmbedded,
+		FS: fstest.MapFS{
+			"templates/coder.md": {Data: []byte(`---
+name: coder-prompt
+agent_id: "` + testAgentID.String() + `"
+schema:
+  type: object
+  properties:
+    topics:
+      $fragment: topics
+---
+{{template "greeting" .}} {{.topics | join ", " | upper}}`)},
+			"templates/partials/greeting.tmpl": {Data: []byte("Hello\n")},
+			"templates/schemas/topics.yaml":    {Data: []byte("type: array\nitems:\n  $fragment: topic\n")},
+			"templates/schemas/topic.yaml":     {Data: []byte("type: string\n")},
+		},
+		Root: "templates",
+	}
+	user := resource.Source{
+		Name: resource.SourceUser,
+		FS: fstest.MapFS{
+			"prompts/partials/greeting.tmpl": {Data: []byte("Hi")},
+		},
+		Root: "prompts",
+	}
+
+	manager, err := NewPromptManager(embedded, user)
+	require.NoError(t, err)
+
+	prompt, err := manager.GetPrompt(testAgentID)
+	require.NoError(t, err)
+
+	instruction, err := prompt.GetInstruction(map[string]interface{}{"topics": []string{"go", "cue"}})
+	require.NoError(t, err)
+	assert.Equal(t, "Hi GO, CUE", instruction)
+
+	_, err = prompt.GetInstruction(map[string]interface{}{"topics": []int{1}})
+	assert.ErrorContains(t, err, "prompt data validation failed")
+}
+
+func TestResolveFragments_Errors(t *testing.T) {
+	fragments := map[string]JSONSchema{
+		"loop": {"items": map[string]interface{}{FragmentKey: "loop"}},
+	}
+
+	_, err := resolveFragments(map[string]interface{}{FragmentKey: "missing"}, fragments, nil)
+	assert.ErrorContains(t, err, "unknown schema fragment: missing")
+
+	_, err = resolveFragments(map[string]interface{}{FragmentKey: "loop"}, fragments, nil)
+	assert.ErrorContains(t, err, "schema fragment loop references itself")
+
+	_, err = resolveFragments(map[string]interface{}{FragmentKey: "loop", "type": "array"}, fragments, nil)
+	assert.ErrorContains(t, err, "cannot declare other keys")
+}
+
+func TestFuncMap(t *testing.T) {
+	assert.Equal(t, "a, b", join(", ", []interface{}{"a", "b"}))
+	assert.Equal(t, "  a\n\n  b", indent(2, "a\n\nb"))
+	assert.Equal(t, "none", defaultValue("none", ""))
+	assert.Equal(t, "set", defaultValue("none", "set"))
+
+	formatted, err := date("2006-01-02", time.Date(2025, 8, 27, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Equal(t, "2025-08-27", formatted)
+
+	formatted, err = date("15:04", "2025-08-27T10:30:00Z")
+	require.NoError(t, err)
+	assert.Equal(t, "10:30", formatted)
+}
+
+func TestUsesTimeFuncs(t *testing.T) {
+	parse := func(content string) *template.Template {
+		base := template.Must(template.New("partials").Funcs(FuncMap()).Parse(`{{define "stamp"}}{{now | date "2006"}}{{end}}`))
+		return template.Must(base.New("prompt").Parse(content))
+	}
+
+	assert.False(t, UsesTimeFuncs(parse(`{{.topics | join ", "}}`)))
+	assert.True(t, UsesTimeFuncs(parse(`Today is {{now | date "2006-01-02"}}`)))
+	assert.True(t, UsesTimeFuncs(parse(`{{if .topics}}{{(now).Year}}{{end}}`)))
+	assert.True(t, UsesTimeFuncs(parse(`{{range .topics}}{{template "stamp"}}{{end}}`)))
+}
@@ -1,6 +1,8 @@
 package prompt
 
 import (
+	"sort"
+
 	"github.com/denkhaus/agents/provider"
 	"github.com/denkhaus/agents/shared/resource"
 
@@ -9,29 +11,96 @@ import (
 
 // promptManagerImpl is an unexported implementation of PromptManager.
 type promptManagerImpl struct {
-	prompts *resource.Manager[*promptEntry]
+	// prompts holds the versions of the prompt of each agent, ordered by version
+	prompts *resource.Manager[[]*promptEntry]
+	origins map[provider.PromptKey]resource.Origin
 }
 
 // NewManager creates a new instance of promptManagerImpl.
-func NewManager(prompts map[uuid.UUID]*promptEntry) provider.PromptManager {
-	manager := resource.NewManager[*promptEntry]()
+func NewManager(prompts map[provider.PromptKey]*promptEntry, origins map[provider.PromptKey]resource.Origin) provider.PromptManager {
+	versions := make(map[uuid.UUID][]*promptEntry)
+	for key, entry := range prompts {
+		versions[key.AgentID] = append(versions[key.AgentID], entry)
+	}
 
 	// Populate the generic manager with existing prompts
-	for id, entry := range prompts {
-		manager.Set(id, entry)
+	manager := resource.NewManager[[]*promptEntry]()
+	for id, entries := range versions {
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].GetVersion() < entries[j].GetVersion()
+		})
+		manager.Set(id, entries)
 	}
 
-	return &promptManagerImpl{prompts: manager}
+	return &promptManagerImpl{prompts: manager, origins: origins}
 }
 
 func (pm *promptManagerImpl) GetPrompt(agentID uuid.UUID) (provider.Prompt, error) {
-	entry, ok := pm.prompts.Get(agentID)
-	if !ok {
+	entries, err := pm.entries(agentID)
+	if err != nil {
+		return nil, err
+	}
+
+	return entries[len(entries)-1], nil
+}
+
+// GetPromptVersion returns the given version of the prompt of an agent
+func (pm *promptManagerImpl) GetPromptVersion(agentID uuid.UUID, version int) (provider.Prompt, error) {
+	entries, err := pm.entries(agentID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if entry.GetVersion() == version {
+			return entry, nil
+		}
+	}
+
+	return nil, &PromptError{
+		Message: "prompt version not found",
+		AgentID: agentID,
+		Err:     &VersionError{Version: version},
+	}
+}
+
+// GetPromptVersions returns all versions of the prompt of an agent, ordered by version
+func (pm *promptManagerImpl) GetPromptVersions(agentID uuid.UUID) ([]provider.Prompt, error) {
+	entries, err := pm.entries(agentID)
+	if err != nil {
+		return nil, err
+	}
+
+	prompts := make([]provider.Prompt, len(entries))
+	for i, entry := range entries {
+		prompts[i] = entry
+	}
+	return prompts, nil
+}
+
+func (pm *promptManagerImpl) entries(agentID uuid.UUID) ([]*promptEntry, error) {
+	entries, ok := pm.prompts.Get(agentID)
+	if !ok || len(entries) == 0 {
 		return nil, &PromptError{
 			Message: "prompt template not found",
 			AgentID: agentID,
 		}
 	}
 
-	return entry, nil
+	return entries, nil
+}
+
+// GetOrigin reports the file a prompt version was loaded from
+func (pm *promptManagerImpl) GetOrigin(key provider.PromptKey) (resource.Origin, bool) {
+	origin, ok := pm.origins[key]
+	return origin, ok
+}
+
+// GetOrigins reports the files all prompt versions were loaded from
+func (pm *promptManagerImpl) GetOrigins() map[provider.PromptKey]resource.Origin {
+	origins := make(map[provider.PromptKey]resource.Origin, len(pm.origins))
+	for key, origin := range pm.origins {
+		origins[key] = origin
+	}
+	return origins
 }
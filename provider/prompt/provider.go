@@ -3,9 +3,12 @@ package prompt
 import (
 	"fmt"
 
+	"github.com/denkhaus/agents/logger"
 	"github.com/denkhaus/agents/provider"
+	"github.com/denkhaus/agents/shared/resource"
 	"github.com/google/uuid"
 	"github.com/samber/do"
+	"go.uber.org/zap"
 )
 
 type promptProviderImpl struct {
@@ -13,16 +16,50 @@ type promptProviderImpl struct {
 }
 
 func New(i *do.Injector) (provider.PromptProvider, error) {
-	manager, err := NewPromptManager(promptFS, "templates")
+	userSource, err := resource.UserSource("prompts")
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve user prompt directory: %w", err)
+	}
+
+	// Prompts in the user directory override the embedded defaults per agent ID
+	manager, err := NewPromptManager(EmbeddedSource(), userSource)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize prompt manager: %w", err)
 	}
 
+	for key, origin := range manager.GetOrigins() {
+		log := logger.Log.Debug
+		if len(origin.Overrides) > 0 {
+			log = logger.Log.Info
+		}
+		log("prompt loaded",
+			zap.String("agent_id", key.AgentID.String()),
+			zap.Int("version", key.Version),
+			zap.Stringer("origin", origin),
+		)
+	}
+
 	return &promptProviderImpl{
 		manager: manager,
 	}, nil
 }
 
+// GetPrompt selects a version of the prompt of an agent. Data may be a
+// provider.PromptSelection, the latest version is returned otherwise.
 func (p *promptProviderImpl) GetPrompt(agentID uuid.UUID, data interface{}) (provider.Prompt, error) {
-	return p.manager.GetPrompt(agentID)
+	selection, ok := data.(provider.PromptSelection)
+	if !ok {
+		return p.manager.GetPrompt(agentID)
+	}
+
+	prompts, err := p.manager.GetPromptVersions(agentID)
+	if err != nil {
+		return nil, err
+	}
+
+	return SelectPrompt(agentID, prompts, selection)
+}
+
+func (p *promptProviderImpl) GetPromptVersions(agentID uuid.UUID) ([]provider.Prompt, error) {
+	return p.manager.GetPromptVersions(agentID)
 }
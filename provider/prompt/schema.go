@@ -24,3 +24,62 @@ func (s *JSONSchema) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	*s = convertedMap
 	return nil
 }
+
+// FragmentKey references a schema fragment in place of a schema, e.g. `$fragment: agent_info`
+const FragmentKey = "$fragment"
+
+// resolveFragments replaces every schema referencing a fragment by a copy of
+// the fragment. Fragments may reference other fragments, but not themselves.
+func resolveFragments(schema interface{}, fragments map[string]JSONSchema, resolving []string) (interface{}, error) {
+	switch schema := schema.(type) {
+	case JSONSchema:
+		return resolveFragments(map[string]interface{}(schema), fragments, resolving)
+
+	case map[string]interface{}:
+		if ref, ok := schema[FragmentKey]; ok {
+			name, ok := ref.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s must be a fragment name, got %v", FragmentKey, ref)
+			}
+			if len(schema) > 1 {
+				return nil, fmt.Errorf("schema referencing fragment %s cannot declare other keys", name)
+			}
+
+			fragment, ok := fragments[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown schema fragment: %s", name)
+			}
+			for _, parent := range resolving {
+				if parent == name {
+					return nil, fmt.Errorf("schema fragment %s references itself", name)
+				}
+			}
+
+			return resolveFragments(fragment, fragments, append(resolving, name))
+		}
+
+		resolved := make(map[string]interface{}, len(schema))
+		for key, value := range schema {
+			r, err := resolveFragments(value, fragments, resolving)
+			if err != nil {
+				return nil, err
+			}
+			resolved[key] = r
+		}
+		return resolved, nil
+
+	case []interface{}:
+		resolved := make([]interface{}, len(schema))
+		for i, value := range schema {
+			r, err := resolveFragments(value, fragments, resolving)
+			if err != nil {
+				return nil, err
+			}
+			resolved[i] = r
+		}
+		return resolved, nil
+
+	default:
+		return schema, nil
+	}
+}
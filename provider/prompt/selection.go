@@ -0,0 +1,96 @@
+package prompt
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+
+	"github.com/denkhaus/agents/provider"
+	"github.com/google/uuid"
+)
+
+// SelectPrompt picks one of the versions of the prompt of an agent according to the selection
+func SelectPrompt(agentID uuid.UUID, prompts []provider.Prompt, selection provider.PromptSelection) (provider.Prompt, error) {
+	versions := make([]int, len(prompts))
+	weights := make([]int, len(prompts))
+	for i, prompt := range prompts {
+		versions[i] = prompt.GetVersion()
+		weights[i] = prompt.GetWeight()
+	}
+
+	index, err := selectVersion(agentID, versions, weights, selection)
+	if err != nil {
+		return nil, err
+	}
+	return prompts[index], nil
+}
+
+// SelectVariant picks one of the rendered prompt versions of an agent according to the selection
+func SelectVariant(agentID uuid.UUID, variants []provider.PromptVariant, selection provider.PromptSelection) (provider.PromptVariant, error) {
+	versions := make([]int, len(variants))
+	weights := make([]int, len(variants))
+	for i, variant := range variants {
+		versions[i] = variant.Version
+		weights[i] = variant.Weight
+	}
+
+	index, err := selectVersion(agentID, versions, weights, selection)
+	if err != nil {
+		return provider.PromptVariant{}, err
+	}
+	return variants[index], nil
+}
+
+// selectVersion returns the index of the selected version. Versions are
+// ordered ascending. A sticky selection without a session is weighted.
+func selectVersion(agentID uuid.UUID, versions, weights []int, selection provider.PromptSelection) (int, error) {
+	if len(versions) == 0 {
+		return 0, &PromptError{Message: "prompt template not found", AgentID: agentID}
+	}
+
+	switch selection.Policy {
+	case "", provider.PromptPolicyLatest:
+		return len(versions) - 1, nil
+
+	case provider.PromptPolicyPinned:
+		for i, version := range versions {
+			if version == selection.Version {
+				return i, nil
+			}
+		}
+		return 0, &PromptError{
+			Message: "pinned prompt version not found",
+			AgentID: agentID,
+			Err:     &VersionError{Version: selection.Version},
+		}
+
+	case provider.PromptPolicyWeighted, provider.PromptPolicySticky:
+		total := 0
+		for _, weight := range weights {
+			total += weight
+		}
+		if total <= 0 {
+			return 0, &PromptError{Message: "prompt versions have no weight", AgentID: agentID}
+		}
+
+		var point int
+		if selection.Policy == provider.PromptPolicySticky && selection.SessionID != "" {
+			hash := fnv.New32a()
+			hash.Write([]byte(agentID.String() + "/" + selection.SessionID))
+			point = int(hash.Sum32() % uint32(total))
+		} else {
+			point = rand.Intn(total)
+		}
+
+		for i, weight := range weights {
+			if point < weight {
+				return i, nil
+			}
+			point -= weight
+		}
+		return len(versions) - 1, nil
+
+	default:
+		return 0, fmt.Errorf("invalid prompt policy: %s", selection.Policy)
+	}
+}
@@ -0,0 +1,117 @@
+package prompt
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/denkhaus/agents/provider"
+	"github.com/denkhaus/agents/shared/resource"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testAgentID = uuid.MustParse("550e8400-e29b-41d4-a716-446655440001")
+
+func testPrompt(version, weight string, content string) *fstest.MapFile {
+	return &fstest.MapFile{Data: []byte(`---
+name: coder-prompt
+agent_id: "` + testAgentID.String() + `"
+version: ` + version + `
+weight: ` + weight + `
+schema:
+  type: object
+---
+` + content)}
+}
+
+func newTestManager(t *testing.T) provider.PromptManager {
+	t.Helper()
+
+	manager, err := NewPromptManager(resource.Source{
+		Name: resource.SourceEmbedded,
+		FS: fstest.MapFS{
+			"templates/coder.md":    testPrompt("1", "3", "first"),
+			"templates/coder_v2.md": testPrompt("2", "1", "second"),
+		},
+		Root: "templates",
+	})
+	require.NoError(t, err)
+	return manager
+}
+
+func TestManager_Versions(t *testing.T) {
+	manager := newTestManager(t)
+
+	latest, err := manager.GetPrompt(testAgentID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, latest.GetVersion())
+
+	first, err := manager.GetPromptVersion(testAgentID, 1)
+	require.NoError(t, err)
+	instruction, err := first.GetInstruction(map[string]interface{}{})
+	require.NoError(t, err)
+	assert.Equal(t, "first", instruction)
+	assert.Equal(t, 3, first.GetWeight())
+
+	_, err = manager.GetPromptVersion(testAgentID, 3)
+	assert.ErrorContains(t, err, "unknown version 3")
+
+	origin, ok := manager.GetOrigin(provider.PromptKey{AgentID: testAgentID, Version: 2})
+	require.True(t, ok)
+	assert.Equal(t, "templates/coder_v2.md", origin.Path)
+}
+
+func TestManager_DuplicateVersion(t *testing.T) {
+	_, err := NewPromptManager(resource.Source{
+		Name: resource.SourceEmbedded,
+		FS: fstest.MapFS{
+			"templates/a.md": testPrompt("2", "1", "a"),
+			"templates/b.md": testPrompt("2", "1", "b"),
+		},
+		Root: "templates",
+	})
+	assert.ErrorContains(t, err, "duplicate agent id "+testAgentID.String()+" version 2")
+}
+
+func TestSelectPrompt(t *testing.T) {
+	prompts, err := newTestManager(t).GetPromptVersions(testAgentID)
+	require.NoError(t, err)
+
+	selected, err := SelectPrompt(testAgentID, prompts, provider.PromptSelection{})
+	require.NoError(t, err)
+	assert.Equal(t, 2, selected.GetVersion())
+
+	selected, err = SelectPrompt(testAgentID, prompts, provider.PromptSelection{Policy: provider.PromptPolicyPinned, Version: 1})
+	require.NoError(t, err)
+	assert.Equal(t, 1, selected.GetVersion())
+
+	_, err = SelectPrompt(testAgentID, prompts, provider.PromptSelection{Policy: provider.PromptPolicyPinned, Version: 7})
+	assert.ErrorContains(t, err, "pinned prompt version not found")
+
+	// A sticky selection keeps the version of a session
+	sticky := provider.PromptSelection{Policy: provider.PromptPolicySticky, SessionID: "session-1"}
+	first, err := SelectPrompt(testAgentID, prompts, sticky)
+	require.NoError(t, err)
+	for i := 0; i < 20; i++ {
+		selected, err := SelectPrompt(testAgentID, prompts, sticky)
+		require.NoError(t, err)
+		assert.Equal(t, first.GetVersion(), selected.GetVersion())
+	}
+
+	// Weighted selections follow the weights of the versions
+	counts := map[int]int{}
+	for i := 0; i < 2000; i++ {
+		selected, err := SelectPrompt(testAgentID, prompts, provider.PromptSelection{Policy: provider.PromptPolicyWeighted})
+		require.NoError(t, err)
+		counts[selected.GetVersion()]++
+	}
+	assert.Greater(t, counts[1], counts[2])
+	assert.NotZero(t, counts[2])
+}
+
+func TestPromptSelection_Validate(t *testing.T) {
+	assert.NoError(t, provider.PromptSelection{}.Validate())
+	assert.ErrorContains(t, provider.PromptSelection{Policy: "random"}.Validate(), "invalid prompt policy: random")
+	assert.ErrorContains(t, provider.PromptSelection{Policy: provider.PromptPolicyPinned}.Validate(), "requires a version")
+}
@@ -14,6 +14,10 @@ type PromptMetadata struct {
 	Description       string     `yaml:"description"`
 	AgentID           uuid.UUID  `yaml:"agent_id"`
 	Schema            JSONSchema `yaml:"schema"`
+	// Version distinguishes revisions of the prompt of one agent, defaults to 1
+	Version int `yaml:"version"`
+	// Weight is the share of invocations the version receives in a weighted split, defaults to 1
+	Weight *int `yaml:"weight"`
 }
 
 // promptEntry holds a compiled template and its associated metadata and JSON schema.
@@ -21,4 +25,6 @@ type promptEntry struct {
 	template *template.Template
 	metadata PromptMetadata
 	schema   *gojsonschema.Schema
+	// timeDependent is set if the template calls now or date
+	timeDependent bool
 }
@@ -0,0 +1,97 @@
+package provider
+
+import "fmt"
+
+// RouterClassifier selects how a router agent classifies messages
+type RouterClassifier string
+
+const (
+	// RouterClassifierLLM asks the model of the router which sub-agent fits the message
+	RouterClassifierLLM RouterClassifier = "llm"
+	// RouterClassifierRules takes the first route whose keywords and metadata match
+	RouterClassifierRules RouterClassifier = "rules"
+)
+
+// DefaultRouterMinConfidence is the confidence an LLM classification needs by default
+const DefaultRouterMinConfidence = 0.5
+
+// RouterSettings declares how a router agent delegates messages to its sub-agents
+type RouterSettings struct {
+	// Classifier is llm or rules, llm by default
+	Classifier RouterClassifier `yaml:"classifier" json:"classifier,omitempty"`
+	// Routes describe the sub-agents. The LLM classifier chooses between all
+	// sub-agents, the rules classifier only between the routes.
+	Routes []RouterRoute `yaml:"routes" json:"routes,omitempty"`
+	// Fallback handles the messages classified with too little confidence or
+	// matching no route, by UUID. CUE configs may also use its name. It defaults
+	// to the first sub-agent.
+	Fallback string `yaml:"fallback" json:"fallback,omitempty"`
+	// MinConfidence is the confidence between 0 and 1 an LLM classification needs
+	MinConfidence float64 `yaml:"min_confidence" json:"min_confidence,omitempty"`
+}
+
+// RouterRoute leads to a sub-agent
+type RouterRoute struct {
+	// Agent references the sub-agent by UUID. CUE configs may also use its name.
+	Agent string `yaml:"agent" json:"agent"`
+	// Description tells the LLM classifier which messages the agent handles, it
+	// defaults to the description of the agent
+	Description string `yaml:"description" json:"description,omitempty"`
+	// Keywords match if the message contains one of them, ignoring case
+	Keywords []string `yaml:"keywords" json:"keywords,omitempty"`
+	// Metadata match if all session state values equal them
+	Metadata map[string]string `yaml:"metadata" json:"metadata,omitempty"`
+}
+
+// HasRules reports whether the route can be taken by the rules classifier
+func (r RouterRoute) HasRules() bool {
+	return len(r.Keywords) > 0 || len(r.Metadata) > 0
+}
+
+// GetClassifier returns the classifier of the router
+func (s RouterSettings) GetClassifier() RouterClassifier {
+	if s.Classifier == "" {
+		return RouterClassifierLLM
+	}
+	return s.Classifier
+}
+
+// GetMinConfidence returns the confidence an LLM classification needs
+func (s RouterSettings) GetMinConfidence() float64 {
+	if s.MinConfidence == 0 {
+		return DefaultRouterMinConfidence
+	}
+	return s.MinConfidence
+}
+
+// Validate checks the classifier and that the routes lead to distinct agents
+func (s RouterSettings) Validate() error {
+	switch s.GetClassifier() {
+	case RouterClassifierLLM, RouterClassifierRules:
+	default:
+		return fmt.Errorf("invalid router classifier %s", s.Classifier)
+	}
+
+	if s.MinConfidence < 0 || s.MinConfidence > 1 {
+		return fmt.Errorf("router min_confidence %v is not between 0 and 1", s.MinConfidence)
+	}
+
+	agents := make(map[string]bool, len(s.Routes))
+	hasRules := false
+	for _, route := range s.Routes {
+		switch {
+		case route.Agent == "":
+			return fmt.Errorf("router route without agent")
+		case agents[route.Agent]:
+			return fmt.Errorf("router route to agent %s is declared twice", route.Agent)
+		}
+		agents[route.Agent] = true
+		hasRules = hasRules || route.HasRules()
+	}
+
+	if s.GetClassifier() == RouterClassifierRules && !hasRules {
+		return fmt.Errorf("rules router has no route with keywords or metadata")
+	}
+
+	return nil
+}
@@ -3,9 +3,9 @@ package settings
 import (
 	"embed"
 	"fmt"
-	"io/fs"
-	"strings"
 
+	"github.com/denkhaus/agents/provider"
+	"github.com/denkhaus/agents/provider/llm"
 	"github.com/denkhaus/agents/shared"
 	"github.com/denkhaus/agents/shared/resource"
 	"github.com/google/uuid"
@@ -15,98 +15,109 @@ import (
 //go:embed templates/*.yaml
 var SettingsFS embed.FS
 
+// EmbeddedSource returns the source of the compiled-in settings templates
+func EmbeddedSource() resource.Source {
+	return resource.Source{
+		Name: resource.SourceEmbedded,
+		FS:   SettingsFS,
+		Root: "templates",
+	}
+}
+
 // SettingsManager defines the interface for managing agent settings.
 type SettingsManager interface {
 	GetSettings(agentID uuid.UUID) (*Settings, error)
 	GetAllSettings() map[uuid.UUID]*Settings
+	GetOrigin(agentID uuid.UUID) (resource.Origin, bool)
+	GetOrigins() map[uuid.UUID]resource.Origin
 }
 
 // settingsManagerImpl is an unexported implementation of SettingsManager.
 type settingsManagerImpl struct {
 	settings *resource.Manager[*Settings]
+	origins  map[uuid.UUID]resource.Origin
 }
 
 // NewSettingsManager creates a new instance of SettingsManager.
-// It takes an embed.FS for loading settings templates.
-func NewSettingsManager(fsys embed.FS, rootPath string) (SettingsManager, error) {
-	settings := make(map[uuid.UUID]*Settings)
-
-	// Use fs.WalkDir to correctly traverse the embedded directory
-	err := fs.WalkDir(fsys, rootPath, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-		if d.IsDir() {
-			return nil
-		}
+// Settings are loaded from the sources in order, settings of a later source
+// replace the settings with the same agent ID of an earlier source.
+func NewSettingsManager(sources ...resource.Source) (SettingsManager, error) {
+	settings, origins, err := resource.LoadSources(sources, []string{".yaml", ".yml"}, parseSettings)
+	if err != nil {
+		return nil, err
+	}
 
-		if !strings.HasSuffix(path, ".yaml") && !strings.HasSuffix(path, ".yml") {
-			return nil
-		}
+	// Create generic manager and populate it
+	manager := resource.NewManager[*Settings]()
+	for id, setting := range settings {
+		manager.Set(id, setting)
+	}
 
-		content, err := fsys.ReadFile(path)
-		if err != nil {
-			return fmt.Errorf("failed to read embedded settings file %s: %w", path, err)
-		}
+	return &settingsManagerImpl{settings: manager, origins: origins}, nil
+}
 
-		// Parse the YAML content
-		var settingsData struct {
-			Settings
-		}
+// parseSettings parses and validates a single YAML settings file
+func parseSettings(path string, content []byte) (uuid.UUID, *Settings, error) {
+	var settings Settings
+	if err := yaml.Unmarshal(content, &settings); err != nil {
+		return uuid.Nil, nil, fmt.Errorf("failed to parse YAML settings in %s: %w", path, err)
+	}
 
-		if err := yaml.Unmarshal(content, &settingsData.Settings); err != nil {
-			return fmt.Errorf("failed to parse YAML settings in %s: %w", path, err)
-		}
+	if settings.AgentID == uuid.Nil {
+		return uuid.Nil, nil, fmt.Errorf("agent ID cannot be empty in %s", path)
+	}
 
-		if settingsData.AgentID == uuid.Nil {
-			return fmt.Errorf("agent ID cannot be empty in %s", path)
-		}
+	if settings.Agent.Type == "" {
+		settings.Agent.Type = shared.AgentTypeDefault
+	}
 
-		if settingsData.Agent.Type == "" {
-			settingsData.Agent.Type = shared.AgentTypeDefault
-		}
+	// Validate the agent type
+	if err := settings.Agent.Type.Validate(); err != nil {
+		return uuid.Nil, nil, fmt.Errorf("invalid agent type in %s: %w", path, err)
+	}
 
-		// Validate the agent type
-		if err := settingsData.Agent.Type.Validate(); err != nil {
-			return fmt.Errorf("invalid agent type in %s: %w", path, err)
-		}
+	// Validate the agent role
+	if err := settings.Agent.Role.Validate(); err != nil {
+		return uuid.Nil, nil, fmt.Errorf("invalid agent role in %s: %w", path, err)
+	}
 
-		// Validate the agent role
-		if err := settingsData.Agent.Role.Validate(); err != nil {
-			return fmt.Errorf("invalid agent role in %s: %w", path, err)
-		}
+	if err := settings.Model.Provider.Validate(); err != nil {
+		return uuid.Nil, nil, fmt.Errorf("invalid model provider in %s: %w", path, err)
+	}
 
-		if err := settingsData.Model.Provider.Validate(); err != nil {
-			return fmt.Errorf("invalid model provider in %s: %w", path, err)
-		}
+	if err := llm.Validate(settings.Model.Config()); err != nil {
+		return uuid.Nil, nil, fmt.Errorf("invalid model settings in %s: %w", path, err)
+	}
 
-		if _, exists := settings[settingsData.AgentID]; exists {
-			return fmt.Errorf("duplicate agent id in settings %s: settings with agent id %s already exists", path, settingsData.AgentID)
-		}
+	if err := settings.Agent.Prompt.Validate(); err != nil {
+		return uuid.Nil, nil, fmt.Errorf("invalid prompt selection in %s: %w", path, err)
+	}
 
-		// Create Settings struct from parsed data
-		settingsStruct := &Settings{
-			AgentID: settingsData.AgentID,
-			Model:   settingsData.Model,
-			Agent:   settingsData.Agent,
-		}
+	if err := llm.ValidateGenerationConfig(settings.Agent.GenerationConfig()); err != nil {
+		return uuid.Nil, nil, fmt.Errorf("invalid generation settings in %s: %w", path, err)
+	}
 
-		settings[settingsData.AgentID] = settingsStruct
+	if err := settings.Agent.PromptContext.Validate(); err != nil {
+		return uuid.Nil, nil, fmt.Errorf("invalid prompt context settings in %s: %w", path, err)
+	}
 
-		return nil
-	})
+	if err := settings.Agent.Workspace.Validate(); err != nil {
+		return uuid.Nil, nil, fmt.Errorf("invalid workspace settings in %s: %w", path, err)
+	}
 
-	if err != nil {
-		return nil, err
+	if settings.Agent.Type == shared.AgentTypeGraph {
+		if err := validateGraph(settings.Agent.Graph); err != nil {
+			return uuid.Nil, nil, fmt.Errorf("invalid graph in %s: %w", path, err)
+		}
 	}
 
-	// Create generic manager and populate it
-	manager := resource.NewManager[*Settings]()
-	for id, setting := range settings {
-		manager.Set(id, setting)
+	if settings.Agent.Type == shared.AgentTypeRouter {
+		if err := validateRouter(settings.Agent.Router, settings.Agent.SubAgents); err != nil {
+			return uuid.Nil, nil, fmt.Errorf("invalid router in %s: %w", path, err)
+		}
 	}
 
-	return &settingsManagerImpl{settings: manager}, nil
+	return settings.AgentID, &settings, nil
 }
 
 func (sm *settingsManagerImpl) GetSettings(agentID uuid.UUID) (*Settings, error) {
@@ -122,3 +133,65 @@ func (sm *settingsManagerImpl) GetSettings(agentID uuid.UUID) (*Settings, error)
 func (sm *settingsManagerImpl) GetAllSettings() map[uuid.UUID]*Settings {
 	return sm.settings.GetAll()
 }
+
+// GetOrigin reports the file the settings of an agent were loaded from
+func (sm *settingsManagerImpl) GetOrigin(agentID uuid.UUID) (resource.Origin, bool) {
+	origin, ok := sm.origins[agentID]
+	return origin, ok
+}
+
+// GetOrigins reports the files the settings of all agents were loaded from
+func (sm *settingsManagerImpl) GetOrigins() map[uuid.UUID]resource.Origin {
+	origins := make(map[uuid.UUID]resource.Origin, len(sm.origins))
+	for id, origin := range sm.origins {
+		origins[id] = origin
+	}
+	return origins
+}
+
+// validateGraph checks the graph and that its nodes reference agents by UUID
+func validateGraph(graph provider.GraphSettings) error {
+	if err := graph.Validate(); err != nil {
+		return err
+	}
+	for _, node := range graph.Nodes {
+		if node.Agent == "" {
+			continue
+		}
+		if _, err := uuid.Parse(node.Agent); err != nil {
+			return fmt.Errorf("graph node %s references agent %q, which is no UUID", node.ID, node.Agent)
+		}
+	}
+	return nil
+}
+
+// validateRouter checks the router and that its routes lead to sub-agents by UUID
+func validateRouter(router provider.RouterSettings, subAgents []uuid.UUID) error {
+	if err := router.Validate(); err != nil {
+		return err
+	}
+	if len(subAgents) == 0 {
+		return fmt.Errorf("router has no sub-agents")
+	}
+
+	isSubAgent := make(map[uuid.UUID]bool, len(subAgents))
+	for _, agentID := range subAgents {
+		isSubAgent[agentID] = true
+	}
+	for _, route := range router.Routes {
+		agentID, err := uuid.Parse(route.Agent)
+		if err != nil {
+			return fmt.Errorf("router route references agent %q, which is no UUID", route.Agent)
+		}
+		if !isSubAgent[agentID] {
+			return fmt.Errorf("router route to agent %s, which is no sub-agent", agentID)
+		}
+	}
+
+	if router.Fallback != "" {
+		if _, err := uuid.Parse(router.Fallback); err != nil {
+			return fmt.Errorf("router fallback references agent %q, which is no UUID", router.Fallback)
+		}
+	}
+	return nil
+}
@@ -3,30 +3,59 @@ package settings
 import (
 	"fmt"
 
+	"github.com/denkhaus/agents/logger"
 	"github.com/denkhaus/agents/provider"
 	"github.com/denkhaus/agents/shared"
+	"github.com/denkhaus/agents/shared/resource"
 	"github.com/google/uuid"
 	"github.com/samber/do"
+	"go.uber.org/zap"
 )
 
 type agentSettingsProviderImpl struct {
 	workspaceProvider provider.WorkspaceProvider
 	promptProvider    provider.PromptProvider
+	contextProvider   provider.PromptContextProvider
 	settingsManager   SettingsManager
 }
 
 func New(i *do.Injector) (provider.SettingsProvider, error) {
 	workspaceProvider := do.MustInvoke[provider.WorkspaceProvider](i)
 	promptProvider := do.MustInvoke[provider.PromptProvider](i)
+	contextProvider := do.MustInvoke[provider.PromptContextProvider](i)
 
-	settingsManager, err := NewSettingsManager(SettingsFS, "templates")
+	userSource, err := resource.UserSource("settings")
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve user settings directory: %w", err)
+	}
+
+	// Settings in the user directory override the embedded defaults per agent ID
+	settingsManager, err := NewSettingsManager(EmbeddedSource(), userSource)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize settings manager: %w", err)
 	}
 
+	for agentID, settings := range settingsManager.GetAllSettings() {
+		if err := workspaceProvider.Configure(agentID, settings.Agent.Workspace); err != nil {
+			return nil, fmt.Errorf("failed to configure workspace for agent %s: %w", agentID, err)
+		}
+	}
+
+	for agentID, origin := range settingsManager.GetOrigins() {
+		log := logger.Log.Debug
+		if len(origin.Overrides) > 0 {
+			log = logger.Log.Info
+		}
+		log("settings loaded",
+			zap.String("agent_id", agentID.String()),
+			zap.Stringer("origin", origin),
+		)
+	}
+
 	return &agentSettingsProviderImpl{
 		workspaceProvider: workspaceProvider,
 		promptProvider:    promptProvider,
+		contextProvider:   contextProvider,
 		settingsManager:   settingsManager,
 	}, nil
 }
@@ -71,15 +100,20 @@ func (p *agentSettingsProviderImpl) GetAgentConfiguration(agentID uuid.UUID) (pr
 		return nil, fmt.Errorf("failed to get workspace for agent %s", agentID)
 	}
 
-	prompt, err := p.promptProvider.GetPrompt(agentID, nil)
+	settings, err := p.settingsManager.GetSettings(agentID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get prompt for agent %s", agentID)
+		return nil, fmt.Errorf("failed to get settings for agent %s", agentID)
 	}
 
-	settings, err := p.settingsManager.GetSettings(agentID)
+	prompt, err := p.promptProvider.GetPrompt(agentID, settings.Agent.Prompt)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get settings for agent %s", agentID)
+		return nil, fmt.Errorf("failed to get prompt for agent %s: %w", agentID, err)
+	}
+
+	promptVersions, err := p.promptProvider.GetPromptVersions(agentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get prompt versions for agent %s: %w", agentID, err)
 	}
 
-	return NewConfiguration(workspace, prompt, p, settings)
+	return NewConfiguration(workspace, prompt, promptVersions, p.contextProvider, p, settings)
 }
@@ -4,10 +4,10 @@ import (
 	"context"
 	"fmt"
 
-	"github.com/denkhaus/agents/logger"
 	"github.com/denkhaus/agents/provider"
+	"github.com/denkhaus/agents/provider/llm"
+	"github.com/denkhaus/agents/provider/prompt"
 	"github.com/denkhaus/agents/shared"
-	"go.uber.org/zap"
 
 	"github.com/denkhaus/agents/utils"
 	"trpc.group/trpc-go/trpc-agent-go/agent"
@@ -16,8 +16,8 @@ import (
 	"trpc.group/trpc-go/trpc-agent-go/agent/llmagent"
 	"trpc.group/trpc-go/trpc-agent-go/agent/parallelagent"
 	"trpc.group/trpc-go/trpc-agent-go/model"
-	"trpc.group/trpc-go/trpc-agent-go/model/openai"
 	"trpc.group/trpc-go/trpc-agent-go/planner/react"
+	"trpc.group/trpc-go/trpc-agent-go/session"
 	"trpc.group/trpc-go/trpc-agent-go/tool"
 )
 
@@ -25,12 +25,19 @@ type agentSettingsImpl struct {
 	*Settings
 	workspaceProvider provider.Workspace
 	promptProvider    provider.Prompt
+	promptVersions    []provider.Prompt
+	contextProvider   provider.PromptContextProvider
 	settingsProvider  provider.SettingsProvider
 }
 
+// NewConfiguration creates the configuration of an agent. The prompt is the
+// version used by GetDefaultOptions, promptVersions are all versions of it.
+// The contextProvider contributes the context keys the prompt schemas declare.
 func NewConfiguration(
 	workspaceProvider provider.Workspace,
 	promptProvider provider.Prompt,
+	promptVersions []provider.Prompt,
+	contextProvider provider.PromptContextProvider,
 	settingsProvider provider.SettingsProvider,
 	settings *Settings,
 ) (provider.AgentConfiguration, error) {
@@ -39,6 +46,8 @@ func NewConfiguration(
 		settingsProvider:  settingsProvider,
 		workspaceProvider: workspaceProvider,
 		promptProvider:    promptProvider,
+		promptVersions:    promptVersions,
+		contextProvider:   contextProvider,
 	}, nil
 }
 
@@ -54,48 +63,43 @@ func (p *agentSettingsImpl) IsStreamingEnabled() bool {
 	return p.Agent.StreamingEnabled
 }
 
-func (p *agentSettingsImpl) getGenerationConfig() (model.GenerationConfig, error) {
-	return model.GenerationConfig{
-		MaxTokens:   utils.IntPtr(p.Agent.MaxTokens),
-		Temperature: utils.FloatPtr(p.Agent.Temperature),
-		Stream:      p.Agent.StreamingEnabled,
-	}, nil
+func (p *agentSettingsImpl) GetRole() shared.AgentRole {
+	return p.Agent.Role
 }
 
-func (p *agentSettingsImpl) getModel() (model.Model, error) {
-	switch p.Model.Provider {
-	case shared.ModelProviderOpenAI:
-		modelOptions := []openai.Option{}
-
-		if len(p.Model.BaseURL) > 0 {
-			modelOptions = append(modelOptions,
-				openai.WithBaseURL(
-					p.Model.BaseURL,
-				),
-			)
-		}
+func (p *agentSettingsImpl) GetDescription() string {
+	return p.Agent.Description
+}
 
-		if len(p.Model.APIKey) > 0 {
-			modelOptions = append(modelOptions,
-				openai.WithAPIKey(
-					p.Model.APIKey,
-				),
-			)
-		}
+func (p *agentSettingsImpl) GetInputSchema() map[string]interface{} {
+	return p.Agent.InputSchema
+}
 
-		if p.Model.ChannelBufferSize > 0 {
-			modelOptions = append(modelOptions,
-				openai.WithChannelBufferSize(
-					p.Model.ChannelBufferSize,
-				),
-			)
-		}
+func (p *agentSettingsImpl) GetOutputSchema() map[string]interface{} {
+	return p.Agent.OutputSchema
+}
 
-		modelInstance := openai.New(p.Model.Name, modelOptions...)
-		return modelInstance, nil
+func (p *agentSettingsImpl) GetCapabilities() shared.AgentCapabilities {
+	return shared.AgentCapabilities{
+		Streaming: p.Agent.StreamingEnabled,
+		Planning:  p.Agent.PlanningEnabled,
 	}
+}
 
-	return nil, fmt.Errorf("model provider %s is unknown", p.Model.Provider)
+func (p *agentSettingsImpl) GetGraph() (provider.GraphSettings, error) {
+	return p.Agent.Graph, nil
+}
+
+func (p *agentSettingsImpl) GetRouter() (provider.RouterSettings, error) {
+	return p.Agent.Router, nil
+}
+
+func (p *agentSettingsImpl) getGenerationConfig() (model.GenerationConfig, error) {
+	return p.Agent.GenerationConfig(), nil
+}
+
+func (p *agentSettingsImpl) getModel() (model.Model, error) {
+	return llm.New(p.Model.Config())
 }
 
 func (p *agentSettingsImpl) getSubAgents(
@@ -107,7 +111,7 @@ func (p *agentSettingsImpl) getSubAgents(
 	for _, agentID := range p.Agent.SubAgents {
 		agent, err := provider.GetAgent(ctx, agentID)
 		if err != nil {
-			return nil, fmt.Errorf("failed to get agent with id %s", agentID)
+			return nil, fmt.Errorf("failed to get agent with id %s: %w", agentID, err)
 		}
 
 		subAgents = append(subAgents, agent)
@@ -120,41 +124,11 @@ func (p *agentSettingsImpl) getSubAgents(
 func (p *agentSettingsImpl) getToolsFromOptions(ctx context.Context, options ...llmagent.Option) ([]tool.Tool, error) {
 	var llmOptions llmagent.Options
 
-	// Helper function to add a tool to the map, handling duplicates.
-	addToolToMap := func(toolsMap map[string]tool.Tool, t tool.Tool) {
-		name := t.Declaration().Name
-		if _, exists := toolsMap[name]; exists {
-			logger.Log.Warn("getToolsFromOptions: tool already registered, skipping", zap.String("tool_name", name))
-		} else {
-			toolsMap[name] = t
-		}
-	}
-
 	for _, opt := range options {
 		opt(&llmOptions)
 	}
 
-	// toolsMap is used to deduplicate tools by their name.
-	toolsMap := make(map[string]tool.Tool)
-
-	for _, t := range llmOptions.Tools {
-		addToolToMap(toolsMap, t)
-	}
-
-	for _, toolSet := range llmOptions.ToolSets {
-		for _, t := range toolSet.Tools(ctx) {
-			addToolToMap(toolsMap, t)
-		}
-	}
-
-	// Convert the map of unique tools back to a slice.
-	// Pre-allocate slice capacity for minor performance optimization.
-	allTools := make([]tool.Tool, 0, len(toolsMap))
-	for _, t := range toolsMap {
-		allTools = append(allTools, t)
-	}
-
-	return allTools, nil
+	return utils.UniqueTools(ctx, llmOptions.Tools, llmOptions.ToolSets), nil
 }
 
 func (p *agentSettingsImpl) GetDefaultOptions(
@@ -178,22 +152,9 @@ func (p *agentSettingsImpl) GetDefaultOptions(
 
 	options = append(options, llmagent.WithGenerationConfig(generationConfig))
 
-	//TODO: make the includeHumanAgent setting configurable
-	availableAgentsVal, err := p.settingsProvider.GetActiveAgents(true) // Renamed to avoid conflict
+	promptContext, err := p.getPromptContext(ctx, p.promptProvider, tools, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get active agents: %w", err)
-	}
-
-	// Convert []shared.AgentInfo to []*shared.AgentInfo
-	availableAgentsPtr := make([]*shared.AgentInfo, len(availableAgentsVal))
-	for i := range availableAgentsVal {
-		availableAgentsPtr[i] = &availableAgentsVal[i]
-	}
-
-	// prepare context for prompt renderring
-	promptContext := map[string]interface{}{
-		shared.ContextKeyToolInfo:  utils.GetToolInfo(tools...),
-		shared.ContextKeyAgentInfo: utils.GetAgentInfoForAgent(p.AgentID, availableAgentsPtr...), // Pass pointers
+		return nil, err
 	}
 
 	instruction, err := p.promptProvider.GetInstruction(promptContext)
@@ -234,6 +195,164 @@ func (p *agentSettingsImpl) GetDefaultOptions(
 	return options, nil
 }
 
+// getPromptContext prepares the data the prompt is rendered with, the
+// contributed keys are limited to the keys its schema declares
+func (p *agentSettingsImpl) getPromptContext(
+	ctx context.Context,
+	prompt provider.Prompt,
+	tools []tool.Tool,
+	session *session.Session,
+) (map[string]interface{}, error) {
+	//TODO: make the includeHumanAgent setting configurable
+	availableAgentsVal, err := p.settingsProvider.GetActiveAgents(true) // Renamed to avoid conflict
+	if err != nil {
+		return nil, fmt.Errorf("failed to get active agents: %w", err)
+	}
+
+	// Convert []shared.AgentInfo to []*shared.AgentInfo
+	availableAgentsPtr := make([]*shared.AgentInfo, len(availableAgentsVal))
+	for i := range availableAgentsVal {
+		availableAgentsPtr[i] = &availableAgentsVal[i]
+	}
+
+	workspacePath, err := p.workspaceProvider.GetPath()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get workspace path: %w", err)
+	}
+
+	var scratchDir string
+	if session != nil {
+		scratchDir, err = p.workspaceProvider.GetScratchDir(session.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get scratch directory: %w", err)
+		}
+	}
+
+	promptContext, err := p.contextProvider.GetContext(ctx, prompt.GetContextKeys(), provider.PromptContextRequest{
+		AgentID:   p.AgentID,
+		Settings:  p.Agent.PromptContext,
+		Workspace: workspacePath,
+		Scratch:   scratchDir,
+		Session:   session,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get prompt context: %w", err)
+	}
+
+	promptContext[shared.ContextKeyToolInfo] = utils.GetToolInfo(tools...)
+	promptContext[shared.ContextKeyAgentInfo] = utils.GetAgentInfoForAgent(p.AgentID, availableAgentsPtr...) // Pass pointers
+	return promptContext, nil
+}
+
+// GetPromptVariants renders every prompt version if the agent selects its
+// version per invocation, with the weighted or the sticky policy. Otherwise
+// the selection carries the version GetDefaultOptions resolved.
+func (p *agentSettingsImpl) GetPromptVariants(
+	ctx context.Context,
+	agentProvider provider.AgentProvider,
+	opt ...llmagent.Option,
+) ([]provider.PromptVariant, provider.PromptSelection, error) {
+
+	selection := p.Agent.Prompt
+	if len(p.promptVersions) < 2 ||
+		(selection.Policy != provider.PromptPolicyWeighted && selection.Policy != provider.PromptPolicySticky) {
+		selection.Version = p.promptProvider.GetVersion()
+		return nil, selection, nil
+	}
+
+	tools, err := p.getToolsFromOptions(ctx, opt...)
+	if err != nil {
+		return nil, selection, fmt.Errorf("failed to get toolsets for [%s]-[%s]: %w", p.Agent.Role, p.AgentID, err)
+	}
+
+	variants := make([]provider.PromptVariant, 0, len(p.promptVersions))
+	for _, prompt := range p.promptVersions {
+		promptContext, err := p.getPromptContext(ctx, prompt, tools, nil)
+		if err != nil {
+			return nil, selection, err
+		}
+
+		instruction, err := prompt.GetInstruction(promptContext)
+		if err != nil {
+			return nil, selection, fmt.Errorf("failed to get instruction prompt version %d for agent [%s]-[%s]: %w",
+				prompt.GetVersion(), p.Agent.Role, p.AgentID, err)
+		}
+
+		variants = append(variants, provider.PromptVariant{
+			Version:           prompt.GetVersion(),
+			Weight:            prompt.GetWeight(),
+			Instruction:       instruction,
+			GlobalInstruction: prompt.GetGlobalInstruction(),
+		})
+	}
+
+	return variants, selection, nil
+}
+
+// GetInstructionRenderer returns a renderer re-rendering the prompt versions
+// with the active agents and session state of each invocation. The tools are
+// resolved once, they are fixed for the lifetime of the agent.
+func (p *agentSettingsImpl) GetInstructionRenderer(
+	ctx context.Context,
+	opt ...llmagent.Option,
+) (provider.InstructionRenderer, error) {
+	tools, err := p.getToolsFromOptions(ctx, opt...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get toolsets for [%s]-[%s]: %w", p.Agent.Role, p.AgentID, err)
+	}
+
+	return &instructionRendererImpl{
+		settings: p,
+		tools:    tools,
+		cache:    prompt.NewInstructionCache(),
+	}, nil
+}
+
+// instructionRendererImpl implements provider.InstructionRenderer
+type instructionRendererImpl struct {
+	settings *agentSettingsImpl
+	tools    []tool.Tool
+	cache    *prompt.InstructionCache
+}
+
+func (r *instructionRendererImpl) Render(ctx context.Context, version int, session *session.Session) (string, error) {
+	p := r.settings
+
+	selected := p.promptProvider
+	if version != 0 {
+		selected = nil
+		for _, candidate := range p.promptVersions {
+			if candidate.GetVersion() == version {
+				selected = candidate
+				break
+			}
+		}
+		if selected == nil {
+			return "", fmt.Errorf("unknown prompt version %d for agent [%s]-[%s]", version, p.Agent.Role, p.AgentID)
+		}
+	}
+
+	promptContext, err := p.getPromptContext(ctx, selected, r.tools, session)
+	if err != nil {
+		return "", err
+	}
+
+	var instruction string
+	if selected.IsTimeDependent() {
+		instruction, err = selected.GetInstruction(promptContext)
+	} else {
+		instruction, err = r.cache.Render(selected.GetVersion(), promptContext, func(data map[string]interface{}) (string, error) {
+			return selected.GetInstruction(data)
+		})
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get instruction prompt version %d for agent [%s]-[%s]: %w",
+			selected.GetVersion(), p.Agent.Role, p.AgentID, err)
+	}
+
+	return instruction, nil
+}
+
 func (p *agentSettingsImpl) GetCycleOptions(
 	ctx context.Context,
 	agentProvider provider.AgentProvider,
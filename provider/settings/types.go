@@ -1,8 +1,12 @@
 package settings
 
 import (
+	"github.com/denkhaus/agents/provider"
+	"github.com/denkhaus/agents/provider/llm"
 	"github.com/denkhaus/agents/shared"
+	"github.com/denkhaus/agents/utils"
 	"github.com/google/uuid"
+	"trpc.group/trpc-go/trpc-agent-go/model"
 )
 
 type ModelSettings struct {
@@ -11,6 +15,34 @@ type ModelSettings struct {
 	Provider          shared.ModelProvider `yaml:"provider"`
 	BaseURL           string               `yaml:"base_url"`
 	APIKey            string               `yaml:"api_key"`
+	// Options holds the provider specific options, see the llm package
+	Options map[string]interface{} `yaml:"options"`
+	// Retry controls how often the model is retried before a fallback is taken
+	Retry llm.RetryPolicy `yaml:"retry"`
+	// FailoverOn lists the error classes that move on to the next fallback
+	FailoverOn []llm.ErrorClass `yaml:"failover_on"`
+	// Fallbacks are tried in order when the model fails
+	Fallbacks []ModelSettings `yaml:"fallbacks"`
+}
+
+// Config returns the model configuration passed to the llm registry
+func (m ModelSettings) Config() llm.Config {
+	config := llm.Config{
+		Provider:          m.Provider,
+		Model:             m.Name,
+		BaseURL:           m.BaseURL,
+		APIKey:            m.APIKey,
+		ChannelBufferSize: m.ChannelBufferSize,
+		Options:           m.Options,
+		Retry:             m.Retry,
+		FailoverOn:        m.FailoverOn,
+	}
+
+	for _, fallback := range m.Fallbacks {
+		config.Fallbacks = append(config.Fallbacks, fallback.Config())
+	}
+
+	return config
 }
 
 type AgentSettings struct {
@@ -18,6 +50,13 @@ type AgentSettings struct {
 	StreamingEnabled  bool                   `yaml:"streaming_enabled"`
 	Active            bool                   `yaml:"active"`
 	Temperature       float64                `yaml:"temperature"`
+	TopP              *float64               `yaml:"top_p"`
+	FrequencyPenalty  *float64               `yaml:"frequency_penalty"`
+	PresencePenalty   *float64               `yaml:"presence_penalty"`
+	Stop              []string               `yaml:"stop"`
+	ReasoningEffort   *string                `yaml:"reasoning_effort"`
+	ThinkingEnabled   *bool                  `yaml:"thinking_enabled"`
+	ThinkingTokens    *int                   `yaml:"thinking_tokens"`
 	ChannelBufferSize int                    `yaml:"channel_buffer_size"`
 	MaxTokens         int                    `yaml:"max_tokens"`
 	MaxIterations     int                    `yaml:"max_iterations"`
@@ -29,6 +68,32 @@ type AgentSettings struct {
 	OutputKey         string                 `yaml:"output_key"`
 	InputSchema       map[string]interface{} `yaml:"input_schema"`
 	OutputSchema      map[string]interface{} `yaml:"output_schema"`
+	// Prompt selects the version of the prompt used by an invocation
+	Prompt provider.PromptSelection `yaml:"prompt"`
+	// PromptContext configures the context contributors the prompt is rendered with
+	PromptContext provider.PromptContextSettings `yaml:"prompt_context"`
+	// Workspace configures the directory the agent and its tools work in
+	Workspace provider.WorkspaceSettings `yaml:"workspace"`
+	// Graph declares the workflow of a graph agent
+	Graph provider.GraphSettings `yaml:"graph"`
+	// Router declares how a router agent delegates to its sub-agents
+	Router provider.RouterSettings `yaml:"router"`
+}
+
+// GenerationConfig returns the generation parameters passed to the model
+func (a AgentSettings) GenerationConfig() model.GenerationConfig {
+	return model.GenerationConfig{
+		MaxTokens:        utils.IntPtr(a.MaxTokens),
+		Temperature:      utils.FloatPtr(a.Temperature),
+		TopP:             a.TopP,
+		FrequencyPenalty: a.FrequencyPenalty,
+		PresencePenalty:  a.PresencePenalty,
+		Stop:             a.Stop,
+		ReasoningEffort:  a.ReasoningEffort,
+		ThinkingEnabled:  a.ThinkingEnabled,
+		ThinkingTokens:   a.ThinkingTokens,
+		Stream:           a.StreamingEnabled,
+	}
 }
 
 type Settings struct {
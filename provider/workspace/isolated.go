@@ -0,0 +1,404 @@
+package workspace
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/denkhaus/agents/provider"
+	"github.com/google/uuid"
+)
+
+// gitIdentity commits the snapshots of isolated workspaces independent of the git configuration
+var gitIdentity = []string{
+	"GIT_AUTHOR_NAME=agents",
+	"GIT_AUTHOR_EMAIL=agents@localhost",
+	"GIT_COMMITTER_NAME=agents",
+	"GIT_COMMITTER_EMAIL=agents@localhost",
+}
+
+// isolatedWorkspaceImpl implements provider.IsolatedWorkspace on top of a git
+// checkout. The changes are the difference between the checkout and baseRef,
+// the commit of the last merge.
+type isolatedWorkspaceImpl struct {
+	*workspaceImpl
+	basePath string
+	// checkout is the directory of the base workspace within the git checkout holding the changes
+	checkout string
+	// applyDir and applyArgs apply the patches of the checkout to the base workspace
+	applyDir  string
+	applyArgs []string
+	remove    func() error
+
+	gitMu   sync.Mutex
+	baseRef string
+}
+
+// NewIsolatedWorkspace creates an isolated copy of the workspace in basePath in
+// dir, which is replaced if it exists. Callers that isolate single invocations
+// pass a dir per invocation and close the workspace once it is merged or discarded.
+func NewIsolatedWorkspace(
+	ctx context.Context,
+	agentID uuid.UUID,
+	basePath string,
+	dir string,
+	settings provider.WorkspaceSettings,
+) (provider.IsolatedWorkspace, error) {
+	basePath, err := filepath.Abs(basePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve workspace path %s: %w", basePath, err)
+	}
+	if dir, err = filepath.Abs(dir); err != nil {
+		return nil, fmt.Errorf("failed to resolve isolation directory %s: %w", dir, err)
+	}
+
+	if err := os.MkdirAll(basePath, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create workspace %s: %w", basePath, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(dir), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create isolation directory %s: %w", filepath.Dir(dir), err)
+	}
+
+	var w *isolatedWorkspaceImpl
+	switch settings.Isolation {
+	case provider.WorkspaceIsolationWorktree:
+		w, err = newWorktreeWorkspace(ctx, basePath, dir)
+	case provider.WorkspaceIsolationCopy:
+		w, err = newCopyWorkspace(ctx, basePath, dir)
+	default:
+		return nil, fmt.Errorf("workspace isolation %q does not isolate", settings.Isolation)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to isolate workspace %s for agent %s: %w", basePath, agentID, err)
+	}
+
+	w.baseRef, err = runGit(ctx, w.checkout, nil, "rev-parse", "HEAD")
+	if err != nil {
+		w.remove()
+		return nil, err
+	}
+
+	workspace, err := NewWorkspace(agentID, w.checkout, settings)
+	if err != nil {
+		w.remove()
+		return nil, err
+	}
+	w.workspaceImpl = workspace.(*workspaceImpl)
+
+	return w, nil
+}
+
+// newWorktreeWorkspace adds a detached worktree of the repository containing
+// basePath at its HEAD plus the uncommitted changes of basePath, the workspace
+// is the directory of basePath within it
+func newWorktreeWorkspace(ctx context.Context, basePath, dir string) (*isolatedWorkspaceImpl, error) {
+	topLevel, err := runGit(ctx, basePath, nil, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, fmt.Errorf("worktree isolation requires a git repository: %w", err)
+	}
+	prefix, err := runGit(ctx, basePath, nil, "rev-parse", "--show-prefix")
+	if err != nil {
+		return nil, err
+	}
+
+	// A worktree left behind by a previous run is replaced
+	if _, err := os.Stat(dir); err == nil {
+		_, _ = runGit(ctx, topLevel, nil, "worktree", "remove", "--force", dir)
+		if err := os.RemoveAll(dir); err != nil {
+			return nil, fmt.Errorf("failed to remove stale worktree %s: %w", dir, err)
+		}
+	}
+	if _, err := runGit(ctx, topLevel, nil, "worktree", "prune"); err != nil {
+		return nil, err
+	}
+	if _, err := runGit(ctx, topLevel, nil, "worktree", "add", "--detach", dir, "HEAD"); err != nil {
+		return nil, err
+	}
+
+	w := &isolatedWorkspaceImpl{
+		basePath: basePath,
+		checkout: filepath.Join(dir, prefix),
+		applyDir: topLevel,
+		remove: func() error {
+			if _, err := runGit(context.Background(), topLevel, nil, "worktree", "remove", "--force", dir); err != nil {
+				return err
+			}
+			return nil
+		},
+	}
+
+	if err := copyDirtyState(ctx, basePath, dir, w.checkout); err != nil {
+		w.remove()
+		return nil, fmt.Errorf("failed to copy uncommitted changes: %w", err)
+	}
+
+	return w, nil
+}
+
+// copyDirtyState carries the uncommitted changes and untracked files of
+// basePath into the worktree and commits them there, so the changes of the
+// agent start from the state of basePath rather than from its HEAD
+func copyDirtyState(ctx context.Context, basePath, worktree, checkout string) error {
+	diff, err := runGitRaw(ctx, basePath, nil, "diff", "--binary", "HEAD", "--", ".")
+	if err != nil {
+		return err
+	}
+	if diff != "" {
+		if _, err := runGit(ctx, worktree, []byte(diff), "apply", "--binary", "-"); err != nil {
+			return err
+		}
+	}
+
+	untracked, err := runGitRaw(ctx, basePath, nil,
+		"ls-files", "-z", "--others", "--exclude-standard", "--", ".", ":(exclude)"+ScratchDir)
+	if err != nil {
+		return err
+	}
+	for _, rel := range strings.Split(untracked, "\x00") {
+		if rel == "" {
+			continue
+		}
+		if err := copyEntry(filepath.Join(basePath, rel), filepath.Join(checkout, rel)); err != nil {
+			return err
+		}
+	}
+
+	if diff == "" && untracked == "" {
+		return nil
+	}
+
+	for _, args := range [][]string{
+		{"add", "-A"},
+		{"commit", "-q", "--no-gpg-sign", "-m", "uncommitted changes of the base workspace"},
+	} {
+		if _, err := runGit(ctx, worktree, nil, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newCopyWorkspace copies basePath to dir and snapshots the copy in a git repository of its own
+func newCopyWorkspace(ctx context.Context, basePath, dir string) (*isolatedWorkspaceImpl, error) {
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, fmt.Errorf("failed to remove stale copy %s: %w", dir, err)
+	}
+	if err := copyTree(basePath, dir); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("failed to copy workspace: %w", err)
+	}
+
+	w := &isolatedWorkspaceImpl{
+		basePath: basePath,
+		checkout: dir,
+		applyDir: basePath,
+		remove: func() error {
+			return os.RemoveAll(dir)
+		},
+	}
+
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "-A", "--", "."},
+		{"commit", "-q", "--no-gpg-sign", "--allow-empty", "-m", "workspace snapshot"},
+	} {
+		if _, err := runGit(ctx, dir, nil, args...); err != nil {
+			w.remove()
+			return nil, err
+		}
+	}
+
+	// Patches are relative to the top of a repository containing the base workspace
+	if prefix, err := runGit(ctx, basePath, nil, "rev-parse", "--show-prefix"); err == nil && prefix != "" {
+		w.applyArgs = []string{"--directory=" + strings.TrimSuffix(prefix, "/")}
+	}
+
+	return w, nil
+}
+
+func (w *isolatedWorkspaceImpl) GetBasePath() string {
+	return w.basePath
+}
+
+// pathspec selects the changes of the workspace within the checkout, without the scratch directories
+func (w *isolatedWorkspaceImpl) pathspec() []string {
+	return []string{"--", ".", ":(exclude)" + ScratchDir}
+}
+
+func (w *isolatedWorkspaceImpl) Diff(ctx context.Context) (string, error) {
+	w.gitMu.Lock()
+	defer w.gitMu.Unlock()
+
+	return w.diff(ctx)
+}
+
+func (w *isolatedWorkspaceImpl) diff(ctx context.Context) (string, error) {
+	if _, err := runGit(ctx, w.checkout, nil, append([]string{"add", "-A"}, w.pathspec()...)...); err != nil {
+		return "", err
+	}
+
+	diff, err := runGitRaw(ctx, w.checkout, nil, append([]string{"diff", "--cached", "--binary", w.baseRef}, w.pathspec()...)...)
+	if err != nil {
+		return "", err
+	}
+	return diff, nil
+}
+
+// Merge applies the changes to the base workspace and commits them in the
+// checkout, so later diffs only hold the changes made after the merge
+func (w *isolatedWorkspaceImpl) Merge(ctx context.Context) error {
+	w.gitMu.Lock()
+	defer w.gitMu.Unlock()
+
+	diff, err := w.diff(ctx)
+	if err != nil {
+		return err
+	}
+	if diff == "" {
+		return nil
+	}
+
+	args := append([]string{"apply", "--binary"}, w.applyArgs...)
+	if _, err := runGit(ctx, w.applyDir, []byte(diff), append(args, "-")...); err != nil {
+		return fmt.Errorf("failed to merge changes into %s: %w", w.basePath, err)
+	}
+
+	if _, err := runGit(ctx, w.checkout, nil, "commit", "-q", "--no-gpg-sign", "-m", "merged into base workspace"); err != nil {
+		return err
+	}
+
+	w.baseRef, err = runGit(ctx, w.checkout, nil, "rev-parse", "HEAD")
+	return err
+}
+
+// Discard resets the checkout to the last merge, scratch directories are kept
+func (w *isolatedWorkspaceImpl) Discard(ctx context.Context) error {
+	w.gitMu.Lock()
+	defer w.gitMu.Unlock()
+
+	if _, err := runGit(ctx, w.checkout, nil, "reset", "-q", "--hard", w.baseRef); err != nil {
+		return err
+	}
+	if _, err := runGit(ctx, w.checkout, nil, "clean", "-fdq", "-e", ScratchDir); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (w *isolatedWorkspaceImpl) Close() error {
+	if err := w.workspaceImpl.Close(); err != nil {
+		return err
+	}
+
+	w.gitMu.Lock()
+	defer w.gitMu.Unlock()
+
+	if err := w.remove(); err != nil {
+		return fmt.Errorf("failed to remove isolated workspace %s: %w", w.checkout, err)
+	}
+	return nil
+}
+
+// runGit runs git in dir and returns its trimmed output
+func runGit(ctx context.Context, dir string, stdin []byte, args ...string) (string, error) {
+	out, err := runGitRaw(ctx, dir, stdin, args...)
+	return strings.TrimSpace(out), err
+}
+
+func runGitRaw(ctx context.Context, dir string, stdin []byte, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), gitIdentity...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s failed: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// copyTree copies the directory src to dst, without the git metadata and the scratch directories of src
+func copyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if rel == ".git" || rel == ScratchDir {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if entry.IsDir() {
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			return os.MkdirAll(filepath.Join(dst, rel), info.Mode().Perm())
+		}
+		return copyEntry(path, filepath.Join(dst, rel))
+	})
+}
+
+// copyEntry copies the file or symlink src to dst, creating the parent directories of dst
+func copyEntry(src, dst string) error {
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+
+	switch {
+	case info.Mode()&fs.ModeSymlink != 0:
+		link, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(link, dst)
+	case info.Mode().IsRegular():
+		return copyFile(src, dst, info.Mode().Perm())
+	default:
+		// Sockets, devices and pipes are not part of a workspace
+		return nil
+	}
+}
+
+func copyFile(src, dst string, perm fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,140 @@
+package workspace
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/denkhaus/agents/provider"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestRepository creates a git repository with a committed file in the subdirectory project
+func newTestRepository(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	repo := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(repo, "project"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(repo, "project", "main.go"), []byte("package main\n"), 0o644))
+
+	ctx := context.Background()
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "-A"},
+		{"commit", "-q", "--no-gpg-sign", "-m", "initial"},
+	} {
+		_, err := runGit(ctx, repo, nil, args...)
+		require.NoError(t, err)
+	}
+	return repo
+}
+
+func TestIsolatedWorkspace(t *testing.T) {
+	for _, isolation := range []provider.WorkspaceIsolation{provider.WorkspaceIsolationWorktree, provider.WorkspaceIsolationCopy} {
+		t.Run(string(isolation), func(t *testing.T) {
+			ctx := context.Background()
+			base := filepath.Join(newTestRepository(t), "project")
+
+			workspace, err := NewIsolatedWorkspace(ctx, uuid.New(), base, filepath.Join(t.TempDir(), "isolated"),
+				provider.WorkspaceSettings{Isolation: isolation, Scratch: true},
+			)
+			require.NoError(t, err)
+			defer workspace.Close()
+
+			path, err := workspace.GetPath()
+			require.NoError(t, err)
+			assert.NotEqual(t, base, path)
+			assert.Equal(t, base, workspace.GetBasePath())
+			assert.FileExists(t, filepath.Join(path, "main.go"))
+
+			// Changes stay in the isolated workspace, scratch directories are no changes
+			require.NoError(t, os.WriteFile(filepath.Join(path, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0o644))
+			require.NoError(t, os.WriteFile(filepath.Join(path, "util.go"), []byte("package main\n"), 0o644))
+			scratch, err := workspace.GetScratchDir("session-1")
+			require.NoError(t, err)
+			require.NoError(t, os.WriteFile(filepath.Join(scratch, "notes.txt"), []byte("notes"), 0o644))
+
+			diff, err := workspace.Diff(ctx)
+			require.NoError(t, err)
+			assert.Contains(t, diff, "+func main() {}")
+			assert.Contains(t, diff, "util.go\nnew file mode")
+			assert.NotContains(t, diff, "notes.txt")
+			assert.NoFileExists(t, filepath.Join(base, "util.go"))
+
+			require.NoError(t, workspace.Merge(ctx))
+			assert.FileExists(t, filepath.Join(base, "util.go"))
+			content, err := os.ReadFile(filepath.Join(base, "main.go"))
+			require.NoError(t, err)
+			assert.Equal(t, "package main\n\nfunc main() {}\n", string(content))
+
+			diff, err = workspace.Diff(ctx)
+			require.NoError(t, err)
+			assert.Empty(t, diff)
+
+			// Discarding drops the changes made after the merge
+			require.NoError(t, os.WriteFile(filepath.Join(path, "util.go"), []byte("broken"), 0o644))
+			require.NoError(t, os.WriteFile(filepath.Join(path, "tmp.go"), []byte("package main\n"), 0o644))
+			require.NoError(t, workspace.Discard(ctx))
+
+			content, err = os.ReadFile(filepath.Join(path, "util.go"))
+			require.NoError(t, err)
+			assert.Equal(t, "package main\n", string(content))
+			assert.NoFileExists(t, filepath.Join(path, "tmp.go"))
+			assert.FileExists(t, filepath.Join(scratch, "notes.txt"))
+
+			require.NoError(t, workspace.Close())
+			assert.NoDirExists(t, path)
+		})
+	}
+}
+
+func TestIsolatedWorkspace_WorktreeRequiresRepository(t *testing.T) {
+	_, err := NewIsolatedWorkspace(context.Background(), uuid.New(), t.TempDir(), filepath.Join(t.TempDir(), "isolated"),
+		provider.WorkspaceSettings{Isolation: provider.WorkspaceIsolationWorktree},
+	)
+	assert.ErrorContains(t, err, "worktree isolation requires a git repository")
+}
+
+func TestIsolatedWorkspace_UncommittedChanges(t *testing.T) {
+	for _, isolation := range []provider.WorkspaceIsolation{provider.WorkspaceIsolationWorktree, provider.WorkspaceIsolationCopy} {
+		t.Run(string(isolation), func(t *testing.T) {
+			ctx := context.Background()
+			base := filepath.Join(newTestRepository(t), "project")
+			require.NoError(t, os.WriteFile(filepath.Join(base, "main.go"), []byte("package main\n\n// dirty\n"), 0o644))
+			require.NoError(t, os.MkdirAll(filepath.Join(base, "cmd"), 0o755))
+			require.NoError(t, os.WriteFile(filepath.Join(base, "cmd", "new.go"), []byte("package cmd\n"), 0o644))
+
+			workspace, err := NewIsolatedWorkspace(ctx, uuid.New(), base, filepath.Join(t.TempDir(), "isolated"),
+				provider.WorkspaceSettings{Isolation: isolation},
+			)
+			require.NoError(t, err)
+			defer workspace.Close()
+
+			// The isolated workspace starts from the uncommitted state of the base
+			path, err := workspace.GetPath()
+			require.NoError(t, err)
+			content, err := os.ReadFile(filepath.Join(path, "main.go"))
+			require.NoError(t, err)
+			assert.Equal(t, "package main\n\n// dirty\n", string(content))
+			assert.FileExists(t, filepath.Join(path, "cmd", "new.go"))
+
+			// and the uncommitted changes are no changes of the agent
+			diff, err := workspace.Diff(ctx)
+			require.NoError(t, err)
+			assert.Empty(t, diff)
+
+			require.NoError(t, os.WriteFile(filepath.Join(path, "cmd", "new.go"), []byte("package cmd\n\nvar x int\n"), 0o644))
+			require.NoError(t, workspace.Merge(ctx))
+			content, err = os.ReadFile(filepath.Join(base, "cmd", "new.go"))
+			require.NoError(t, err)
+			assert.Equal(t, "package cmd\n\nvar x int\n", string(content))
+		})
+	}
+}
@@ -1,7 +1,12 @@
 package workspace
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
 
 	"github.com/denkhaus/agents/provider"
 	"github.com/denkhaus/agents/shared/resource"
@@ -9,21 +14,126 @@ import (
 	"github.com/samber/do"
 )
 
+// RootEnv sets the workspace root of agents without a configured root, it defaults to the working directory
+const RootEnv = "AGENTS_WORKSPACE_ROOT"
+
 type workspaceProviderImpl struct {
-	workspaces *resource.Manager[provider.Workspace]
+	root         string
+	isolationDir string
+	workspaces   *resource.Manager[provider.Workspace]
+
+	mu       sync.RWMutex
+	settings map[uuid.UUID]provider.WorkspaceSettings
+}
+
+// Option configures a workspace provider
+type Option func(*workspaceProviderImpl)
+
+// WithRoot sets the workspace root of agents without a configured root
+func WithRoot(root string) Option {
+	return func(p *workspaceProviderImpl) {
+		p.root = root
+	}
 }
 
+// WithIsolationDir sets the directory holding the isolated copies of the
+// workspaces, a directory below the temporary directory by default
+func WithIsolationDir(dir string) Option {
+	return func(p *workspaceProviderImpl) {
+		p.isolationDir = dir
+	}
+}
+
+// New creates the WorkspaceProvider using dependency injection, the root is read from AGENTS_WORKSPACE_ROOT
 func New(i *do.Injector) (provider.WorkspaceProvider, error) {
-	return &workspaceProviderImpl{
-		workspaces: resource.NewManager[provider.Workspace](),
-	}, nil
+	return NewFromEnv()
+}
+
+// NewFromEnv creates a WorkspaceProvider with the root read from AGENTS_WORKSPACE_ROOT
+func NewFromEnv() (provider.WorkspaceProvider, error) {
+	var opts []Option
+	if root := os.Getenv(RootEnv); root != "" {
+		opts = append(opts, WithRoot(root))
+	}
+	return NewProvider(opts...)
+}
+
+// NewProvider creates a WorkspaceProvider, the root defaults to the working directory
+func NewProvider(opts ...Option) (provider.WorkspaceProvider, error) {
+	p := &workspaceProviderImpl{
+		isolationDir: filepath.Join(os.TempDir(), "agents", "workspaces"),
+		workspaces:   resource.NewManager[provider.Workspace](),
+		settings:     make(map[uuid.UUID]provider.WorkspaceSettings),
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	if p.root == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve working directory: %w", err)
+		}
+		p.root = wd
+	}
+
+	root, err := ExpandPath(p.root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand workspace root %s: %w", p.root, err)
+	}
+
+	p.root, err = filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve workspace root %s: %w", root, err)
+	}
+
+	return p, nil
+}
+
+func (p *workspaceProviderImpl) Configure(agentID uuid.UUID, settings provider.WorkspaceSettings) error {
+	if err := settings.Validate(); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.workspaces.Exists(agentID) && p.settings[agentID] != settings {
+		return fmt.Errorf("workspace for agent %s is already in use with different settings", agentID)
+	}
+
+	p.settings[agentID] = settings
+	return nil
 }
 
 func (p *workspaceProviderImpl) GetWorkspace(agentID uuid.UUID) (provider.Workspace, error) {
 	// Use GetOrSetWithError for thread-safe lazy initialization with proper error handling
 	workspace, err := p.workspaces.GetOrSetWithError(agentID, func() (provider.Workspace, error) {
-		// This is hardcoded for now, make this dynamic in the future
-		w, err := NewWorkspace(agentID, "/home/denkhaus/dev/gomodules/agents")
+		p.mu.RLock()
+		settings := p.settings[agentID]
+		p.mu.RUnlock()
+
+		path := p.root
+		if settings.Root != "" {
+			expanded, err := ExpandPath(settings.Root)
+			if err != nil {
+				return nil, fmt.Errorf("failed to expand workspace root %s: %w", settings.Root, err)
+			}
+			// Relative roots are resolved against the root of the provider
+			path = expanded
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(p.root, path)
+			}
+		}
+
+		// An isolated agent works in its own copy of the root
+		if settings.Isolation != provider.WorkspaceIsolationNone {
+			dir := filepath.Join(p.isolationDir, agentID.String())
+			return NewIsolatedWorkspace(context.Background(), agentID, path, dir, settings)
+		}
+
+		w, err := NewWorkspace(agentID, path, settings)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create workspace for agent %s: %w", agentID, err)
 		}
@@ -36,3 +146,24 @@ func (p *workspaceProviderImpl) GetWorkspace(agentID uuid.UUID) (provider.Worksp
 
 	return workspace, nil
 }
+
+func (p *workspaceProviderImpl) ReleaseSession(sessionID string) error {
+	var errs []error
+	for agentID, workspace := range p.workspaces.GetAll() {
+		if err := workspace.ReleaseScratchDir(sessionID); err != nil {
+			errs = append(errs, fmt.Errorf("failed to release workspace for agent %s: %w", agentID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Shutdown removes the scratch directories of all workspaces, it is called by the DI container
+func (p *workspaceProviderImpl) Shutdown() error {
+	var errs []error
+	for agentID, workspace := range p.workspaces.GetAll() {
+		if err := workspace.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close workspace for agent %s: %w", agentID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,129 @@
+package workspace
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/denkhaus/agents/provider"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvider_GetWorkspace(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("AGENT_HOME", root)
+
+	workspaceProvider, err := NewProvider(WithRoot("$AGENT_HOME/default"))
+	require.NoError(t, err)
+
+	defaultAgent, configuredAgent, relativeAgent := uuid.New(), uuid.New(), uuid.New()
+	require.NoError(t, workspaceProvider.Configure(configuredAgent, provider.WorkspaceSettings{Root: "${AGENT_HOME}/coder"}))
+	require.NoError(t, workspaceProvider.Configure(relativeAgent, provider.WorkspaceSettings{Root: "research"}))
+
+	for agentID, expected := range map[uuid.UUID]string{
+		defaultAgent:    filepath.Join(root, "default"),
+		configuredAgent: filepath.Join(root, "coder"),
+		relativeAgent:   filepath.Join(root, "default", "research"),
+	} {
+		workspace, err := workspaceProvider.GetWorkspace(agentID)
+		require.NoError(t, err)
+
+		path, err := workspace.GetPath()
+		require.NoError(t, err)
+		assert.Equal(t, expected, path)
+		assert.DirExists(t, path)
+	}
+
+	// The settings of a workspace in use cannot change
+	err = workspaceProvider.Configure(configuredAgent, provider.WorkspaceSettings{Root: "elsewhere"})
+	assert.ErrorContains(t, err, "already in use with different settings")
+	assert.NoError(t, workspaceProvider.Configure(configuredAgent, provider.WorkspaceSettings{Root: "${AGENT_HOME}/coder"}))
+}
+
+func TestProvider_ReleaseSession(t *testing.T) {
+	workspaceProvider, err := NewProvider(WithRoot(t.TempDir()))
+	require.NoError(t, err)
+
+	coder, researcher := uuid.New(), uuid.New()
+	var ended, running []string
+	for _, agentID := range []uuid.UUID{coder, researcher} {
+		require.NoError(t, workspaceProvider.Configure(agentID, provider.WorkspaceSettings{Scratch: true}))
+		workspace, err := workspaceProvider.GetWorkspace(agentID)
+		require.NoError(t, err)
+
+		dir, err := workspace.GetScratchDir("ended")
+		require.NoError(t, err)
+		ended = append(ended, dir)
+
+		dir, err = workspace.GetScratchDir("running")
+		require.NoError(t, err)
+		running = append(running, dir)
+	}
+
+	require.NoError(t, workspaceProvider.ReleaseSession("ended"))
+	for _, dir := range ended {
+		assert.NoDirExists(t, dir)
+	}
+	for _, dir := range running {
+		assert.DirExists(t, dir)
+	}
+
+	// Releasing a session twice or without scratch directories is a no-op
+	assert.NoError(t, workspaceProvider.ReleaseSession("ended"))
+}
+
+func TestWorkspace_ScratchDir(t *testing.T) {
+	agentID := uuid.New()
+	workspace, err := NewWorkspace(agentID, t.TempDir(), provider.WorkspaceSettings{Scratch: true})
+	require.NoError(t, err)
+
+	first, err := workspace.GetScratchDir("session-1")
+	require.NoError(t, err)
+	assert.DirExists(t, first)
+	assert.Equal(t, filepath.Join(ScratchDir, agentID.String(), "session-1"), relativePath(t, workspace, first))
+
+	second, err := workspace.GetScratchDir("session-2")
+	require.NoError(t, err)
+
+	require.NoError(t, workspace.ReleaseScratchDir("session-1"))
+	assert.NoDirExists(t, first)
+	assert.DirExists(t, second)
+
+	require.NoError(t, workspace.Close())
+	assert.NoDirExists(t, second)
+
+	_, err = workspace.GetScratchDir("../escape")
+	assert.ErrorContains(t, err, "invalid session id")
+
+	// Scratch directories are disabled by default
+	disabled, err := NewWorkspace(agentID, t.TempDir(), provider.WorkspaceSettings{})
+	require.NoError(t, err)
+	dir, err := disabled.GetScratchDir("session-1")
+	require.NoError(t, err)
+	assert.Empty(t, dir)
+}
+
+func TestExpandPath(t *testing.T) {
+	home, err := os.UserHomeDir()
+	require.NoError(t, err)
+
+	expanded, err := ExpandPath("~/agents")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(home, "agents"), expanded)
+
+	expanded, err = ExpandPath("~other/agents")
+	require.NoError(t, err)
+	assert.Equal(t, "~other/agents", expanded)
+}
+
+func relativePath(t *testing.T, workspace provider.Workspace, path string) string {
+	t.Helper()
+
+	root, err := workspace.GetPath()
+	require.NoError(t, err)
+	rel, err := filepath.Rel(root, path)
+	require.NoError(t, err)
+	return rel
+}
@@ -1,22 +1,121 @@
 package workspace
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
 	"github.com/denkhaus/agents/provider"
 	"github.com/google/uuid"
 )
 
+// ScratchDir is the directory below a workspace holding the session scratch directories
+const ScratchDir = ".scratch"
+
+// validSessionID restricts session IDs to names that cannot escape the scratch directory
+var validSessionID = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
 type workspaceImpl struct {
-	agentID uuid.UUID
-	path    string
+	agentID  uuid.UUID
+	path     string
+	settings provider.WorkspaceSettings
+
+	mu      sync.Mutex
+	scratch map[string]string
 }
 
-func NewWorkspace(agentID uuid.UUID, path string) (provider.Workspace, error) {
+// NewWorkspace creates the workspace of an agent in path, which is created if it does not exist
+func NewWorkspace(agentID uuid.UUID, path string, settings provider.WorkspaceSettings) (provider.Workspace, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve workspace path %s: %w", path, err)
+	}
+
+	if err := os.MkdirAll(absPath, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create workspace %s: %w", absPath, err)
+	}
+
 	return &workspaceImpl{
-		agentID: agentID,
-		path:    path,
+		agentID:  agentID,
+		path:     absPath,
+		settings: settings,
+		scratch:  make(map[string]string),
 	}, nil
 }
 
 func (p *workspaceImpl) GetPath() (string, error) {
 	return p.path, nil
 }
+
+// GetScratchDir returns <workspace>/.scratch/<agent id>/<session id>
+func (p *workspaceImpl) GetScratchDir(sessionID string) (string, error) {
+	if !p.settings.Scratch {
+		return "", nil
+	}
+	if !validSessionID.MatchString(sessionID) || strings.Trim(sessionID, ".") == "" {
+		return "", fmt.Errorf("invalid session id %q for a scratch directory", sessionID)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if dir, ok := p.scratch[sessionID]; ok {
+		return dir, nil
+	}
+
+	dir := filepath.Join(p.path, ScratchDir, p.agentID.String(), sessionID)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create scratch directory for session %s: %w", sessionID, err)
+	}
+
+	p.scratch[sessionID] = dir
+	return dir, nil
+}
+
+func (p *workspaceImpl) ReleaseScratchDir(sessionID string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	dir, ok := p.scratch[sessionID]
+	if !ok {
+		return nil
+	}
+
+	delete(p.scratch, sessionID)
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to remove scratch directory for session %s: %w", sessionID, err)
+	}
+	return nil
+}
+
+func (p *workspaceImpl) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var errs []error
+	for sessionID, dir := range p.scratch {
+		if err := os.RemoveAll(dir); err != nil {
+			errs = append(errs, fmt.Errorf("failed to remove scratch directory for session %s: %w", sessionID, err))
+		}
+		delete(p.scratch, sessionID)
+	}
+	return errors.Join(errs...)
+}
+
+// ExpandPath expands a leading ~ to the home directory and environment variables in path
+func ExpandPath(path string) (string, error) {
+	path = os.ExpandEnv(path)
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve home directory: %w", err)
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~")), nil
+}
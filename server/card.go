@@ -0,0 +1,60 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/denkhaus/agents/shared"
+	"trpc.group/trpc-go/trpc-a2a-go/server"
+)
+
+// NewAgentCard creates the agent card of an agent served at url from its descriptor.
+// Every skill of the agent becomes a card skill tagged with the role and type of
+// the agent, an agent without skills gets a single skill named after its role.
+func NewAgentCard(descriptor shared.AgentDescriptor, url string) server.AgentCard {
+	var tags []string
+	if descriptor.Role != "" {
+		tags = append(tags, descriptor.Role.String())
+	}
+	if descriptor.Type != "" {
+		tags = append(tags, descriptor.Type.String())
+	}
+
+	skills := make([]server.AgentSkill, 0, len(descriptor.Capabilities.Skills))
+	for _, skill := range descriptor.Capabilities.Skills {
+		skills = append(skills, server.AgentSkill{
+			ID:          skill,
+			Name:        skill,
+			Description: stringPtr(fmt.Sprintf("%s of %s", skill, descriptor.Name)),
+			InputModes:  []string{"text"},
+			OutputModes: []string{"text"},
+			Tags:        tags,
+		})
+	}
+
+	if len(skills) == 0 {
+		name := descriptor.Role.String()
+		if name == "" {
+			name = "default"
+		}
+		skills = append(skills, server.AgentSkill{
+			ID:          name,
+			Name:        name,
+			Description: stringPtr(descriptor.Description),
+			InputModes:  []string{"text"},
+			OutputModes: []string{"text"},
+			Tags:        tags,
+		})
+	}
+
+	return server.AgentCard{
+		Name:        descriptor.Name,
+		Description: descriptor.Description,
+		URL:         url,
+		Skills:      skills,
+		Capabilities: server.AgentCapabilities{
+			Streaming: boolPtr(descriptor.Capabilities.Streaming),
+		},
+		DefaultInputModes:  []string{"text"},
+		DefaultOutputModes: []string{"text"},
+	}
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/denkhaus/agents/shared"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"trpc.group/trpc-go/trpc-a2a-go/protocol"
+	"trpc.group/trpc-go/trpc-a2a-go/server"
+	"trpc.group/trpc-go/trpc-a2a-go/taskmanager"
+)
+
+// nopProcessor accepts every message without answering
+type nopProcessor struct{}
+
+func (nopProcessor) ProcessMessage(
+	ctx context.Context,
+	message protocol.Message,
+	options taskmanager.ProcessOptions,
+	handle taskmanager.TaskHandler,
+) (*taskmanager.MessageProcessingResult, error) {
+	return &taskmanager.MessageProcessingResult{}, nil
+}
+
+func newTestDescriptor() shared.AgentDescriptor {
+	return shared.AgentDescriptor{
+		ID:           uuid.New(),
+		Name:         "coder",
+		Description:  "Writes code",
+		Role:         shared.AgentRoleCoder,
+		Type:         shared.AgentTypeDefault,
+		OutputSchema: map[string]interface{}{"type": "object"},
+		Capabilities: shared.AgentCapabilities{
+			Streaming: true,
+			Skills:    []string{"execute_command", "read_file"},
+		},
+	}
+}
+
+func TestNewAgentCard(t *testing.T) {
+	descriptor := newTestDescriptor()
+	original := descriptor.Clone()
+
+	card := NewAgentCard(descriptor, "http://localhost:8080/api/v1/agent/coder/")
+	assert.Equal(t, "coder", card.Name)
+	assert.Equal(t, "Writes code", card.Description)
+	assert.Equal(t, "http://localhost:8080/api/v1/agent/coder/", card.URL)
+	require.NotNil(t, card.Capabilities.Streaming)
+	assert.True(t, *card.Capabilities.Streaming)
+
+	require.Len(t, card.Skills, 2)
+	assert.Equal(t, "execute_command", card.Skills[0].ID)
+	assert.Equal(t, "read_file of coder", *card.Skills[1].Description)
+	assert.Equal(t, []string{"coder", "default"}, card.Skills[0].Tags)
+
+	// The card does not share state with the descriptor
+	card.Skills[0].Tags[0] = "changed"
+	card.Skills[0].Name = "changed"
+	assert.Equal(t, original, descriptor)
+
+	// An agent without skills gets a skill named after its role
+	descriptor.Capabilities.Skills = nil
+	card = NewAgentCard(descriptor, "")
+	require.Len(t, card.Skills, 1)
+	assert.Equal(t, "coder", card.Skills[0].Name)
+	assert.Equal(t, "Writes code", *card.Skills[0].Description)
+
+	card = NewAgentCard(shared.AgentDescriptor{Name: "anonymous"}, "")
+	assert.Equal(t, "default", card.Skills[0].Name)
+	assert.Empty(t, card.Skills[0].Tags)
+}
+
+func TestMultiAgentCardHandler(t *testing.T) {
+	manager := NewMultiAgentManager()
+	require.NoError(t, manager.RegisterDescribedAgent("coder", newTestDescriptor(), nopProcessor{}))
+
+	handler := manager.CreateCardHandler("localhost:8080")
+	serve := func(agentName string) server.AgentCard {
+		routeContext := chi.NewRouteContext()
+		routeContext.URLParams.Add("agentName", agentName)
+		request := httptest.NewRequest(http.MethodGet, "/api/v1/agent/"+agentName+"/.well-known/agent.json", nil)
+		request = request.WithContext(context.WithValue(request.Context(), chi.RouteCtxKey, routeContext))
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		require.Equal(t, http.StatusOK, recorder.Code)
+
+		var card server.AgentCard
+		require.NoError(t, json.NewDecoder(recorder.Body).Decode(&card))
+		return card
+	}
+
+	card := serve("coder")
+	assert.Equal(t, "Writes code", card.Description)
+	assert.Equal(t, "http://localhost:8080/api/v1/agent/coder/", card.URL)
+	assert.Len(t, card.Skills, 2)
+
+	// Unknown agents get a card built from their name
+	card = serve("researcher")
+	assert.Equal(t, "Dynamic agent: researcher", card.Description)
+	assert.Equal(t, "http://localhost:8080/api/v1/agent/researcher/", card.URL)
+	require.Len(t, card.Skills, 1)
+	assert.Equal(t, "default", card.Skills[0].Name)
+	assert.Equal(t, "Default skill for researcher", *card.Skills[0].Description)
+	assert.Equal(t, []string{"dynamic"}, card.Skills[0].Tags)
+	assert.Equal(t, []string{"Hello"}, card.Skills[0].Examples)
+	require.NotNil(t, card.Capabilities.Streaming)
+	assert.False(t, *card.Capabilities.Streaming)
+}
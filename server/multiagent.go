@@ -6,7 +6,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"sync"
 
+	"github.com/denkhaus/agents/shared"
 	"github.com/go-chi/chi/v5"
 	"trpc.group/trpc-go/trpc-a2a-go/protocol"
 	"trpc.group/trpc-go/trpc-a2a-go/server"
@@ -15,8 +17,13 @@ import (
 
 // MultiAgentManager manages multiple agents with dynamic routing.
 type MultiAgentManager interface {
-	// RegisterAgent registers an agent with the manager
+	// RegisterAgent registers an agent with the manager. Registering an existing
+	// name replaces the agent atomically, requests in flight complete on the
+	// previous processor.
 	RegisterAgent(name string, card server.AgentCard, processor MessageProcessor) error
+
+	// RegisterDescribedAgent registers an agent with the card NewAgentCard builds from its descriptor
+	RegisterDescribedAgent(name string, descriptor shared.AgentDescriptor, processor MessageProcessor) error
 	
 	// UnregisterAgent removes an agent from the manager
 	UnregisterAgent(name string) error
@@ -37,6 +44,7 @@ type MultiAgentManager interface {
 // multiAgentManager implements MultiAgentManager.
 // Extracted from examples/multi_endpoint/server/main.go
 type multiAgentManager struct {
+	mu     sync.RWMutex
 	agents map[string]agentInfo
 }
 
@@ -63,6 +71,9 @@ func (mam *multiAgentManager) RegisterAgent(name string, card server.AgentCard,
 		return fmt.Errorf("processor cannot be nil")
 	}
 	
+	mam.mu.Lock()
+	defer mam.mu.Unlock()
+
 	mam.agents[name] = agentInfo{
 		card:      card,
 		processor: processor,
@@ -71,8 +82,17 @@ func (mam *multiAgentManager) RegisterAgent(name string, card server.AgentCard,
 	return nil
 }
 
+// RegisterDescribedAgent registers an agent with the card built from its descriptor,
+// the card handler sets the URL the agent is served at.
+func (mam *multiAgentManager) RegisterDescribedAgent(name string, descriptor shared.AgentDescriptor, processor MessageProcessor) error {
+	return mam.RegisterAgent(name, NewAgentCard(descriptor, ""), processor)
+}
+
 // UnregisterAgent removes an agent from the manager.
 func (mam *multiAgentManager) UnregisterAgent(name string) error {
+	mam.mu.Lock()
+	defer mam.mu.Unlock()
+
 	if _, exists := mam.agents[name]; !exists {
 		return fmt.Errorf("agent %s not found", name)
 	}
@@ -83,6 +103,9 @@ func (mam *multiAgentManager) UnregisterAgent(name string) error {
 
 // GetAgent retrieves an agent by name.
 func (mam *multiAgentManager) GetAgent(name string) (server.AgentCard, MessageProcessor, error) {
+	mam.mu.RLock()
+	defer mam.mu.RUnlock()
+
 	info, exists := mam.agents[name]
 	if !exists {
 		return server.AgentCard{}, nil, fmt.Errorf("agent %s not found", name)
@@ -93,6 +116,9 @@ func (mam *multiAgentManager) GetAgent(name string) (server.AgentCard, MessagePr
 
 // ListAgents returns all registered agents.
 func (mam *multiAgentManager) ListAgents() map[string]server.AgentCard {
+	mam.mu.RLock()
+	defer mam.mu.RUnlock()
+
 	result := make(map[string]server.AgentCard)
 	for name, info := range mam.agents {
 		result[name] = info.card
@@ -148,27 +174,17 @@ func (h *multiAgentCardHandler) ServeHTTP(w http.ResponseWriter, r *http.Request
 }
 
 // createDefaultAgentCard creates a default agent card for unknown agents.
+// Its skill keeps the dynamic tag and the example clients rely on.
 func (h *multiAgentCardHandler) createDefaultAgentCard(agentName string) server.AgentCard {
-	return server.AgentCard{
+	card := NewAgentCard(shared.AgentDescriptor{
 		Name:        agentName,
 		Description: fmt.Sprintf("Dynamic agent: %s", agentName),
-		URL:         fmt.Sprintf("http://%s/api/v1/agent/%s/", h.host, agentName),
-		Skills: []server.AgentSkill{
-			{
-				Name:        "default",
-				Description: stringPtr(fmt.Sprintf("Default skill for %s", agentName)),
-				InputModes:  []string{"text"},
-				OutputModes: []string{"text"},
-				Tags:        []string{"dynamic"},
-				Examples:    []string{"Hello"},
-			},
-		},
-		Capabilities: server.AgentCapabilities{
-			Streaming: boolPtr(false),
-		},
-		DefaultInputModes:  []string{"text"},
-		DefaultOutputModes: []string{"text"},
-	}
+	}, fmt.Sprintf("http://%s/api/v1/agent/%s/", h.host, agentName))
+
+	card.Skills[0].Description = stringPtr(fmt.Sprintf("Default skill for %s", agentName))
+	card.Skills[0].Tags = []string{"dynamic"}
+	card.Skills[0].Examples = []string{"Hello"}
+	return card
 }
 
 // multiAgentProcessor routes messages based on agent name from context.
@@ -2,6 +2,9 @@ package shared
 
 import (
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
 
 	"github.com/google/uuid"
 )
@@ -21,36 +24,74 @@ func (p ModelProvider) String() string {
 	return string(p)
 }
 
-// Validate checks if the AgentRole is a valid defined role
+// Validate checks if the ModelProvider is a registered model provider
 func (p ModelProvider) Validate() error {
-	switch p {
-	case ModelProviderOpenAI:
-		return nil
-	default:
-		return fmt.Errorf("invalid agent role: %s. Valid roles are: %s",
-			p, ModelProviderOpenAI)
+	modelProvidersMu.RLock()
+	_, exists := modelProviders[p]
+	modelProvidersMu.RUnlock()
+
+	if !exists {
+		return fmt.Errorf("invalid model provider: %s. Valid providers are: %s",
+			p, strings.Join(ModelProviderNames(), ", "))
 	}
+	return nil
 }
 
 const (
-	ModelProviderOpenAI ModelProvider = "openai"
+	ModelProviderOpenAI           ModelProvider = "openai"
+	ModelProviderOpenAICompatible ModelProvider = "openai-compatible"
+	ModelProviderOllama           ModelProvider = "ollama"
 )
 
+var (
+	modelProvidersMu sync.RWMutex
+	modelProviders   = map[ModelProvider]struct{}{
+		ModelProviderOpenAI:           {},
+		ModelProviderOpenAICompatible: {},
+		ModelProviderOllama:           {},
+	}
+)
+
+// RegisterModelProvider makes a model provider name valid, the llm registry
+// calls it for every provider it registers
+func RegisterModelProvider(p ModelProvider) {
+	modelProvidersMu.Lock()
+	defer modelProvidersMu.Unlock()
+	modelProviders[p] = struct{}{}
+}
+
+// ModelProviderNames returns the names of all valid model providers, sorted
+func ModelProviderNames() []string {
+	modelProvidersMu.RLock()
+	defer modelProvidersMu.RUnlock()
+
+	names := make([]string, 0, len(modelProviders))
+	for p := range modelProviders {
+		names = append(names, p.String())
+	}
+	sort.Strings(names)
+	return names
+}
+
 type AgentType string
 
 func (p AgentType) String() string {
 	return string(p)
 }
 
-// Validate checks if the AgentRole is a valid defined role
+// Validate checks if the AgentType is a registered agent type
 func (p AgentType) Validate() error {
-	switch p {
-	case AgentTypeDefault, AgentTypeChain, AgentTypeCycle, AgentTypeParallel:
-		return nil
-	default:
-		return fmt.Errorf("invalid agent role: %s. Valid roles are: %s, %s, %s, %s",
-			p, AgentTypeDefault, AgentTypeChain, AgentTypeCycle, AgentTypeParallel)
+	if _, exists := agentTypes.description(p); !exists {
+		return fmt.Errorf("invalid agent type: %s. Valid types are: %s",
+			p, strings.Join(AgentTypeNames(), ", "))
 	}
+	return nil
+}
+
+// Description returns the description the agent type was registered with
+func (p AgentType) Description() string {
+	description, _ := agentTypes.description(p)
+	return description
 }
 
 const (
@@ -58,28 +99,49 @@ const (
 	AgentTypeChain    AgentType = "chain"
 	AgentTypeCycle    AgentType = "cycle"
 	AgentTypeParallel AgentType = "parallel"
+	AgentTypeGraph    AgentType = "graph"
+	AgentTypeRouter   AgentType = "router"
 )
 
+var agentTypes = newDescriptionRegistry[AgentType]("agent type", map[AgentType]string{
+	AgentTypeDefault:  "LLM agent with a prompt and tools",
+	AgentTypeChain:    "Runs its sub-agents in sequential order",
+	AgentTypeCycle:    "Runs its sub-agents in a cycle until the maximum number of iterations",
+	AgentTypeParallel: "Runs its sub-agents in parallel",
+	AgentTypeGraph:    "Runs a workflow of agents and tools connected by conditional edges",
+	AgentTypeRouter:   "Classifies a message and delegates it to one of its sub-agents",
+})
+
+// RegisterAgentType makes an agent type valid. The agent provider registers
+// the types it has a constructor for, see agent.RegisterType.
+func RegisterAgentType(agentType AgentType, description string) error {
+	return agentTypes.register(agentType, description)
+}
+
+// AgentTypeNames returns the names of all registered agent types, sorted
+func AgentTypeNames() []string {
+	return agentTypes.names()
+}
+
 type AgentRole string
 
 func (p AgentRole) String() string {
 	return string(p)
 }
 
-// Validate checks if the AgentRole is a valid defined role
+// Validate checks if the AgentRole is a registered role
 func (p AgentRole) Validate() error {
-	switch p {
-	case AgentRoleSupervisor,
-		AgentRoleCoder,
-		AgentRoleDebugger,
-		AgentRoleProjectManager,
-		AgentRoleResearcher,
-		AgentRoleHuman:
-		return nil
-	default:
-		return fmt.Errorf("invalid agent role: %s. Valid roles are: %s, %s, %s, %s, %s, %s",
-			p, AgentRoleSupervisor, AgentRoleCoder, AgentRoleDebugger, AgentRoleProjectManager, AgentRoleHuman, AgentRoleResearcher)
+	if _, exists := agentRoles.description(p); !exists {
+		return fmt.Errorf("invalid agent role: %s. Valid roles are: %s",
+			p, strings.Join(AgentRoleNames(), ", "))
 	}
+	return nil
+}
+
+// Description returns the description the role was registered with
+func (p AgentRole) Description() string {
+	description, _ := agentRoles.description(p)
+	return description
 }
 
 const (
@@ -90,3 +152,79 @@ const (
 	AgentRoleHuman          AgentRole = "human"
 	AgentRoleResearcher     AgentRole = "researcher"
 )
+
+var agentRoles = newDescriptionRegistry[AgentRole]("agent role", map[AgentRole]string{
+	AgentRoleSupervisor:     "Coordinates the other agents and delegates the work",
+	AgentRoleCoder:          "Writes and changes code",
+	AgentRoleDebugger:       "Analyzes and fixes failures",
+	AgentRoleProjectManager: "Plans the work and tracks its progress",
+	AgentRoleHuman:          "A human taking part in the chat",
+	AgentRoleResearcher:     "Gathers and summarizes information",
+})
+
+// RegisterAgentRole makes a role valid, so agents of other packages can use it
+func RegisterAgentRole(role AgentRole, description string) error {
+	return agentRoles.register(role, description)
+}
+
+// MustRegisterAgentRole registers a role and panics on error
+func MustRegisterAgentRole(role AgentRole, description string) {
+	if err := RegisterAgentRole(role, description); err != nil {
+		panic(err.Error())
+	}
+}
+
+// AgentRoleNames returns the names of all registered roles, sorted
+func AgentRoleNames() []string {
+	return agentRoles.names()
+}
+
+// descriptionRegistry holds the valid values of a string type with their descriptions
+type descriptionRegistry[T ~string] struct {
+	kind    string
+	mu      sync.RWMutex
+	entries map[T]string
+}
+
+func newDescriptionRegistry[T ~string](kind string, entries map[T]string) *descriptionRegistry[T] {
+	return &descriptionRegistry[T]{
+		kind:    kind,
+		entries: entries,
+	}
+}
+
+func (r *descriptionRegistry[T]) register(value T, description string) error {
+	if value == "" {
+		return fmt.Errorf("%s cannot be empty", r.kind)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.entries[value]; exists {
+		return fmt.Errorf("%s %s is already registered", r.kind, value)
+	}
+
+	r.entries[value] = description
+	return nil
+}
+
+func (r *descriptionRegistry[T]) description(value T) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	description, exists := r.entries[value]
+	return description, exists
+}
+
+func (r *descriptionRegistry[T]) names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.entries))
+	for value := range r.entries {
+		names = append(names, string(value))
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,73 @@
+package shared
+
+import (
+	"github.com/google/uuid"
+)
+
+// AgentCapabilities are the features of an agent clients can rely on
+type AgentCapabilities struct {
+	Streaming bool `json:"streaming"`
+	Planning  bool `json:"planning"`
+	// Skills name what the agent can be asked for, the names of its tools by default
+	Skills []string `json:"skills,omitempty"`
+}
+
+// AgentDescriptor describes an agent. It is fixed when the agent is built,
+// the accessors of TheAgent return copies.
+type AgentDescriptor struct {
+	ID           uuid.UUID              `json:"id"`
+	Name         string                 `json:"name"`
+	Description  string                 `json:"description,omitempty"`
+	Role         AgentRole              `json:"role,omitempty"`
+	Type         AgentType              `json:"type,omitempty"`
+	InputSchema  map[string]interface{} `json:"input_schema,omitempty"`
+	OutputSchema map[string]interface{} `json:"output_schema,omitempty"`
+	Capabilities AgentCapabilities      `json:"capabilities"`
+}
+
+// Info returns the agent info of the descriptor
+func (d AgentDescriptor) Info() *AgentInfo {
+	info := NewAgentInfo(d.ID, d.Role, d.Capabilities.Streaming, d.Name, d.Description)
+	info.InputSchema = cloneSchema(d.InputSchema)
+	info.OutputSchema = cloneSchema(d.OutputSchema)
+	return &info
+}
+
+// Clone returns a deep copy of the descriptor
+func (d AgentDescriptor) Clone() AgentDescriptor {
+	clone := d
+	clone.InputSchema = cloneSchema(d.InputSchema)
+	clone.OutputSchema = cloneSchema(d.OutputSchema)
+	if d.Capabilities.Skills != nil {
+		clone.Capabilities.Skills = append([]string(nil), d.Capabilities.Skills...)
+	}
+	return clone
+}
+
+func cloneSchema(schema map[string]interface{}) map[string]interface{} {
+	if schema == nil {
+		return nil
+	}
+	return cloneValue(schema).(map[string]interface{})
+}
+
+func cloneValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		clone := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			clone[key] = cloneValue(item)
+		}
+		return clone
+	case []interface{}:
+		clone := make([]interface{}, len(v))
+		for i, item := range v {
+			clone[i] = cloneValue(item)
+		}
+		return clone
+	case []string:
+		return append([]string(nil), v...)
+	default:
+		return v
+	}
+}
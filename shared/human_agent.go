@@ -34,6 +34,15 @@ func (d *humanAgentImpl) GetRole() AgentRole {
 	return AgentRoleHuman
 }
 
+func (d *humanAgentImpl) Descriptor() AgentDescriptor {
+	return AgentDescriptor{
+		ID:          d.ID(),
+		Name:        d.Name,
+		Description: d.AgentInfo.Description,
+		Role:        AgentRoleHuman,
+	}
+}
+
 func (d *humanAgentImpl) Run(ctx context.Context, invocation *agent.Invocation) (<-chan *event.Event, error) {
 	// Create an event channel for the human agent
 	eventChan := make(chan *event.Event, 10)
@@ -0,0 +1,176 @@
+package resource
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// ConfigDirEnv overrides the user configuration directory, defaults to ~/.config/agents
+const ConfigDirEnv = "AGENTS_CONFIG_DIR"
+
+// Source names used by the DI constructors
+const (
+	SourceEmbedded = "embedded"
+	SourceUser     = "user"
+)
+
+// Source is a named directory of resource files inside a file system
+type Source struct {
+	// Name identifies the source when reporting where a resource was loaded from
+	Name string
+	FS   fs.FS
+	// Root is the directory inside FS that is walked
+	Root string
+	// Dir is the location of FS on disk, if any, used for reporting
+	Dir string
+	// Optional sources are skipped if Root does not exist
+	Optional bool
+}
+
+// NewDirSource creates an optional source for a directory on disk
+func NewDirSource(name, dir, root string) Source {
+	return Source{
+		Name:     name,
+		FS:       os.DirFS(dir),
+		Root:     root,
+		Dir:      dir,
+		Optional: true,
+	}
+}
+
+// UserConfigDir returns $AGENTS_CONFIG_DIR or ~/.config/agents
+func UserConfigDir() (string, error) {
+	if dir := os.Getenv(ConfigDirEnv); dir != "" {
+		return dir, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve home directory: %w", err)
+	}
+
+	return filepath.Join(home, ".config", "agents"), nil
+}
+
+// UserSource returns the optional source for the given subdirectory of the user configuration directory
+func UserSource(root string) (Source, error) {
+	dir, err := UserConfigDir()
+	if err != nil {
+		return Source{}, err
+	}
+
+	return NewDirSource(SourceUser, dir, root), nil
+}
+
+// location returns the path of a file of the source as reported to the user
+func (s Source) location(filePath string) string {
+	if s.Dir != "" {
+		return filepath.Join(s.Dir, filepath.FromSlash(filePath))
+	}
+	return filePath
+}
+
+// Origin records the file a resource was loaded from and the files it overrides
+type Origin struct {
+	Source    string   `json:"source"`
+	Path      string   `json:"path"`
+	Overrides []Origin `json:"overrides,omitempty"`
+}
+
+func (o Origin) String() string {
+	origin := o.Source + ":" + o.Path
+	for _, overridden := range o.Overrides {
+		origin += " (overrides " + overridden.Source + ":" + overridden.Path + ")"
+	}
+	return origin
+}
+
+// LoadFunc parses a single resource file and returns the ID of the agent it belongs to
+type LoadFunc[T any] func(path string, content []byte) (uuid.UUID, T, error)
+
+// KeyedLoadFunc parses a single resource file and returns the key it is stored under
+type KeyedLoadFunc[K comparable, T any] func(path string, content []byte) (K, T, error)
+
+// LoadSources loads all files with one of the given extensions from the sources in order.
+// A resource loaded from a later source replaces the resource with the same agent ID of
+// an earlier source, two files of the same source must not share an agent ID.
+func LoadSources[T any](sources []Source, extensions []string, load LoadFunc[T]) (map[uuid.UUID]T, map[uuid.UUID]Origin, error) {
+	return LoadKeyedSources(sources, extensions, KeyedLoadFunc[uuid.UUID, T](load))
+}
+
+// LoadKeyedSources works like LoadSources for resources stored under keys other than
+// the agent ID, e.g. several versions of the prompt of one agent.
+func LoadKeyedSources[K comparable, T any](sources []Source, extensions []string, load KeyedLoadFunc[K, T]) (map[K]T, map[K]Origin, error) {
+	resources := make(map[K]T)
+	origins := make(map[K]Origin)
+
+	for _, source := range sources {
+		loaded := make(map[K]string)
+
+		err := fs.WalkDir(source.FS, source.Root, func(filePath string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || !hasExtension(filePath, extensions) {
+				return nil
+			}
+
+			location := source.location(filePath)
+
+			content, err := fs.ReadFile(source.FS, filePath)
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", location, err)
+			}
+
+			key, resource, err := load(location, content)
+			if err != nil {
+				return err
+			}
+
+			if previous, exists := loaded[key]; exists {
+				return fmt.Errorf("duplicate agent id %v in source %s: %s and %s", key, source.Name, previous, location)
+			}
+			loaded[key] = location
+
+			origin := Origin{Source: source.Name, Path: location}
+			if overridden, exists := origins[key]; exists {
+				origin.Overrides = append([]Origin{{Source: overridden.Source, Path: overridden.Path}}, overridden.Overrides...)
+			}
+
+			resources[key] = resource
+			origins[key] = origin
+			return nil
+		})
+
+		if errors.Is(err, fs.ErrNotExist) && source.Optional && !rootExists(source) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to load source %s: %w", source.Name, err)
+		}
+	}
+
+	return resources, origins, nil
+}
+
+func rootExists(source Source) bool {
+	_, err := fs.Stat(source.FS, source.Root)
+	return err == nil
+}
+
+func hasExtension(filePath string, extensions []string) bool {
+	ext := path.Ext(filePath)
+	for _, extension := range extensions {
+		if strings.EqualFold(ext, extension) {
+			return true
+		}
+	}
+	return false
+}
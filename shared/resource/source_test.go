@@ -0,0 +1,80 @@
+package resource
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testCoderID      = uuid.MustParse("550e8400-e29b-41d4-a716-446655440001")
+	testResearcherID = uuid.MustParse("550e8400-e29b-41d4-a716-446655440004")
+)
+
+// loadTestFile parses files of the form "<agent id>\n<value>"
+func loadTestFile(path string, content []byte) (uuid.UUID, string, error) {
+	id, value, _ := strings.Cut(string(content), "\n")
+	agentID, err := uuid.Parse(id)
+	return agentID, value, err
+}
+
+func TestLoadSources_OverrideByAgentID(t *testing.T) {
+	embedded := Source{
+		Name: SourceEmbedded,
+		FS: fstest.MapFS{
+			"templates/coder.yaml":      {Data: []byte(testCoderID.String() + "\nembedded coder")},
+			"templates/researcher.yaml": {Data: []byte(testResearcherID.String() + "\nembedded researcher")},
+			"templates/README.txt":      {Data: []byte("ignored")},
+		},
+		Root: "templates",
+	}
+	user := Source{
+		Name: SourceUser,
+		FS: fstest.MapFS{
+			"settings/my-coder.yml": {Data: []byte(testCoderID.String() + "\nuser coder")},
+		},
+		Root:     "settings",
+		Dir:      "/home/dev/.config/agents",
+		Optional: true,
+	}
+
+	resources, origins, err := LoadSources([]Source{embedded, user}, []string{".yaml", ".yml"}, loadTestFile)
+	require.NoError(t, err)
+
+	assert.Equal(t, "user coder", resources[testCoderID])
+	assert.Equal(t, "embedded researcher", resources[testResearcherID])
+
+	assert.Equal(t, "user:/home/dev/.config/agents/settings/my-coder.yml (overrides embedded:templates/coder.yaml)",
+		origins[testCoderID].String())
+	assert.Equal(t, "embedded:templates/researcher.yaml", origins[testResearcherID].String())
+}
+
+func TestLoadSources_MissingOptionalSource(t *testing.T) {
+	user := Source{Name: SourceUser, FS: fstest.MapFS{}, Root: "settings", Optional: true}
+
+	resources, _, err := LoadSources([]Source{user}, []string{".yaml"}, loadTestFile)
+	require.NoError(t, err)
+	assert.Empty(t, resources)
+
+	user.Optional = false
+	_, _, err = LoadSources([]Source{user}, []string{".yaml"}, loadTestFile)
+	assert.Error(t, err)
+}
+
+func TestLoadSources_DuplicateWithinSource(t *testing.T) {
+	source := Source{
+		Name: SourceUser,
+		FS: fstest.MapFS{
+			"settings/a.yaml": {Data: []byte(testCoderID.String() + "\na")},
+			"settings/b.yaml": {Data: []byte(testCoderID.String() + "\nb")},
+		},
+		Root: "settings",
+	}
+
+	_, _, err := LoadSources([]Source{source}, []string{".yaml"}, loadTestFile)
+	assert.ErrorContains(t, err, "duplicate agent id "+testCoderID.String()+" in source user")
+}
@@ -0,0 +1,32 @@
+package shared
+
+import "trpc.group/trpc-go/trpc-agent-go/model"
+
+// Object types of the partial responses announcing what a model or an agent
+// does. They carry a system delta and are reported as progress, not as answers.
+const (
+	// ObjectTypeModelFallback marks the partial response a fallback model emits when it fails over
+	ObjectTypeModelFallback = "model.fallback"
+	// ObjectTypeAgentRoute marks the partial response a router agent emits before it delegates
+	ObjectTypeAgentRoute = "agent.route"
+	// ObjectTypePromptVersion marks the partial response announcing the prompt
+	// version an invocation runs on
+	ObjectTypePromptVersion = "agent.prompt_version"
+)
+
+// IsFallbackResponse reports whether a response announces a failover. The
+// response carries the new model in Model and a description as system delta.
+func IsFallbackResponse(response *model.Response) bool {
+	return response != nil && response.Object == ObjectTypeModelFallback
+}
+
+// IsRouteResponse reports whether a response announces a route decision. The
+// response is authored by the router and carries the decision as system delta.
+func IsRouteResponse(response *model.Response) bool {
+	return response != nil && response.Object == ObjectTypeAgentRoute
+}
+
+// IsPromptVersionResponse reports whether a response announces a prompt version
+func IsPromptVersionResponse(response *model.Response) bool {
+	return response != nil && response.Object == ObjectTypePromptVersion
+}
@@ -1,10 +1,13 @@
 package shared
 
 import (
+	"errors"
 	"fmt"
+	"io"
 
 	"github.com/google/uuid"
 	"trpc.group/trpc-go/trpc-agent-go/agent"
+	"trpc.group/trpc-go/trpc-agent-go/tool"
 )
 
 const (
@@ -79,46 +82,126 @@ type TheAgent interface {
 	IsStreaming() bool
 	GetInfo() *AgentInfo
 	GetRole() AgentRole
+	// Descriptor returns a copy of the descriptor the agent was built with
+	Descriptor() AgentDescriptor
 }
 
 type theAgentImpl struct {
 	agent.Agent
-	role        AgentRole
-	id          uuid.UUID
-	isStreaming bool
+	descriptor AgentDescriptor
+	toolSets   []tool.ToolSet
+	// sharedSubAgents leaves the sub-agents open on Close, their owner closes them
+	sharedSubAgents bool
+}
+
+// AgentOption is a function that configures a TheAgent created by NewAgent.
+type AgentOption func(*theAgentImpl)
+
+// WithDescriptor describes the agent. The ID and the streaming capability are
+// taken from NewAgent, an empty name or description from the info of the agent.
+func WithDescriptor(descriptor AgentDescriptor) AgentOption {
+	return func(a *theAgentImpl) {
+		a.descriptor = descriptor.Clone()
+	}
+}
+
+// WithSharedSubAgents marks the sub-agents as owned by someone else, e.g. an
+// agent cache sharing them between agents. Close leaves them open.
+func WithSharedSubAgents() AgentOption {
+	return func(a *theAgentImpl) {
+		a.sharedSubAgents = true
+	}
+}
+
+// WithOwnedToolSets hands ownership of the given toolsets to the agent.
+// They are closed when the agent is closed.
+func WithOwnedToolSets(toolSets ...tool.ToolSet) AgentOption {
+	return func(a *theAgentImpl) {
+		a.toolSets = append(a.toolSets, toolSets...)
+	}
 }
 
 func (p *theAgentImpl) ID() uuid.UUID {
-	return p.id
+	return p.descriptor.ID
 }
 
 func (p *theAgentImpl) GetRole() AgentRole {
-	return p.role
+	return p.descriptor.Role
 }
 
 func (p *theAgentImpl) IsStreaming() bool {
-	return p.isStreaming
+	return p.descriptor.Capabilities.Streaming
 }
 
 func (p *theAgentImpl) GetInfo() *AgentInfo {
-	return &AgentInfo{
-		Info: p.Agent.Info(),
-		id:   p.id,
+	return p.descriptor.Info()
+}
+
+func (p *theAgentImpl) Descriptor() AgentDescriptor {
+	return p.descriptor.Clone()
+}
+
+// Close closes the toolsets owned by the agent and all closable sub-agents,
+// unless they are shared.
+func (p *theAgentImpl) Close() error {
+	var errs []error
+	for _, toolSet := range p.toolSets {
+		if err := toolSet.Close(); err != nil {
+			errs = append(errs, err)
+		}
 	}
+	p.toolSets = nil
+
+	if p.sharedSubAgents {
+		return errors.Join(errs...)
+	}
+
+	for _, subAgent := range p.SubAgents() {
+		if err := CloseAgent(subAgent); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
 }
 
-func NewAgent(agent agent.Agent, agentID uuid.UUID, isStreaming bool) TheAgent {
-	return &theAgentImpl{
-		Agent:       agent,
-		id:          agentID,
-		isStreaming: isStreaming,
+func NewAgent(agent agent.Agent, agentID uuid.UUID, isStreaming bool, opts ...AgentOption) TheAgent {
+	theAgent := &theAgentImpl{
+		Agent: agent,
 	}
+
+	for _, opt := range opts {
+		opt(theAgent)
+	}
+
+	theAgent.descriptor.ID = agentID
+	theAgent.descriptor.Capabilities.Streaming = isStreaming
+
+	info := agent.Info()
+	if theAgent.descriptor.Name == "" {
+		theAgent.descriptor.Name = info.Name
+	}
+	if theAgent.descriptor.Description == "" {
+		theAgent.descriptor.Description = info.Description
+	}
+	if theAgent.descriptor.Capabilities.Skills == nil {
+		for _, t := range agent.Tools() {
+			theAgent.descriptor.Capabilities.Skills = append(theAgent.descriptor.Capabilities.Skills, t.Declaration().Name)
+		}
+	}
+
+	return theAgent
 }
 
-func TheAgentToInfo(agent TheAgent) *AgentInfo {
-	return &AgentInfo{
-		Info: agent.Info(),
-		id:   agent.ID(),
-		role: agent.GetRole(),
+// CloseAgent releases the resources held by an agent if it supports closing.
+func CloseAgent(agent agent.Agent) error {
+	if closer, ok := agent.(io.Closer); ok {
+		return closer.Close()
 	}
+	return nil
+}
+
+// TheAgentToInfo returns the agent info of the descriptor of the agent
+func TheAgentToInfo(agent TheAgent) *AgentInfo {
+	return agent.Descriptor().Info()
 }
@@ -37,7 +37,9 @@ func CreateCoderAgent(ctx context.Context, injector *do.Injector) (shared.TheAge
 		return nil, err
 	}
 
-	shellToolSet, err := shelltoolset.NewToolSet()
+	shellToolSet, err := shelltoolset.NewToolSet(
+		shelltoolset.WithBaseDir(wkspcePath),
+	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create shell toolset: %w", err)
 	}
@@ -8,15 +8,22 @@ import (
 	"github.com/denkhaus/agents/multi"
 	"github.com/denkhaus/agents/multi/plugins"
 	"github.com/denkhaus/agents/multi/plugins/cli"
+	"github.com/denkhaus/agents/provider"
 	"github.com/denkhaus/agents/shared"
 	"github.com/denkhaus/agents/system/agents"
 	"github.com/google/uuid"
+	"github.com/samber/do"
 	"go.uber.org/zap"
 )
 
 func startup(ctx context.Context) error {
 
 	injector := di.NewContainer()
+	defer func() {
+		if err := injector.Shutdown(); err != nil {
+			logger.Log.Warn("failed to shut down services", zap.Error(err))
+		}
+	}()
 
 	projectManager, err := agents.CreateProjectManagerAgent(ctx, injector)
 	if err != nil {
@@ -33,11 +40,15 @@ func startup(ctx context.Context) error {
 		return err
 	}
 
+	workspaces := do.MustInvoke[provider.WorkspaceProvider](injector)
+
 	// Enhanced Bubble Tea Chat with real LLM calls and spinners
 	chat := cli.NewCLIMultiAgentChat(
+		plugins.WithWorkspaceProvider(workspaces),
 		plugins.WithProcessorOptions(
 			multi.WithSessionID(uuid.New()),
 			multi.WithApplicationName("denkhaus-multi-agent"),
+			multi.WithWorkspaceProvider(workspaces),
 			multi.WithAgents(
 				shared.NewHumanAgent(shared.AgentInfoHuman),
 				researcher,
@@ -1,8 +1,12 @@
 package utils
 
 import (
+	"context"
+
+	"github.com/denkhaus/agents/logger"
 	"github.com/denkhaus/agents/shared"
 	"github.com/google/uuid"
+	"go.uber.org/zap"
 	"trpc.group/trpc-go/trpc-agent-go/tool"
 )
 
@@ -55,4 +59,33 @@ func GetAgentInfoForAgent(agentID uuid.UUID, availableAgents ...*shared.AgentInf
 	}
 
 	return info
-}
\ No newline at end of file
+}
+
+// UniqueTools combines tools and the tools provided by toolsets into a single slice.
+// Tools are deduplicated by their declared name, the first registration wins.
+func UniqueTools(ctx context.Context, tools []tool.Tool, toolSets []tool.ToolSet) []tool.Tool {
+	seen := make(map[string]struct{})
+	uniqueTools := make([]tool.Tool, 0, len(tools))
+
+	add := func(t tool.Tool) {
+		name := t.Declaration().Name
+		if _, exists := seen[name]; exists {
+			logger.Log.Warn("UniqueTools: tool already registered, skipping", zap.String("tool_name", name))
+			return
+		}
+		seen[name] = struct{}{}
+		uniqueTools = append(uniqueTools, t)
+	}
+
+	for _, t := range tools {
+		add(t)
+	}
+
+	for _, toolSet := range toolSets {
+		for _, t := range toolSet.Tools(ctx) {
+			add(t)
+		}
+	}
+
+	return uniqueTools
+}